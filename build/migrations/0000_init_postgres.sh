@@ -7,10 +7,4 @@
 cat << EOM | psql -U "${POSTGRES_USER}" -d postgres -f -
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 CREATE EXTENSION IF NOT EXISTS pg_cron;
-SELECT cron.schedule_in_database(
-    'blob-cache-clean-expired', 
-    '*/15 * * * *', 
-    'CALL blob_cache_clean_expired()', 
-    '${POSTGRES_USER}'
-);
-EOM
\ No newline at end of file
+EOM
@@ -0,0 +1,18 @@
+-- A work groups the editions (books) of what readers think of as the
+-- same book, so reviews of the paperback and the ebook end up in one
+-- place.
+CREATE TABLE works (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    title TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+--------------
+-- Triggers --
+--------------
+
+CREATE TRIGGER t_works_set_updated_at
+BEFORE UPDATE ON works
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -19,8 +19,12 @@ CREATE TABLE author_identifiers (
 -- Indexes --
 -------------
 
-CREATE INDEX i_authors_full_name ON authors (TRIM((given_name || ' ' || family_name)));
+CREATE INDEX i_authors_full_name ON authors (TRIM(COALESCE(given_name, '') || ' ' || family_name));
+CREATE INDEX i_author_identifiers_author ON author_identifiers (author_id);
 CREATE INDEX i_authors_family_name ON authors (family_name);
+-- For suggestions as a name is typed
+CREATE INDEX i_authors_full_name_trgm ON authors
+USING GIN ((TRIM(COALESCE(given_name, '') || ' ' || family_name)) gin_trgm_ops);
 
 CREATE INDEX i_authors_search ON authors
 USING bm25 (id, family_name, given_name, bio)
@@ -4,9 +4,43 @@ CREATE TABLE books (
     title TEXT NOT NULL,
     subtitle TEXT,
     description TEXT,
-    published DATE NOT NULL,
+    -- The days the book could have been published on: one day, or a
+    -- whole month or year when that's all that's known. NULL if unknown.
+    published DATERANGE CHECK (NOT isempty(published)),
     cover_image UUID REFERENCES blobs(id),
     thumbnail_image UUID REFERENCES blobs(id),
+    work_id UUID NOT NULL REFERENCES works(id),
+    -- Set when an admin has put the edition in its work by hand, so
+    -- clustering leaves it be
+    work_pinned BOOLEAN NOT NULL DEFAULT false,
+    -- Edition details
+    format TEXT CHECK (format IN ('hardcover', 'paperback', 'ebook', 'audiobook')),
+    publisher TEXT,
+    page_count INTEGER CHECK (page_count > 0),
+    language TEXT,
+    -- Where the metadata was scraped from, if it was
+    provider TEXT,
+    provider_id TEXT,
+    cover_url TEXT,
+    fetched_at TIMESTAMPTZ,
+    -- What books are searched, filtered and faceted on, copied here as
+    -- ParadeDB only searches a table's own columns. Triggers keep them
+    -- up to date (see refresh_book_search and refresh_work_rating);
+    -- nothing else should write them.
+    author_ids TEXT[] NOT NULL DEFAULT '{}',
+    author_given_names TEXT,
+    author_family_names TEXT,
+    isbn_codes TEXT[] NOT NULL DEFAULT '{}',
+    -- The subjects the book is filed under and all of their ancestors
+    subject_ids TEXT[] NOT NULL DEFAULT '{}',
+    -- The mean rating of the book's work, over every edition's reviews
+    rating REAL,
+    language_code TEXT GENERATED ALWAYS AS (lower(language)) STORED,
+    published_year INTEGER GENERATED ALWAYS AS (
+        EXTRACT(YEAR FROM lower(published))::INTEGER
+    ) STORED,
+    published_first DATE GENERATED ALWAYS AS (lower(published)) STORED,
+    published_last DATE GENERATED ALWAYS AS (upper(published) - 1) STORED,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
@@ -27,16 +61,51 @@ CREATE TABLE isbns (
 
 CREATE INDEX i_books_title ON books USING GIN (to_tsvector('english', title));
 CREATE UNIQUE INDEX i_isbns_unique_book ON isbns(book_id, isbn_type);
+CREATE INDEX i_books_fetched_at ON books(fetched_at) WHERE provider IS NOT NULL;
+CREATE INDEX i_books_work ON books(work_id);
+CREATE INDEX i_books_published ON books USING GIST (published);
+-- For suggestions as a title is typed
+CREATE INDEX i_books_title_trgm ON books USING GIN (title gin_trgm_ops);
 
+-- Identifiers are matched whole, and the fast fields are what facets
+-- are aggregated over
 CREATE INDEX i_books_search ON books
-USING bm25 (id, title, subtitle, description, published)
-WITH (key_field='id');
+USING bm25 (
+    id,
+    title,
+    subtitle,
+    description,
+    author_ids,
+    author_given_names,
+    author_family_names,
+    isbn_codes,
+    subject_ids,
+    rating,
+    language_code,
+    published_year,
+    published_first,
+    published_last
+) WITH (
+    key_field='id',
+    text_fields='{
+        "author_ids": {"tokenizer": {"type": "keyword"}},
+        "isbn_codes": {"tokenizer": {"type": "keyword"}},
+        "subject_ids": {"tokenizer": {"type": "keyword"}, "fast": true},
+        "language_code": {"tokenizer": {"type": "keyword"}}
+    }',
+    numeric_fields='{
+        "rating": {"fast": true},
+        "published_year": {"fast": true}
+    }'
+);
 
 --------------
 -- Triggers --
 --------------
 
+-- Triggers copying what books are searched on onto them aren't edits
 CREATE TRIGGER t_books_set_updated_at
 BEFORE UPDATE ON books
 FOR EACH ROW
+WHEN (pg_trigger_depth() = 0)
 EXECUTE FUNCTION update_timestamp();
\ No newline at end of file
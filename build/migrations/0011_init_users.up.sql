@@ -9,6 +9,8 @@ CREATE TABLE users (
     email TEXT,
     avatar UUID REFERENCES blobs(id),
     superuser BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Opted out of being found in searches and suggestions
+    unlisted BOOLEAN NOT NULL DEFAULT FALSE,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 
@@ -29,22 +31,15 @@ CREATE UNIQUE INDEX i_users_full_username ON users (
 );
 CREATE UNIQUE INDEX i_users_handle_discriminator ON users 
     (handle, discriminator);
+-- For suggestions as a handle is typed
+CREATE INDEX i_users_handle_trgm ON users USING GIN (handle gin_trgm_ops);
 
 
+-- Only what people can be searched by; anything private (like email)
+-- must never be in here
 CREATE INDEX i_users_search ON users
-USING bm25 (
-    id, 
-    github_id, 
-    display_name, 
-    pronouns, 
-    handle, 
-    discriminator, 
-    email, 
-    avatar, 
-    superuser, 
-    created_at, 
-    updated_at
-) WITH (key_field = 'id');
+USING bm25 (id, handle, display_name)
+WITH (key_field = 'id');
 
 --------------
 -- Triggers --
@@ -0,0 +1,16 @@
+-- When duplicate authors are merged the duplicates are deleted, but
+-- their IDs and names are kept here so old links (and the scraper's
+-- name lookups) resolve to the author they were merged into.
+CREATE TABLE author_redirects (
+    old_id UUID PRIMARY KEY,
+    new_id UUID NOT NULL REFERENCES authors (id) ON DELETE CASCADE,
+    old_name TEXT NOT NULL,
+    merged_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-------------
+-- Indexes --
+-------------
+
+CREATE INDEX i_author_redirects_new_id ON author_redirects (new_id);
+CREATE INDEX i_author_redirects_old_name ON author_redirects (old_name);
@@ -0,0 +1,20 @@
+-- A hierarchical subject taxonomy, e.g. Fiction > Science Fiction
+CREATE TABLE subjects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    parent_id UUID REFERENCES subjects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (length(name) > 0),
+    -- Providers are inconsistent about case, so siblings are matched
+    -- on this instead of name
+    slug TEXT GENERATED ALWAYS AS (lower(name)) STORED,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT unique_sibling_subject UNIQUE NULLS NOT DISTINCT (parent_id, slug)
+);
+
+CREATE INDEX i_subjects_parent ON subjects(parent_id);
+
+CREATE TRIGGER t_subjects_set_updated_at
+BEFORE UPDATE ON subjects
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -0,0 +1,22 @@
+-- An ordered run of books, e.g. The Expanse
+CREATE TABLE series (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    name TEXT NOT NULL CHECK (length(name) > 0),
+    description TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Scraped series are matched by name regardless of case. This isn't
+-- unique, as two different series can share a name; those are made by
+-- hand and resolving a name picks the oldest.
+CREATE INDEX i_series_name ON series(lower(name));
+
+--------------
+-- Triggers --
+--------------
+
+CREATE TRIGGER t_series_set_updated_at
+BEFORE UPDATE ON series
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
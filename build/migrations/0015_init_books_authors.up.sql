@@ -1,5 +1,14 @@
 CREATE TABLE books_authors (
     book_id UUID REFERENCES books(id),
     author_id UUID REFERENCES authors(id),
-    PRIMARY KEY (book_id, author_id)
-);
\ No newline at end of file
+    -- Translators, illustrators and so on are credited here too. These
+    -- mirror model.ContributorRole.
+    role TEXT NOT NULL DEFAULT 'author' CHECK (
+        role IN ('author', 'editor', 'translator', 'illustrator', 'narrator')
+    ),
+    -- Where the credit comes in the book's billing, lowest first
+    billing SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX i_books_authors_author ON books_authors(author_id);
@@ -0,0 +1,11 @@
+-- Where each field of a book came from. Which source wins when two
+-- disagree (admin > user > scraper) is decided by the backend.
+CREATE TABLE book_provenance (
+    book_id UUID NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('scraper', 'user', 'admin')),
+    source_id TEXT,
+    actor UUID REFERENCES users(id) ON DELETE SET NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (book_id, field)
+);
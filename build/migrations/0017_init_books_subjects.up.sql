@@ -0,0 +1,7 @@
+CREATE TABLE books_subjects (
+    book_id UUID REFERENCES books(id) ON DELETE CASCADE,
+    subject_id UUID REFERENCES subjects(id) ON DELETE CASCADE,
+    PRIMARY KEY (book_id, subject_id)
+);
+
+CREATE INDEX i_books_subjects_subject ON books_subjects(subject_id);
@@ -0,0 +1,9 @@
+-- A book is in at most one series, hence the key on book_id alone
+CREATE TABLE books_series (
+    book_id UUID PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    series_id UUID NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+    -- Fractional, so novellas can sit between the main books
+    position NUMERIC(8, 2) NOT NULL CHECK (position >= 0)
+);
+
+CREATE INDEX i_books_series_series ON books_series(series_id, position);
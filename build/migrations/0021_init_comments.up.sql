@@ -1,6 +1,8 @@
 CREATE TABLE comments (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
     book_id UUID REFERENCES books(id) ON DELETE CASCADE,
+    -- Reviews of a work as a whole rather than one of its editions
+    work_id UUID REFERENCES works(id) ON DELETE CASCADE,
     poster_id UUID REFERENCES users(id) ON DELETE SET NULL,
     body TEXT,
     rating REAL,
@@ -14,6 +16,7 @@ CREATE TABLE comments (
         (rating IS NULL) OR
         (rating >= 0.0 AND rating <= 1.0)
     ),
+    CONSTRAINT book_xor_work CHECK (book_id IS NULL OR work_id IS NULL),
     CONSTRAINT review_xor_reply_xor_deleted CHECK (
         (rating IS NOT NULL AND parent_comment_id IS NULL) OR
         (rating IS NULL AND parent_comment_id IS NOT NULL) OR
@@ -28,7 +31,13 @@ CREATE TABLE comments (
 CREATE UNIQUE INDEX i_one_user_one_review ON comments (poster_id, book_id)
     WHERE parent_comment_id IS NULL;
 
+-- Work reviews have no book_id, so the above doesn't cover them.
+-- Joining two works deletes all but a user's newest review of them.
+CREATE UNIQUE INDEX i_one_user_one_work_review ON comments (poster_id, work_id)
+    WHERE parent_comment_id IS NULL;
+
 CREATE INDEX i_comments_books ON comments (book_id);
+CREATE INDEX i_comments_works ON comments (work_id);
 CREATE INDEX i_comments_parent ON comments (parent_comment_id);
 CREATE INDEX i_comments_user ON comments (poster_id);
 
@@ -36,6 +45,7 @@ CREATE INDEX i_comments_search ON comments
 USING bm25 (
     id,
     book_id,
+    work_id,
     poster_id,
     body,
     rating,
@@ -49,6 +49,204 @@ BEGIN
 END;
 $$ LANGUAGE plpgsql;
 
+-- Count what stopped and started using blobs (see blobs.refs). A blob
+-- used twice by one row, as a book's cover and thumbnail, counts twice.
+CREATE OR REPLACE FUNCTION update_blob_refs(old_ids UUID[], new_ids UUID[])
+RETURNS VOID AS $$
+BEGIN
+    UPDATE blobs b
+    SET refs = b.refs + d.delta
+    FROM (
+        SELECT c.id, SUM(c.delta)::INTEGER AS delta FROM (
+            SELECT unnest(old_ids) AS id, -1 AS delta
+            UNION ALL
+            SELECT unnest(new_ids), 1
+        ) c
+        WHERE c.id IS NOT NULL
+        GROUP BY c.id
+    ) d
+    WHERE b.id = d.id AND d.delta <> 0;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When a book's cover or thumbnail changes
+CREATE OR REPLACE FUNCTION update_blob_refs_books()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM update_blob_refs('{}', ARRAY[NEW.cover_image, NEW.thumbnail_image]);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM update_blob_refs(ARRAY[OLD.cover_image, OLD.thumbnail_image], ARRAY[NEW.cover_image, NEW.thumbnail_image]);
+    ELSE
+        PERFORM update_blob_refs(ARRAY[OLD.cover_image, OLD.thumbnail_image], '{}');
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When a user's avatar changes
+CREATE OR REPLACE FUNCTION update_blob_refs_users()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM update_blob_refs('{}', ARRAY[NEW.avatar]);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM update_blob_refs(ARRAY[OLD.avatar], ARRAY[NEW.avatar]);
+    ELSE
+        PERFORM update_blob_refs(ARRAY[OLD.avatar], '{}');
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Copy what books are searched on from other tables onto the books
+-- themselves (see books.author_ids)
+CREATE OR REPLACE FUNCTION refresh_book_search(ids UUID[])
+RETURNS VOID AS $$
+BEGIN
+    UPDATE books b SET
+        author_ids = ARRAY(
+            SELECT DISTINCT ba.author_id::TEXT
+            FROM books_authors ba
+            WHERE ba.book_id = b.id
+        ),
+        author_given_names = (
+            SELECT string_agg(DISTINCT a.given_name, ' ')
+            FROM books_authors ba
+            JOIN authors a ON a.id = ba.author_id
+            WHERE ba.book_id = b.id
+        ),
+        author_family_names = (
+            SELECT string_agg(DISTINCT a.family_name, ' ')
+            FROM books_authors ba
+            JOIN authors a ON a.id = ba.author_id
+            WHERE ba.book_id = b.id
+        ),
+        isbn_codes = ARRAY(
+            SELECT i.isbn FROM isbns i WHERE i.book_id = b.id
+        ),
+        -- A book counts as filed under every ancestor of its subjects
+        subject_ids = ARRAY(
+            WITH RECURSIVE t (id) AS (
+                SELECT bs.subject_id FROM books_subjects bs WHERE bs.book_id = b.id
+            UNION
+                SELECT s.parent_id FROM subjects s JOIN t ON s.id = t.id
+                WHERE s.parent_id IS NOT NULL
+            )
+            SELECT id::TEXT FROM t
+        )
+    WHERE b.id = ANY(ids);
+END;
+$$ LANGUAGE plpgsql;
+
+-- When what a book is credited to, identified by or filed under changes
+CREATE OR REPLACE FUNCTION refresh_book_search_row()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM refresh_book_search(ARRAY[NEW.book_id]);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM refresh_book_search(ARRAY[OLD.book_id, NEW.book_id]);
+    ELSE
+        PERFORM refresh_book_search(ARRAY[OLD.book_id]);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When an author is renamed
+CREATE OR REPLACE FUNCTION refresh_book_search_authors()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_book_search(ARRAY(
+        SELECT ba.book_id FROM books_authors ba WHERE ba.author_id = NEW.id
+    ));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When a subject moves, which changes the ancestors of every book
+-- filed under it or its descendants
+CREATE OR REPLACE FUNCTION refresh_book_search_subjects()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_book_search(ARRAY(
+        WITH RECURSIVE t (id) AS (
+            SELECT NEW.id
+        UNION
+            SELECT s.id FROM subjects s JOIN t ON s.parent_id = t.id
+        )
+        SELECT DISTINCT bs.book_id FROM books_subjects bs JOIN t ON t.id = bs.subject_id
+    ));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A work's mean rating. Reviews of any edition count towards the whole
+-- work. A user may have reviewed the work and several of its editions,
+-- but only their newest review is counted.
+CREATE OR REPLACE FUNCTION work_rating(work UUID)
+RETURNS REAL AS $$
+    SELECT AVG(r.rating)::REAL
+    FROM (
+        SELECT DISTINCT ON (COALESCE(c.poster_id, c.id)) c.rating
+        FROM comments c
+        WHERE (
+                c.work_id = work OR
+                c.book_id IN (SELECT e.id FROM books e WHERE e.work_id = work)
+            )
+            AND c.parent_comment_id IS NULL
+            AND NOT c.deleted
+        ORDER BY COALESCE(c.poster_id, c.id), c.created_at DESC, c.id DESC
+    ) r
+$$ LANGUAGE sql STABLE;
+
+-- Copy works' ratings onto their editions (see books.rating)
+CREATE OR REPLACE FUNCTION refresh_work_rating(ids UUID[])
+RETURNS VOID AS $$
+BEGIN
+    UPDATE books b SET rating = w.rating
+    FROM (
+        SELECT u.id, work_rating(u.id) AS rating
+        FROM (SELECT DISTINCT unnest(ids) AS id) u
+    ) w
+    WHERE b.work_id = w.id AND b.rating IS DISTINCT FROM w.rating;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When a review is posted, edited, deleted or moved
+CREATE OR REPLACE FUNCTION refresh_work_rating_comments()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM refresh_work_rating(ARRAY[COALESCE(
+            NEW.work_id, (SELECT work_id FROM books WHERE id = NEW.book_id)
+        )]);
+    END IF;
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM refresh_work_rating(ARRAY[COALESCE(
+            OLD.work_id, (SELECT work_id FROM books WHERE id = OLD.book_id)
+        )]);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- When an edition joins or leaves a work
+CREATE OR REPLACE FUNCTION refresh_work_rating_books()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM refresh_work_rating(ARRAY[NEW.work_id]);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM refresh_work_rating(ARRAY[OLD.work_id, NEW.work_id]);
+    ELSE
+        PERFORM refresh_work_rating(ARRAY[OLD.work_id]);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
 
 
 CREATE TRIGGER t_comments_delete
@@ -65,4 +263,40 @@ FOR EACH ROW EXECUTE FUNCTION update_vote_total_update();
 
 CREATE TRIGGER t_votes_delete
 AFTER DELETE ON votes
-FOR EACH ROW EXECUTE FUNCTION update_vote_total_delete();
\ No newline at end of file
+FOR EACH ROW EXECUTE FUNCTION update_vote_total_delete();
+
+CREATE TRIGGER t_books_blob_refs
+AFTER INSERT OR UPDATE OF cover_image, thumbnail_image OR DELETE ON books
+FOR EACH ROW EXECUTE FUNCTION update_blob_refs_books();
+
+CREATE TRIGGER t_users_blob_refs
+AFTER INSERT OR UPDATE OF avatar OR DELETE ON users
+FOR EACH ROW EXECUTE FUNCTION update_blob_refs_users();
+
+CREATE TRIGGER t_books_authors_search
+AFTER INSERT OR UPDATE OR DELETE ON books_authors
+FOR EACH ROW EXECUTE FUNCTION refresh_book_search_row();
+
+CREATE TRIGGER t_isbns_search
+AFTER INSERT OR UPDATE OR DELETE ON isbns
+FOR EACH ROW EXECUTE FUNCTION refresh_book_search_row();
+
+CREATE TRIGGER t_books_subjects_search
+AFTER INSERT OR UPDATE OR DELETE ON books_subjects
+FOR EACH ROW EXECUTE FUNCTION refresh_book_search_row();
+
+CREATE TRIGGER t_authors_search
+AFTER UPDATE OF given_name, family_name ON authors
+FOR EACH ROW EXECUTE FUNCTION refresh_book_search_authors();
+
+CREATE TRIGGER t_subjects_search
+AFTER UPDATE OF parent_id ON subjects
+FOR EACH ROW EXECUTE FUNCTION refresh_book_search_subjects();
+
+CREATE TRIGGER t_comments_rating
+AFTER INSERT OR UPDATE OF rating, deleted, poster_id, work_id, book_id OR DELETE ON comments
+FOR EACH ROW EXECUTE FUNCTION refresh_work_rating_comments();
+
+CREATE TRIGGER t_books_rating
+AFTER INSERT OR UPDATE OF work_id OR DELETE ON books
+FOR EACH ROW EXECUTE FUNCTION refresh_work_rating_books();
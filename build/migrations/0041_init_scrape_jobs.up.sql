@@ -0,0 +1,41 @@
+-- Background scrape jobs. Workers claim queued jobs with
+-- `FOR UPDATE SKIP LOCKED` so any number of them can share the table
+-- without stepping on each other.
+CREATE TABLE scrape_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    query TEXT NOT NULL,
+    start_index INTEGER NOT NULL DEFAULT 0 CHECK (start_index >= 0),
+    page_size INTEGER NOT NULL CHECK (page_size > 0),
+    status TEXT NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'running', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    added INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    run_after TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-------------
+-- Indexes --
+-------------
+
+-- Only one live job per query, identical searches share it
+CREATE UNIQUE INDEX i_scrape_jobs_active ON scrape_jobs (query, start_index, page_size)
+    WHERE status IN ('queued', 'running');
+CREATE INDEX i_scrape_jobs_runnable ON scrape_jobs (run_after)
+    WHERE status = 'queued';
+CREATE INDEX i_scrape_jobs_finished ON scrape_jobs (query, start_index, page_size, finished_at)
+    WHERE status = 'done';
+
+--------------
+-- Triggers --
+--------------
+
+CREATE TRIGGER t_scrape_jobs_set_updated_at
+BEFORE UPDATE ON scrape_jobs
+FOR EACH ROW
+EXECUTE FUNCTION update_timestamp();
@@ -8,25 +8,45 @@ CREATE VIEW v_books_summary AS
         b.description,
         b.published,
         b.thumbnail_image,
-        COALESCE(
-            jsonb_agg(DISTINCT jsonb_build_object(
-                'id', a.id,
-                'family_name', a.family_name,
-                'given_name', a.given_name
-            )) FILTER (WHERE a.id IS NOT NULL),
-            '[]'::jsonb
+        -- Credits are gathered on their own, rather than through a join
+        -- below, so they can be kept in billing order
+        (
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object(
+                    'id', a.id,
+                    'family_name', a.family_name,
+                    'given_name', a.given_name
+                ) ORDER BY ba.billing, a.id),
+                '[]'::jsonb
+            )
+            FROM books_authors ba
+            JOIN authors a ON ba.author_id = a.id
+            WHERE ba.book_id = b.id AND ba.role = 'author'
         ) AS authors,
+        (
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object(
+                    'id', a.id,
+                    'family_name', a.family_name,
+                    'given_name', a.given_name,
+                    'role', ba.role
+                ) ORDER BY ba.billing, a.id),
+                '[]'::jsonb
+            )
+            FROM books_authors ba
+            JOIN authors a ON ba.author_id = a.id
+            WHERE ba.book_id = b.id
+        ) AS contributors,
         COALESCE(
             jsonb_agg(jsonb_build_object(
                 'value', i.isbn,
                 'type', i.isbn_type
             )) FILTER (WHERE i.isbn IS NOT NULL),
             '[]'::jsonb
-        ) AS isbns
+        ) AS isbns,
+        b.rating
     FROM 
         books b
-        LEFT JOIN books_authors ba ON b.id = ba.book_id
-        LEFT JOIN authors a ON ba.author_id = a.id
         LEFT JOIN isbns i ON b.id = i.book_id
     GROUP BY
         b.id;
\ No newline at end of file
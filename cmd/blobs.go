@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+const blobsUsage = `usage: blobs <command> [flags]
+
+commands:
+  migrate -from <store> -to <store>  move blobs' content between stores
+  dedupe                             merge blobs with the same content and
+                                     recount what references them
+  gc [-dry-run]                      delete blobs which nothing uses
+`
+
+// Maintenance of the blobs in the datastore, run as `blobs <command>`
+func runBlobs(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, blobsUsage)
+		return 2
+	}
+	switch args[0] {
+	case "migrate":
+		return runBlobsMigrate(args[1:])
+	case "dedupe":
+		return runBlobsDedupe(args[1:])
+	case "gc":
+		return runBlobsGC(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown blobs command %q\n%v", args[0], blobsUsage)
+		return 2
+	}
+}
+
+// Parse a blobs command's flags, on top of the ones every command has,
+// and connect to the datastore. A non-zero exit code is returned if
+// either fails.
+func blobsDatastore(conf *flagVars, fs *flag.FlagSet, args []string, valid func() bool) (repository.Repository[string], int) {
+	conf.define(fs)
+	if err := fs.Parse(args); err != nil {
+		return repository.Repository[string]{}, 2
+	}
+	if !valid() {
+		fs.Usage()
+		return repository.Repository[string]{}, 2
+	}
+	if conf.DockerMode {
+		conf.readEnv()
+	}
+
+	ds, err := conf.datastore()
+	if err != nil {
+		fmt.Printf("error connecting to datastore: %s\n", err)
+		return ds, 8
+	}
+	return ds, 0
+}
+
+// Move the content of every blob in one store to another, a batch at a
+// time. It can be stopped and run again; whatever was moved stays moved.
+func runBlobsMigrate(args []string) int {
+	var (
+		conf     flagVars
+		from, to string
+		batch    int
+	)
+	fs := flag.NewFlagSet("blobs migrate", flag.ContinueOnError)
+	fs.StringVar(&from, "from", "", "Store to move blobs' content out of")
+	fs.StringVar(&to, "to", "", "Store to move blobs' content into")
+	fs.IntVar(&batch, "batch", 100, "Blobs moved per batch")
+	ds, code := blobsDatastore(&conf, fs, args, func() bool {
+		return from != "" && to != "" && from != to && batch > 0
+	})
+	if code != 0 {
+		return code
+	}
+	defer ds.Store.Disconnect()
+
+	m, ok := ds.Blob.(repository.BlobMigrator)
+	if !ok {
+		fmt.Println("datastore cannot migrate blobs")
+		return 1
+	}
+	ctx := context.Background()
+	total := 0
+	for {
+		n, err := m.MigrateBlobs(ctx, from, to, batch)
+		total += n
+		if err != nil {
+			fmt.Printf("error migrating blobs after %d: %s\n", total, err)
+			return 1
+		}
+		if n == 0 {
+			break
+		}
+		fmt.Printf("migrated %d blobs from %v to %v\n", total, from, to)
+	}
+	fmt.Printf("done, %d blobs migrated from %v to %v\n", total, from, to)
+	return 0
+}
+
+// Merge blobs made before blobs were deduplicated which have the same
+// content, a batch at a time, then make sure every blob's count of
+// references is right. Like migrating, it can be stopped and run again.
+func runBlobsDedupe(args []string) int {
+	var (
+		conf  flagVars
+		batch int
+	)
+	fs := flag.NewFlagSet("blobs dedupe", flag.ContinueOnError)
+	fs.IntVar(&batch, "batch", 100, "Sets of blobs with the same content merged per batch")
+	ds, code := blobsDatastore(&conf, fs, args, func() bool { return batch > 0 })
+	if code != 0 {
+		return code
+	}
+	defer ds.Store.Disconnect()
+
+	d, ok := ds.Blob.(repository.BlobDeduplicator)
+	if !ok {
+		fmt.Println("datastore cannot deduplicate blobs")
+		return 1
+	}
+	ctx := context.Background()
+	total := 0
+	for {
+		n, err := d.DedupeBlobs(ctx, batch)
+		total += n
+		if err != nil {
+			fmt.Printf("error deduplicating blobs after %d: %s\n", total, err)
+			return 1
+		}
+		if n == 0 {
+			break
+		}
+		fmt.Printf("merged away %d duplicate blobs\n", total)
+	}
+	fixed, err := d.RecountBlobRefs(ctx)
+	if err != nil {
+		fmt.Printf("error recounting blob references: %s\n", err)
+		return 1
+	}
+	fmt.Printf("done, %d duplicate blobs merged away and %d reference counts corrected\n", total, fixed)
+	return 0
+}
+
+// Delete the blobs which nothing uses and which are older than
+// -blobgrace, or with -dry-run only list them.
+func runBlobsGC(args []string) int {
+	var (
+		conf   flagVars
+		dryRun bool
+	)
+	fs := flag.NewFlagSet("blobs gc", flag.ContinueOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "List the blobs which would be deleted, without deleting them")
+	ds, code := blobsDatastore(&conf, fs, args, func() bool { return conf.BlobGrace >= 0 })
+	if code != 0 {
+		return code
+	}
+	defer ds.Store.Disconnect()
+
+	c, ok := ds.Blob.(repository.BlobCollector)
+	if !ok {
+		fmt.Println("datastore cannot collect blobs")
+		return 1
+	}
+	report, err := c.CollectBlobs(context.Background(), conf.BlobGrace, dryRun)
+	if report != nil {
+		for _, o := range report.Orphans {
+			fmt.Printf("%v\t%v\t%d\n", o.ID, o.Created.Format(time.RFC3339), o.Size)
+		}
+		fmt.Println(collectionSummary(report))
+	}
+	if err != nil {
+		fmt.Printf("error collecting blobs: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Collect unused blobs periodically until ctx is cancelled
+func collectBlobs(ctx context.Context, c repository.BlobCollector, grace, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		report, err := c.CollectBlobs(ctx, grace, false)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("blob gc: %v", err)
+		}
+		if report != nil && len(report.Orphans) > 0 {
+			log.Printf("blob gc: %v", collectionSummary(report))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func collectionSummary(r *model.BlobCollection) string {
+	verb := "deleted"
+	if r.DryRun {
+		verb = "would delete"
+	}
+	return fmt.Sprintf("%v %d unused blobs, %d bytes", verb, len(r.Orphans), r.Size)
+}
@@ -13,7 +13,9 @@ import (
 	oauth2Endpoints "golang.org/x/oauth2/endpoints"
 
 	"github.com/whit-colm/itsc-4155-project/internal/db"
+	"github.com/whit-colm/itsc-4155-project/pkg/blobstore"
 	"github.com/whit-colm/itsc-4155-project/pkg/endpoints"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
 	"github.com/whit-colm/itsc-4155-project/pkg/scraper"
 )
 
@@ -32,51 +34,196 @@ type flagVars struct {
 
 	OAuth2GithubClientID     string
 	OAuth2GithubClientSecret string
+
+	ScrapeWorkers int
+
+	GoogleBooksRate    float64
+	GoogleBooksWorkers int
+	OpenLibraryRate    float64
+
+	RefreshAge time.Duration
+
+	BlobMaxSize    int64
+	BlobStore      string
+	BlobDir        string
+	BlobGrace      time.Duration
+	BlobGCInterval time.Duration
+
+	S3Endpoint  string
+	S3Bucket    string
+	S3Region    string
+	S3Prefix    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
 }
 
 var runtimeConfig flagVars
 
-func Run(args []string) int {
-	// Define a bunch of flags and parse them
-	flag.BoolVar(&runtimeConfig.DockerMode, "docker", false, "Weather to run in Docker mode (i.e. read ENV vars)")
-	flag.BoolVar(&runtimeConfig.DebugMode, "debug", true, "Weather to run in Docker mode (i.e. read ENV vars)")
-	flag.StringVar(&runtimeConfig.GinHost, "host", "localhost", "Hostname to listen to")
-	flag.StringVar(&runtimeConfig.GinPort, "port", "9000", "Port to listen to")
+// Define the flags which are shared by every command
+func (f *flagVars) define(fs *flag.FlagSet) {
+	fs.BoolVar(&f.DockerMode, "docker", false, "Weather to run in Docker mode (i.e. read ENV vars)")
+	fs.BoolVar(&f.DebugMode, "debug", true, "Weather to run in Docker mode (i.e. read ENV vars)")
+	fs.StringVar(&f.GinHost, "host", "localhost", "Hostname to listen to")
+	fs.StringVar(&f.GinPort, "port", "9000", "Port to listen to")
 
-	flag.StringVar(&runtimeConfig.PsqlPassword, "dbdatabase", "jaws", "Database to be used in the PostgreSQL instance")
-	flag.StringVar(&runtimeConfig.PsqlUser, "dbuser", "jaws", "Username for the PostgreSQL user")
-	flag.StringVar(&runtimeConfig.PsqlDatabase, "dbpasswd", "", "Password for the PostgreSQL user")
-	flag.StringVar(&runtimeConfig.PsqlHost, "dbhost", "127.0.0.1", "Hostname or IP for the PostgeSQL instance")
-	flag.StringVar(&runtimeConfig.PsqlPort, "dbport", "5432", "Port for the PostgreSQL instance")
+	fs.StringVar(&f.PsqlPassword, "dbdatabase", "jaws", "Database to be used in the PostgreSQL instance")
+	fs.StringVar(&f.PsqlUser, "dbuser", "jaws", "Username for the PostgreSQL user")
+	fs.StringVar(&f.PsqlDatabase, "dbpasswd", "", "Password for the PostgreSQL user")
+	fs.StringVar(&f.PsqlHost, "dbhost", "127.0.0.1", "Hostname or IP for the PostgeSQL instance")
+	fs.StringVar(&f.PsqlPort, "dbport", "5432", "Port for the PostgreSQL instance")
 
-	flag.StringVar(&runtimeConfig.OAuth2GithubClientID, "oa2ghclientid", "", "GitHub Application Client ID")
-	flag.StringVar(&runtimeConfig.OAuth2GithubClientSecret, "oa2ghclientsecret", "", "GitHub Application Client Secret")
+	fs.StringVar(&f.OAuth2GithubClientID, "oa2ghclientid", "", "GitHub Application Client ID")
+	fs.StringVar(&f.OAuth2GithubClientSecret, "oa2ghclientsecret", "", "GitHub Application Client Secret")
 
-	flag.Parse()
+	fs.IntVar(&f.ScrapeWorkers, "scrapeworkers", 2, "Number of background scrape workers")
+	fs.Float64Var(&f.GoogleBooksRate, "gbrate", 5, "Google Books requests per second, shared by all scrapes")
+	fs.IntVar(&f.GoogleBooksWorkers, "gbworkers", 4, "Google Books volumes fetched at once, per scrape")
+	fs.Float64Var(&f.OpenLibraryRate, "olrate", 1, "Open Library requests per second, shared by all scrapes")
+	fs.DurationVar(&f.RefreshAge, "refreshage", 30*24*time.Hour, "Age at which scraped books are refreshed, 0 to never refresh")
 
-	// Before continuing, check if running in docker mode
-	// if we are we need to re-read runtime values from env vars
-	if runtimeConfig.DockerMode {
-		// In docker mode, the backend is only ever touched by the
-		// front-end (nginx); and is not exposed to the network.
-		runtimeConfig.GinHost = "0.0.0.0"
-		runtimeConfig.GinPort = "9000"
-
-		debugMode := os.Getenv("DEBUG_MODE")
-		var err error = nil
-		runtimeConfig.DebugMode, err = strconv.ParseBool(debugMode)
+	fs.Int64Var(&f.BlobMaxSize, "blobmaxsize", endpoints.DefaultBlobMaxSize, "Largest blob which can be uploaded, in bytes")
+	fs.StringVar(&f.BlobStore, "blobstore", db.PostgresBlobStore, "Store new blobs' content goes in: postgres, filesystem or s3")
+	fs.StringVar(&f.BlobDir, "blobdir", "", "Directory for the filesystem blob store, if it is used")
+	fs.DurationVar(&f.BlobGrace, "blobgrace", 24*time.Hour, "Age at which unused blobs are garbage collected")
+	fs.DurationVar(&f.BlobGCInterval, "blobgcinterval", 6*time.Hour, "How often unused blobs are garbage collected, 0 to never collect them")
+
+	fs.StringVar(&f.S3Endpoint, "s3endpoint", "", "Endpoint URL of the S3 blob store")
+	fs.StringVar(&f.S3Bucket, "s3bucket", "", "Bucket of the S3 blob store, if it is used")
+	fs.StringVar(&f.S3Region, "s3region", "us-east-1", "Region of the S3 blob store")
+	fs.StringVar(&f.S3Prefix, "s3prefix", "", "Prefix for keys in the S3 blob store")
+	fs.StringVar(&f.S3AccessKey, "s3accesskey", "", "Access key for the S3 blob store")
+	fs.StringVar(&f.S3SecretKey, "s3secretkey", "", "Secret key for the S3 blob store")
+	fs.BoolVar(&f.S3PathStyle, "s3pathstyle", true, "Whether the S3 bucket is in the path rather than the hostname")
+}
+
+// In docker mode, re-read runtime values from env vars
+func (f *flagVars) readEnv() {
+	// In docker mode, the backend is only ever touched by the
+	// front-end (nginx); and is not exposed to the network.
+	f.GinHost = "0.0.0.0"
+	f.GinPort = "9000"
+
+	debugMode := os.Getenv("DEBUG_MODE")
+	var err error = nil
+	f.DebugMode, err = strconv.ParseBool(debugMode)
+	if err != nil {
+		f.DebugMode = true
+	}
+
+	f.PsqlHost = os.Getenv("PG_HOST")
+	f.PsqlPort = os.Getenv("PG_PORT")
+	f.PsqlDatabase = os.Getenv("PG_DATABASE")
+	f.PsqlPassword = os.Getenv("PG_PASSWORD")
+	f.PsqlUser = os.Getenv("PG_USER")
+
+	f.OAuth2GithubClientID = os.Getenv("GH_CLIENTID")
+	f.OAuth2GithubClientSecret = os.Getenv("GH_CLIENTSECRET")
+
+	if n, err := strconv.Atoi(os.Getenv("SCRAPE_WORKERS")); err == nil {
+		f.ScrapeWorkers = n
+	}
+	if r, err := strconv.ParseFloat(os.Getenv("GB_RATE"), 64); err == nil {
+		f.GoogleBooksRate = r
+	}
+	if n, err := strconv.Atoi(os.Getenv("GB_WORKERS")); err == nil {
+		f.GoogleBooksWorkers = n
+	}
+	if r, err := strconv.ParseFloat(os.Getenv("OL_RATE"), 64); err == nil {
+		f.OpenLibraryRate = r
+	}
+	if d, err := time.ParseDuration(os.Getenv("REFRESH_AGE")); err == nil {
+		f.RefreshAge = d
+	}
+	if n, err := strconv.ParseInt(os.Getenv("BLOB_MAX_SIZE"), 10, 64); err == nil {
+		f.BlobMaxSize = n
+	}
+
+	if s := os.Getenv("BLOB_STORE"); s != "" {
+		f.BlobStore = s
+	}
+	f.BlobDir = os.Getenv("BLOB_DIR")
+	if d, err := time.ParseDuration(os.Getenv("BLOB_GRACE")); err == nil {
+		f.BlobGrace = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("BLOB_GC_INTERVAL")); err == nil {
+		f.BlobGCInterval = d
+	}
+	f.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	f.S3Bucket = os.Getenv("S3_BUCKET")
+	if r := os.Getenv("S3_REGION"); r != "" {
+		f.S3Region = r
+	}
+	f.S3Prefix = os.Getenv("S3_PREFIX")
+	f.S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	f.S3SecretKey = os.Getenv("S3_SECRET_KEY")
+	if b, err := strconv.ParseBool(os.Getenv("S3_PATH_STYLE")); err == nil {
+		f.S3PathStyle = b
+	}
+}
+
+// The blob stores which are configured, other than Postgres
+func (f *flagVars) blobStores() ([]repository.BlobStore, error) {
+	var stores []repository.BlobStore
+	if f.BlobDir != "" {
+		fs, err := blobstore.NewFilesystem(f.BlobDir)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, fs)
+	}
+	if f.S3Bucket != "" {
+		s3, err := blobstore.NewS3(blobstore.S3Config{
+			Endpoint:  f.S3Endpoint,
+			Bucket:    f.S3Bucket,
+			Region:    f.S3Region,
+			AccessKey: f.S3AccessKey,
+			SecretKey: f.S3SecretKey,
+			PathStyle: f.S3PathStyle,
+			Prefix:    f.S3Prefix,
+		})
 		if err != nil {
-			runtimeConfig.DebugMode = true
+			return nil, err
 		}
+		stores = append(stores, s3)
+	}
+	return stores, nil
+}
+
+// Instantiate our concrete storage class (PostgreSQL)
+// Although as far as the rest of the program is concerned, it's a
+// bunch of repositories
+func (f *flagVars) datastore() (repository.Repository[string], error) {
+	stores, err := f.blobStores()
+	if err != nil {
+		return repository.Repository[string]{}, fmt.Errorf("blob stores: %w", err)
+	}
+	return db.NewRepository(
+		fmt.Sprintf("postgres://%v:%v@%v:%v/%v",
+			f.PsqlUser,
+			f.PsqlPassword,
+			f.PsqlHost,
+			f.PsqlPort,
+			f.PsqlDatabase,
+		),
+		30*time.Second,
+		db.BlobOptions{Stores: stores, Write: f.BlobStore},
+	)
+}
+
+func Run(args []string) int {
+	if len(args) > 0 && args[0] == "blobs" {
+		return runBlobs(args[1:])
+	}
 
-		runtimeConfig.PsqlHost = os.Getenv("PG_HOST")
-		runtimeConfig.PsqlPort = os.Getenv("PG_PORT")
-		runtimeConfig.PsqlDatabase = os.Getenv("PG_DATABASE")
-		runtimeConfig.PsqlPassword = os.Getenv("PG_PASSWORD")
-		runtimeConfig.PsqlUser = os.Getenv("PG_USER")
+	// Define a bunch of flags and parse them
+	runtimeConfig.define(flag.CommandLine)
+	flag.CommandLine.Parse(args)
 
-		runtimeConfig.OAuth2GithubClientID = os.Getenv("GH_CLIENTID")
-		runtimeConfig.OAuth2GithubClientSecret = os.Getenv("GH_CLIENTSECRET")
+	// Before continuing, check if running in docker mode
+	// if we are we need to re-read runtime values from env vars
+	if runtimeConfig.DockerMode {
+		runtimeConfig.readEnv()
 	}
 
 	// Set Gin running mode based on value of the debug mode
@@ -87,19 +234,7 @@ func Run(args []string) int {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Instantiate our concrete storage class (PostgreSQL)
-	// Although as far as the rest of the program is concerned, it's a
-	// bunch of repositories
-	ds, err := db.NewRepository(
-		fmt.Sprintf("postgres://%v:%v@%v:%v/%v",
-			runtimeConfig.PsqlUser,
-			runtimeConfig.PsqlPassword,
-			runtimeConfig.PsqlHost,
-			runtimeConfig.PsqlPort,
-			runtimeConfig.PsqlDatabase,
-		),
-		30*time.Second,
-	)
+	ds, err := runtimeConfig.datastore()
 	if err != nil {
 		fmt.Printf("error connecting to datastore: %s\n", err)
 		return 8
@@ -117,13 +252,34 @@ func Run(args []string) int {
 		Endpoint:     oauth2Endpoints.GitHub,
 	}
 
-	sc := scraper.NewBookScraper(ds.Blob, ds.Book, ds.Author)
+	providers := []scraper.Provider{
+		scraper.NewGoogleBooks(scraper.GoogleBooksConfig{
+			Limiter: scraper.NewRateLimiter(runtimeConfig.GoogleBooksRate, 2*int(runtimeConfig.GoogleBooksRate)),
+			Workers: runtimeConfig.GoogleBooksWorkers,
+		}),
+		scraper.NewOpenLibrary(scraper.OpenLibraryConfig{
+			Limiter: scraper.NewRateLimiter(runtimeConfig.OpenLibraryRate, 3*int(runtimeConfig.OpenLibraryRate)),
+		}),
+	}
+	sc := scraper.NewBookScraper(ds.Blob, ds.Book, ds.Author, ds.Subject, ds.Series, providers...)
+	// Scraping happens in the background, requests only queue it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := scraper.NewQueue(ds.ScrapeJob, sc, runtimeConfig.ScrapeWorkers)
+	go queue.Run(ctx)
+	refresher := scraper.NewRefresher(ds.Blob, ds.Book, runtimeConfig.RefreshAge, providers...)
+	if runtimeConfig.RefreshAge > 0 {
+		go refresher.Run(ctx)
+	}
+	if c, ok := ds.Blob.(repository.BlobCollector); ok && runtimeConfig.BlobGCInterval > 0 {
+		go collectBlobs(ctx, c, runtimeConfig.BlobGrace, runtimeConfig.BlobGCInterval)
+	}
 
 	// Define the Gin router
 	router := gin.Default()
 
 	// Set up endpoints
-	endpoints.Configure(router, &ds, &ghoa2, sc)
+	endpoints.Configure(router, &ds, &ghoa2, queue, refresher, runtimeConfig.BlobMaxSize)
 
 	// Start the router
 	err = router.Run(fmt.Sprintf("%v:%v", runtimeConfig.GinHost, runtimeConfig.GinPort))
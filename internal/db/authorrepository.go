@@ -1,10 +1,12 @@
 package db
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
@@ -26,6 +28,10 @@ func newAuthorRepository(psql *postgres) repository.AuthorManager[string] {
 	return &authorRepository[string]{db: psql.db}
 }
 
+// How an author's name is displayed (and matched on). Must match the
+// i_authors_full_name index expression for lookups to use it.
+const authorFullName string = `TRIM(COALESCE(a.given_name, '') || ' ' || a.family_name)`
+
 func (a authorRepository[S]) queryString(clause string, search bool) string {
 	return fmt.Sprintf(`SELECT
 			 %v
@@ -35,8 +41,8 @@ func (a authorRepository[S]) queryString(clause string, search bool) string {
 			 COALESCE(a.bio, ''),
 			 COALESCE(
 			 	 json_agg(json_build_object(
-				 	 'type', i.type,
-					 identifier, i.identifier
+				 	 'type', RTRIM(i.type),
+					 'id', i.identifier
 			 	 )) FILTER (WHERE i.author_id IS NOT NULL),
 				 '[]'::json
 			 )
@@ -45,7 +51,7 @@ func (a authorRepository[S]) queryString(clause string, search bool) string {
 		 WHERE %v`,
 		func() string {
 			if search {
-				return "paradedb.score(a.id),"
+				return "paradedb.score(a.id) AS score,"
 			}
 			return ""
 		}(),
@@ -100,8 +106,12 @@ func (a *authorRepository[S]) Book(ctx context.Context, bookID uuid.UUID) ([]*mo
 
 // Create implements repository.AuthorManager.
 func (a *authorRepository[S]) Create(ctx context.Context, author *model.Author) error {
+	const errorCaller string = "create author"
 	if author.FamilyName == "" {
-		return fmt.Errorf("author family name cannot be empty")
+		return repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: author family name cannot be empty", errorCaller),
+		}
 	}
 
 	tx, err := a.db.Begin(ctx)
@@ -141,27 +151,76 @@ func (a *authorRepository[S]) Create(ctx context.Context, author *model.Author)
 		}
 	}
 
-	for _, extID := range author.ExtIDs {
-		_, err = tx.Exec(ctx,
-			`INSERT INTO author_identifiers (author_id, type, identifier)
-			 VALUES ($1, $2, $3)
-			 ON CONFLICT (author_id, type, identifier) DO NOTHING`,
-			author.ID, extID.Type, extID.ID,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert author external identifier: %w", err)
-		}
+	if err = a.setIdentifiers(ctx, tx, author.ID, author.ExtIDs); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
 	}
 
 	return tx.Commit(ctx)
 }
 
+// Make the author's external identifiers exactly `ids`. An identifier
+// can only ever point at one author, so one already held by someone
+// else is a conflict; if they are the same person they should be
+// merged instead.
+func (a *authorRepository[S]) setIdentifiers(ctx context.Context, tx pgx.Tx, authorID uuid.UUID, ids []model.AuthorIDs) error {
+	types := make([]string, len(ids))
+	values := make([]string, len(ids))
+	for i, v := range ids {
+		if err := v.Validate(); err != nil {
+			return repository.Err{Code: repository.ErrInvalidInput, Err: err}
+		}
+		types[i], values[i] = v.Type, v.ID
+	}
+
+	var taken []string
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(array_agg(RTRIM(i.type) || ':' || i.identifier), '{}')
+		 FROM author_identifiers i
+		 JOIN unnest($2::TEXT[], $3::TEXT[]) AS n(type, identifier)
+			 ON i.type = n.type AND i.identifier = n.identifier
+		 WHERE i.author_id <> $1`,
+		authorID, types, values,
+	).Scan(&taken); err != nil {
+		return err
+	} else if len(taken) > 0 {
+		return repository.Err{
+			Code: repository.ErrConflict,
+			Err:  fmt.Errorf("identifiers %v belong to another author", taken),
+		}
+	}
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM author_identifiers i
+		 WHERE i.author_id = $1 AND NOT EXISTS (
+			 SELECT 1 FROM unnest($2::TEXT[], $3::TEXT[]) AS n(type, identifier)
+			 WHERE i.type = n.type AND i.identifier = n.identifier
+		 )`,
+		authorID, types, values,
+	); err != nil {
+		return fmt.Errorf("remove identifiers: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO author_identifiers (author_id, type, identifier)
+		 SELECT $1, n.type, n.identifier
+		 FROM unnest($2::TEXT[], $3::TEXT[]) AS n(type, identifier)
+		 ON CONFLICT (type, identifier) DO NOTHING`,
+		authorID, types, values,
+	); err != nil {
+		return fmt.Errorf("add identifiers: %w", err)
+	}
+	return nil
+}
+
 func (a *authorRepository[S]) ExistsByName(ctx context.Context, name string) (*model.Author, bool, error) {
 	const errorCaller string = "get author by name"
 	var author *model.Author
+	// Names of authors which have since been merged away still count,
+	// otherwise the scraper would just recreate the duplicate.
 	rows, err := a.db.Query(ctx,
 		a.queryString(
-			`TRIM((given_name || ' ' || family_name)) = $1`,
+			authorFullName+` = $1 OR a.id IN (
+				 SELECT r.new_id FROM author_redirects r WHERE r.old_name = $1
+			 )`,
 			false,
 		),
 		name,
@@ -189,30 +248,205 @@ func (a *authorRepository[S]) ExistsByName(ctx context.Context, name string) (*m
 	return author, author != nil && author.ID != uuid.Nil, rows.Err()
 }
 
-// Update implements repository.BookManager.
+// ExistsByExtID implements repository.AuthorManager.
+func (a *authorRepository[S]) ExistsByExtID(ctx context.Context, id model.AuthorIDs) (*model.Author, bool, error) {
+	const errorCaller string = "get author by external id"
+	rows, err := a.db.Query(ctx,
+		a.queryString(
+			`a.id = (
+				 SELECT x.author_id FROM author_identifiers x
+				 WHERE x.type = $1 AND x.identifier = $2
+			 )`,
+			false,
+		),
+		id.Type, id.ID,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		return nil, false, nil
+	}
+	author, _, err := a.rowsParse(rows, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return author, true, nil
+}
+
+// Update implements repository.AuthorManager.
 func (a *authorRepository[S]) Update(ctx context.Context, to *model.Author) (*model.Author, error) {
-	panic("unimplemented")
+	const errorCaller string = "update author"
+	if to.FamilyName == "" {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: author family name cannot be empty", errorCaller),
+		}
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if tag, err := tx.Exec(ctx,
+		`UPDATE authors SET (
+			 given_name,
+			 family_name,
+			 bio
+		 ) = (
+			 NULLIF($2, ''), $3, NULLIF($4, '')
+		 ) WHERE id = $1`,
+		to.ID, to.GivenName, to.FamilyName, to.Bio,
+	); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no author with ID `%v`", errorCaller, to.ID),
+		}
+	}
+	if err = a.setIdentifiers(ctx, tx, to.ID, to.ExtIDs); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return a.GetByID(ctx, to.ID)
 }
 
 // Delete implements repository.AuthorManager.
+//
+// Authors who are still credited on a book cannot be deleted, they
+// should be merged into another author instead.
 func (a *authorRepository[S]) Delete(ctx context.Context, id uuid.UUID) error {
+	const errorCaller string = "delete author"
 	tx, err := a.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("%v: %w", errorCaller, err)
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx,
+	var books int
+	if err = tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM books_authors WHERE author_id = $1`,
+		id,
+	).Scan(&books); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if books > 0 {
+		return repository.Err{
+			Code: repository.ErrConflict,
+			Err:  fmt.Errorf("%v: author is credited on %d books", errorCaller, books),
+		}
+	}
+
+	if tag, err := tx.Exec(ctx,
 		`DELETE FROM authors a
 		 WHERE a.id = $1`,
 		id,
 	); err != nil {
-		return fmt.Errorf("delete author: %w", err)
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no author with ID `%v`", errorCaller, id),
+		}
 	}
 
 	return tx.Commit(ctx)
 }
 
+// Merge implements repository.AuthorManager.
+func (a *authorRepository[S]) Merge(ctx context.Context, into uuid.UUID, from ...uuid.UUID) (*model.Author, error) {
+	const errorCaller string = "merge authors"
+	from = slices.DeleteFunc(slices.Clone(from), func(id uuid.UUID) bool {
+		return id == into
+	})
+	slices.SortFunc(from, func(x, y uuid.UUID) int {
+		return bytes.Compare(x[:], y[:])
+	})
+	from = slices.Compact(from)
+	if len(from) == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: no authors to merge into `%v`", errorCaller, into),
+		}
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock everyone involved, so nobody can credit a book to an author
+	// we are halfway through deleting
+	ids := append(uuid.UUIDs{into}, from...)
+	var locked int
+	if err = tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM (
+			 SELECT id FROM authors WHERE id = ANY($1) FOR UPDATE
+		 ) l`,
+		ids,
+	).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	} else if locked != len(ids) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: only found %d of %d authors", errorCaller, locked, len(ids)),
+		}
+	}
+
+	statements := []struct {
+		what string
+		sql  string
+	}{{
+		"repoint books",
+		`INSERT INTO books_authors (book_id, author_id, role, billing)
+		 SELECT ba.book_id, $1, ba.role, ba.billing FROM books_authors ba
+		 WHERE ba.author_id = ANY($2)
+		 ON CONFLICT (book_id, author_id, role) DO NOTHING`,
+	}, {
+		"remove old credits",
+		`DELETE FROM books_authors WHERE author_id = ANY($2)`,
+	}, {
+		"move identifiers",
+		`UPDATE author_identifiers SET author_id = $1
+		 WHERE author_id = ANY($2)`,
+	}, {
+		// Anything that was redirecting to a duplicate now needs to
+		// skip straight to the canonical author
+		"repoint redirects",
+		`UPDATE author_redirects SET new_id = $1
+		 WHERE new_id = ANY($2)`,
+	}, {
+		"add redirects",
+		`INSERT INTO author_redirects (old_id, new_id, old_name)
+		 SELECT a.id, $1, ` + authorFullName + `
+		 FROM authors a WHERE a.id = ANY($2)`,
+	}, {
+		"remove duplicates",
+		`DELETE FROM authors WHERE id = ANY($2)`,
+	}}
+	for _, stmt := range statements {
+		if _, err = tx.Exec(ctx, stmt.sql, into, uuid.UUIDs(from)); err != nil {
+			return nil, fmt.Errorf("%v: %v: %w", errorCaller, stmt.what, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return a.GetByID(ctx, into)
+}
+
 // GetByID implements repository.AuthorManager.
 func (a *authorRepository[S]) GetByID(ctx context.Context, id uuid.UUID) (*model.Author, error) {
 	const errorCaller string = "author by id"
@@ -241,29 +475,75 @@ func (a *authorRepository[S]) GetByID(ctx context.Context, id uuid.UUID) (*model
 		if rows.Err() != nil {
 			return nil, fmt.Errorf("%v: %w", errorCaller, rows.Err())
 		}
-		return nil, repository.Err{Code: repository.ErrNotFound, Err: rows.Err()}
+		rows.Close()
+		// The author may have been merged into another, in which case
+		// we hand back the one it was merged into.
+		var newID uuid.UUID
+		if err = a.db.QueryRow(ctx,
+			`SELECT new_id FROM author_redirects WHERE old_id = $1`,
+			id,
+		).Scan(&newID); errors.Is(err, pgx.ErrNoRows) {
+			return nil, repository.Err{
+				Code: repository.ErrNotFound,
+				Err:  fmt.Errorf("%v: no author with ID `%v`", errorCaller, id),
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		return a.GetByID(ctx, newID)
 	}
 	return author, rows.Err()
 }
 
 // Search implements repository.AuthorManager.
-func (a *authorRepository[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
+func (a *authorRepository[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
+	return a.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.AuthorFilter{})
+}
+
+// SearchFiltered implements repository.AuthorManager.
+func (a *authorRepository[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.AuthorFilter) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
 	const errorCaller string = "author search"
 	var resultsT []repository.SearchResult[model.Author]
 	var resultsASI []repository.AnyScoreItemer
 
-	qStr := strings.Join(query, " ")
-	rows, err := a.db.Query(ctx,
-		a.queryString(`family_name @@@ $1 OR given_name @@@ $1
-			 GROUP BY a.id
-			 ORDER BY paradedb.score(id) DESC, family_name DESC
-			 LIMIT $2 OFFSET $3`,
+	search, args, ok := authorQueryTarget.where(query, nil)
+	if !ok {
+		return resultsT, resultsASI, nil
+	}
+	where := "true"
+	if !filter.IsZero() {
+		var filters []string
+		filters, args = bookFilterQueries(filter.Books, args)
+		books := "true"
+		if len(filters) > 0 {
+			books = fmt.Sprintf("b.id @@@ paradedb.boolean(must => ARRAY[%v])", strings.Join(filters, ", "))
+		}
+		roles := "true"
+		if len(filter.Roles) > 0 {
+			r := make([]string, len(filter.Roles))
+			for i, role := range filter.Roles {
+				r[i] = string(role)
+			}
+			args = append(args, r)
+			roles = fmt.Sprintf("ba.role = ANY($%d::TEXT[])", len(args))
+		}
+		where = fmt.Sprintf(`a.id IN (
+			 SELECT ba.author_id
+			 FROM books_authors ba
+			 JOIN books b ON b.id = ba.book_id
+			 WHERE %v AND %v
+		 )`, roles, books)
+	}
+	sql, args := searchPage(
+		a.queryString(fmt.Sprintf(`%v
+			 AND %v
+			 GROUP BY a.id`, search, where),
 			true,
 		),
-		qStr,
-		limit,
-		offset,
+		page,
+		args,
 	)
+	rows, err := a.db.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
@@ -280,9 +560,15 @@ func (a *authorRepository[S]) Search(ctx context.Context, offset int, limit int,
 			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
 
+		// Names are short enough to mark up ourselves
 		r := repository.SearchResult[model.Author]{
 			Item:  u,
+			ID:    u.ID,
 			Score: s,
+			Highlights: highlights(map[string]string{
+				"given_name":  query.Mark(u.GivenName, repository.AuthorQueryFields...),
+				"family_name": query.Mark(u.FamilyName, repository.AuthorQueryFields...),
+			}),
 		}
 		resultsT = append(resultsT, r)
 		resultsASI = append(resultsASI, r)
@@ -290,3 +576,13 @@ func (a *authorRepository[S]) Search(ctx context.Context, offset int, limit int,
 
 	return resultsT, resultsASI, rows.Err()
 }
+
+// Suggest implements repository.AuthorManager.
+func (a *authorRepository[S]) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	const errorCaller string = "suggest authors"
+	s, err := suggest(ctx, a.db, "a.id", authorFullName, authorFullName, "authors a", prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return s, nil
+}
@@ -3,11 +3,18 @@ package db
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
@@ -16,81 +23,669 @@ import (
 
 type blobRepository struct {
 	db *pgxpool.Pool
+	// Where new blobs' content is put
+	write repository.BlobStore
+	// Every store content may be in, by name
+	stores map[string]repository.BlobStore
 }
 
-func newBlobRepository(psql *postgres) repository.BlobManager {
-	return &blobRepository{db: psql.db}
+// Where blobs' content is kept
+type BlobOptions struct {
+	// Stores other than Postgres itself which blobs' content may be in
+	Stores []repository.BlobStore
+	// The name of the store new blobs' content is put in. Postgres, if
+	// empty.
+	Write string
+}
+
+func newBlobRepository(psql *postgres, opts BlobOptions) (*blobRepository, error) {
+	b := &blobRepository{
+		db:     psql.db,
+		stores: map[string]repository.BlobStore{PostgresBlobStore: &postgresBlobStore{psql.db}},
+	}
+	for _, s := range opts.Stores {
+		b.stores[s.Name()] = s
+	}
+	if opts.Write == "" {
+		opts.Write = PostgresBlobStore
+	}
+	var err error
+	if b.write, err = b.store(opts.Write); err != nil {
+		return nil, err
+	}
+	return b, nil
 }
 
 // Useful to check that a type implements an interface
-var _ repository.BlobManager = (*blobRepository)(nil)
+var (
+	_ repository.BlobManager      = (*blobRepository)(nil)
+	_ repository.BlobMigrator     = (*blobRepository)(nil)
+	_ repository.BlobDeduplicator = (*blobRepository)(nil)
+	_ repository.BlobCollector    = (*blobRepository)(nil)
+)
 
-// Create implements repository.BlobManager.
+func (b *blobRepository) store(name string) (repository.BlobStore, error) {
+	s, ok := b.stores[name]
+	if !ok {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("blob store `%v` isn't configured", name),
+		}
+	}
+	return s, nil
+}
+
+// Hold a lock on some content until the transaction ends. Content is
+// shared by every blob with the same checksum, so this keeps it from
+// being deleted from a store while a blob is being made with it.
+func lockContent(ctx context.Context, tx pgx.Tx, sum string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, sum)
+	return err
+}
+
+// Create implements repository.BlobManager. The blob's checksum is
+// added to its metadata. If there is already a blob with the same
+// content, `t` is made into that one instead.
 func (b *blobRepository) Create(ctx context.Context, t *model.Blob) error {
-	tx, err := b.db.Begin(ctx)
+	const errorCaller string = "create blob"
+	data, err := io.ReadAll(t.Content)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return fmt.Errorf("%v: read blob content: %w", errorCaller, err)
 	}
-	defer tx.Rollback(ctx)
+	s := sha256.Sum256(data)
+	sum := hex.EncodeToString(s[:])
+	metadata := maps.Clone(t.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata[model.BlobMetaSHA256] = sum
 
-	data, err := io.ReadAll(t.Content)
+	tx, err := b.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("read blob content: %w", err)
+		return fmt.Errorf("%v: begin transaction: %w", errorCaller, err)
 	}
+	defer tx.Rollback(ctx)
 
+	if err := lockContent(ctx, tx, sum); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var (
+		existing         uuid.UUID
+		existingMetadata map[string]string
+	)
+	// Being created again keeps the blob from being collected before
+	// it's used
+	err = tx.QueryRow(ctx,
+		`UPDATE blobs SET claimed_at = NOW()
+		 WHERE id = (
+			 SELECT id FROM blobs
+			 WHERE sha256 = $1
+			 ORDER BY created_at, id
+			 LIMIT 1
+		 )
+		 RETURNING id, metadata`,
+		sum,
+	).Scan(&existing, &existingMetadata)
+	if err == nil {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		t.ID, t.Metadata = existing, existingMetadata
+		return nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := b.write.Put(ctx, sum, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
 	if _, err = tx.Exec(ctx,
-		`INSERT INTO blobs (id, metadata, value)
-		 VALUES ($1, $2, $3)`,
-		t.ID, t.Metadata, data,
+		`INSERT INTO blobs (id, metadata, sha256, store)
+		 VALUES ($1, $2, $3, $4)`,
+		t.ID, metadata, sum, b.write.Name(),
 	); err != nil {
-		return fmt.Errorf("insert into blobs: %w", err)
+		err = fmt.Errorf("insert into blobs: %w", err)
+	} else {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		// Nothing would ever find the content to remove it otherwise
+		tx.Rollback(ctx)
+		if derr := b.discardContent(ctx, sum, b.write.Name()); derr != nil {
+			err = errors.Join(err, fmt.Errorf("discard content: %w", derr))
+		}
+		return fmt.Errorf("%v: %w", errorCaller, err)
 	}
+	t.Metadata = metadata
+	return nil
+}
 
-	return tx.Commit(ctx)
+// Delete content from a store if no blob has it there, on its own. It
+// is only called once whatever stopped using the content is committed,
+// as stores can't take a deletion back if that fails. The transaction
+// which changed the blobs must be over, or this waits on its lock
+// forever. It goes ahead even if ctx is done, as it is cleaning up
+// after whatever was.
+func (b *blobRepository) discardContent(ctx context.Context, sum, store string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), discardTimeout)
+	defer cancel()
+	s, err := b.store(store)
+	if err != nil {
+		return err
+	}
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	// Holding the lock keeps a blob with the content from being created
+	// while it is deleted
+	if err := lockContent(ctx, tx, sum); err != nil {
+		return err
+	}
+	var used bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM blobs WHERE sha256 = $1 AND store = $2)`,
+		sum, store,
+	).Scan(&used); err != nil {
+		return err
+	} else if used {
+		return nil
+	}
+	return s.Delete(ctx, sum)
 }
 
-// Delete implements repository.BlobManager.
+// How long discarding content may take
+const discardTimeout time.Duration = 10 * time.Second
+
+// Delete implements repository.BlobManager. The blob's content goes
+// too, unless another blob has the same.
 func (b *blobRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	const errorCaller string = "delete blob"
 	tx, err := b.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return fmt.Errorf("%v: begin transaction: %w", errorCaller, err)
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx,
+	var sum, store string
+	err = tx.QueryRow(ctx,
 		`DELETE FROM blobs
-		 WHERE id = $1`,
+		 WHERE id = $1
+		 RETURNING sha256, store`,
 		id,
-	); err != nil {
-		return fmt.Errorf("delete blob: %w", err)
+	).Scan(&sum, &store)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return repository.Err{
+			Code: repository.ErrConflict,
+			Err:  fmt.Errorf("%v: blob `%v` is still referenced", errorCaller, id),
+		}
+	} else if errors.Is(err, pgx.ErrNoRows) {
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no blob with ID `%v`", errorCaller, id),
+		}
+	} else if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := b.discardContent(ctx, sum, store); err != nil {
+		return fmt.Errorf("%v: discard content: %w", errorCaller, err)
 	}
+	return nil
+}
 
-	return tx.Commit(ctx)
+// Postgres' code for a row still being referenced by a foreign key
+const foreignKeyViolation string = "23503"
+
+// References implements repository.BlobManager. Most blobs are used
+// by nothing or one thing, so what uses it is only looked for when its
+// reference count says there is something.
+func (b *blobRepository) References(ctx context.Context, id uuid.UUID) ([]model.BlobReference, error) {
+	const errorCaller string = "blob references"
+	var n int
+	err := b.db.QueryRow(ctx, `SELECT refs FROM blobs WHERE id = $1`, id).Scan(&n)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && n == 0) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	rows, err := b.db.Query(ctx,
+		`SELECT 'cover', id FROM books WHERE cover_image = $1
+		 UNION ALL
+		 SELECT 'thumbnail', id FROM books WHERE thumbnail_image = $1
+		 UNION ALL
+		 SELECT 'avatar', id FROM users WHERE avatar = $1`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	var refs []model.BlobReference
+	for rows.Next() {
+		var r model.BlobReference
+		if err := rows.Scan(&r.Use, &r.ID); err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
 }
 
-// GetByID implements repository.BlobManager.
-//
-// This is mostly just a wrapper for the custom cache system
-// implemented in the database itself.
-func (b *blobRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Blob, error) {
-	var blob model.Blob
-	var metadata []byte
-	var data []byte
+// Detach implements repository.BlobManager.
+func (b *blobRepository) Detach(ctx context.Context, id uuid.UUID) ([]model.BlobReference, error) {
+	const errorCaller string = "detach blob"
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: begin transaction: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
 
+	var refs []model.BlobReference
+	for _, u := range []struct {
+		use   model.BlobUse
+		query string
+	}{
+		{model.BlobUseCover, `UPDATE books SET cover_image = NULL WHERE cover_image = $1 RETURNING id`},
+		{model.BlobUseThumbnail, `UPDATE books SET thumbnail_image = NULL WHERE thumbnail_image = $1 RETURNING id`},
+		{model.BlobUseAvatar, `UPDATE users SET avatar = NULL WHERE avatar = $1 RETURNING id`},
+	} {
+		rows, err := tx.Query(ctx, u.query, id)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		for rows.Next() {
+			r := model.BlobReference{Use: u.use}
+			if err := rows.Scan(&r.ID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%v: %w", errorCaller, err)
+			}
+			refs = append(refs, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return refs, nil
+}
+
+// GetByID implements repository.BlobManager. The blob's content comes
+// from whichever store it is in, and must be closed.
+func (b *blobRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Blob, error) {
+	const errorCaller string = "get blob"
+	blob := model.Blob{ID: id}
+	var sum, store string
 	if err := b.db.QueryRow(ctx,
-		`SELECT id, metadata, value FROM get_blob($1)`,
+		`SELECT metadata, sha256, store FROM blobs WHERE id = $1`,
 		id,
-	).Scan(&blob.ID, &metadata, &data); err != nil {
-		return nil, fmt.Errorf("receive blob: %w", err)
+	).Scan(&blob.Metadata, &sum, &store); errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no blob with ID `%v`", errorCaller, id),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
-	blob.Content = bytes.NewReader(data)
-	if err := json.Unmarshal(metadata, &blob.Metadata); err != nil {
-		return nil, fmt.Errorf("receive blob: %w", err)
+	s, err := b.store(store)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if blob.Content, err = s.Get(ctx, sum); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
-
 	return &blob, nil
 }
 
+// MigrateBlobs implements repository.BlobMigrator. Each blob's content
+// is copied to the new store before the blob is pointed at it, and only
+// deleted from the old one after, so it can be read throughout.
+func (b *blobRepository) MigrateBlobs(ctx context.Context, from, to string, limit int) (int, error) {
+	const errorCaller string = "migrate blobs"
+	src, err := b.store(from)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	dst, err := b.store(to)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	} else if from == to {
+		return 0, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: blobs are already in `%v`", errorCaller, to),
+		}
+	}
+
+	rows, err := b.db.Query(ctx,
+		`SELECT DISTINCT sha256 FROM blobs WHERE store = $1 LIMIT $2`,
+		from, limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var sums []string
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		sums = append(sums, sum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	moved := 0
+	for _, sum := range sums {
+		n, err := b.migrateContent(ctx, sum, src, dst)
+		if err != nil {
+			return moved, fmt.Errorf("%v: `%v`: %w", errorCaller, sum, err)
+		}
+		moved += n
+	}
+	return moved, nil
+}
+
+// Move one piece of content between stores, returning how many blobs
+// now have it in the new one
+func (b *blobRepository) migrateContent(ctx context.Context, sum string, src, dst repository.BlobStore) (int, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	if err := lockContent(ctx, tx, sum); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE blobs SET store = $3 WHERE sha256 = $1 AND store = $2`,
+		sum, src.Name(), dst.Name(),
+	)
+	if err != nil {
+		return 0, err
+	} else if tag.RowsAffected() == 0 {
+		// Deleted or moved since
+		return 0, nil
+	}
+	r, err := src.Get(ctx, sum)
+	if err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return 0, err
+	}
+	if err := dst.Put(ctx, sum, bytes.NewReader(data), int64(len(data))); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		// The blobs still have the old store's copy
+		tx.Rollback(ctx)
+		if derr := b.discardContent(ctx, sum, dst.Name()); derr != nil {
+			err = errors.Join(err, fmt.Errorf("discard content: %w", derr))
+		}
+		return 0, err
+	}
+
+	// New blobs could have been put in the old store since, in which
+	// case they still need its copy
+	if err := b.discardContent(ctx, sum, src.Name()); err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// CollectBlobs implements repository.BlobCollector. Unused blobs are
+// the ones whose reference count is zero, so nothing else need be
+// read to find them. Each blob is swept in its own transaction, so one
+// failing doesn't stop the others.
+func (b *blobRepository) CollectBlobs(ctx context.Context, grace time.Duration, dryRun bool) (*model.BlobCollection, error) {
+	const errorCaller string = "collect blobs"
+	rows, err := b.db.Query(ctx,
+		`SELECT id, sha256, COALESCE(metadata->>'size', ''), created_at
+		 FROM blobs
+		 WHERE refs = 0 AND claimed_at < NOW() - make_interval(secs => $1)
+		 ORDER BY created_at, id`,
+		grace.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	type orphan struct {
+		model.OrphanedBlob
+		sum string
+	}
+	var orphans []orphan
+	for rows.Next() {
+		var (
+			o    orphan
+			size string
+		)
+		if err := rows.Scan(&o.ID, &o.sum, &size, &o.Created); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		// Scraped blobs' sizes are whatever the server said, if anything
+		o.Size, _ = strconv.ParseInt(size, 10, 64)
+		orphans = append(orphans, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	c := model.BlobCollection{DryRun: dryRun, Orphans: []model.OrphanedBlob{}}
+	var errs []error
+	for _, o := range orphans {
+		if !dryRun {
+			swept, err := b.sweepBlob(ctx, o.ID, o.sum, grace)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%v: blob `%v`: %w", errorCaller, o.ID, err))
+			}
+			if !swept {
+				continue
+			}
+		}
+		c.Orphans = append(c.Orphans, o.OrphanedBlob)
+		c.Size += o.Size
+	}
+	return &c, errors.Join(errs...)
+}
+
+// Delete a blob found unused, unless it has been used or created again
+// since. Whether it was deleted is returned, even if its content then
+// couldn't be.
+func (b *blobRepository) sweepBlob(ctx context.Context, id uuid.UUID, sum string, grace time.Duration) (bool, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	// Creating a blob with the same content claims it under this lock
+	if err := lockContent(ctx, tx, sum); err != nil {
+		return false, err
+	}
+	var store string
+	err = tx.QueryRow(ctx,
+		`DELETE FROM blobs
+		 WHERE id = $1 AND refs = 0
+			 AND claimed_at < NOW() - make_interval(secs => $2)
+		 RETURNING store`,
+		id, grace.Seconds(),
+	).Scan(&store)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		// Used, but miscounted. RecountBlobRefs will fix it.
+		return false, nil
+	} else if errors.Is(err, pgx.ErrNoRows) {
+		// Used, claimed again or deleted since
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, b.discardContent(ctx, sum, store)
+}
+
+// DedupeBlobs implements repository.BlobDeduplicator. Of each set of
+// blobs with the same content, the oldest is kept.
+func (b *blobRepository) DedupeBlobs(ctx context.Context, limit int) (int, error) {
+	const errorCaller string = "dedupe blobs"
+	rows, err := b.db.Query(ctx,
+		`SELECT sha256 FROM blobs
+		 GROUP BY sha256
+		 HAVING count(*) > 1
+		 LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var sums []string
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		sums = append(sums, sum)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	merged := 0
+	for _, sum := range sums {
+		n, err := b.mergeContent(ctx, sum)
+		merged += n
+		if err != nil {
+			return merged, fmt.Errorf("%v: `%v`: %w", errorCaller, sum, err)
+		}
+	}
+	return merged, nil
+}
+
+// Merge every blob with some content into the oldest, returning how
+// many were merged away
+func (b *blobRepository) mergeContent(ctx context.Context, sum string) (int, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+	if err := lockContent(ctx, tx, sum); err != nil {
+		return 0, err
+	}
+	var keep uuid.UUID
+	if err := tx.QueryRow(ctx,
+		`SELECT id FROM blobs
+		 WHERE sha256 = $1
+		 ORDER BY created_at, id
+		 LIMIT 1`,
+		sum,
+	).Scan(&keep); errors.Is(err, pgx.ErrNoRows) {
+		// Deleted since
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	for _, query := range []string{
+		`UPDATE books SET cover_image = $1
+		 WHERE cover_image IN (SELECT id FROM blobs WHERE sha256 = $2 AND id <> $1)`,
+		`UPDATE books SET thumbnail_image = $1
+		 WHERE thumbnail_image IN (SELECT id FROM blobs WHERE sha256 = $2 AND id <> $1)`,
+		`UPDATE users SET avatar = $1
+		 WHERE avatar IN (SELECT id FROM blobs WHERE sha256 = $2 AND id <> $1)`,
+	} {
+		if _, err := tx.Exec(ctx, query, keep, sum); err != nil {
+			return 0, err
+		}
+	}
+	rows, err := tx.Query(ctx,
+		`DELETE FROM blobs
+		 WHERE sha256 = $2 AND id <> $1
+		 RETURNING store`,
+		keep, sum,
+	)
+	if err != nil {
+		return 0, err
+	}
+	merged, stores := 0, map[string]bool{}
+	for rows.Next() {
+		var store string
+		if err := rows.Scan(&store); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		merged++
+		stores[store] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	// The merged blobs' content may have been in other stores than
+	// the kept one's
+	var errs []error
+	for store := range stores {
+		if err := b.discardContent(ctx, sum, store); err != nil {
+			errs = append(errs, fmt.Errorf("discard content in %v: %w", store, err))
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// RecountBlobRefs implements repository.BlobDeduplicator. Books and
+// users can't be changed while they are counted.
+func (b *blobRepository) RecountBlobRefs(ctx context.Context) (int, error) {
+	const errorCaller string = "recount blob references"
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%v: begin transaction: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `LOCK TABLE books, users IN SHARE MODE`); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE blobs b
+		 SET refs = c.refs
+		 FROM (
+			 SELECT bl.id,
+				 (SELECT count(*) FROM books WHERE cover_image = bl.id)
+				 + (SELECT count(*) FROM books WHERE thumbnail_image = bl.id)
+				 + (SELECT count(*) FROM users WHERE avatar = bl.id) AS refs
+			 FROM blobs bl
+		 ) c
+		 WHERE b.id = c.id AND b.refs <> c.refs`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
 // Necessary to implement repository.BlobManager.
 // This should never be called. If it does it just creates a new blob,
 // blobs are considered immutable.
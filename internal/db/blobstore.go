@@ -0,0 +1,86 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// The name of the store which keeps blobs' content in Postgres itself
+const PostgresBlobStore string = "postgres"
+
+// Keeps blobs' content in Postgres itself. This needs nothing else set
+// up, but makes the database as big as every image in it.
+type postgresBlobStore struct {
+	db *pgxpool.Pool
+}
+
+var _ repository.BlobStore = (*postgresBlobStore)(nil)
+
+func (s *postgresBlobStore) Name() string {
+	return PostgresBlobStore
+}
+
+// Put implements repository.BlobStore.
+func (s *postgresBlobStore) Put(ctx context.Context, sum string, content io.Reader, size int64) error {
+	const errorCaller string = "put blob content"
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if _, err := s.db.Exec(ctx,
+		`INSERT INTO blob_values (sha256, value)
+		 VALUES ($1, $2)
+		 ON CONFLICT (sha256) DO NOTHING`,
+		sum, data,
+	); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return nil
+}
+
+// Content which is already all in memory. It can still be seeked
+// through, which io.NopCloser would hide.
+type bytesContent struct {
+	*bytes.Reader
+}
+
+func (bytesContent) Close() error {
+	return nil
+}
+
+// Get implements repository.BlobStore.
+func (s *postgresBlobStore) Get(ctx context.Context, sum string) (io.ReadCloser, error) {
+	const errorCaller string = "get blob content"
+	var data []byte
+	if err := s.db.QueryRow(ctx,
+		`SELECT value FROM blob_values WHERE sha256 = $1`,
+		sum,
+	).Scan(&data); errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no content `%v`", errorCaller, sum),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return bytesContent{bytes.NewReader(data)}, nil
+}
+
+// Delete implements repository.BlobStore.
+func (s *postgresBlobStore) Delete(ctx context.Context, sum string) error {
+	if _, err := s.db.Exec(ctx,
+		`DELETE FROM blob_values WHERE sha256 = $1`,
+		sum,
+	); err != nil {
+		return fmt.Errorf("delete blob content: %w", err)
+	}
+	return nil
+}
@@ -1,16 +1,21 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
@@ -28,8 +33,246 @@ func newBookRepository(psql *postgres) repository.BookManager[string] {
 	return &bookRepository[string]{db: psql.db}
 }
 
-func (b *bookRepository[S]) Author(ctx context.Context, authorID uuid.UUID) ([]*model.Book, error) {
-	panic("unimplemented")
+// Where in an author's bibliography a page left off. The sort order
+// is kept alongside the position so a cursor can't be replayed
+// against a different ordering.
+type bibliographyCursor struct {
+	Sort       repository.BookSort `json:"s"`
+	Descending bool                `json:"d"`
+	Published  civil.Date          `json:"p"`
+	Rating     float32             `json:"r"`
+	ID         uuid.UUID           `json:"id"`
+}
+
+// Author implements repository.BookManager.
+func (b *bookRepository[S]) Author(ctx context.Context, authorID uuid.UUID, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	const errorCaller string = "author books"
+	books, next, err := b.page(ctx,
+		`v.id IN (SELECT book_id FROM books_authors WHERE author_id = $1)`,
+		authorID, opts,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return books, next, nil
+}
+
+// Subject implements repository.BookManager.
+func (b *bookRepository[S]) Subject(ctx context.Context, subjectID uuid.UUID, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	const errorCaller string = "subject books"
+	books, next, err := b.page(ctx,
+		fmt.Sprintf(`v.id IN (
+			 SELECT book_id FROM books_subjects WHERE subject_id IN (%v)
+		 )`, subjectTree("ARRAY[$1::UUID]")),
+		subjectID, opts,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return books, next, nil
+}
+
+// A subquery for the IDs of the given subjects (an array expression)
+// and all of their descendants
+func subjectTree(ids string) string {
+	return fmt.Sprintf(`WITH RECURSIVE t (id) AS (
+			 SELECT unnest(%v)
+		 UNION
+			 SELECT s.id FROM subjects s JOIN t ON s.parent_id = t.id
+		 )
+		 SELECT id FROM t`, ids)
+}
+
+// The ParadeDB queries on i_books_search a book must match for a
+// filter, with parameters numbered on from those already in `args`
+func bookFilterQueries(filter repository.BookFilter, args []any) ([]string, []any) {
+	var queries []string
+	param := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	// Any of some values of a field which is matched whole
+	anyOf := func(field string, values []string) string {
+		terms := make([]string, len(values))
+		for i, v := range values {
+			terms[i] = fmt.Sprintf("paradedb.term(field => '%v', value => %v::TEXT)", field, param(v))
+		}
+		return fmt.Sprintf("paradedb.term_set(terms => ARRAY[%v])", strings.Join(terms, ", "))
+	}
+	if len(filter.Subjects) > 0 {
+		// A book's subject_ids has its subjects' ancestors too
+		queries = append(queries, anyOf("subject_ids", filter.Subjects.Strings()))
+	}
+	if len(filter.Authors) > 0 {
+		queries = append(queries, anyOf("author_ids", filter.Authors.Strings()))
+	}
+	if len(filter.Languages) > 0 {
+		langs := make([]string, len(filter.Languages))
+		for i, l := range filter.Languages {
+			langs[i] = strings.ToLower(l)
+		}
+		queries = append(queries, anyOf("language_code", langs))
+	}
+	// A book's date only has to overlap the range, so its last day must
+	// be after the start and its first before the end
+	if !filter.PublishedFrom.IsZero() {
+		queries = append(queries, fmt.Sprintf(
+			"paradedb.range(field => 'published_last', range => daterange(%v::DATE, NULL, '[]'))",
+			param(pgtype.Date{Time: filter.PublishedFrom.Start().In(time.UTC), Valid: true}),
+		))
+	}
+	if !filter.PublishedTo.IsZero() {
+		queries = append(queries, fmt.Sprintf(
+			"paradedb.range(field => 'published_first', range => daterange(NULL, %v::DATE, '[]'))",
+			param(pgtype.Date{Time: filter.PublishedTo.End().In(time.UTC), Valid: true}),
+		))
+	}
+	if filter.MinRating != nil || filter.MaxRating != nil {
+		queries = append(queries, fmt.Sprintf(
+			"paradedb.range(field => 'rating', range => numrange(%v::NUMERIC, %v::NUMERIC, '[]'))",
+			param(ratingBound(filter.MinRating)), param(ratingBound(filter.MaxRating)),
+		))
+	}
+	return queries, args
+}
+
+// A rating exactly as the index has it, which is widened to a double,
+// so that a bound on a rating includes that rating
+func ratingBound(r *float32) *string {
+	if r == nil {
+		return nil
+	}
+	s := strconv.FormatFloat(float64(*r), 'g', -1, 64)
+	return &s
+}
+
+// The ParadeDB query on i_books_search for the books matching a query
+// and filter, with parameters numbered on from those already in
+// `args`. False is returned if no book can match.
+func bookSearchQuery(query repository.Query, filter repository.BookFilter, args []any) (string, []any, bool) {
+	search, args, ok := bookQueryTarget.query(query, args)
+	if !ok {
+		return "", args, false
+	}
+	filters, args := bookFilterQueries(filter, args)
+	if len(filters) == 0 {
+		return search, args, true
+	}
+	return fmt.Sprintf("paradedb.boolean(must => ARRAY[%v])",
+		strings.Join(append([]string{search}, filters...), ", "),
+	), args, true
+}
+
+// Keyset pagination over the book summaries matching `where`, which
+// is given `id` as $1.
+func (b *bookRepository[S]) page(ctx context.Context, where string, id uuid.UUID, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	const errorCaller string = "page books"
+	limit := repository.PageSize(opts.Limit)
+
+	// Unrated and undated books always go at the end of the list
+	// regardless of direction, so they are sorted as if just outside
+	// [0,1] or the calendar. Partial dates sort by their first day.
+	var key, dir, cmp string
+	unrated := float32(2.0)
+	undated := civil.Date{Year: 9999, Month: time.December, Day: 31}
+	if opts.Descending {
+		dir, cmp, unrated = "DESC", "<", -1.0
+		undated = civil.Date{Year: 1, Month: time.January, Day: 1}
+	} else {
+		dir, cmp = "ASC", ">"
+	}
+	switch opts.Sort {
+	case repository.BookSortPublished, "":
+		opts.Sort = repository.BookSortPublished
+		key = fmt.Sprintf("COALESCE(lower(v.published), '%v'::DATE)", undated)
+	case repository.BookSortRating:
+		key = fmt.Sprintf("COALESCE(v.rating, %v)", unrated)
+	default:
+		return nil, "", repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: unknown sort `%v`", errorCaller, opts.Sort),
+		}
+	}
+
+	args := []any{id, limit + 1}
+	if opts.Cursor != "" {
+		var cur bibliographyCursor
+		if err := repository.DecodeCursor(opts.Cursor, &cur); err != nil {
+			return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+		} else if cur.Sort != opts.Sort || cur.Descending != opts.Descending {
+			return nil, "", repository.Err{
+				Code: repository.ErrInvalidInput,
+				Err:  fmt.Errorf("%v: cursor does not match requested order", errorCaller),
+			}
+		}
+		if opts.Sort == repository.BookSortRating {
+			args = append(args, cur.Rating, cur.ID)
+		} else {
+			args = append(args, cur.Published.In(time.UTC), cur.ID)
+		}
+		where += fmt.Sprintf(" AND (%v, v.id) %v ($3, $4)", key, cmp)
+	}
+
+	rows, err := b.db.Query(ctx,
+		fmt.Sprintf(`SELECT
+			 v.id,
+			 v.title,
+			 COALESCE(v.subtitle, ''),
+			 COALESCE(v.description, ''),
+			 v.published,
+			 v.thumbnail_image,
+			 v.authors,
+			 v.contributors,
+			 v.isbns,
+			 v.rating
+		 FROM v_books_summary v
+		 WHERE %v
+		 ORDER BY %v %v, v.id %v
+		 LIMIT $2`, where, key, dir, dir),
+		args...,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	var books []*model.BookSummary
+	for rows.Next() {
+		o, err := b.summaryParse(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		books = append(books, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	// We asked for one more than the limit to find out if there is
+	// another page without a second query
+	if len(books) <= limit {
+		return books, "", nil
+	}
+	books = books[:limit]
+	last := books[limit-1]
+	cur := bibliographyCursor{
+		Sort:       opts.Sort,
+		Descending: opts.Descending,
+		Published:  undated,
+		Rating:     unrated,
+		ID:         last.ID,
+	}
+	if !last.Published.IsZero() {
+		cur.Published = last.Published.Start()
+	}
+	if last.Rating != nil {
+		cur.Rating = *last.Rating
+	}
+	next, err := repository.EncodeCursor(cur)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return books, next, nil
 }
 
 // Create implements BookRepositoryManager.
@@ -40,12 +283,38 @@ func (b *bookRepository[S]) Create(ctx context.Context, book *model.Book) error
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
+	book.SyncContributors(model.Book{})
+
+	// A new book is the first edition of a new work, unless it says
+	// otherwise
+	if book.Work == uuid.Nil {
+		if err = tx.QueryRow(ctx,
+			`INSERT INTO works (title) VALUES ($1) RETURNING id`,
+			book.Title,
+		).Scan(&book.Work); err != nil {
+			return fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
 
+	// Only scraped books have a source
+	var src model.BookSource
+	if book.Source != nil {
+		src = *book.Source
+	}
 	_, err = tx.Exec(ctx,
-		`INSERT INTO books (id, title, subtitle, description, published)
-		 VALUES ($1, $2, $3, $4, $5)`,
+		`INSERT INTO books (
+			 id, title, subtitle, description, published,
+			 provider, provider_id, cover_url, fetched_at,
+			 work_id, format, publisher, page_count, language
+		 ) VALUES (
+			 $1, $2, $3, $4, $5,
+			 NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9,
+			 $10, NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, 0), NULLIF($14, '')
+		 )`,
 		book.ID, book.Title, book.Subtitle, book.Description,
-		book.Published.In(time.UTC),
+		publishedRange(book.Published),
+		src.Provider, src.ProviderID, src.CoverURL, nullableTime(src.Fetched),
+		book.Work, string(book.Format), book.Publisher, book.Pages, book.Language,
 	)
 	if err != nil {
 		return fmt.Errorf("%v: %w", errorCaller, err)
@@ -85,18 +354,36 @@ func (b *bookRepository[S]) Create(ctx context.Context, book *model.Book) error
 	}
 
 	rows = [][]interface{}{}
-	for _, aID := range book.AuthorIDs {
-		row := []interface{}{book.ID, aID}
+	for i, c := range book.Contributors {
+		row := []interface{}{book.ID, c.ID, string(c.Role), int16(i)}
 		rows = append(rows, row)
 	}
 	if _, err := tx.CopyFrom(ctx,
 		pgx.Identifier{"books_authors"},
-		[]string{"book_id", "author_id"},
+		[]string{"book_id", "author_id", "role", "billing"},
 		pgx.CopyFromRows(rows),
 	); err != nil {
 		return fmt.Errorf("create book: %w", err)
 	}
 
+	rows = [][]interface{}{}
+	for _, sID := range book.Subjects {
+		rows = append(rows, []interface{}{book.ID, sID})
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"books_subjects"},
+		[]string{"book_id", "subject_id"},
+		pgx.CopyFromRows(rows),
+	); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	for field, p := range book.Provenance {
+		if err := b.attribute(ctx, tx, book.ID, []string{field}, p); err != nil {
+			return fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+
 	return tx.Commit(ctx)
 }
 
@@ -131,22 +418,45 @@ func (b bookRepository[S]) queryString(clause string, search bool) string {
 		 COALESCE(b.subtitle, ''),
 		 COALESCE(b.description, ''),
 		 b.published,
-		 COALESCE(
-			 jsonb_agg(DISTINCT a.author_id) FILTER (WHERE a.author_id IS NOT NULL),
+		 (SELECT COALESCE(
+			 jsonb_agg(jsonb_build_object(
+				 'id', ba.author_id,
+				 'role', ba.role
+			 ) ORDER BY ba.billing, ba.author_id),
 			 '[]'::jsonb
-		 ),
+		  ) FROM books_authors ba WHERE ba.book_id = b.id),
 		 COALESCE(
-			 jsonb_agg(jsonb_build_object(
+			 jsonb_agg(DISTINCT jsonb_build_object(
 				 'value', i.isbn,
 				 'type', i.isbn_type
 			 )) FILTER (WHERE i.isbn IS NOT NULL),
 			 '[]'::jsonb
 		 ),
+		 COALESCE(
+			 jsonb_agg(DISTINCT bs.subject_id) FILTER (WHERE bs.subject_id IS NOT NULL),
+			 '[]'::jsonb
+		 ),
 		 b.cover_image,
-		 b.thumbnail_image
+		 b.thumbnail_image,
+		 b.provider,
+		 COALESCE(b.provider_id, ''),
+		 COALESCE(b.cover_url, ''),
+		 b.fetched_at,
+		 b.work_id,
+		 COALESCE(b.format, ''),
+		 COALESCE(b.publisher, ''),
+		 COALESCE(b.page_count, 0),
+		 COALESCE(b.language, ''),
+		 (SELECT jsonb_build_object(
+			 'id', sr.id,
+			 'name', sr.name,
+			 'position', bsr.position
+		  ) FROM books_series bsr
+		  JOIN series sr ON sr.id = bsr.series_id
+		  WHERE bsr.book_id = b.id)
 		 FROM books b
 		 LEFT JOIN isbns i ON i.book_id = b.id
-		 LEFT JOIN books_authors a ON a.book_id = b.id
+		 LEFT JOIN books_subjects bs ON bs.book_id = b.id
 		 WHERE %v
 		 GROUP BY b.id`,
 		func() string {
@@ -162,42 +472,69 @@ func (b bookRepository[S]) queryString(clause string, search bool) string {
 func (b bookRepository[S]) rowsParse(rows pgx.Rows, search bool) (*model.Book, float64, error) {
 	var (
 		book      model.Book
-		published time.Time
-		authorIDs []byte
+		published pgtype.Range[pgtype.Date]
+		contribs  []byte
 		isbns     []byte
+		subjects  []byte
 		score     float64
+		provider  *string
+		fetched   *time.Time
+		src       model.BookSource
+		format    string
+		series    []byte
 	)
 
+	dest := []any{
+		&book.ID, &book.Title, &book.Subtitle, &book.Description,
+		&published, &contribs, &isbns, &subjects, &book.CoverImage, &book.ThumbImage,
+		&provider, &src.ProviderID, &src.CoverURL, &fetched,
+		&book.Work, &format, &book.Publisher, &book.Pages, &book.Language,
+		&series,
+	}
 	if search {
-		if err := rows.Scan(
-			&score, &book.ID, &book.Title, &book.Subtitle, &book.Description,
-			&published, &authorIDs, &isbns, &book.CoverImage, &book.ThumbImage,
-		); err != nil {
-			return nil, -1.0, err
-		}
-	} else {
-		if err := rows.Scan(
-			&book.ID, &book.Title, &book.Subtitle, &book.Description,
-			&published, &authorIDs, &isbns, &book.CoverImage, &book.ThumbImage,
-		); err != nil {
-			return nil, -1.0, err
-		}
+		dest = append([]any{&score}, dest...)
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, -1.0, err
 	}
 
-	book.Published = civil.DateOf(published)
+	book.Published = partialDate(published)
+	book.Format = model.EditionFormat(format)
+	if provider != nil {
+		src.Provider = *provider
+		if fetched != nil {
+			src.Fetched = *fetched
+		}
+		book.Source = &src
+	}
 
 	if err := json.Unmarshal(isbns, &book.ISBNs); err != nil {
 		return nil, -1.0, err
 	}
-	if err := json.Unmarshal(authorIDs, &book.AuthorIDs); err != nil {
+	if err := json.Unmarshal(contribs, &book.Contributors); err != nil {
+		return nil, -1.0, err
+	}
+	book.AuthorIDs = book.Credited(model.RoleAuthor)
+	if err := json.Unmarshal(subjects, &book.Subjects); err != nil {
 		return nil, -1.0, err
 	}
+	if series != nil {
+		book.Series = &model.BookSeries{}
+		if err := json.Unmarshal(series, book.Series); err != nil {
+			return nil, -1.0, err
+		}
+	}
 
 	return &book, score, nil
 }
 
 func (b *bookRepository[S]) getWhere(ctx context.Context, clause string, vals ...any) (*model.Book, error) {
-	rows, err := b.db.Query(ctx,
+	return b.getWhereIn(ctx, b.db, clause, vals...)
+}
+
+// Like getWhere, but in a transaction
+func (b *bookRepository[S]) getWhereIn(ctx context.Context, q querier, clause string, vals ...any) (*model.Book, error) {
+	rows, err := q.Query(ctx,
 		b.queryString(clause, false),
 		vals...,
 	)
@@ -218,7 +555,261 @@ func (b *bookRepository[S]) getWhere(ctx context.Context, clause string, vals ..
 
 // Update implements repository.BookManager.
 func (b *bookRepository[S]) Update(ctx context.Context, book *model.Book) (*model.Book, error) {
-	panic("unimplemented")
+	updated, _, err := b.Edit(ctx, book, model.Provenance{Source: model.ProvenanceAdmin})
+	return updated, err
+}
+
+// Edit implements repository.BookManager.
+func (b *bookRepository[S]) Edit(ctx context.Context, book *model.Book, by model.Provenance) (*model.Book, []string, error) {
+	const errorCaller string = "edit book"
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	changed, err := b.edit(ctx, tx, book, by)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	updated, err := b.GetByID(ctx, book.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return updated, changed, nil
+}
+
+// Write the fields of a book which `by` is allowed to change, and
+// record that it changed them.
+//
+// The book row itself is rewritten wholesale, while the ISBN and
+// author join tables are diffed against what is currently stored so
+// rows which did not change are left alone. Cover and thumbnail
+// references are swapped to whatever the new book points at; a
+// uuid.Nil reference clears the column. The old blobs are not deleted
+// as they may still be referenced elsewhere.
+func (b *bookRepository[S]) edit(ctx context.Context, tx pgx.Tx, book *model.Book, by model.Provenance) ([]string, error) {
+	// Lock the row so concurrent edits of the same book serialize
+	if err := tx.QueryRow(ctx,
+		`SELECT id FROM books WHERE id = $1 FOR UPDATE`,
+		book.ID,
+	).Scan(new(uuid.UUID)); errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("no book with ID `%v`", book.ID),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	current, err := b.getWhereIn(ctx, tx, "b.id = $1", book.ID)
+	if err != nil {
+		return nil, err
+	}
+	prov, err := b.provenance(ctx, tx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authors may have been given either way, see SyncContributors
+	in := *book
+	in.SyncContributors(*current)
+	var changed []string
+	for _, f := range current.Changed(in) {
+		if by.Overrides(prov[f]) {
+			changed = append(changed, f)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	merged := current.Merge(in, changed...)
+	merged.SyncContributors(*current)
+
+	if _, err = tx.Exec(ctx,
+		`UPDATE books SET (
+			 title,
+			 subtitle,
+			 description,
+			 published,
+			 cover_image,
+			 thumbnail_image,
+			 format,
+			 publisher,
+			 page_count,
+			 language
+		 ) = (
+			 $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7,
+			 NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, 0), NULLIF($11, '')
+		 ) WHERE id = $1`,
+		merged.ID, merged.Title, merged.Subtitle, merged.Description,
+		publishedRange(merged.Published),
+		nullableID(merged.CoverImage), nullableID(merged.ThumbImage),
+		string(merged.Format), merged.Publisher, merged.Pages, merged.Language,
+	); err != nil {
+		return nil, err
+	}
+
+	/*** ISBNs ***/
+	isbns := make([]string, len(merged.ISBNs))
+	isbnTypes := make([]string, len(merged.ISBNs))
+	for i, v := range merged.ISBNs {
+		isbns[i] = v.String()
+		isbnTypes[i] = v.Version().String()
+	}
+	// An ISBN belonging to another book is a conflict and not
+	// something we should silently steal.
+	var taken []string
+	if err = tx.QueryRow(ctx,
+		`SELECT COALESCE(array_agg(isbn), '{}')
+		 FROM isbns
+		 WHERE isbn = ANY($2) AND book_id <> $1`,
+		merged.ID, isbns,
+	).Scan(&taken); err != nil {
+		return nil, err
+	} else if len(taken) > 0 {
+		return nil, repository.Err{
+			Code: repository.ErrConflict,
+			Err:  fmt.Errorf("ISBNs %v belong to another book", taken),
+		}
+	}
+	if _, err = tx.Exec(ctx,
+		`DELETE FROM isbns
+		 WHERE book_id = $1 AND NOT (isbn = ANY($2))`,
+		merged.ID, isbns,
+	); err != nil {
+		return nil, fmt.Errorf("remove isbns: %w", err)
+	}
+	if _, err = tx.Exec(ctx,
+		`INSERT INTO isbns (isbn, book_id, isbn_type)
+		 SELECT n.isbn, $1, n.isbn_type
+		 FROM unnest($2::TEXT[], $3::TEXT[]) AS n(isbn, isbn_type)
+		 ON CONFLICT (isbn) DO NOTHING`,
+		merged.ID, isbns, isbnTypes,
+	); err != nil {
+		return nil, fmt.Errorf("add isbns: %w", err)
+	}
+
+	/*** Contributors ***/
+	// A nil slice would be sent as NULL, which unnest() makes nothing of
+	authorIDs := uuid.UUIDs{}
+	roles, billing := []string{}, []int16{}
+	for i, c := range merged.Contributors {
+		authorIDs = append(authorIDs, c.ID)
+		roles = append(roles, string(c.Role))
+		billing = append(billing, int16(i))
+	}
+	if _, err = tx.Exec(ctx,
+		`DELETE FROM books_authors ba
+		 WHERE ba.book_id = $1 AND NOT EXISTS (
+			 SELECT 1 FROM unnest($2::UUID[], $3::TEXT[]) AS c(author_id, role)
+			 WHERE c.author_id = ba.author_id AND c.role = ba.role
+		 )`,
+		merged.ID, authorIDs, roles,
+	); err != nil {
+		return nil, fmt.Errorf("remove contributors: %w", err)
+	}
+	if _, err = tx.Exec(ctx,
+		`INSERT INTO books_authors (book_id, author_id, role, billing)
+		 SELECT $1, c.author_id, c.role, c.billing
+		 FROM unnest($2::UUID[], $3::TEXT[], $4::SMALLINT[]) AS c(author_id, role, billing)
+		 ON CONFLICT (book_id, author_id, role) DO UPDATE
+		 SET billing = EXCLUDED.billing`,
+		merged.ID, authorIDs, roles, billing,
+	); err != nil {
+		return nil, fmt.Errorf("add contributors: %w", err)
+	}
+
+	/*** Subjects ***/
+	subjectIDs := append(uuid.UUIDs{}, merged.Subjects...)
+	if _, err = tx.Exec(ctx,
+		`DELETE FROM books_subjects
+		 WHERE book_id = $1 AND NOT (subject_id = ANY($2))`,
+		merged.ID, subjectIDs,
+	); err != nil {
+		return nil, fmt.Errorf("remove subjects: %w", err)
+	}
+	if _, err = tx.Exec(ctx,
+		`INSERT INTO books_subjects (book_id, subject_id)
+		 SELECT $1, s FROM unnest($2::UUID[]) AS s
+		 ON CONFLICT (book_id, subject_id) DO NOTHING`,
+		merged.ID, subjectIDs,
+	); err != nil {
+		return nil, fmt.Errorf("add subjects: %w", err)
+	}
+
+	/*** Provenance ***/
+	if err = b.attribute(ctx, tx, merged.ID, changed, by); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// Provenance implements repository.BookManager.
+func (b *bookRepository[S]) Provenance(ctx context.Context, id uuid.UUID) (map[string]model.Provenance, error) {
+	const errorCaller string = "book provenance"
+	prov, err := b.provenance(ctx, b.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return prov, nil
+}
+
+func (b *bookRepository[S]) provenance(ctx context.Context, q querier, id uuid.UUID) (map[string]model.Provenance, error) {
+	rows, err := q.Query(ctx,
+		`SELECT field, source, COALESCE(source_id, ''), actor, recorded_at
+		 FROM book_provenance
+		 WHERE book_id = $1`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prov := map[string]model.Provenance{}
+	for rows.Next() {
+		var (
+			field string
+			p     model.Provenance
+			actor *uuid.UUID
+		)
+		if err = rows.Scan(&field, &p.Source, &p.SourceID, &actor, &p.Recorded); err != nil {
+			return nil, err
+		}
+		if actor != nil {
+			p.Actor = *actor
+		}
+		prov[field] = p
+	}
+	return prov, rows.Err()
+}
+
+// Record `by` as the provenance of the given fields
+func (b *bookRepository[S]) attribute(ctx context.Context, tx pgx.Tx, id uuid.UUID, fields []string, by model.Provenance) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	recorded := by.Recorded
+	if recorded.IsZero() {
+		recorded = time.Now()
+	}
+	_, err := tx.Exec(ctx,
+		`INSERT INTO book_provenance (book_id, field, source, source_id, actor, recorded_at)
+		 SELECT $1, f, $3, NULLIF($4, ''), $5, $6 FROM unnest($2::TEXT[]) AS f
+		 ON CONFLICT (book_id, field) DO UPDATE SET
+			 source = EXCLUDED.source,
+			 source_id = EXCLUDED.source_id,
+			 actor = EXCLUDED.actor,
+			 recorded_at = EXCLUDED.recorded_at`,
+		id, fields, string(by.Source), by.SourceID, nullableID(by.Actor), recorded,
+	)
+	if err != nil {
+		return fmt.Errorf("record provenance: %w", err)
+	}
+	return nil
 }
 
 func (b *bookRepository[S]) Delete(ctx context.Context, id uuid.UUID) error {
@@ -228,13 +819,25 @@ func (b *bookRepository[S]) Delete(ctx context.Context, id uuid.UUID) error {
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx,
+	var workID uuid.UUID
+	if err := tx.QueryRow(ctx,
 		`DELETE FROM books b
-		 WHERE b.id = $1`,
+		 WHERE b.id = $1
+		 RETURNING b.work_id`,
 		id,
-	); err != nil {
+	).Scan(&workID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return fmt.Errorf("failed to delete book: %w", err)
 	}
+	// The work goes with its last edition, unless it has been reviewed
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM works w
+		 WHERE w.id = $1
+		 AND NOT EXISTS (SELECT 1 FROM books WHERE work_id = w.id)
+		 AND NOT EXISTS (SELECT 1 FROM comments WHERE work_id = w.id)`,
+		workID,
+	); err != nil {
+		return fmt.Errorf("failed to delete work: %w", err)
+	}
 
 	// We shouldn't have to delete the ISBNs ourselves, on delete they
 	// cascade
@@ -252,16 +855,94 @@ func (b *bookRepository[S]) GetByISBN(ctx context.Context, isbn model.ISBN) (*mo
 	return b.getWhere(ctx, "i.isbn = $1", isbn.String())
 }
 
-// Search implements BookRepositoryManager.
-func (b *bookRepository[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
+// Stale implements repository.BookManager.
+func (b *bookRepository[S]) Stale(ctx context.Context, before time.Time, limit int) ([]*model.Book, error) {
+	const errorCaller string = "stale books"
+	rows, err := b.db.Query(ctx,
+		b.queryString("b.provider IS NOT NULL AND b.fetched_at < $1", false)+
+			` ORDER BY b.fetched_at ASC LIMIT $2`,
+		before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	var books []*model.Book
+	for rows.Next() {
+		book, _, err := b.rowsParse(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		books = append(books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return books, nil
+}
+
+// Refresh implements repository.BookManager.
+func (b *bookRepository[S]) Refresh(ctx context.Context, book *model.Book) (*model.Book, []string, error) {
+	const errorCaller string = "refresh book"
+	var src model.BookSource
+	if book.Source != nil {
+		src = *book.Source
+	}
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	changed, err := b.edit(ctx, tx, book, model.Provenance{
+		Source:   model.ProvenanceScraper,
+		SourceID: src.Provider + ":" + src.ProviderID,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if _, err = tx.Exec(ctx,
+		`UPDATE books SET
+			 provider = NULLIF($2, ''),
+			 provider_id = NULLIF($3, ''),
+			 cover_url = NULLIF($4, ''),
+			 fetched_at = NOW()
+		 WHERE id = $1`,
+		book.ID, src.Provider, src.ProviderID, src.CoverURL,
+	); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	updated, err := b.GetByID(ctx, book.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return updated, changed, nil
+}
+
+func (b *bookRepository[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
+	return b.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.BookFilter{})
+}
+
+// SearchFiltered implements repository.BookManager.
+func (b *bookRepository[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.BookFilter) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
 	const errorCaller string = "book search"
 	var resultsT []repository.SearchResult[model.BookSummary]
 	var resultsASI []repository.AnyScoreItemer
 
-	qStr := strings.Join(query, " ")
-	rows, err := b.db.Query(ctx,
-		`SELECT
-			 paradedb.score(b.id),
+	search, args, ok := bookSearchQuery(query, filter, nil)
+	if !ok {
+		return resultsT, resultsASI, nil
+	}
+	sql, args := searchPage(
+		fmt.Sprintf(`SELECT
+			 paradedb.score(b.id) AS score,
+			 %v,
+			 %v,
+			 %v,
 			 b.id,
 			 b.title,
 			 b.subtitle,
@@ -269,44 +950,60 @@ func (b *bookRepository[S]) Search(ctx context.Context, offset int, limit int, q
 			 b.published,
 			 v.thumbnail_image,
 			 v.authors,
+			 v.contributors,
 			 v.isbns
 		 FROM books b
 		 LEFT JOIN v_books_summary v ON v.id = b.id
-		 WHERE b.title @@@ $1 OR b.subtitle @@@ $1 OR b.description @@@ $1
-		 ORDER BY paradedb.score(b.id) DESC, v.title DESC
-		 LIMIT $2 OFFSET $3`,
-		qStr,
-		limit,
-		offset,
+		 WHERE b.id @@@ %v`,
+			snippet("b.title"), snippet("b.subtitle"), snippet("b.description"),
+			search,
+		),
+		page,
+		args,
 	)
+	rows, err := b.db.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
 			s  float64
 			o  model.BookSummary
+			p  pgtype.Range[pgtype.Date]
 			aS []byte
+			cS []byte
 			iS []byte
+			// Snippets of the title, subtitle and description
+			sT, sS, sD string
 		)
 
 		if err = rows.Scan(
-			&s, &o.ID, &o.Title, &o.Subtitle, &o.Description,
-			&o.Published, &o.ThumbImage, &aS, &iS,
+			&s, &sT, &sS, &sD, &o.ID, &o.Title, &o.Subtitle, &o.Description,
+			&p, &o.ThumbImage, &aS, &cS, &iS,
 		); err != nil {
 			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
+		o.Published = partialDate(p)
 
 		if err = json.Unmarshal(aS, &o.Authors); err != nil {
 			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+		} else if err = json.Unmarshal(cS, &o.Contributors); err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 		} else if err = json.Unmarshal(iS, &o.ISBNs); err != nil {
 			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
 
 		r := repository.SearchResult[model.BookSummary]{
 			Item:  &o,
+			ID:    o.ID,
 			Score: s,
+			Highlights: highlights(map[string]string{
+				"title":       sT,
+				"subtitle":    sS,
+				"description": sD,
+			}),
 		}
 		resultsT = append(resultsT, r)
 		resultsASI = append(resultsASI, r)
@@ -315,7 +1012,319 @@ func (b *bookRepository[S]) Search(ctx context.Context, offset int, limit int, q
 	return resultsT, resultsASI, rows.Err()
 }
 
+// Facets implements repository.BookManager. The facets are ParadeDB
+// aggregations over the same query on i_books_search as SearchFiltered
+// makes, so they count exactly the books it finds.
+func (b *bookRepository[S]) Facets(ctx context.Context, query repository.Query, filter repository.BookFilter) (*repository.BookFacets, error) {
+	const errorCaller string = "book facets"
+	facets := repository.BookFacets{
+		Decades:  []repository.FacetCount{},
+		Subjects: []repository.FacetCount{},
+		Ratings:  []repository.FacetCount{},
+	}
+	search, args, ok := bookSearchQuery(query, filter, nil)
+	if !ok {
+		return &facets, nil
+	}
+	aggs, err := json.Marshal(bookFacetAggregations())
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	args = append(args, string(aggs))
+	var raw []byte
+	if err = b.db.QueryRow(ctx,
+		fmt.Sprintf(`SELECT paradedb.aggregate('i_books_search', %v, $%d::JSON)`, search, len(args)),
+		args...,
+	).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var counts struct {
+		Decades  facetBuckets[float64] `json:"decades"`
+		Subjects facetBuckets[string]  `json:"subjects"`
+		Ratings  facetBuckets[string]  `json:"ratings"`
+	}
+	if err = json.Unmarshal(raw, &counts); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	for _, d := range counts.Decades.Buckets {
+		facets.Decades = append(facets.Decades, repository.FacetCount{
+			Value: strconv.Itoa(int(d.Key)),
+			Count: d.Count,
+		})
+	}
+	// Every rating bucket comes back, even the empty ones
+	for _, r := range counts.Ratings.Buckets {
+		if r.Count > 0 {
+			facets.Ratings = append(facets.Ratings, repository.FacetCount{
+				Value: r.Key,
+				Count: r.Count,
+			})
+		}
+	}
+	if len(counts.Subjects.Buckets) == 0 {
+		return &facets, nil
+	}
+	// The index only has the subjects' IDs, so they are named here
+	ids := make([]string, len(counts.Subjects.Buckets))
+	for i, s := range counts.Subjects.Buckets {
+		ids[i] = s.Key
+	}
+	rows, err := b.db.Query(ctx,
+		`SELECT id::TEXT, name FROM subjects WHERE id = ANY($1::UUID[])`,
+		ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	names, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) ([2]string, error) {
+		var idName [2]string
+		err := row.Scan(&idName[0], &idName[1])
+		return idName, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	labels := make(map[string]string, len(names))
+	for _, n := range names {
+		labels[n[0]] = n[1]
+	}
+	for _, s := range counts.Subjects.Buckets {
+		facets.Subjects = append(facets.Subjects, repository.FacetCount{
+			Value: s.Key,
+			Label: labels[s.Key],
+			Count: s.Count,
+		})
+	}
+	// Subjects come back most books first, but ties in no given order
+	slices.SortStableFunc(facets.Subjects, func(x, y repository.FacetCount) int {
+		if c := cmp.Compare(y.Count, x.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.Label, y.Label)
+	})
+	return &facets, nil
+}
+
+// The most subjects Facets counts books under, those with the most
+const facetSubjects int = 1000
+
+// A bucket aggregation's result from ParadeDB
+type facetBuckets[K any] struct {
+	Buckets []struct {
+		Key   K   `json:"key"`
+		Count int `json:"doc_count"`
+	} `json:"buckets"`
+}
+
+// The aggregations Facets asks ParadeDB for. Ratings are counted in
+// ranges rather than a histogram so that the top bucket includes 1.
+func bookFacetAggregations() map[string]any {
+	n := int(math.Round(float64(1 / repository.RatingBucket)))
+	ratings := make([]map[string]any, n)
+	for i := range n {
+		r := map[string]any{"key": ratingBucketValue(i)}
+		if i > 0 {
+			r["from"] = float64(ratingBucket(i))
+		}
+		if i < n-1 {
+			r["to"] = float64(ratingBucket(i + 1))
+		}
+		ratings[i] = r
+	}
+	return map[string]any{
+		"decades": map[string]any{"histogram": map[string]any{
+			"field":         "published_year",
+			"interval":      10,
+			"min_doc_count": 1,
+		}},
+		"subjects": map[string]any{"terms": map[string]any{
+			"field": "subject_ids",
+			"size":  facetSubjects,
+		}},
+		"ratings": map[string]any{"range": map[string]any{
+			"field":  "rating",
+			"ranges": ratings,
+		}},
+	}
+}
+
+// The lowest rating in the nth rating bucket in BookFacets
+func ratingBucket(n int) float32 {
+	return float32(n) * repository.RatingBucket
+}
+
+// The value of the nth rating bucket in BookFacets, its lowest rating
+func ratingBucketValue(n int) string {
+	return strconv.FormatFloat(float64(ratingBucket(n)), 'f', -1, 32)
+}
+
 // Summarize implements repository.BookManager.
-func (b *bookRepository[S]) Summarize(context.Context, *model.Book) (*model.BookSummary, error) {
-	panic("unimplemented")
+func (b *bookRepository[S]) Summarize(ctx context.Context, book *model.Book) (*model.BookSummary, error) {
+	const errorCaller string = "summarize book"
+	rows, err := b.db.Query(ctx,
+		`SELECT
+			 v.id,
+			 v.title,
+			 COALESCE(v.subtitle, ''),
+			 COALESCE(v.description, ''),
+			 v.published,
+			 v.thumbnail_image,
+			 v.authors,
+			 v.contributors,
+			 v.isbns,
+			 v.rating
+		 FROM v_books_summary v
+		 WHERE v.id = $1`,
+		book.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no book with ID `%v`", errorCaller, book.ID),
+		}
+	}
+	o, err := b.summaryParse(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return o, nil
+}
+
+// Scan a row of v_books_summary, in the column order the view
+// defines.
+func (b *bookRepository[S]) summaryParse(rows pgx.Rows) (*model.BookSummary, error) {
+	var (
+		o         model.BookSummary
+		published pgtype.Range[pgtype.Date]
+		aS        []byte
+		cS        []byte
+		iS        []byte
+	)
+	if err := rows.Scan(
+		&o.ID, &o.Title, &o.Subtitle, &o.Description, &published,
+		&o.ThumbImage, &aS, &cS, &iS, &o.Rating,
+	); err != nil {
+		return nil, err
+	}
+	o.Published = partialDate(published)
+
+	if err := json.Unmarshal(aS, &o.Authors); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(cS, &o.Contributors); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(iS, &o.ISBNs); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// uuid.Nil is how the model says "no image", which is NULL here
+func nullableID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// Likewise the zero time is NULL
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// A partial date is stored as the days it could fall on, or NULL if
+// it isn't known at all
+func publishedRange(d model.PartialDate) pgtype.Range[pgtype.Date] {
+	if d.IsZero() {
+		return pgtype.Range[pgtype.Date]{}
+	}
+	return dateRange(d.Start(), d.End())
+}
+
+// The days from `first` to `last`, inclusive. A zero date leaves that
+// end unbounded.
+func dateRange(first, last civil.Date) pgtype.Range[pgtype.Date] {
+	r := pgtype.Range[pgtype.Date]{
+		LowerType: pgtype.Unbounded,
+		UpperType: pgtype.Unbounded,
+		Valid:     true,
+	}
+	if !first.IsZero() {
+		r.Lower = pgtype.Date{Time: first.In(time.UTC), Valid: true}
+		r.LowerType = pgtype.Inclusive
+	}
+	if !last.IsZero() {
+		r.Upper = pgtype.Date{Time: last.In(time.UTC), Valid: true}
+		r.UpperType = pgtype.Inclusive
+	}
+	return r
+}
+
+// Postgres hands date ranges back as [first, day after last)
+func partialDate(r pgtype.Range[pgtype.Date]) model.PartialDate {
+	if !r.Valid || r.LowerType != pgtype.Inclusive || r.UpperType != pgtype.Exclusive {
+		return model.PartialDate{}
+	}
+	return model.PartialDateBetween(
+		civil.DateOf(r.Lower.Time),
+		civil.DateOf(r.Upper.Time).AddDays(-1),
+	)
+}
+
+// Cut the results of a search down to the page asked for. `results` is
+// a query with `score` and `id` columns taking `args` as parameters;
+// the page's own parameters are appended after them.
+func searchPage(results string, page repository.SearchPage, args []any) (string, []any) {
+	limit := page.Limit
+	if limit <= 0 {
+		limit = repository.DefaultPageSize
+	}
+	n := len(args) + 1
+	where, order := "true", "r.score DESC, r.id"
+	switch {
+	case page.Through != nil:
+		// Walk backwards from the end of the page, then put it the
+		// right way round
+		where = fmt.Sprintf("r.score > $%d OR (r.score = $%d AND r.id <= $%d)", n, n, n+1)
+		order = "r.score, r.id DESC"
+		args = append(args, page.Through.Score, page.Through.ID)
+	case page.After != nil:
+		where = fmt.Sprintf("r.score < $%d OR (r.score = $%d AND r.id > $%d)", n, n, n+1)
+		args = append(args, page.After.Score, page.After.ID)
+	}
+	query := fmt.Sprintf(`SELECT * FROM (%v) r
+		 WHERE %v
+		 ORDER BY %v
+		 LIMIT $%d`, results, where, order, len(args)+1)
+	if page.Through != nil {
+		query = fmt.Sprintf(`SELECT * FROM (%v) r ORDER BY r.score DESC, r.id`, query)
+	}
+	return query, append(args, limit)
+}
+
+// Either the pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Suggest implements repository.BookManager.
+func (b *bookRepository[S]) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	const errorCaller string = "suggest books"
+	s, err := suggest(ctx, b.db, "b.id", "b.title", "b.title", "books b", prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return s, nil
 }
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -26,7 +25,7 @@ func (b *bookRepository[S]) PopulateDummyValues(ctx context.Context) error {
 	for _, book := range dummyvalues.ExampleBooks {
 		batch.Queue(`INSERT INTO books (id, title, published)
 					 VALUES ($1, $2, $3)`,
-			book.ID, book.Title, book.Published.In(time.UTC))
+			book.ID, book.Title, publishedRange(book.Published))
 		// isbn used instead of i because `i` generally means index
 		for _, isbn := range book.ISBNs {
 			batch.Queue(`INSERT INTO isbns (isbn, book_id, isbn_type)
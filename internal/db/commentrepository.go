@@ -2,12 +2,14 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
@@ -31,7 +33,7 @@ func newCommentRepository(psql *postgres) repository.CommentManager[string] {
 // Parameters:
 //
 //	clause - The condition appended to the WHERE clause, affecting which comment rows are returned.
-//	search - A boolean toggle indicating whether to include search scoring in the result.
+//	search - A boolean toggle indicating whether to include search scoring and a snippet of the body in the result.
 //
 // Returns:
 //
@@ -41,6 +43,7 @@ func (c commentRepository[S]) queryString(clause string, search bool) string {
 			 %v
 			 c.id,
 			 c.book_id,
+			 c.work_id,
 			 COALESCE(c.body, ''),
 			 COALESCE(c.rating, -1.0),
 			 c.parent_comment_id,
@@ -48,7 +51,7 @@ func (c commentRepository[S]) queryString(clause string, search bool) string {
 			 c.deleted,
 			 c.created_at,
 			 c.updated_at,
-			 u.id,
+			 u.id AS poster_id,
 			 COALESCE(u.display_name, u.handle, 'Deleted'),
 			 COALESCE(u.pronouns, ''),
 			 COALESCE(u.handle, 'deleted'),
@@ -59,7 +62,7 @@ func (c commentRepository[S]) queryString(clause string, search bool) string {
 		 WHERE %v`,
 		func() string {
 			if search {
-				return "paradedb.score(c.id),"
+				return "paradedb.score(c.id) AS score,\n\t\t\t " + snippet("c.body") + ","
 			}
 			return ""
 		}(),
@@ -68,14 +71,16 @@ func (c commentRepository[S]) queryString(clause string, search bool) string {
 }
 
 // rowsParse retrieves and parses comment data from the provided pgx.Rows.
-// If the search parameter is true, it expects an additional float32 score as
-// the first scanned column. The function then scans data into a model.Comment
-// along with associated user information, determines whether the comment
-// should be flagged as edited based on creation and update timestamps, and
-// constructs the comment's poster username using handle components. It returns
-// the populated *model.Comment, a float32 representing the comment's search
-// score, and an error if any field scanning or username construction fails.
-func (c commentRepository[S]) rowsParse(rows pgx.Rows, search bool) (*model.Comment, float64, error) {
+// If the snippet parameter is non-nil, the rows are from a search: it
+// expects an additional float32 score as the first scanned column, then a
+// snippet of the body, which is scanned into snippet. The function then
+// scans data into a model.Comment along with associated user information,
+// determines whether the comment should be flagged as edited based on
+// creation and update timestamps, and constructs the comment's poster
+// username using handle components. It returns the populated
+// *model.Comment, a float32 representing the comment's search score, and
+// an error if any field scanning or username construction fails.
+func (c commentRepository[S]) rowsParse(rows pgx.Rows, snippet *string) (*model.Comment, float64, error) {
 	var cmt model.Comment
 	var cmtUser model.CommentUser
 	var s float64
@@ -84,9 +89,9 @@ func (c commentRepository[S]) rowsParse(rows pgx.Rows, search bool) (*model.Comm
 	var h string
 	var d int16
 
-	if search {
+	if snippet != nil {
 		if err := rows.Scan(
-			&s, &cmt.ID, &cmt.Book, &cmt.Body, &cmt.Rating,
+			&s, snippet, &cmt.ID, &cmt.Book, &cmt.Work, &cmt.Body, &cmt.Rating,
 			&cmt.Parent, &cmt.Votes, &cmt.Deleted, &cmt.Date, &e,
 			&cmtUser.ID, &cmtUser.DisplayName, &cmtUser.Pronouns, &h,
 			&d, &cmtUser.Avatar,
@@ -95,7 +100,7 @@ func (c commentRepository[S]) rowsParse(rows pgx.Rows, search bool) (*model.Comm
 		}
 	} else {
 		if err := rows.Scan(
-			&cmt.ID, &cmt.Book, &cmt.Body, &cmt.Rating, &cmt.Parent,
+			&cmt.ID, &cmt.Book, &cmt.Work, &cmt.Body, &cmt.Rating, &cmt.Parent,
 			&cmt.Votes, &cmt.Deleted, &cmt.Date, &e, &cmtUser.ID,
 			&cmtUser.DisplayName, &cmtUser.Pronouns, &h, &d, &cmtUser.Avatar,
 		); err != nil {
@@ -131,7 +136,34 @@ func (c *commentRepository[S]) BookComments(ctx context.Context, bookID uuid.UUI
 	defer rows.Close()
 
 	for rows.Next() {
-		cmt, _, err := c.rowsParse(rows, false)
+		cmt, _, err := c.rowsParse(rows, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		comments = append(comments, cmt)
+	}
+
+	return comments, rows.Err()
+}
+
+// WorkComments implements repository.CommentManager.
+func (c *commentRepository[S]) WorkComments(ctx context.Context, workID uuid.UUID) ([]*model.Comment, error) {
+	const errorCaller string = "work comments"
+	comments := []*model.Comment{}
+
+	rows, err := c.db.Query(ctx,
+		c.queryString(`c.work_id = $1 OR c.book_id IN (
+			 SELECT id FROM books WHERE work_id = $1
+		 )`, false),
+		workID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cmt, _, err := c.rowsParse(rows, nil)
 		if err != nil {
 			return nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
@@ -154,27 +186,33 @@ func (c *commentRepository[S]) Create(ctx context.Context, comment *model.Commen
 	if comment.Parent == uuid.Nil {
 		_, err = tx.Exec(ctx,
 			`INSERT INTO comments (
-				 id, book_id, poster_id, body, rating, created_at, 
-				 updated_at
+				 id, book_id, work_id, poster_id, body, rating,
+				 created_at, updated_at
 			 ) VALUES (
-				 $1, $2, $3, $4, $5, $6, $7
+				 $1, $2, $3, $4, $5, $6, $7, $8
 			 )`,
-			comment.ID, comment.Book, comment.Poster.ID, comment.Body,
-			comment.Rating, now, now,
+			comment.ID, nullableID(comment.Book), nullableID(comment.Work),
+			comment.Poster.ID, comment.Body, comment.Rating, now, now,
 		)
 	} else {
 		_, err = tx.Exec(ctx,
 			`INSERT INTO comments (
-				 id, book_id, poster_id, body, parent_comment_id, 
-				 created_at, updated_at
+				 id, book_id, work_id, poster_id, body,
+				 parent_comment_id, created_at, updated_at
 			 ) VALUES (
-				 $1, $2, $3, $4, $5, $6, $7
+				 $1, $2, $3, $4, $5, $6, $7, $8
 			 )`,
-			comment.ID, comment.Book, comment.Poster.ID, comment.Body,
-			comment.Parent, now, now,
+			comment.ID, nullableID(comment.Book), nullableID(comment.Work),
+			comment.Poster.ID, comment.Body, comment.Parent, now, now,
 		)
 	}
-	if err != nil {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return repository.Err{
+			Code: repository.ErrConflict,
+			Err:  fmt.Errorf("%v: user `%v` has already reviewed this", errorCaller, comment.Poster.ID),
+		}
+	} else if err != nil {
 		return fmt.Errorf("%v: %w", errorCaller, err)
 	}
 
@@ -188,6 +226,10 @@ func (c *commentRepository[S]) Create(ctx context.Context, comment *model.Commen
 	return tx.Commit(ctx)
 }
 
+// Postgres' code for a row which would break a unique index, here
+// the one review each user gets of a book or work
+const uniqueViolation string = "23505"
+
 // Delete implements repository.CommentManager.
 func (c *commentRepository[S]) Delete(ctx context.Context, commentID uuid.UUID) error {
 	const errorCaller string = "delete comment"
@@ -229,7 +271,7 @@ func (c *commentRepository[S]) GetByID(ctx context.Context, commentID uuid.UUID)
 		}
 		multiple = true
 
-		cmt, _, err := c.rowsParse(r, false)
+		cmt, _, err := c.rowsParse(r, nil)
 		if err != nil {
 			return nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
@@ -240,35 +282,65 @@ func (c *commentRepository[S]) GetByID(ctx context.Context, commentID uuid.UUID)
 }
 
 // Search implements repository.CommentManager.
-func (c *commentRepository[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
+func (c *commentRepository[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
+	return c.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.CommentFilter{})
+}
+
+// SearchFiltered implements repository.CommentManager.
+func (c *commentRepository[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.CommentFilter) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
 	const errorCaller string = "comment search"
 	var resultsT []repository.SearchResult[model.Comment]
 	var resultsASI []repository.AnyScoreItemer
 
-	rows, err := c.db.Query(ctx,
-		c.queryString(`c.body @@@ $1
-			 ORDER BY paradedb.score(c.id) DESC, updated_at DESC
-			 LIMIT $2 OFFSET $3`,
-			true,
-		),
-		strings.Join(query, " "),
-		limit,
-		offset,
+	search, args, ok := commentQueryTarget.where(query, nil)
+	if !ok {
+		return resultsT, resultsASI, nil
+	}
+	conds := []string{search}
+	param := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if len(filter.Posters) > 0 {
+		conds = append(conds, fmt.Sprintf(`c.poster_id = ANY(%v::UUID[])`, param(filter.Posters)))
+	}
+	if len(filter.Books) > 0 {
+		p := param(filter.Books)
+		conds = append(conds, fmt.Sprintf(`(c.book_id = ANY(%v::UUID[]) OR
+			 c.work_id IN (SELECT work_id FROM books WHERE id = ANY(%v::UUID[])))`, p, p))
+	}
+	if filter.Reviews {
+		conds = append(conds, `c.parent_comment_id IS NULL`)
+	}
+	if filter.MinRating != nil {
+		conds = append(conds, fmt.Sprintf(`c.rating >= %v::REAL`, param(*filter.MinRating)))
+	}
+	if filter.MaxRating != nil {
+		conds = append(conds, fmt.Sprintf(`c.rating <= %v::REAL`, param(*filter.MaxRating)))
+	}
+	sql, args := searchPage(
+		c.queryString(strings.Join(conds, "\n\t\t AND "), true),
+		page,
+		args,
 	)
+	rows, err := c.db.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		c, s, err := c.rowsParse(rows, true)
+		var body string
+		c, s, err := c.rowsParse(rows, &body)
 		if err != nil {
 			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
 		}
 
 		r := repository.SearchResult[model.Comment]{
-			Item:  c,
-			Score: s,
+			Item:       c,
+			ID:         c.ID,
+			Score:      s,
+			Highlights: highlights(map[string]string{"body": body}),
 		}
 		resultsT = append(resultsT, r)
 		resultsASI = append(resultsASI, r)
@@ -292,6 +364,7 @@ func (c *commentRepository[S]) Update(ctx context.Context, comment *model.Commen
 
 	// Check to make sure none of the unalterable values are changed
 	if cc.Book != comment.Book ||
+		cc.Work != comment.Work ||
 		cc.Poster.ID != comment.Poster.ID ||
 		cc.Parent != comment.ID ||
 		cc.Date != comment.Date {
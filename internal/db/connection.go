@@ -39,7 +39,6 @@ var connOnce sync.Once
 func (p *postgres) Connect(ctx context.Context, args ...any) error {
 	uri, chn, err := func(args ...any) (string, chan<- error, error) {
 		if len(args) != 2 {
-			panic("WRONG LEN")
 			return "", nil, fmt.Errorf("invalid number of arguments, want `2` have `%d`",
 				len(args),
 			)
@@ -48,12 +47,10 @@ func (p *postgres) Connect(ctx context.Context, args ...any) error {
 		chnA := args[1]
 		uri, ok := uriA.(string)
 		if !ok {
-			panic("BAD CAST URI")
 			return "", nil, fmt.Errorf("cannot cast arg uri (`%#v`) to `string`", uriA)
 		}
 		chn, ok := chnA.(chan error)
 		if !ok {
-			panic("BAD CAST CHAN")
 			return "", nil, fmt.Errorf("cannot cast arg eCh (`%#v`) to `chan error`", chnA)
 		}
 		return uri, chn, nil
@@ -94,69 +91,10 @@ func (p *postgres) Connect(ctx context.Context, args ...any) error {
 		}
 	}
 
-	// Check blob cache table configuration
-	var jsonData []byte
-	var cacheConfigured bool
-	if err = p.db.QueryRow(ctx,
-		`SELECT EXISTS (
-			 SELECT 1 FROM admin WHERE key = 'blobs_cache_config'
-		 ),
-		 (
-		 	 SELECT value FROM admin WHERE key = 'blobs_cache_config'
-		 )`,
-	).Scan(&cacheConfigured, &jsonData); err != nil && !errors.Is(err, pgx.ErrNoRows) {
-
-		errF := fmt.Errorf("retrieve key config: %w", err)
-		chn <- errF
-		return errF
-	}
-
-	needsRegen := !cacheConfigured
-
-	if cacheConfigured {
-		var aux struct {
-			MCS int           `json:"maxCache"`
-			TTL time.Duration `json:"ttl"`
-		}
-		if err := json.Unmarshal(jsonData, &aux); err != nil {
-			// If json is invalid, we just regenerate it
-			needsRegen = true
-		} else {
-			// Check conditions that require regeneration:
-			// 1. Max Cache Size is 0
-			// 2. TTL is Zero
-			needsRegen = aux.MCS == 0 ||
-				aux.TTL == 0
-		}
-	}
-
-	if needsRegen {
-		// TODO: no magic numbers.
-		// This is 3/4 of a GiB != 750 MiB (yay base2)
-		// or (1<<29) + (1<<28)
-		const maxCache uint = 805306368
-		const ttl time.Duration = (1 * time.Hour)
-		if _, err := p.db.Exec(ctx,
-			`INSERT INTO admin (key, value)
-			 VALUES ('blobs_cache_config', jsonb_build_object(
-			 	 'maxSize', $1::BIGINT,
-				 'ttl', ($2::INTEGER::TEXT || ' seconds')::INTERVAL
-			 ))
-			 ON CONFLICT (key) DO 
-			 UPDATE SET value = jsonb_build_object(
-			 	 'maxSize', $1::BIGINT,
-				 'ttl', ($2::INTEGER::TEXT || ' seconds')::INTERVAL
-			 )`, maxCache, ttl.Seconds(),
-		); err != nil {
-			errF := fmt.Errorf("update blobs_cache_config: %w", err)
-			chn <- errF
-			return errF
-		}
-	}
 	return nil
 }
 
-func NewRepository(uri string, timeout time.Duration) (repository.Repository[string], error) {
+func NewRepository(uri string, timeout time.Duration, blobs BlobOptions) (repository.Repository[string], error) {
 	db := &postgres{}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -181,9 +119,18 @@ func NewRepository(uri string, timeout time.Duration) (repository.Repository[str
 	r.Book = newBookRepository(db)
 	r.Author = newAuthorRepository(db)
 	r.User = newUserRepository(db)
-	r.Blob = newBlobRepository(db)
+	blob, err := newBlobRepository(db, blobs)
+	if err != nil {
+		db.Disconnect()
+		return r, fmt.Errorf("instantiate repository: %w", err)
+	}
+	r.Blob = blob
 	r.Comment = newCommentRepository(db)
 	r.Vote = newVoteRepository(db)
+	r.ScrapeJob = newScrapeJobRepository(db)
+	r.Subject = newSubjectRepository(db)
+	r.Work = newWorkRepository(db)
+	r.Series = newSeriesRepository(db)
 	return r, nil
 }
 
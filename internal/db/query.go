@@ -0,0 +1,243 @@
+package db
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// How a table answers each field of a search query
+type queryTarget struct {
+	// The table's key column, which its bm25 index is searched through
+	key string
+	// The bm25 index fields each query field searches
+	indexed map[repository.QueryField][]string
+	// The query fields whose phrases may be split across their indexed
+	// fields, as a name is across given and family names. Such phrases
+	// only need each of their words to be somewhere.
+	spread map[repository.QueryField]bool
+	// For fields which aren't in the index, a condition on the key
+	// for a term, given a function which adds a parameter and returns
+	// its placeholder
+	joined map[repository.QueryField]func(t repository.QueryTerm, param func(any) string) string
+}
+
+var (
+	bookQueryTarget = queryTarget{
+		key: "b.id",
+		indexed: map[repository.QueryField][]string{
+			repository.FieldAny:         {"title", "subtitle", "description"},
+			repository.FieldTitle:       {"title"},
+			repository.FieldSubtitle:    {"subtitle"},
+			repository.FieldDescription: {"description"},
+			// Copied onto books so that everything a book is searched
+			// on is in its index, which facets are counted over
+			repository.FieldAuthor: {"author_family_names", "author_given_names"},
+			repository.FieldISBN:   {"isbn_codes"},
+		},
+		spread: map[repository.QueryField]bool{repository.FieldAuthor: true},
+	}
+	authorQueryTarget = queryTarget{
+		key: "a.id",
+		indexed: map[repository.QueryField][]string{
+			repository.FieldAny:    {"family_name", "given_name"},
+			repository.FieldAuthor: {"family_name", "given_name"},
+		},
+		spread: map[repository.QueryField]bool{
+			repository.FieldAny:    true,
+			repository.FieldAuthor: true,
+		},
+	}
+	userQueryTarget = queryTarget{
+		key: "u.id",
+		indexed: map[repository.QueryField][]string{
+			repository.FieldAny:  {"handle", "display_name"},
+			repository.FieldUser: {"handle", "display_name"},
+		},
+		joined: map[repository.QueryField]func(repository.QueryTerm, func(any) string) string{
+			fieldUsername: func(t repository.QueryTerm, param func(any) string) string {
+				return fmt.Sprintf(`(u.handle || '#' || lpad(u.discriminator::TEXT, 4, '0')) = %v`, param(t.Text))
+			},
+		},
+	}
+	commentQueryTarget = queryTarget{
+		key: "c.id",
+		indexed: map[repository.QueryField][]string{
+			repository.FieldAny:     {"body"},
+			repository.FieldComment: {"body"},
+		},
+	}
+)
+
+// Full usernames, which are looked up exactly, as the index would split
+// them at the `#`. This can't be written in a query; see usernameQuery.
+const fieldUsername repository.QueryField = "#username"
+
+// The query with every term for a user which is a full username looking
+// it up exactly
+func usernameQuery(q repository.Query) repository.Query {
+	term := func(t repository.QueryTerm) repository.QueryTerm {
+		if t.Field == repository.FieldAny || t.Field == repository.FieldUser {
+			if _, err := model.UsernameFromString(t.Text); err == nil {
+				t.Field = fieldUsername
+			}
+		}
+		return t
+	}
+	var r repository.Query
+	for _, c := range q.Clauses {
+		rc := make(repository.QueryClause, len(c))
+		for i, t := range c {
+			rc[i] = term(t)
+		}
+		r.Clauses = append(r.Clauses, rc)
+	}
+	for _, t := range q.Exclude {
+		r.Exclude = append(r.Exclude, term(t))
+	}
+	return r
+}
+
+// Quote text for ParadeDB's query parser, so none of it is syntax
+func quoteQueryText(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// A term in ParadeDB's query syntax, matching if any of the field's
+// columns do.
+func (qt queryTarget) term(t repository.QueryTerm) string {
+	cols := qt.indexed[t.Field]
+	words := []string{t.Text}
+	if t.Phrase && qt.spread[t.Field] && len(cols) > 1 {
+		words = strings.Fields(t.Text)
+	}
+	var must []string
+	for _, w := range words {
+		var either []string
+		for _, c := range cols {
+			either = append(either, c+":"+quoteQueryText(w))
+		}
+		must = append(must, "+("+strings.Join(either, " ")+")")
+	}
+	return "(" + strings.Join(must, " ") + ")"
+}
+
+// The conditions for a row of the target to match a query, with
+// parameters numbered on from those already in `args`. False is
+// returned if nothing in the target can match. There is always a
+// condition on the bm25 index, so results can be scored.
+func (qt queryTarget) where(q repository.Query, args []any) (string, []any, bool) {
+	search, conds, args, ok := qt.split(q, args)
+	if !ok {
+		return "", args, false
+	}
+	search = fmt.Sprintf("%v @@@ %v", qt.key, search)
+	return strings.Join(append([]string{search}, conds...), "\n\t\t AND "), args, true
+}
+
+// The ParadeDB query for a row of the target to match a query, as
+// where gives, for targets which have nothing joined and so can be
+// searched by their index alone.
+func (qt queryTarget) query(q repository.Query, args []any) (string, []any, bool) {
+	search, _, args, ok := qt.split(q, args)
+	return search, args, ok
+}
+
+// A query split into the ParadeDB query on the target's index and the
+// conditions on joined fields, which the index can't answer alone
+func (qt queryTarget) split(q repository.Query, args []any) (string, []string, []any, bool) {
+	q, ok := q.Only(slices.Concat(
+		slices.Collect(maps.Keys(qt.indexed)),
+		slices.Collect(maps.Keys(qt.joined)),
+	)...)
+	if !ok {
+		return "", nil, args, false
+	}
+
+	param := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var (
+		// The parts of the indexed query which must and mustn't match
+		must, mustNot []string
+		// Conditions which the index can't answer alone
+		conds []string
+	)
+	for _, c := range q.Clauses {
+		var indexed, joined []string
+		for _, t := range c {
+			if j, ok := qt.joined[t.Field]; ok {
+				joined = append(joined, j(t, param))
+			} else {
+				indexed = append(indexed, qt.term(t))
+			}
+		}
+		switch {
+		case len(joined) == 0:
+			must = append(must, "+("+strings.Join(indexed, " ")+")")
+		case len(indexed) == 0:
+			conds = append(conds, "("+strings.Join(joined, " OR ")+")")
+		default:
+			conds = append(conds, fmt.Sprintf("(%v @@@ paradedb.parse(%v) OR %v)",
+				qt.key, param(strings.Join(indexed, " ")), strings.Join(joined, " OR "),
+			))
+		}
+	}
+	for _, t := range q.Exclude {
+		if j, ok := qt.joined[t.Field]; ok {
+			conds = append(conds, "NOT "+j(t, param))
+		} else {
+			mustNot = append(mustNot, qt.term(t))
+		}
+	}
+
+	var search string
+	switch {
+	case len(must) > 0:
+		for _, t := range mustNot {
+			must = append(must, "-"+t)
+		}
+		search = fmt.Sprintf("paradedb.parse(%v)", param(strings.Join(must, " ")))
+	case len(mustNot) > 0:
+		search = fmt.Sprintf(
+			"paradedb.boolean(must => ARRAY[paradedb.all()], must_not => ARRAY[paradedb.parse(%v)])",
+			param(strings.Join(mustNot, " ")),
+		)
+	default:
+		search = "paradedb.all()"
+	}
+	return search, conds, args, true
+}
+
+// The most of a field a snippet shows
+const snippetLength int = 200
+
+// ParadeDB's snippet of an indexed column for the search the query
+// makes, marked as repository.Highlight expects. Columns which weren't
+// searched have empty snippets.
+func snippet(col string) string {
+	return fmt.Sprintf(
+		"COALESCE(paradedb.snippet(%v, start_tag => chr(%d), end_tag => chr(%d), max_num_chars => %d), '')",
+		col, repository.SnippetStart[0], repository.SnippetEnd[0], snippetLength,
+	)
+}
+
+// Highlights from the snippets of each field, leaving out those which
+// didn't match
+func highlights(snippets map[string]string) repository.Highlights {
+	var h repository.Highlights
+	for field, s := range snippets {
+		if hl, ok := repository.Highlight(s); ok {
+			if h == nil {
+				h = repository.Highlights{}
+			}
+			h[field] = hl
+		}
+	}
+	return h
+}
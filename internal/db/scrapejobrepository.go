@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type scrapeJobRepository struct {
+	db *pgxpool.Pool
+}
+
+// Useful to check that a type implements an interface
+var _ repository.ScrapeJobManager = (*scrapeJobRepository)(nil)
+
+func newScrapeJobRepository(psql *postgres) repository.ScrapeJobManager {
+	return &scrapeJobRepository{db: psql.db}
+}
+
+const scrapeJobColumns string = `id, query, start_index, page_size, status,
+	attempts, max_attempts, added, COALESCE(last_error, ''),
+	created_at, run_after, started_at, finished_at`
+
+func (r *scrapeJobRepository) scan(row pgx.Row) (*model.ScrapeJob, error) {
+	var (
+		j                 model.ScrapeJob
+		started, finished *time.Time
+	)
+	if err := row.Scan(
+		&j.ID, &j.Query, &j.Offset, &j.Limit, &j.Status,
+		&j.Attempts, &j.MaxAttempts, &j.Added, &j.Error,
+		&j.Created, &j.RunAfter, &started, &finished,
+	); err != nil {
+		return nil, err
+	}
+	if started != nil {
+		j.Started = *started
+	}
+	if finished != nil {
+		j.Finished = *finished
+	}
+	return &j, nil
+}
+
+// Enqueue implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) Enqueue(ctx context.Context, job *model.ScrapeJob, fresh time.Duration) (*model.ScrapeJob, bool, error) {
+	const errorCaller string = "enqueue scrape job"
+	if job.Limit <= 0 || job.Offset < 0 {
+		return nil, false, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: bad range %d+%d", errorCaller, job.Offset, job.Limit),
+		}
+	}
+	if job.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		job.ID = id
+	}
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+
+	existing := func() (*model.ScrapeJob, error) {
+		return r.scan(r.db.QueryRow(ctx,
+			`SELECT `+scrapeJobColumns+`
+			 FROM scrape_jobs
+			 WHERE query = $1 AND start_index = $2 AND page_size = $3
+				 AND (
+					 status IN ('queued', 'running') OR
+					 (status = 'done' AND finished_at > NOW() - make_interval(secs => $4))
+				 )
+			 ORDER BY created_at DESC
+			 LIMIT 1`,
+			job.Query, job.Offset, job.Limit, fresh.Seconds(),
+		))
+	}
+
+	if j, err := existing(); err == nil {
+		return j, false, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	j, err := r.scan(r.db.QueryRow(ctx,
+		`INSERT INTO scrape_jobs (id, query, start_index, page_size, max_attempts)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (query, start_index, page_size)
+			 WHERE status IN ('queued', 'running')
+			 DO NOTHING
+		 RETURNING `+scrapeJobColumns,
+		job.ID, job.Query, job.Offset, job.Limit, maxAttempts,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Someone else queued the same thing between our two queries
+		if j, err = existing(); err != nil {
+			return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		return j, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return j, true, nil
+}
+
+// GetByID implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.ScrapeJob, error) {
+	const errorCaller string = "get scrape job"
+	j, err := r.scan(r.db.QueryRow(ctx,
+		`SELECT `+scrapeJobColumns+` FROM scrape_jobs WHERE id = $1`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no job with ID `%v`", errorCaller, id),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return j, nil
+}
+
+// Claim implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) Claim(ctx context.Context, lease time.Duration) (*model.ScrapeJob, error) {
+	const errorCaller string = "claim scrape job"
+	// Jobs whose lease ran out on their last attempt fail, rather
+	// than being run again by every worker they take down
+	j, err := r.scan(r.db.QueryRow(ctx,
+		`WITH exhausted AS (
+			 UPDATE scrape_jobs SET
+				 status = 'failed',
+				 finished_at = NOW(),
+				 last_error = $2
+			 WHERE status = 'running'
+				 AND started_at < NOW() - make_interval(secs => $1)
+				 AND attempts >= max_attempts
+		 )
+		 UPDATE scrape_jobs SET
+			 status = 'running',
+			 attempts = attempts + 1,
+			 started_at = NOW()
+		 WHERE id = (
+			 SELECT id FROM scrape_jobs
+			 WHERE (status = 'queued' AND run_after <= NOW())
+				 OR (status = 'running' AND started_at < NOW() - make_interval(secs => $1)
+					 AND attempts < max_attempts)
+			 ORDER BY run_after, created_at
+			 FOR UPDATE SKIP LOCKED
+			 LIMIT 1
+		 )
+		 RETURNING `+scrapeJobColumns,
+		lease.Seconds(), errLeaseExpired,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no runnable jobs", errorCaller),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return j, nil
+}
+
+// Complete implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) Complete(ctx context.Context, id uuid.UUID, attempt int, added int) error {
+	const errorCaller string = "complete scrape job"
+	if tag, err := r.db.Exec(ctx,
+		`UPDATE scrape_jobs SET
+			 status = 'done',
+			 added = $2,
+			 last_error = NULL,
+			 finished_at = NOW()
+		 WHERE id = $1 AND status = 'running' AND attempts = $3`,
+		id, added, attempt,
+	); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return lostClaim(errorCaller, id, attempt)
+	}
+	return nil
+}
+
+// Fail implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) Fail(ctx context.Context, id uuid.UUID, attempt int, cause error, backoff time.Duration) error {
+	const errorCaller string = "fail scrape job"
+	var reason string
+	if cause != nil {
+		reason = cause.Error()
+	}
+	if tag, err := r.db.Exec(ctx,
+		`UPDATE scrape_jobs SET
+			 status = CASE WHEN attempts >= max_attempts
+				 THEN 'failed' ELSE 'queued' END,
+			 finished_at = CASE WHEN attempts >= max_attempts
+				 THEN NOW() ELSE NULL END,
+			 run_after = NOW() + make_interval(secs => $3),
+			 last_error = $2
+		 WHERE id = $1 AND status = 'running' AND attempts = $4`,
+		id, reason, backoff.Seconds(), attempt,
+	); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return lostClaim(errorCaller, id, attempt)
+	}
+	return nil
+}
+
+// Release implements repository.ScrapeJobManager.
+func (r *scrapeJobRepository) Release(ctx context.Context, id uuid.UUID, attempt int) error {
+	const errorCaller string = "release scrape job"
+	if tag, err := r.db.Exec(ctx,
+		`UPDATE scrape_jobs SET
+			 status = 'queued',
+			 attempts = attempts - 1,
+			 started_at = NULL,
+			 run_after = NOW()
+		 WHERE id = $1 AND status = 'running' AND attempts = $2`,
+		id, attempt,
+	); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return lostClaim(errorCaller, id, attempt)
+	}
+	return nil
+}
+
+// Recorded against a job whose worker never finished its last attempt
+const errLeaseExpired string = "lease expired before the job finished"
+
+// The error for a job which isn't running under a claim any more
+func lostClaim(errorCaller string, id uuid.UUID, attempt int) error {
+	return repository.Err{
+		Code: repository.ErrConflict,
+		Err:  fmt.Errorf("%v: job `%v` is no longer running as attempt %d", errorCaller, id, attempt),
+	}
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type seriesRepository struct {
+	db *pgxpool.Pool
+}
+
+// Useful to check that a type implements an interface
+var _ repository.SeriesManager = (*seriesRepository)(nil)
+
+func newSeriesRepository(psql *postgres) repository.SeriesManager {
+	return &seriesRepository{db: psql.db}
+}
+
+// GetByID implements repository.SeriesManager.
+func (r *seriesRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Series, error) {
+	const errorCaller string = "get series"
+	s, err := r.get(ctx, r.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return s, nil
+}
+
+func (r *seriesRepository) get(ctx context.Context, q querier, id uuid.UUID) (*model.Series, error) {
+	var (
+		s       model.Series
+		entries []byte
+	)
+	err := q.QueryRow(ctx,
+		`SELECT
+			 s.id,
+			 s.name,
+			 COALESCE(s.description, ''),
+			 COALESCE(
+				 jsonb_agg(jsonb_build_object(
+					 'book', b.id,
+					 'title', b.title,
+					 'position', sb.position
+				 ) ORDER BY sb.position, b.published, b.id) FILTER (WHERE b.id IS NOT NULL),
+				 '[]'::jsonb
+			 )
+		 FROM series s
+		 LEFT JOIN books_series sb ON sb.series_id = s.id
+		 LEFT JOIN books b ON b.id = sb.book_id
+		 WHERE s.id = $1
+		 GROUP BY s.id`,
+		id,
+	).Scan(&s.ID, &s.Name, &s.Description, &entries)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("no series with ID `%v`", id),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(entries, &s.Entries); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create implements repository.SeriesManager.
+func (r *seriesRepository) Create(ctx context.Context, s *model.Series) error {
+	const errorCaller string = "create series"
+	if err := s.Validate(); err != nil {
+		return repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: %w", errorCaller, err),
+		}
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx,
+		`INSERT INTO series (id, name, description)
+		 VALUES (COALESCE($1, gen_random_uuid()), $2, NULLIF($3, ''))
+		 RETURNING id`,
+		nullableID(s.ID), s.Name, s.Description,
+	).Scan(&s.ID); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = r.setEntries(ctx, tx, s.ID, s.Entries); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Update implements repository.SeriesManager.
+func (r *seriesRepository) Update(ctx context.Context, s *model.Series) (*model.Series, error) {
+	const errorCaller string = "update series"
+	if err := s.Validate(); err != nil {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: %w", errorCaller, err),
+		}
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE series SET (name, description) = ($2, NULLIF($3, ''))
+		 WHERE id = $1`,
+		s.ID, s.Name, s.Description,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no series with ID `%v`", errorCaller, s.ID),
+		}
+	}
+	if err = r.setEntries(ctx, tx, s.ID, s.Entries); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	updated, err := r.get(ctx, tx, s.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return updated, nil
+}
+
+// Replace a series' entries. Books which were in other series are
+// moved into this one.
+func (r *seriesRepository) setEntries(ctx context.Context, tx pgx.Tx, id uuid.UUID, entries []model.SeriesEntry) error {
+	books := make(uuid.UUIDs, len(entries))
+	positions := make([]float64, len(entries))
+	for i, e := range entries {
+		books[i], positions[i] = e.Book, e.Position
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM books_series WHERE series_id = $1 AND NOT book_id = ANY($2)`,
+		id, books,
+	); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO books_series (book_id, series_id, position)
+		 SELECT m.book_id, $1, m.position
+		 FROM unnest($2::UUID[], $3::NUMERIC[]) AS m(book_id, position)
+		 JOIN books b ON b.id = m.book_id
+		 ON CONFLICT (book_id) DO UPDATE
+		 SET (series_id, position) = (EXCLUDED.series_id, EXCLUDED.position)`,
+		id, books, positions,
+	)
+	if err != nil {
+		return err
+	} else if int(tag.RowsAffected()) != len(entries) {
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("not every book in the series exists"),
+		}
+	}
+	return nil
+}
+
+// Delete implements repository.SeriesManager.
+func (r *seriesRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	const errorCaller string = "delete series"
+	// Membership cascades
+	if _, err := r.db.Exec(ctx, `DELETE FROM series WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return nil
+}
+
+// Resolve implements repository.SeriesManager.
+func (r *seriesRepository) Resolve(ctx context.Context, name string) (*model.Series, error) {
+	const errorCaller string = "resolve series"
+	if err := (model.Series{Name: name}).Validate(); err != nil {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: %w", errorCaller, err),
+		}
+	}
+	var id uuid.UUID
+	err := r.db.QueryRow(ctx,
+		`SELECT id FROM series
+		 WHERE lower(name) = lower($1)
+		 ORDER BY created_at, id
+		 LIMIT 1`,
+		name,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = r.db.QueryRow(ctx,
+			`INSERT INTO series (name) VALUES ($1) RETURNING id`,
+			name,
+		).Scan(&id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: `%v`: %w", errorCaller, name, err)
+	}
+	return r.GetByID(ctx, id)
+}
+
+// Place implements repository.SeriesManager.
+func (r *seriesRepository) Place(ctx context.Context, seriesID, bookID uuid.UUID, position float64) error {
+	const errorCaller string = "place book in series"
+	if position < 0 {
+		return repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: negative position %v", errorCaller, position),
+		}
+	}
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO books_series (book_id, series_id, position)
+		 SELECT b.id, s.id, $3
+		 FROM books b, series s
+		 WHERE b.id = $1 AND s.id = $2
+		 ON CONFLICT (book_id) DO UPDATE
+		 SET (series_id, position) = (EXCLUDED.series_id, EXCLUDED.position)`,
+		bookID, seriesID, position,
+	)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if tag.RowsAffected() == 0 {
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no book `%v` or series `%v`", errorCaller, bookID, seriesID),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type subjectRepository struct {
+	db *pgxpool.Pool
+}
+
+// Useful to check that a type implements an interface
+var _ repository.SubjectManager = (*subjectRepository)(nil)
+
+func newSubjectRepository(psql *postgres) repository.SubjectManager {
+	return &subjectRepository{db: psql.db}
+}
+
+// Walk the taxonomy from the top to work out each subject's path. The
+// taxonomy is small enough that this is cheaper than storing paths and
+// keeping them up to date.
+func (r *subjectRepository) queryString(clause string) string {
+	return fmt.Sprintf(`WITH RECURSIVE t (id, parent_id, name, path) AS (
+			 SELECT id, parent_id, name, ARRAY[name]
+			 FROM subjects
+			 WHERE parent_id IS NULL
+		 UNION ALL
+			 SELECT s.id, s.parent_id, s.name, t.path || s.name
+			 FROM subjects s
+			 JOIN t ON s.parent_id = t.id
+		 )
+		 SELECT id, parent_id, name, path
+		 FROM t
+		 WHERE %v
+		 ORDER BY path`, clause)
+}
+
+func (r *subjectRepository) scan(rows pgx.Rows) (*model.Subject, error) {
+	var (
+		s      model.Subject
+		parent *uuid.UUID
+	)
+	if err := rows.Scan(&s.ID, &parent, &s.Name, &s.Path); err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		s.Parent = *parent
+	}
+	return &s, nil
+}
+
+func (r *subjectRepository) getWhere(ctx context.Context, clause string, vals ...any) ([]*model.Subject, error) {
+	rows, err := r.db.Query(ctx, r.queryString(clause), vals...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subjects []*model.Subject
+	for rows.Next() {
+		s, err := r.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, s)
+	}
+	return subjects, rows.Err()
+}
+
+// GetByID implements repository.SubjectManager.
+func (r *subjectRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Subject, error) {
+	const errorCaller string = "get subject"
+	subjects, err := r.getWhere(ctx, "id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	} else if len(subjects) == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no subject with ID `%v`", errorCaller, id),
+		}
+	}
+	return subjects[0], nil
+}
+
+// List implements repository.SubjectManager.
+func (r *subjectRepository) List(ctx context.Context) ([]*model.Subject, error) {
+	const errorCaller string = "list subjects"
+	subjects, err := r.getWhere(ctx, "TRUE")
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return subjects, nil
+}
+
+// Resolve implements repository.SubjectManager.
+func (r *subjectRepository) Resolve(ctx context.Context, path []string) (*model.Subject, error) {
+	const errorCaller string = "resolve subject"
+	if len(path) == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: empty path", errorCaller),
+		}
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The no-op update is so that RETURNING gives us the existing row
+	var parent *uuid.UUID
+	for _, name := range path {
+		var id uuid.UUID
+		if err = tx.QueryRow(ctx,
+			`INSERT INTO subjects (parent_id, name)
+			 VALUES ($1, $2)
+			 ON CONFLICT ON CONSTRAINT unique_sibling_subject
+			 DO UPDATE SET name = subjects.name
+			 RETURNING id`,
+			parent, name,
+		).Scan(&id); err != nil {
+			return nil, fmt.Errorf("%v: `%v`: %w", errorCaller, name, err)
+		}
+		parent = &id
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return r.GetByID(ctx, *parent)
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// A LIKE pattern for anything starting with `prefix`
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// Suggestions from the rows of `from` whose `match` expression starts
+// with the prefix, or has a word resembling it going by pg_trgm's word
+// similarity. Prefixes rank above resemblances, and shorter names above
+// longer ones. `match` should have a trigram index, or this will be far
+// too slow to keep up with typing.
+func suggest(ctx context.Context, db querier, id, match, text, from, prefix string, limit int) ([]repository.Suggestion, error) {
+	rows, err := db.Query(ctx, fmt.Sprintf(
+		`SELECT s.id, s.text, s.score FROM (
+			 SELECT %[1]v AS id, %[3]v AS text, length(%[2]v) AS length,
+				 CASE WHEN %[2]v ILIKE $2 THEN 1
+				 ELSE word_similarity($1, %[2]v) END AS score
+			 FROM %[4]v
+			 WHERE %[2]v ILIKE $2 OR $1 <%% %[2]v
+		 ) s
+		 ORDER BY s.score DESC, s.length, s.text, s.id
+		 LIMIT $3`,
+		id, match, text, from),
+		prefix, likePrefix(prefix), min(limit, repository.MaxSuggestions),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []repository.Suggestion
+	for rows.Next() {
+		var (
+			s     repository.Suggestion
+			score float32
+		)
+		if err := rows.Scan(&s.ID, &s.Text, &score); err != nil {
+			return nil, err
+		}
+		s.Score = float64(score)
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
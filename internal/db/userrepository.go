@@ -44,10 +44,10 @@ func (u *userRepository) Create(ctx context.Context, t *model.User) error {
 
 	if _, err = tx.Exec(ctx,
 		`INSERT INTO users (id, github_id, display_name, handle,
-		 	discriminator, email, avatar, superuser)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		 	discriminator, email, avatar, superuser, unlisted)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
 		t.ID, t.GithubID, t.DisplayName, handle, discriminator,
-		t.Email, t.Avatar, t.Admin,
+		t.Email, t.Avatar, t.Admin, t.Unlisted,
 	); err != nil {
 		return fmt.Errorf("create user: %w", err)
 	}
@@ -126,7 +126,8 @@ func (u *userRepository) getByColumn(ctx context.Context, col, match string) (*m
 			 u.discriminator,
 			 COALESCE(u.email, ''),
 			 u.avatar,
-		 	 u.superuser
+		 	 u.superuser,
+			 u.unlisted
 		 FROM users u
 		 WHERE %v = $1
 		 GROUP BY u.id`,
@@ -136,6 +137,7 @@ func (u *userRepository) getByColumn(ctx context.Context, col, match string) (*m
 		query, match,
 	).Scan(&user.ID, &user.GithubID, &user.DisplayName, &user.Pronouns,
 		&handle, &discriminator, &user.Email, &user.Avatar, &user.Admin,
+		&user.Unlisted,
 	); err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
 	}
@@ -176,8 +178,79 @@ func (u *userRepository) Permissions(ctx context.Context, userID uuid.UUID) (boo
 }
 
 // Search implements repository.UserManager.
-func (u *userRepository) Search(ctx context.Context) ([]model.User, error) {
-	panic("unimplemented")
+func (u *userRepository) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.UserProfile], []repository.AnyScoreItemer, error) {
+	return u.SearchQuery(ctx, page, repository.PlainQuery(query...))
+}
+
+// SearchQuery implements repository.UserManager.
+func (u *userRepository) SearchQuery(ctx context.Context, page repository.SearchPage, query repository.Query) ([]repository.SearchResult[model.UserProfile], []repository.AnyScoreItemer, error) {
+	const errorCaller string = "user search"
+	var resultsT []repository.SearchResult[model.UserProfile]
+	var resultsASI []repository.AnyScoreItemer
+
+	search, args, ok := userQueryTarget.where(usernameQuery(query), nil)
+	if !ok {
+		return resultsT, resultsASI, nil
+	}
+	sql, args := searchPage(
+		fmt.Sprintf(`SELECT
+			 paradedb.score(u.id) AS score,
+			 %v,
+			 %v,
+			 u.id,
+			 COALESCE(u.display_name, u.handle),
+			 COALESCE(u.pronouns, ''),
+			 u.handle,
+			 u.discriminator,
+			 u.avatar
+		 FROM users u
+		 WHERE %v
+		 AND NOT u.unlisted`,
+			snippet("u.handle"), snippet("u.display_name"), search,
+		),
+		page,
+		args,
+	)
+	rows, err := u.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			s             float64
+			p             model.UserProfile
+			handle        string
+			discriminator int16
+			// Snippets of the handle and display name
+			sH, sN string
+		)
+		if err = rows.Scan(
+			&s, &sH, &sN, &p.ID, &p.DisplayName, &p.Pronouns,
+			&handle, &discriminator, &p.Avatar,
+		); err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		if p.Username, err = model.UsernameFromComponents(handle, discriminator); err != nil {
+			return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+
+		r := repository.SearchResult[model.UserProfile]{
+			Item:  &p,
+			ID:    p.ID,
+			Score: s,
+			Highlights: highlights(map[string]string{
+				// Handles are short enough to always be whole
+				"username": sH + fmt.Sprintf("#%04d", discriminator),
+				"name":     sN,
+			}),
+		}
+		resultsT = append(resultsT, r)
+		resultsASI = append(resultsASI, r)
+	}
+
+	return resultsT, resultsASI, rows.Err()
 }
 
 // Update implements repository.UserManager.
@@ -215,12 +288,13 @@ func (u *userRepository) Update(ctx context.Context, to *model.User) (*model.Use
 			 handle,
 			 discriminator,
 			 email,
-			 avatar
+			 avatar,
+			 unlisted
 		 ) = (
-			 $2, $3, $4, $5, $6, $7, $8
+			 $2, $3, $4, $5, $6, $7, $8, $9
 		 ) WHERE id=$1`,
 		to.ID, to.GithubID, to.DisplayName, to.Pronouns, handle,
-		discriminator, to.Email, to.Avatar,
+		discriminator, to.Email, to.Avatar, to.Unlisted,
 	); err != nil {
 		return nil, fmt.Errorf("%v: %w", errorCaller, err)
 	}
@@ -266,3 +340,17 @@ func (u *userRepository) findDiscriminator(ctx context.Context, handle string) (
 	}
 	return discriminator, nil
 }
+
+// Suggest implements repository.UserManager.
+func (u *userRepository) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	const errorCaller string = "suggest users"
+	s, err := suggest(ctx, u.db, "u.id", "u.handle",
+		"u.handle || '#' || lpad(u.discriminator::TEXT, 4, '0')",
+		"(SELECT * FROM users WHERE NOT unlisted) u",
+		prefix, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return s, nil
+}
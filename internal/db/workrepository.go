@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type workRepository struct {
+	db *pgxpool.Pool
+}
+
+// Useful to check that a type implements an interface
+var _ repository.WorkManager = (*workRepository)(nil)
+
+func newWorkRepository(psql *postgres) repository.WorkManager {
+	return &workRepository{db: psql.db}
+}
+
+// GetByID implements repository.WorkManager.
+func (r *workRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Work, error) {
+	const errorCaller string = "get work"
+	w, err := r.get(ctx, r.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return w, nil
+}
+
+func (r *workRepository) get(ctx context.Context, q querier, id uuid.UUID) (*model.Work, error) {
+	var w model.Work
+	err := q.QueryRow(ctx,
+		`SELECT
+			 w.id,
+			 w.title,
+			 COALESCE(
+				 array_agg(b.id ORDER BY b.published, b.id) FILTER (WHERE b.id IS NOT NULL),
+				 '{}'
+			 )
+		 FROM works w
+		 LEFT JOIN books b ON b.work_id = w.id
+		 WHERE w.id = $1
+		 GROUP BY w.id`,
+		id,
+	).Scan(&w.ID, &w.Title, &w.Editions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("no work with ID `%v`", id),
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Join implements repository.WorkManager.
+func (r *workRepository) Join(ctx context.Context, workID uuid.UUID, editions ...uuid.UUID) (*model.Work, error) {
+	const errorCaller string = "join editions"
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.QueryRow(ctx,
+		`SELECT id FROM works WHERE id = $1 FOR UPDATE`,
+		workID,
+	).Scan(new(uuid.UUID)); errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no work with ID `%v`", errorCaller, workID),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = r.move(ctx, tx, workID, editions); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	w, err := r.get(ctx, tx, workID)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return w, nil
+}
+
+// Split implements repository.WorkManager.
+func (r *workRepository) Split(ctx context.Context, editions ...uuid.UUID) (*model.Work, error) {
+	const errorCaller string = "split editions"
+	if len(editions) == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: no editions given", errorCaller),
+		}
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The new work is named after the first edition given
+	var workID uuid.UUID
+	if err = tx.QueryRow(ctx,
+		`INSERT INTO works (title)
+		 SELECT title FROM books WHERE id = $1
+		 RETURNING id`,
+		editions[0],
+	).Scan(&workID); errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no book with ID `%v`", errorCaller, editions[0]),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = r.move(ctx, tx, workID, editions); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	w, err := r.get(ctx, tx, workID)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return w, nil
+}
+
+// Move editions into a work by hand, pinning them there
+func (r *workRepository) move(ctx context.Context, tx pgx.Tx, workID uuid.UUID, editions uuid.UUIDs) error {
+	var from uuid.UUIDs
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(array_agg(DISTINCT work_id), '{}')
+		 FROM (SELECT work_id FROM books WHERE id = ANY($1) FOR UPDATE) b`,
+		editions,
+	).Scan(&from); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx,
+		`UPDATE books SET (work_id, work_pinned) = ($1, true)
+		 WHERE id = ANY($2)`,
+		workID, editions,
+	)
+	if err != nil {
+		return err
+	} else if int(tag.RowsAffected()) != len(editions) {
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("not every book in %v exists", editions),
+		}
+	}
+	return r.prune(ctx, tx, workID, from)
+}
+
+// Delete those of `works` which no longer have any editions, moving
+// their reviews into `into`. A user may only review a work once, so
+// where they reviewed more than one of them only the newest review is
+// kept.
+func (r *workRepository) prune(ctx context.Context, tx pgx.Tx, into uuid.UUID, works uuid.UUIDs) error {
+	const empty string = `SELECT w.id FROM works w
+		 WHERE w.id = ANY($2) AND w.id <> $1
+		 AND NOT EXISTS (SELECT 1 FROM books b WHERE b.work_id = w.id)`
+	// Deleting a comment only blanks it out, see comment_faux_delete
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM comments c
+		 WHERE c.parent_comment_id IS NULL AND c.poster_id IS NOT NULL
+		 AND (c.work_id = $1 OR c.work_id IN (`+empty+`))
+		 AND EXISTS (
+			 SELECT 1 FROM comments n
+			 WHERE n.poster_id = c.poster_id AND n.parent_comment_id IS NULL
+			 AND (n.work_id = $1 OR n.work_id IN (`+empty+`))
+			 AND (n.created_at, n.id) > (c.created_at, c.id)
+		 )`,
+		into, works,
+	); err != nil {
+		return fmt.Errorf("settle duplicate reviews: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE comments SET work_id = $1
+		 WHERE work_id IN (`+empty+`)`,
+		into, works,
+	); err != nil {
+		return fmt.Errorf("move reviews: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM works WHERE id IN (`+empty+`)`,
+		into, works,
+	); err != nil {
+		return fmt.Errorf("delete empty works: %w", err)
+	}
+	return nil
+}
+
+// Cluster implements repository.WorkManager.
+func (r *workRepository) Cluster(ctx context.Context) (int, error) {
+	const errorCaller string = "cluster editions"
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Keep anyone else from moving editions around underneath us
+	rows, err := tx.Query(ctx,
+		`SELECT
+			 b.id,
+			 b.work_id,
+			 b.title,
+			 ARRAY(
+				 SELECT author_id FROM books_authors
+				 WHERE book_id = b.id AND role = 'author'
+			 )
+		 FROM books b
+		 WHERE NOT b.work_pinned
+		 FOR UPDATE`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var editions []*model.Book
+	for rows.Next() {
+		var e model.Book
+		if err = rows.Scan(&e.ID, &e.Work, &e.Title, &e.AuthorIDs); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		editions = append(editions, &e)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	moves := model.ClusterEditions(editions)
+	if len(moves) == 0 {
+		return 0, nil
+	}
+	var (
+		ids, targets uuid.UUIDs
+		from         = map[uuid.UUID]uuid.UUIDs{}
+	)
+	for _, e := range editions {
+		if to, ok := moves[e.ID]; ok {
+			ids = append(ids, e.ID)
+			targets = append(targets, to)
+			from[to] = append(from[to], e.Work)
+		}
+	}
+	if _, err = tx.Exec(ctx,
+		`UPDATE books b SET work_id = m.work_id
+		 FROM unnest($1::UUID[], $2::UUID[]) AS m(id, work_id)
+		 WHERE b.id = m.id`,
+		ids, targets,
+	); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	for to, works := range from {
+		if err = r.prune(ctx, tx, to, works); err != nil {
+			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return len(moves), nil
+}
@@ -4,7 +4,6 @@ import (
 	"reflect"
 	"time"
 
-	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
 )
@@ -19,7 +18,7 @@ var ExampleBooks []model.Book = []model.Book{
 		},
 		Title:     "A Tale of Two Cities",
 		AuthorIDs: uuid.UUIDs{uuid.MustParse("01959161-cdfc-7142-8bab-a7008477f417")},
-		Published: civil.Date{Year: 1859, Month: time.November, Day: 26},
+		Published: model.PartialDate{Year: 1859, Month: time.November, Day: 26},
 	}, {
 		ID: uuid.MustParse("0124e053-3580-7000-875a-c17e9ba5023c"),
 		ISBNs: []model.ISBN{
@@ -28,7 +27,7 @@ var ExampleBooks []model.Book = []model.Book{
 		},
 		Title:     "The Little Prince",
 		AuthorIDs: uuid.UUIDs{uuid.MustParse("01959161-cdfc-7c45-91e3-9c785be04942")},
-		Published: civil.Date{Year: 1943, Month: time.April},
+		Published: model.PartialDate{Year: 1943, Month: time.April},
 	}, {
 		ID: uuid.MustParse("0124e053-3580-7000-9127-dd33bb29c893"),
 		ISBNs: []model.ISBN{
@@ -37,7 +36,7 @@ var ExampleBooks []model.Book = []model.Book{
 		},
 		Title:     "The Alchemist",
 		AuthorIDs: uuid.UUIDs{uuid.MustParse("01959161-cdfc-77a4-930d-0732bbf87ea6")},
-		Published: civil.Date{Year: 1988},
+		Published: model.PartialDate{Year: 1988},
 	},
 }
 
@@ -49,7 +48,7 @@ var ExampleBook model.Book = model.Book{
 	},
 	Title:     "Oliver Twist",
 	AuthorIDs: uuid.UUIDs{uuid.MustParse("01959161-cdfc-7142-8bab-a7008477f417")},
-	Published: civil.Date{Year: 1837, Month: time.February},
+	Published: model.PartialDate{Year: 1837, Month: time.February},
 }
 
 // Known dead book, will not link to any author.
@@ -61,7 +60,7 @@ var DeadBook model.Book = model.Book{
 	},
 	Title:     "Dream of the Red Chamber",
 	AuthorIDs: uuid.UUIDs{uuid.MustParse("00000000-0000-8000-0000-100000000000")},
-	Published: civil.Date{Year: 1791},
+	Published: model.PartialDate{Year: 1791},
 }
 
 // Bad way to test equivalence of two books
@@ -3,6 +3,9 @@ package mockdatastore
 import (
 	"context"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -13,16 +16,24 @@ import (
 
 // AuthorRepo implements AuthorManager.
 type AuthorRepo[S comparable] struct {
-	book    repository.BookManager[S]
-	mut     sync.RWMutex
-	authors map[uuid.UUID]*model.Author
+	book      repository.BookManager[S]
+	mut       sync.RWMutex
+	authors   map[uuid.UUID]*model.Author
+	redirects map[uuid.UUID]authorRedirect
+}
+
+// Where an author which was merged away now lives
+type authorRedirect struct {
+	to   uuid.UUID
+	name string
 }
 
 var _ repository.AuthorManager[string] = (*AuthorRepo[string])(nil)
 
 func NewInMemoryAuthorManager[S comparable]() *AuthorRepo[S] {
 	return &AuthorRepo[S]{
-		authors: make(map[uuid.UUID]*model.Author),
+		authors:   make(map[uuid.UUID]*model.Author),
+		redirects: make(map[uuid.UUID]authorRedirect),
 	}
 }
 
@@ -62,9 +73,15 @@ func (m *AuthorRepo[S]) GetByID(ctx context.Context, id uuid.UUID) (*model.Autho
 	m.mut.RLock()
 	defer m.mut.RUnlock()
 
+	if r, redirected := m.redirects[id]; redirected {
+		id = r.to
+	}
 	author, exists := m.authors[id]
 	if !exists {
-		return nil, fmt.Errorf("author not found")
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("no author with ID `%v`", id),
+		}
 	}
 	return author, nil
 }
@@ -73,7 +90,25 @@ func (m *AuthorRepo[S]) ExistsByName(ctx context.Context, name string) (*model.A
 	m.mut.RLock()
 	defer m.mut.RUnlock()
 	for _, author := range m.authors {
-		if author.GivenName == name || author.FamilyName == name {
+		if author.GivenName == name || author.FamilyName == name ||
+			fullName(author) == name {
+			return author, true, nil
+		}
+	}
+	for _, r := range m.redirects {
+		if r.name == name {
+			return m.authors[r.to], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// ExistsByExtID implements repository.AuthorManager.
+func (m *AuthorRepo[S]) ExistsByExtID(ctx context.Context, id model.AuthorIDs) (*model.Author, bool, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+	for _, author := range m.authors {
+		if slices.Contains(author.ExtIDs, id) {
 			return author, true, nil
 		}
 	}
@@ -82,16 +117,179 @@ func (m *AuthorRepo[S]) ExistsByName(ctx context.Context, name string) (*model.A
 
 // Delete implements repository.AuthorManager.
 func (m *AuthorRepo[S]) Delete(ctx context.Context, authorID uuid.UUID) error {
+	if m.book != nil {
+		books, _, err := m.book.Author(ctx, authorID,
+			repository.BibliographyOptions{Limit: 1})
+		if err != nil {
+			return err
+		} else if len(books) > 0 {
+			return repository.ErrConflict
+		}
+	}
+
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
+	if _, exists := m.authors[authorID]; !exists {
+		return repository.ErrNotFound
+	}
 	delete(m.authors, authorID)
+	for k, r := range m.redirects {
+		if r.to == authorID {
+			delete(m.redirects, k)
+		}
+	}
+	return nil
+}
+
+// Merge implements repository.AuthorManager.
+func (m *AuthorRepo[S]) Merge(ctx context.Context, into uuid.UUID, from ...uuid.UUID) (*model.Author, error) {
+	from = slices.DeleteFunc(slices.Clone(from), func(id uuid.UUID) bool {
+		return id == into
+	})
+	if len(from) == 0 {
+		return nil, repository.ErrInvalidInput
+	}
+	m.mut.RLock()
+	for _, id := range append(uuid.UUIDs{into}, from...) {
+		if _, exists := m.authors[id]; !exists {
+			m.mut.RUnlock()
+			return nil, repository.ErrNotFound
+		}
+	}
+	m.mut.RUnlock()
+
+	// Repoint books first, the book manager calls back in to this one
+	if m.book != nil {
+		for _, id := range from {
+			if err := m.repointBooks(ctx, id, into); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	canonical := m.authors[into]
+	for _, id := range from {
+		old := m.authors[id]
+		canonical.ExtIDs = append(canonical.ExtIDs, old.ExtIDs...)
+		for k, r := range m.redirects {
+			if r.to == id {
+				m.redirects[k] = authorRedirect{to: into, name: r.name}
+			}
+		}
+		m.redirects[id] = authorRedirect{to: into, name: fullName(old)}
+		delete(m.authors, id)
+	}
+	return canonical, nil
+}
+
+func (m *AuthorRepo[S]) repointBooks(ctx context.Context, from, into uuid.UUID) error {
+	// Collect everything up front, as moving a book off of `from`
+	// would invalidate the cursor
+	var ids uuid.UUIDs
+	opts := repository.BibliographyOptions{}
+	for {
+		page, next, err := m.book.Author(ctx, from, opts)
+		if err != nil {
+			return err
+		}
+		for _, s := range page {
+			ids = append(ids, s.ID)
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+
+	for _, id := range ids {
+		b, err := m.book.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		// The datastore works out the authors, and drops any credit
+		// this makes a duplicate of
+		updated := *b
+		updated.Contributors = []model.Contributor{}
+		for _, c := range b.Contributors {
+			if c.ID == from {
+				c.ID = into
+			}
+			updated.Contributors = append(updated.Contributors, c)
+		}
+		if _, err = m.book.Update(ctx, &updated); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func fullName(a *model.Author) string {
+	return strings.TrimSpace(a.GivenName + " " + a.FamilyName)
+}
+
 // Search implements repository.AuthorManager.
-func (m *AuthorRepo[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
-	panic("unimplemented")
+func (m *AuthorRepo[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
+	return m.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.AuthorFilter{})
+}
+
+// SearchFiltered implements repository.AuthorManager.
+func (m *AuthorRepo[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.AuthorFilter) ([]repository.SearchResult[model.Author], []repository.AnyScoreItemer, error) {
+	// Find who is credited on the books the filter allows before
+	// taking the author lock, as summarizing books takes it too
+	var credited map[uuid.UUID]bool
+	if !filter.IsZero() {
+		books, _, err := m.book.SearchFiltered(ctx,
+			repository.SearchPage{Limit: math.MaxInt}, repository.Query{}, filter.Books,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+		credited = make(map[uuid.UUID]bool)
+		for _, b := range books {
+			for _, c := range b.Item.Contributors {
+				if len(filter.Roles) == 0 || slices.Contains(filter.Roles, c.Role) {
+					credited[c.ID] = true
+				}
+			}
+		}
+	}
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	// As with books, every match scores the same
+	var resultsT []repository.SearchResult[model.Author]
+	for _, a := range m.authors {
+		if credited != nil && !credited[a.ID] {
+			continue
+		}
+		name := fullName(a)
+		if queryMatches(query, map[repository.QueryField][]string{
+			repository.FieldAny:    {name},
+			repository.FieldAuthor: {name},
+		}) {
+			c := *a
+			resultsT = append(resultsT, repository.SearchResult[model.Author]{
+				Item: &c, ID: a.ID, Score: 1.0,
+				Highlights: queryHighlights(query, map[string]string{
+					"given_name":  a.GivenName,
+					"family_name": a.FamilyName,
+				}, map[string][]repository.QueryField{
+					"given_name":  repository.AuthorQueryFields,
+					"family_name": repository.AuthorQueryFields,
+				}),
+			})
+		}
+	}
+	resultsT = searchPage(resultsT, page)
+	resultsASI := make([]repository.AnyScoreItemer, len(resultsT))
+	for i, r := range resultsT {
+		resultsASI[i] = r
+	}
+	return resultsT, resultsASI, nil
 }
 
 // Update implements repository.AuthorManager.
@@ -99,6 +297,24 @@ func (m *AuthorRepo[S]) Update(ctx context.Context, to *model.Author) (*model.Au
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
+	if _, exists := m.authors[to.ID]; !exists {
+		return nil, repository.ErrNotFound
+	}
 	m.authors[to.ID] = to
 	return to, nil
 }
+
+// Suggest implements repository.AuthorManager.
+func (m *AuthorRepo[S]) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	var s []repository.Suggestion
+	for _, a := range m.authors {
+		name := fullName(a)
+		if score := suggestScore(name, prefix); score > 0 {
+			s = append(s, repository.Suggestion{ID: a.ID, Text: name, Score: score})
+		}
+	}
+	return topSuggestions(s, limit), nil
+}
@@ -0,0 +1,86 @@
+package mockdatastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func TestAuthorRepo_Merge(t *testing.T) {
+	ctx := context.Background()
+	repo, canonical, books := bibliographyRepo(t, 2)
+
+	dupe := &model.Author{FamilyName: "C. Dickens"}
+	require.NoError(t, repo.Author.Create(ctx, dupe))
+	// One book is credited to both, which should collapse to one
+	// credit, the other is only on the duplicate
+	books[0].AuthorIDs = uuid.UUIDs{canonical.ID, dupe.ID}
+	_, err := repo.Book.Update(ctx, books[0])
+	require.NoError(t, err)
+	third := &model.Book{Title: "Third", AuthorIDs: uuid.UUIDs{dupe.ID}}
+	require.NoError(t, repo.Book.Create(ctx, third))
+
+	merged, err := repo.Author.Merge(ctx, canonical.ID, dupe.ID)
+	require.NoError(t, err)
+	assert.Equal(t, canonical.ID, merged.ID)
+
+	page, _, err := repo.Book.Author(ctx, canonical.ID, repository.BibliographyOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page, 3)
+	b, err := repo.Book.GetByID(ctx, books[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.UUIDs{canonical.ID}, b.AuthorIDs)
+
+	// The old ID and name both lead to the canonical author now
+	a, err := repo.Author.GetByID(ctx, dupe.ID)
+	require.NoError(t, err)
+	assert.Equal(t, canonical.ID, a.ID)
+	a, exists, err := repo.Author.ExistsByName(ctx, "C. Dickens")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, canonical.ID, a.ID)
+}
+
+func TestAuthorRepo_MergeChain(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+	a, b, c := &model.Author{FamilyName: "A"}, &model.Author{FamilyName: "B"}, &model.Author{FamilyName: "C"}
+	for _, v := range []*model.Author{a, b, c} {
+		require.NoError(t, repo.Author.Create(ctx, v))
+	}
+
+	_, err := repo.Author.Merge(ctx, b.ID, a.ID)
+	require.NoError(t, err)
+	_, err = repo.Author.Merge(ctx, c.ID, b.ID)
+	require.NoError(t, err)
+
+	got, err := repo.Author.GetByID(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, got.ID)
+}
+
+func TestAuthorRepo_MergeInvalid(t *testing.T) {
+	ctx := context.Background()
+	repo, author, _ := bibliographyRepo(t, 1)
+
+	_, err := repo.Author.Merge(ctx, author.ID, author.ID)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+	_, err = repo.Author.Merge(ctx, author.ID, uuid.New())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestAuthorRepo_DeleteWithBooks(t *testing.T) {
+	ctx := context.Background()
+	repo, author, books := bibliographyRepo(t, 1)
+
+	assert.ErrorIs(t, repo.Author.Delete(ctx, author.ID), repository.ErrConflict)
+
+	require.NoError(t, repo.Book.Delete(ctx, books[0].ID))
+	assert.NoError(t, repo.Author.Delete(ctx, author.ID))
+	assert.ErrorIs(t, repo.Author.Delete(ctx, author.ID), repository.ErrNotFound)
+}
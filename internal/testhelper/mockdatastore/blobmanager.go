@@ -3,8 +3,11 @@ package mockdatastore
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"maps"
 	"sync"
 
 	"github.com/google/uuid"
@@ -15,14 +18,33 @@ import (
 // BlobRepo implements BlobManager.
 type BlobRepo struct {
 	mut   sync.RWMutex
-	blobs map[uuid.UUID]*model.Blob
+	blobs map[uuid.UUID]storedBlob
+	// Blobs by their content's checksum
+	content map[string]uuid.UUID
+	// Whatever can reference blobs. Their locks are only ever taken
+	// after this one's.
+	users []blobUser
+}
+
+// A manager whose items can use blobs
+type blobUser interface {
+	blobReferences(id uuid.UUID) []model.BlobReference
+	detachBlob(id uuid.UUID) []model.BlobReference
+}
+
+// A blob's content is kept as bytes, so each retrieval gets a fresh
+// reader over it
+type storedBlob struct {
+	metadata map[string]string
+	data     []byte
 }
 
 var _ repository.BlobManager = (*BlobRepo)(nil)
 
 func NewInMemoryBlobManager() *BlobRepo {
 	return &BlobRepo{
-		blobs: make(map[uuid.UUID]*model.Blob),
+		blobs:   make(map[uuid.UUID]storedBlob),
+		content: make(map[string]uuid.UUID),
 	}
 }
 
@@ -34,13 +56,21 @@ func (m *BlobRepo) Create(ctx context.Context, blob *model.Blob) error {
 	if err != nil {
 		return fmt.Errorf("read blob content: %w", err)
 	}
+	s := sha256.Sum256(data)
+	sum := hex.EncodeToString(s[:])
+	if id, ok := m.content[sum]; ok {
+		blob.ID, blob.Metadata = id, m.blobs[id].metadata
+		return nil
+	}
 
-	// Store content as a new bytes.Reader for each retrieval
-	m.blobs[blob.ID] = &model.Blob{
-		ID:       blob.ID,
-		Metadata: blob.Metadata,
-		Content:  bytes.NewReader(data),
+	metadata := maps.Clone(blob.Metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
 	}
+	metadata[model.BlobMetaSHA256] = sum
+	m.blobs[blob.ID] = storedBlob{metadata, data}
+	m.content[sum] = blob.ID
+	blob.Metadata = metadata
 	return nil
 }
 
@@ -50,15 +80,13 @@ func (m *BlobRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Blob, erro
 
 	blob, exists := m.blobs[id]
 	if !exists {
-		return nil, fmt.Errorf("blob not found")
+		return nil, repository.ErrNotFound
 	}
 
-	// Return a copy with a fresh reader
-	data, _ := io.ReadAll(blob.Content)
 	return &model.Blob{
-		ID:       blob.ID,
-		Metadata: blob.Metadata,
-		Content:  bytes.NewReader(data),
+		ID:       id,
+		Metadata: blob.metadata,
+		Content:  bytes.NewReader(blob.data),
 	}, nil
 }
 
@@ -67,15 +95,57 @@ func (m *BlobRepo) Delete(ctx context.Context, blobID uuid.UUID) error {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
+	if _, exists := m.blobs[blobID]; !exists {
+		return repository.ErrNotFound
+	}
+	for _, u := range m.users {
+		if len(u.blobReferences(blobID)) > 0 {
+			return repository.ErrConflict
+		}
+	}
+	delete(m.content, m.blobs[blobID].metadata[model.BlobMetaSHA256])
 	delete(m.blobs, blobID)
 	return nil
 }
 
+// References implements repository.BlobManager.
+func (m *BlobRepo) References(ctx context.Context, blobID uuid.UUID) ([]model.BlobReference, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	var refs []model.BlobReference
+	for _, u := range m.users {
+		refs = append(refs, u.blobReferences(blobID)...)
+	}
+	return refs, nil
+}
+
+// Detach implements repository.BlobManager.
+func (m *BlobRepo) Detach(ctx context.Context, blobID uuid.UUID) ([]model.BlobReference, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var refs []model.BlobReference
+	for _, u := range m.users {
+		refs = append(refs, u.detachBlob(blobID)...)
+	}
+	return refs, nil
+}
+
 // Update implements repository.BlobManager.
 func (m *BlobRepo) Update(ctx context.Context, to *model.Blob) (*model.Blob, error) {
+	data, err := io.ReadAll(to.Content)
+	if err != nil {
+		return nil, fmt.Errorf("read blob content: %w", err)
+	}
+
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
-	m.blobs[to.ID] = to
-	return to, nil
+	m.blobs[to.ID] = storedBlob{to.Metadata, data}
+	return &model.Blob{
+		ID:       to.ID,
+		Metadata: to.Metadata,
+		Content:  bytes.NewReader(data),
+	}, nil
 }
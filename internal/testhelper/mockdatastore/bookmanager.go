@@ -1,9 +1,17 @@
 package mockdatastore
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
@@ -13,8 +21,12 @@ import (
 // BookRepo implements BookRepo.
 type BookRepo[S comparable] struct {
 	athr       repository.AuthorManager[S]
+	comm       repository.CommentManager[S]
+	subj       *SubjectRepo
+	work       *WorkRepo[S]
 	mut        sync.RWMutex
 	books      map[uuid.UUID]*model.Book
+	prov       map[uuid.UUID]map[string]model.Provenance
 	byISBN     map[model.ISBN]*model.Book
 	byAuthorID map[uuid.UUID][]*model.Book
 }
@@ -24,6 +36,7 @@ var _ repository.BookManager[string] = (*BookRepo[string])(nil)
 func NewInMemoryBookManager[S comparable]() *BookRepo[S] {
 	return &BookRepo[S]{
 		books:      make(map[uuid.UUID]*model.Book),
+		prov:       make(map[uuid.UUID]map[string]model.Provenance),
 		byISBN:     make(map[model.ISBN]*model.Book),
 		byAuthorID: make(map[uuid.UUID][]*model.Book),
 	}
@@ -37,8 +50,11 @@ func (m *BookRepo[S]) reindex() {
 		for _, isbn := range v.ISBNs {
 			m.byISBN[isbn] = v
 		}
-		for _, aID := range v.AuthorIDs {
-			m.byAuthorID[aID] = append(m.byAuthorID[aID], v)
+		// An author's books are those they are credited on in any role
+		for _, c := range v.Contributors {
+			if !slices.Contains(m.byAuthorID[c.ID], v) {
+				m.byAuthorID[c.ID] = append(m.byAuthorID[c.ID], v)
+			}
 		}
 	}
 }
@@ -50,8 +66,26 @@ func (m *BookRepo[S]) Create(ctx context.Context, book *model.Book) error {
 	if book.ID == uuid.Nil {
 		book.ID = uuid.New()
 	}
+	book.SyncContributors(model.Book{})
+	// A new book is the first edition of a new work, unless it says
+	// otherwise
+	if book.Work == uuid.Nil {
+		if m.work != nil {
+			m.work.mut.Lock()
+			book.Work = m.work.create(book.Title)
+			m.work.mut.Unlock()
+		} else {
+			book.Work = uuid.New()
+		}
+	}
 
-	m.books[book.ID] = book
+	// Provenance is only handed back when asked for, and books are
+	// put into series through the series manager
+	b := *book
+	b.Provenance = nil
+	b.Series = nil
+	m.books[b.ID] = &b
+	m.prov[b.ID] = maps.Clone(book.Provenance)
 	m.reindex()
 	return nil
 }
@@ -68,16 +102,114 @@ func (m *BookRepo[S]) GetByID(ctx context.Context, id uuid.UUID) (*model.Book, e
 }
 
 func (m *BookRepo[S]) Update(ctx context.Context, book *model.Book) (*model.Book, error) {
+	b, _, err := m.Edit(ctx, book, model.Provenance{Source: model.ProvenanceAdmin})
+	return b, err
+}
+
+// Edit implements repository.BookManager.
+func (m *BookRepo[S]) Edit(ctx context.Context, book *model.Book, by model.Provenance) (*model.Book, []string, error) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
-	if c, exists := m.books[book.ID]; !exists {
-		return nil, repository.ErrNotFound
-	} else {
-		m.books[book.ID] = c
+	b, changed, err := m.edit(book, by)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, changed, nil
+}
+
+// The lock must be held
+func (m *BookRepo[S]) edit(book *model.Book, by model.Provenance) (*model.Book, []string, error) {
+	current, exists := m.books[book.ID]
+	if !exists {
+		return nil, nil, repository.ErrNotFound
+	}
+	prov := m.prov[book.ID]
+	in := *book
+	in.SyncContributors(*current)
+	book = &in
+	var changed []string
+	for _, f := range current.Changed(*book) {
+		if by.Overrides(prov[f]) {
+			changed = append(changed, f)
+		}
+	}
+	for _, isbn := range book.ISBNs {
+		if other, exists := m.byISBN[isbn]; exists && other.ID != book.ID &&
+			slices.Contains(changed, model.BookFieldISBNs) {
+			return nil, nil, repository.ErrConflict
+		}
+	}
+
+	b := current.Merge(*book, changed...)
+	b.SyncContributors(*current)
+	b.Provenance = nil
+	if prov == nil {
+		prov = map[string]model.Provenance{}
+		m.prov[book.ID] = prov
 	}
+	if by.Recorded.IsZero() {
+		by.Recorded = time.Now()
+	}
+	for _, f := range changed {
+		prov[f] = by
+	}
+	m.books[book.ID] = &b
 	m.reindex()
-	return m.books[book.ID], nil
+	return &b, changed, nil
+}
+
+// Provenance implements repository.BookManager.
+func (m *BookRepo[S]) Provenance(ctx context.Context, id uuid.UUID) (map[string]model.Provenance, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	if _, exists := m.books[id]; !exists {
+		return nil, repository.ErrNotFound
+	}
+	prov := maps.Clone(m.prov[id])
+	if prov == nil {
+		prov = map[string]model.Provenance{}
+	}
+	return prov, nil
+}
+
+// Stale implements repository.BookManager.
+func (m *BookRepo[S]) Stale(ctx context.Context, before time.Time, limit int) ([]*model.Book, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	var books []*model.Book
+	for _, b := range m.books {
+		if b.Source != nil && b.Source.Provider != "" && b.Source.Fetched.Before(before) {
+			books = append(books, b)
+		}
+	}
+	slices.SortFunc(books, func(a, b *model.Book) int {
+		return a.Source.Fetched.Compare(b.Source.Fetched)
+	})
+	return books[:min(limit, len(books))], nil
+}
+
+// Refresh implements repository.BookManager.
+func (m *BookRepo[S]) Refresh(ctx context.Context, book *model.Book) (*model.Book, []string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var src model.BookSource
+	if book.Source != nil {
+		src = *book.Source
+	}
+	b, changed, err := m.edit(book, model.Provenance{
+		Source:   model.ProvenanceScraper,
+		SourceID: src.Provider + ":" + src.ProviderID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	src.Fetched = time.Now()
+	b.Source = &src
+	return b, changed, nil
 }
 
 func (m *BookRepo[S]) Delete(ctx context.Context, id uuid.UUID) error {
@@ -88,6 +220,14 @@ func (m *BookRepo[S]) Delete(ctx context.Context, id uuid.UUID) error {
 		return repository.ErrNotFound
 	} else {
 		delete(m.books, book.ID)
+		delete(m.prov, book.ID)
+		if m.work != nil {
+			m.work.mut.Lock()
+			if !m.work.hasEditions(book.Work) && !m.work.hasReviews(book.Work) {
+				delete(m.work.titles, book.Work)
+			}
+			m.work.mut.Unlock()
+		}
 	}
 	m.reindex()
 	return nil
@@ -115,23 +255,440 @@ func (m *BookRepo[S]) ExistsByISBN(ctx context.Context, isbns ...model.ISBN) (*m
 	return nil, false, nil
 }
 
-func (m *BookRepo[S]) Author(ctx context.Context, authorID uuid.UUID) ([]*model.Book, error) {
+type bibliographyCursor struct {
+	Sort       repository.BookSort `json:"s"`
+	Descending bool                `json:"d"`
+	ID         uuid.UUID           `json:"id"`
+}
+
+// Author implements repository.BookManager.
+func (m *BookRepo[S]) Author(ctx context.Context, authorID uuid.UUID, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	// Copy out so we aren't holding the lock while summarizing, which
+	// calls back into this manager
 	m.mut.RLock()
-	defer m.mut.RUnlock()
+	books := slices.Clone(m.byAuthorID[authorID])
+	m.mut.RUnlock()
+	return m.page(ctx, books, opts)
+}
 
-	if books, exists := m.byAuthorID[authorID]; !exists {
-		return nil, repository.ErrNotFound
-	} else {
-		return books, nil
+// Subject implements repository.BookManager.
+func (m *BookRepo[S]) Subject(ctx context.Context, subjectID uuid.UUID, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	tree := m.subjectTree(subjectID)
+	m.mut.RLock()
+	var books []*model.Book
+	for _, b := range m.books {
+		if inSubjects(b, tree) {
+			books = append(books, b)
+		}
+	}
+	m.mut.RUnlock()
+	return m.page(ctx, books, opts)
+}
+
+func (m *BookRepo[S]) subjectTree(ids ...uuid.UUID) map[uuid.UUID]bool {
+	if m.subj == nil {
+		tree := make(map[uuid.UUID]bool, len(ids))
+		for _, id := range ids {
+			tree[id] = true
+		}
+		return tree
 	}
+	return m.subj.tree(ids...)
+}
+
+func inSubjects(b *model.Book, tree map[uuid.UUID]bool) bool {
+	return slices.ContainsFunc(b.Subjects, func(id uuid.UUID) bool {
+		return tree[id]
+	})
+}
+
+// Sort and page through book summaries as the datastore would.
+func (m *BookRepo[S]) page(ctx context.Context, books []*model.Book, opts repository.BibliographyOptions) ([]*model.BookSummary, string, error) {
+	limit := repository.PageSize(opts.Limit)
+	if opts.Sort == "" {
+		opts.Sort = repository.BookSortPublished
+	} else if opts.Sort != repository.BookSortPublished &&
+		opts.Sort != repository.BookSortRating {
+		return nil, "", repository.ErrInvalidInput
+	}
+
+	summaries := make([]*model.BookSummary, 0, len(books))
+	for _, b := range books {
+		s, err := m.Summarize(ctx, b)
+		if err != nil {
+			return nil, "", err
+		}
+		summaries = append(summaries, s)
+	}
+
+	// Unrated and undated books go last no matter the direction.
+	// Partial dates sort by their first day.
+	publishedKey := func(s *model.BookSummary) civil.Date {
+		switch {
+		case !s.Published.IsZero():
+			return s.Published.Start()
+		case opts.Descending:
+			return civil.Date{Year: 1, Month: time.January, Day: 1}
+		default:
+			return civil.Date{Year: 9999, Month: time.December, Day: 31}
+		}
+	}
+	ratingKey := func(s *model.BookSummary) float32 {
+		switch {
+		case s.Rating != nil:
+			return *s.Rating
+		case opts.Descending:
+			return -1.0
+		default:
+			return 2.0
+		}
+	}
+	slices.SortFunc(summaries, func(a, b *model.BookSummary) int {
+		var c int
+		if opts.Sort == repository.BookSortRating {
+			c = cmp.Compare(ratingKey(a), ratingKey(b))
+		} else {
+			c = publishedKey(a).Compare(publishedKey(b))
+		}
+		if c == 0 {
+			c = bytes.Compare(a.ID[:], b.ID[:])
+		}
+		if opts.Descending {
+			return -c
+		}
+		return c
+	})
+
+	if opts.Cursor != "" {
+		var cur bibliographyCursor
+		if err := repository.DecodeCursor(opts.Cursor, &cur); err != nil {
+			return nil, "", err
+		} else if cur.Sort != opts.Sort || cur.Descending != opts.Descending {
+			return nil, "", repository.ErrInvalidInput
+		}
+		i := slices.IndexFunc(summaries, func(s *model.BookSummary) bool {
+			return s.ID == cur.ID
+		})
+		if i == -1 {
+			return nil, "", repository.ErrInvalidInput
+		}
+		summaries = summaries[i+1:]
+	}
+
+	if len(summaries) <= limit {
+		return summaries, "", nil
+	}
+	summaries = summaries[:limit]
+	next, err := repository.EncodeCursor(bibliographyCursor{
+		Sort:       opts.Sort,
+		Descending: opts.Descending,
+		ID:         summaries[limit-1].ID,
+	})
+	return summaries, next, err
 }
 
 // Search implements repository.BookManager.
-func (m *BookRepo[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
-	panic("unimplemented")
+func (m *BookRepo[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
+	return m.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.BookFilter{})
+}
+
+// SearchFiltered implements repository.BookManager. There is no
+// ranking to speak of; every match scores the same, so results are in
+// ID order.
+func (m *BookRepo[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.BookFilter) ([]repository.SearchResult[model.BookSummary], []repository.AnyScoreItemer, error) {
+	matches, err := m.match(ctx, query, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	resultsT := make([]repository.SearchResult[model.BookSummary], len(matches))
+	for i, b := range matches {
+		resultsT[i] = repository.SearchResult[model.BookSummary]{
+			Item: b.summary, ID: b.book.ID, Score: 1.0,
+			Highlights: queryHighlights(query, map[string]string{
+				"title":       b.book.Title,
+				"subtitle":    b.book.Subtitle,
+				"description": b.book.Description,
+			}, map[string][]repository.QueryField{
+				"title":       {repository.FieldAny, repository.FieldTitle},
+				"subtitle":    {repository.FieldAny, repository.FieldSubtitle},
+				"description": {repository.FieldAny, repository.FieldDescription},
+			}),
+		}
+	}
+	resultsT = searchPage(resultsT, page)
+	resultsASI := make([]repository.AnyScoreItemer, len(resultsT))
+	for i, r := range resultsT {
+		resultsASI[i] = r
+	}
+	return resultsT, resultsASI, nil
+}
+
+// Facets implements repository.BookManager.
+func (m *BookRepo[S]) Facets(ctx context.Context, query repository.Query, filter repository.BookFilter) (*repository.BookFacets, error) {
+	matches, err := m.match(ctx, query, filter)
+	if err != nil {
+		return nil, err
+	}
+	decades := map[int]int{}
+	subjects := map[uuid.UUID]int{}
+	ratings := map[int]int{}
+	names := map[uuid.UUID]string{}
+	for _, b := range matches {
+		if !b.book.Published.IsZero() {
+			decades[b.book.Published.Year/10*10]++
+		}
+		if m.subj != nil {
+			for id, s := range m.subj.ancestors(b.book.Subjects...) {
+				subjects[id]++
+				names[id] = s.Name
+			}
+		}
+		if r := b.summary.Rating; r != nil {
+			last := int(1/repository.RatingBucket) - 1
+			ratings[min(int(*r/repository.RatingBucket), last)]++
+		}
+	}
+
+	facets := repository.BookFacets{
+		Decades:  []repository.FacetCount{},
+		Subjects: []repository.FacetCount{},
+		Ratings:  []repository.FacetCount{},
+	}
+	for _, d := range slices.Sorted(maps.Keys(decades)) {
+		facets.Decades = append(facets.Decades, repository.FacetCount{
+			Value: strconv.Itoa(d), Count: decades[d],
+		})
+	}
+	for id, n := range subjects {
+		facets.Subjects = append(facets.Subjects, repository.FacetCount{
+			Value: id.String(), Label: names[id], Count: n,
+		})
+	}
+	slices.SortFunc(facets.Subjects, func(a, b repository.FacetCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Label, b.Label)
+	})
+	for _, r := range slices.Sorted(maps.Keys(ratings)) {
+		facets.Ratings = append(facets.Ratings, repository.FacetCount{
+			Value: strconv.FormatFloat(float64(float32(r)*repository.RatingBucket), 'f', -1, 32),
+			Count: ratings[r],
+		})
+	}
+	return &facets, nil
+}
+
+// A book matching a search, along with its summary
+type bookMatch struct {
+	book    *model.Book
+	summary *model.BookSummary
+}
+
+// Every book matching the query and filter, in no particular order
+func (m *BookRepo[S]) match(ctx context.Context, query repository.Query, filter repository.BookFilter) ([]bookMatch, error) {
+	var tree map[uuid.UUID]bool
+	if len(filter.Subjects) > 0 {
+		tree = m.subjectTree(filter.Subjects...)
+	}
+
+	m.mut.RLock()
+	var books []*model.Book
+	for _, b := range m.books {
+		if tree != nil && !inSubjects(b, tree) {
+			continue
+		}
+		if len(filter.Authors) > 0 && !slices.ContainsFunc(b.Contributors, func(c model.Contributor) bool {
+			return slices.Contains(filter.Authors, c.ID)
+		}) {
+			continue
+		}
+		if len(filter.Languages) > 0 && !slices.ContainsFunc(filter.Languages, func(l string) bool {
+			return strings.EqualFold(l, b.Language)
+		}) {
+			continue
+		}
+		if (!filter.PublishedFrom.IsZero() || !filter.PublishedTo.IsZero()) &&
+			!b.Published.Within(filter.PublishedFrom, filter.PublishedTo) {
+			continue
+		}
+		books = append(books, b)
+	}
+	m.mut.RUnlock()
+
+	// Authors' names and ratings come from elsewhere, so can only be
+	// checked once the book lock is let go
+	matches := make([]bookMatch, 0, len(books))
+	for _, b := range books {
+		s, err := m.Summarize(ctx, b)
+		if err != nil {
+			return nil, err
+		}
+		fields := map[repository.QueryField][]string{
+			repository.FieldAny:         {b.Title, b.Subtitle, b.Description},
+			repository.FieldTitle:       {b.Title},
+			repository.FieldSubtitle:    {b.Subtitle},
+			repository.FieldDescription: {b.Description},
+		}
+		for _, a := range s.Contributors {
+			fields[repository.FieldAuthor] = append(fields[repository.FieldAuthor], fullName(&a.Author))
+		}
+		for _, i := range b.ISBNs {
+			fields[repository.FieldISBN] = append(fields[repository.FieldISBN], i.String())
+		}
+		if !queryMatches(query, fields) {
+			continue
+		}
+		if filter.MinRating != nil && (s.Rating == nil || *s.Rating < *filter.MinRating) ||
+			filter.MaxRating != nil && (s.Rating == nil || *s.Rating > *filter.MaxRating) {
+			continue
+		}
+		matches = append(matches, bookMatch{b, s})
+	}
+	return matches, nil
+}
+
+// Rank search results as the datastore would, then cut them down to
+// the page asked for.
+func searchPage[T any](results []repository.SearchResult[T], page repository.SearchPage) []repository.SearchResult[T] {
+	slices.SortFunc(results, func(a, b repository.SearchResult[T]) int {
+		return a.Position().Compare(b.Position())
+	})
+	limit := page.Limit
+	if limit <= 0 {
+		limit = repository.DefaultPageSize
+	}
+	switch {
+	case page.Through != nil:
+		end := len(results)
+		for end > 0 && results[end-1].Position().Compare(*page.Through) > 0 {
+			end--
+		}
+		return results[max(0, end-limit):end]
+	case page.After != nil:
+		start := 0
+		for start < len(results) && results[start].Position().Compare(*page.After) <= 0 {
+			start++
+		}
+		results = results[start:]
+	}
+	return results[:min(limit, len(results))]
 }
 
 // Summarize implements repository.BookManager.
-func (m *BookRepo[S]) Summarize(context.Context, *model.Book) (*model.BookSummary, error) {
-	panic("unimplemented")
+func (m *BookRepo[S]) Summarize(ctx context.Context, book *model.Book) (*model.BookSummary, error) {
+	s := model.BookSummary{
+		ID:          book.ID,
+		ISBNs:       book.ISBNs,
+		Title:       book.Title,
+		Subtitle:    book.Subtitle,
+		Description: book.Description,
+		Published:   book.Published,
+		ThumbImage:  book.ThumbImage,
+	}
+
+	if m.athr != nil {
+		authors, err := m.athr.Book(ctx, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range authors {
+			s.Authors = append(s.Authors, model.Author{
+				ID:         a.ID,
+				GivenName:  a.GivenName,
+				FamilyName: a.FamilyName,
+			})
+		}
+		for _, c := range book.Contributors {
+			a, err := m.athr.GetByID(ctx, c.ID)
+			if err != nil {
+				return nil, err
+			}
+			s.Contributors = append(s.Contributors, model.CreditedAuthor{
+				Author: model.Author{
+					ID:         a.ID,
+					GivenName:  a.GivenName,
+					FamilyName: a.FamilyName,
+				},
+				Role: c.Role,
+			})
+		}
+	}
+
+	if m.comm != nil {
+		comments, err := m.comm.BookComments(ctx, book.ID)
+		if err != nil {
+			return nil, err
+		}
+		var total float32
+		var n int
+		for _, c := range comments {
+			if c.Parent == uuid.Nil && !c.Deleted {
+				total += c.Rating
+				n++
+			}
+		}
+		if n > 0 {
+			avg := total / float32(n)
+			s.Rating = &avg
+		}
+	}
+
+	return &s, nil
+}
+
+// Suggest implements repository.BookManager.
+func (m *BookRepo[S]) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	var s []repository.Suggestion
+	for _, b := range m.books {
+		if score := suggestScore(b.Title, prefix); score > 0 {
+			s = append(s, repository.Suggestion{ID: b.ID, Text: b.Title, Score: score})
+		}
+	}
+	return topSuggestions(s, limit), nil
+}
+
+func (m *BookRepo[S]) blobReferences(id uuid.UUID) []model.BlobReference {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	var refs []model.BlobReference
+	for _, b := range m.books {
+		if b.CoverImage == id {
+			refs = append(refs, model.BlobReference{Use: model.BlobUseCover, ID: b.ID})
+		}
+		if b.ThumbImage == id {
+			refs = append(refs, model.BlobReference{Use: model.BlobUseThumbnail, ID: b.ID})
+		}
+	}
+	return refs
+}
+
+func (m *BookRepo[S]) detachBlob(id uuid.UUID) []model.BlobReference {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var refs []model.BlobReference
+	for bookID, b := range m.books {
+		if b.CoverImage != id && b.ThumbImage != id {
+			continue
+		}
+		nb := *b
+		if nb.CoverImage == id {
+			nb.CoverImage = uuid.Nil
+			refs = append(refs, model.BlobReference{Use: model.BlobUseCover, ID: bookID})
+		}
+		if nb.ThumbImage == id {
+			nb.ThumbImage = uuid.Nil
+			refs = append(refs, model.BlobReference{Use: model.BlobUseThumbnail, ID: bookID})
+		}
+		m.books[bookID] = &nb
+	}
+	if refs != nil {
+		m.reindex()
+	}
+	return refs
 }
@@ -0,0 +1,243 @@
+package mockdatastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Build a repository with one author credited on `n` books published
+// a year apart, starting in 1900.
+func bibliographyRepo(t *testing.T, n int) (*InMemoryRepository[string], *model.Author, []*model.Book) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+
+	author := &model.Author{GivenName: "Charles", FamilyName: "Dickens"}
+	require.NoError(t, repo.Author.Create(ctx, author))
+
+	books := make([]*model.Book, n)
+	for i := range n {
+		books[i] = &model.Book{
+			Title:     "Book",
+			AuthorIDs: uuid.UUIDs{author.ID},
+			Published: model.PartialDate{Year: 1900 + i, Month: time.January, Day: 1},
+		}
+		require.NoError(t, repo.Book.Create(ctx, books[i]))
+	}
+	return repo, author, books
+}
+
+func TestBookRepo_Author_Published(t *testing.T) {
+	ctx := context.Background()
+	repo, author, books := bibliographyRepo(t, 5)
+
+	opts := repository.BibliographyOptions{Limit: 2}
+	var seen []uuid.UUID
+	for range 3 {
+		page, next, err := repo.Book.Author(ctx, author.ID, opts)
+		require.NoError(t, err)
+		for _, b := range page {
+			seen = append(seen, b.ID)
+		}
+		opts.Cursor = next
+		if next == "" {
+			break
+		}
+	}
+
+	assert.Empty(t, opts.Cursor)
+	assert.Equal(t, uuid.UUIDs{
+		books[0].ID, books[1].ID, books[2].ID, books[3].ID, books[4].ID,
+	}, uuid.UUIDs(seen))
+}
+
+func TestBookRepo_Author_Undated(t *testing.T) {
+	ctx := context.Background()
+	repo, author, _ := bibliographyRepo(t, 2)
+	undated := &model.Book{Title: "Book", AuthorIDs: uuid.UUIDs{author.ID}}
+	require.NoError(t, repo.Book.Create(ctx, undated))
+
+	for _, desc := range []bool{false, true} {
+		page, _, err := repo.Book.Author(ctx, author.ID, repository.BibliographyOptions{
+			Descending: desc,
+		})
+		require.NoError(t, err)
+		require.Len(t, page, 3)
+		assert.Equal(t, undated.ID, page[2].ID, "undated books go last")
+	}
+}
+
+func TestBookRepo_SearchFiltered_Published(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+	dates := []model.PartialDate{
+		{},
+		{Year: 2006},
+		{Year: 2006, Month: time.March},
+		{Year: 2006, Month: time.July, Day: 4},
+		{Year: 2007},
+	}
+	books := make([]*model.Book, len(dates))
+	for i, d := range dates {
+		books[i] = &model.Book{Title: "Book", Published: d}
+		require.NoError(t, repo.Book.Create(ctx, books[i]))
+	}
+
+	results, _, err := repo.Book.SearchFiltered(ctx, repository.SearchPage{Limit: 10}, repository.PlainQuery("book"), repository.BookFilter{
+		PublishedFrom: model.PartialDate{Year: 2006, Month: time.June},
+		PublishedTo:   model.PartialDate{Year: 2006, Month: time.December},
+	})
+	require.NoError(t, err)
+	var ids uuid.UUIDs
+	for _, r := range results {
+		ids = append(ids, r.Item.ID)
+	}
+	assert.ElementsMatch(t, uuid.UUIDs{books[1].ID, books[3].ID}, ids,
+		"only dates which could fall in the second half of 2006 match")
+
+	results, _, err = repo.Book.SearchFiltered(ctx, repository.SearchPage{Limit: 10}, repository.PlainQuery("book"), repository.BookFilter{})
+	require.NoError(t, err)
+	assert.Len(t, results, len(dates), "no filter keeps undated books")
+}
+
+func TestBookRepo_Facets(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+	sf, err := repo.Subject.Resolve(ctx, []string{"Fiction", "Science Fiction"})
+	require.NoError(t, err)
+	author := &model.Author{FamilyName: "Le Guin"}
+	require.NoError(t, repo.Author.Create(ctx, author))
+
+	books := []*model.Book{
+		{Title: "Book", Published: model.PartialDate{Year: 1969}, Subjects: uuid.UUIDs{sf.ID}, Language: "en", AuthorIDs: uuid.UUIDs{author.ID}},
+		{Title: "Book", Published: model.PartialDate{Year: 1974, Month: time.May}, Subjects: uuid.UUIDs{sf.Parent}, Language: "EN"},
+		{Title: "Book", Published: model.PartialDate{Year: 1985}, Language: "fr"},
+		{Title: "Book"},
+	}
+	for _, b := range books {
+		require.NoError(t, repo.Book.Create(ctx, b))
+	}
+	for i, r := range map[int]float32{0: 1.0, 1: 0.9, 2: 0.3} {
+		id := uuid.New()
+		repo.Comment.comments[id] = &model.Comment{
+			ID: id, Book: books[i].ID, Rating: r,
+		}
+	}
+
+	facets, err := repo.Book.Facets(ctx, repository.PlainQuery("book"), repository.BookFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.FacetCount{
+		{Value: "1960", Count: 1}, {Value: "1970", Count: 1}, {Value: "1980", Count: 1},
+	}, facets.Decades, "undated books aren't counted")
+	assert.Equal(t, []repository.FacetCount{
+		{Value: sf.Parent.String(), Label: "Fiction", Count: 2},
+		{Value: sf.ID.String(), Label: "Science Fiction", Count: 1},
+	}, facets.Subjects, "books count towards their subjects' ancestors")
+	assert.Equal(t, []repository.FacetCount{
+		{Value: "0.2", Count: 1}, {Value: "0.8", Count: 2},
+	}, facets.Ratings, "a rating of 1 is in the top bucket")
+
+	good := float32(0.8)
+	facets, err = repo.Book.Facets(ctx, repository.PlainQuery("book"), repository.BookFilter{
+		Languages: []string{"en"}, MinRating: &good,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []repository.FacetCount{{Value: "0.8", Count: 2}}, facets.Ratings)
+
+	results, _, err := repo.Book.SearchFiltered(ctx, repository.SearchPage{Limit: 10}, repository.PlainQuery("book"), repository.BookFilter{
+		Authors: uuid.UUIDs{author.ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, books[0].ID, results[0].ID)
+
+	authors, _, err := repo.Author.SearchFiltered(ctx, repository.SearchPage{Limit: 10}, repository.PlainQuery("guin"), repository.AuthorFilter{
+		Books: repository.BookFilter{Languages: []string{"fr"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, authors, "Le Guin has no books in French")
+}
+
+func TestBookRepo_Author_Rating(t *testing.T) {
+	ctx := context.Background()
+	repo, author, books := bibliographyRepo(t, 3)
+
+	// books[0] is unrated and should be last either way
+	for i, r := range map[int]float32{1: 0.2, 2: 0.8} {
+		id := uuid.New()
+		repo.Comment.comments[id] = &model.Comment{
+			ID: id, Book: books[i].ID, Rating: r,
+		}
+	}
+
+	page, next, err := repo.Book.Author(ctx, author.ID, repository.BibliographyOptions{
+		Sort: repository.BookSortRating, Descending: true,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, page, 3)
+	assert.Equal(t, books[2].ID, page[0].ID)
+	assert.Equal(t, books[1].ID, page[1].ID)
+	assert.Equal(t, books[0].ID, page[2].ID)
+	assert.Nil(t, page[2].Rating)
+
+	page, _, err = repo.Book.Author(ctx, author.ID, repository.BibliographyOptions{
+		Sort: repository.BookSortRating,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Equal(t, books[1].ID, page[0].ID)
+	assert.Equal(t, books[0].ID, page[2].ID)
+}
+
+func TestBookRepo_Author_CursorMismatch(t *testing.T) {
+	ctx := context.Background()
+	repo, author, _ := bibliographyRepo(t, 3)
+
+	_, next, err := repo.Book.Author(ctx, author.ID, repository.BibliographyOptions{Limit: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+
+	_, _, err = repo.Book.Author(ctx, author.ID, repository.BibliographyOptions{
+		Limit: 1, Cursor: next, Sort: repository.BookSortRating,
+	})
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+}
+
+func TestBookRepo_Edit_Precedence(t *testing.T) {
+	ctx := t.Context()
+	repo := NewInMemoryRepository[string]()
+	book := &model.Book{ID: uuid.New(), Title: "Matilda", Description: "Scraped"}
+	book.Attribute(model.Provenance{Source: model.ProvenanceScraper})
+	assert.NoError(t, repo.Book.Create(ctx, book))
+
+	admin := model.Provenance{Source: model.ProvenanceAdmin, Actor: uuid.New()}
+	edit := *book
+	edit.Title = "Matilda (edited)"
+	_, changed, err := repo.Book.Edit(ctx, &edit, admin)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.BookFieldTitle}, changed)
+
+	// The scraper can change what it set, but not what the admin did
+	scraped := edit
+	scraped.Title = "Matilda"
+	scraped.Description = "Scraped again"
+	got, changed, err := repo.Book.Edit(ctx, &scraped, model.Provenance{Source: model.ProvenanceScraper})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{model.BookFieldDescription}, changed)
+	assert.Equal(t, "Matilda (edited)", got.Title)
+	assert.Equal(t, "Scraped again", got.Description)
+
+	prov, err := repo.Book.Provenance(ctx, book.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ProvenanceAdmin, prov[model.BookFieldTitle].Source)
+	assert.Equal(t, admin.Actor, prov[model.BookFieldTitle].Actor)
+	assert.Equal(t, model.ProvenanceScraper, prov[model.BookFieldDescription].Source)
+	assert.Nil(t, got.Provenance, "provenance is only given when asked for")
+}
@@ -2,6 +2,8 @@ package mockdatastore
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
 
 	"github.com/google/uuid"
@@ -43,6 +45,25 @@ func (r *CommentRepo[S]) BookComments(ctx context.Context, bookID uuid.UUID) ([]
 	return results, nil
 }
 
+// WorkComments implements repository.CommentManager.
+func (r *CommentRepo[S]) WorkComments(ctx context.Context, workID uuid.UUID) ([]*model.Comment, error) {
+	// Look the work up first, the comment lock comes after the work's
+	w, err := r.repo.Work.GetByID(ctx, workID)
+	if err != nil {
+		return nil, err
+	}
+	r.mut.RLock()
+	defer r.mut.RUnlock()
+
+	var results []*model.Comment
+	for _, c := range r.comments {
+		if c.Work == workID || slices.Contains(w.Editions, c.Book) {
+			results = append(results, c)
+		}
+	}
+	return results, nil
+}
+
 // Create implements repository.CommentManager.
 func (r *CommentRepo[S]) Create(context.Context, *model.Comment) error {
 	r.mut.Lock()
@@ -68,11 +89,59 @@ func (r *CommentRepo[S]) GetByID(context.Context, uuid.UUID) (*model.Comment, er
 }
 
 // Search implements repository.CommentManager.
-func (r *CommentRepo[S]) Search(ctx context.Context, offset int, limit int, query ...string) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
+func (r *CommentRepo[S]) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
+	return r.SearchFiltered(ctx, page, repository.PlainQuery(query...), repository.CommentFilter{})
+}
+
+// SearchFiltered implements repository.CommentManager.
+func (r *CommentRepo[S]) SearchFiltered(ctx context.Context, page repository.SearchPage, query repository.Query, filter repository.CommentFilter) ([]repository.SearchResult[model.Comment], []repository.AnyScoreItemer, error) {
+	// Reviews of a book's work count as being on the book. The books
+	// are looked up first, the comment lock comes after the book's.
+	var works uuid.UUIDs
+	for _, id := range filter.Books {
+		b, err := r.repo.Book.GetByID(ctx, id)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, err
+		} else if err == nil && b.Work != uuid.Nil {
+			works = append(works, b.Work)
+		}
+	}
+
 	r.mut.RLock()
 	defer r.mut.RUnlock()
 
-	panic("unimplemented")
+	// As with books, every match scores the same
+	var resultsT []repository.SearchResult[model.Comment]
+	for _, c := range r.comments {
+		review := c.Parent == uuid.Nil
+		switch {
+		case len(filter.Posters) > 0 && !slices.Contains(filter.Posters, c.Poster.ID),
+			len(filter.Books) > 0 && !slices.Contains(filter.Books, c.Book) && !slices.Contains(works, c.Work),
+			filter.Reviews && !review,
+			filter.MinRating != nil && (!review || c.Rating < *filter.MinRating),
+			filter.MaxRating != nil && (!review || c.Rating > *filter.MaxRating):
+			continue
+		}
+		if !c.Deleted && queryMatches(query, map[repository.QueryField][]string{
+			repository.FieldAny:     {c.Body},
+			repository.FieldComment: {c.Body},
+		}) {
+			cc := *c
+			resultsT = append(resultsT, repository.SearchResult[model.Comment]{
+				Item: &cc, ID: c.ID, Score: 1.0,
+				Highlights: queryHighlights(query,
+					map[string]string{"body": c.Body},
+					map[string][]repository.QueryField{"body": repository.CommentQueryFields},
+				),
+			})
+		}
+	}
+	resultsT = searchPage(resultsT, page)
+	resultsASI := make([]repository.AnyScoreItemer, len(resultsT))
+	for i, r := range resultsT {
+		resultsASI[i] = r
+	}
+	return resultsT, resultsASI, nil
 }
 
 // Update implements repository.CommentManager.
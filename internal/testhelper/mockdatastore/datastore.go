@@ -20,6 +20,10 @@ type InMemoryRepository[S comparable] struct {
 	Book    *BookRepo[S]
 	Blob    *BlobRepo
 	Comment *CommentRepo[S]
+	Scrape  *ScrapeJobRepo
+	Subject *SubjectRepo
+	Work    *WorkRepo[S]
+	Series  *SeriesRepo[S]
 }
 
 // NewInMemoryRepository creates a new repository with all in-memory managers.
@@ -32,12 +36,23 @@ func NewInMemoryRepository[S comparable]() *InMemoryRepository[S] {
 		Book:    NewInMemoryBookManager[S](),
 		Blob:    NewInMemoryBlobManager(),
 		Comment: NewInMemoryCommentManager[S](),
+		Scrape:  NewInMemoryScrapeJobManager(),
+		Subject: NewInMemorySubjectManager(),
+		Work:    NewInMemoryWorkManager[S](),
+		Series:  NewInMemorySeriesManager[S](),
 	}
 
 	// Link child managers back to the repository for cross-manager access
 	repo.Author.book = repo.Book
 	repo.Book.athr = repo.Author
+	repo.Book.comm = repo.Comment
+	repo.Book.subj = repo.Subject
+	repo.Book.work = repo.Work
+	repo.Work.book = repo.Book
+	repo.Work.comm = repo.Comment
+	repo.Series.book = repo.Book
 	repo.Comment.repo = repo
+	repo.Blob.users = []blobUser{repo.Book, repo.User}
 
 	return repo
 }
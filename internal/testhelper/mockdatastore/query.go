@@ -0,0 +1,51 @@
+package mockdatastore
+
+import (
+	"strings"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Whether a query matches something, given the text of each field it
+// has. A term matches if any text in its field contains it, ignoring
+// case. Unlike the datastore, an empty query matches everything.
+func queryMatches(q repository.Query, fields map[repository.QueryField][]string) bool {
+	term := func(t repository.QueryTerm) bool {
+		for _, text := range fields[t.Field] {
+			if strings.Contains(strings.ToLower(text), strings.ToLower(t.Text)) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range q.Clauses {
+		matched := false
+		for _, t := range c {
+			matched = matched || term(t)
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, t := range q.Exclude {
+		if term(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Highlights for a result, marking the query's terms in the text of
+// each of its fields. `searched` is which query fields search each one.
+func queryHighlights(q repository.Query, text map[string]string, searched map[string][]repository.QueryField) repository.Highlights {
+	var h repository.Highlights
+	for field, t := range text {
+		if hl, ok := repository.Highlight(q.Mark(t, searched[field]...)); ok {
+			if h == nil {
+				h = repository.Highlights{}
+			}
+			h[field] = hl
+		}
+	}
+	return h
+}
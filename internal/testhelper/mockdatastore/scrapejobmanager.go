@@ -0,0 +1,175 @@
+package mockdatastore
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// ScrapeJobRepo implements ScrapeJobManager.
+type ScrapeJobRepo struct {
+	mut  sync.Mutex
+	jobs map[uuid.UUID]*model.ScrapeJob
+	// Lets tests pretend time has passed
+	now func() time.Time
+}
+
+var _ repository.ScrapeJobManager = (*ScrapeJobRepo)(nil)
+
+func NewInMemoryScrapeJobManager() *ScrapeJobRepo {
+	return &ScrapeJobRepo{
+		jobs: make(map[uuid.UUID]*model.ScrapeJob),
+		now:  time.Now,
+	}
+}
+
+// Enqueue implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) Enqueue(ctx context.Context, job *model.ScrapeJob, fresh time.Duration) (*model.ScrapeJob, bool, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if job.Limit <= 0 || job.Offset < 0 {
+		return nil, false, repository.ErrInvalidInput
+	}
+	now := m.now()
+	for _, j := range m.jobs {
+		if j.Query != job.Query || j.Offset != job.Offset || j.Limit != job.Limit {
+			continue
+		}
+		if !j.Terminal() ||
+			(j.Status == model.ScrapeJobDone && now.Sub(j.Finished) < fresh) {
+			c := *j
+			return &c, false, nil
+		}
+	}
+
+	j := *job
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	if j.MaxAttempts <= 0 {
+		j.MaxAttempts = 5
+	}
+	j.Status = model.ScrapeJobQueued
+	j.Created, j.RunAfter = now, now
+	m.jobs[j.ID] = &j
+	c := j
+	return &c, true, nil
+}
+
+// GetByID implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.ScrapeJob, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	j, exists := m.jobs[id]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	c := *j
+	return &c, nil
+}
+
+// Claim implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) Claim(ctx context.Context, lease time.Duration) (*model.ScrapeJob, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	now := m.now()
+	var runnable []*model.ScrapeJob
+	for _, j := range m.jobs {
+		expired := j.Status == model.ScrapeJobRunning && now.Sub(j.Started) > lease
+		if expired && j.Attempts >= j.MaxAttempts {
+			j.Status = model.ScrapeJobFailed
+			j.Finished = now
+			j.Error = "lease expired before the job finished"
+		} else if expired || (j.Status == model.ScrapeJobQueued && !j.RunAfter.After(now)) {
+			runnable = append(runnable, j)
+		}
+	}
+	if len(runnable) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	j := slices.MinFunc(runnable, func(a, b *model.ScrapeJob) int {
+		if c := a.RunAfter.Compare(b.RunAfter); c != 0 {
+			return c
+		}
+		return a.Created.Compare(b.Created)
+	})
+	j.Status = model.ScrapeJobRunning
+	j.Attempts++
+	j.Started = now
+	c := *j
+	return &c, nil
+}
+
+// Complete implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) Complete(ctx context.Context, id uuid.UUID, attempt int, added int) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	j, err := m.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	j.Status = model.ScrapeJobDone
+	j.Added = added
+	j.Error = ""
+	j.Finished = m.now()
+	return nil
+}
+
+// Fail implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) Fail(ctx context.Context, id uuid.UUID, attempt int, cause error, backoff time.Duration) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	j, err := m.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	if cause != nil {
+		j.Error = cause.Error()
+	}
+	now := m.now()
+	if j.Attempts >= j.MaxAttempts {
+		j.Status = model.ScrapeJobFailed
+		j.Finished = now
+	} else {
+		j.Status = model.ScrapeJobQueued
+	}
+	j.RunAfter = now.Add(backoff)
+	return nil
+}
+
+// Release implements repository.ScrapeJobManager.
+func (m *ScrapeJobRepo) Release(ctx context.Context, id uuid.UUID, attempt int) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	j, err := m.claimed(id, attempt)
+	if err != nil {
+		return err
+	}
+	j.Status = model.ScrapeJobQueued
+	j.Attempts--
+	j.Started = time.Time{}
+	j.RunAfter = m.now()
+	return nil
+}
+
+// The job, if it is still running under the claim. The lock must be
+// held.
+func (m *ScrapeJobRepo) claimed(id uuid.UUID, attempt int) (*model.ScrapeJob, error) {
+	j, exists := m.jobs[id]
+	if !exists || j.Status != model.ScrapeJobRunning || j.Attempts != attempt {
+		return nil, repository.ErrConflict
+	}
+	return j, nil
+}
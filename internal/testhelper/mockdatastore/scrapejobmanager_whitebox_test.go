@@ -0,0 +1,61 @@
+package mockdatastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func TestScrapeJobClaims(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryScrapeJobManager()
+
+	job, _, err := repo.Enqueue(ctx, &model.ScrapeJob{Query: "matilda", Limit: 10}, 0)
+	require.NoError(t, err)
+	stale, err := repo.Claim(ctx, time.Hour)
+	require.NoError(t, err)
+	// Its lease runs out, so someone else takes it
+	current, err := repo.Claim(ctx, -time.Second)
+	require.NoError(t, err)
+	require.Equal(t, job.ID, current.ID)
+
+	require.NoError(t, repo.Complete(ctx, job.ID, current.Attempts, 3))
+	err = repo.Fail(ctx, job.ID, stale.Attempts, errors.New("timed out"), 0)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+	err = repo.Complete(ctx, job.ID, current.Attempts, 5)
+	assert.ErrorIs(t, err, repository.ErrConflict, "a finished job can't finish again")
+
+	job, err = repo.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobDone, job.Status)
+	assert.Equal(t, 3, job.Added)
+	assert.Empty(t, job.Error)
+}
+
+func TestScrapeJobLeaseExhausted(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryScrapeJobManager()
+
+	job, _, err := repo.Enqueue(ctx, &model.ScrapeJob{Query: "matilda", Limit: 10, MaxAttempts: 2}, 0)
+	require.NoError(t, err)
+	// Every worker which takes it dies without recording anything
+	for range job.MaxAttempts {
+		_, err = repo.Claim(ctx, -time.Second)
+		require.NoError(t, err)
+	}
+	_, err = repo.Claim(ctx, -time.Second)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	job, err = repo.GetByID(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobFailed, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.NotEmpty(t, job.Error)
+}
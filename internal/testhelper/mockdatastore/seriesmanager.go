@@ -0,0 +1,214 @@
+package mockdatastore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// SeriesRepo implements SeriesManager. Like works, which series a book
+// is in lives on the book itself.
+//
+// Locks are taken book first, then series.
+type SeriesRepo[S comparable] struct {
+	book   *BookRepo[S]
+	mut    sync.RWMutex
+	series map[uuid.UUID]*model.Series
+	// Creation order, so Resolve picks the oldest of a name
+	order uuid.UUIDs
+}
+
+var _ repository.SeriesManager = (*SeriesRepo[string])(nil)
+
+func NewInMemorySeriesManager[S comparable]() *SeriesRepo[S] {
+	return &SeriesRepo[S]{
+		series: make(map[uuid.UUID]*model.Series),
+	}
+}
+
+// GetByID implements repository.SeriesManager.
+func (m *SeriesRepo[S]) GetByID(ctx context.Context, id uuid.UUID) (*model.Series, error) {
+	m.book.mut.RLock()
+	defer m.book.mut.RUnlock()
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return m.get(id)
+}
+
+// Both locks must be held
+func (m *SeriesRepo[S]) get(id uuid.UUID) (*model.Series, error) {
+	s, exists := m.series[id]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	var members []*model.Book
+	for _, b := range m.book.books {
+		if b.Series != nil && b.Series.ID == id {
+			members = append(members, b)
+		}
+	}
+	slices.SortFunc(members, func(a, b *model.Book) int {
+		if c := cmp.Compare(a.Series.Position, b.Series.Position); c != 0 {
+			return c
+		}
+		if c := a.Published.Compare(b.Published); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	c := *s
+	c.Entries = []model.SeriesEntry{}
+	for _, b := range members {
+		c.Entries = append(c.Entries, model.SeriesEntry{
+			Book:     b.ID,
+			Title:    b.Title,
+			Position: b.Series.Position,
+		})
+	}
+	return &c, nil
+}
+
+// Create implements repository.SeriesManager.
+func (m *SeriesRepo[S]) Create(ctx context.Context, s *model.Series) error {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if err := s.Validate(); err != nil {
+		return repository.ErrInvalidInput
+	}
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	m.series[s.ID] = &model.Series{ID: s.ID, Name: s.Name, Description: s.Description}
+	m.order = append(m.order, s.ID)
+	if err := m.setEntries(s.ID, s.Entries); err != nil {
+		delete(m.series, s.ID)
+		m.order = m.order[:len(m.order)-1]
+		return err
+	}
+	return nil
+}
+
+// Update implements repository.SeriesManager.
+func (m *SeriesRepo[S]) Update(ctx context.Context, s *model.Series) (*model.Series, error) {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if err := s.Validate(); err != nil {
+		return nil, repository.ErrInvalidInput
+	}
+	if _, exists := m.series[s.ID]; !exists {
+		return nil, repository.ErrNotFound
+	}
+	m.series[s.ID] = &model.Series{ID: s.ID, Name: s.Name, Description: s.Description}
+	if err := m.setEntries(s.ID, s.Entries); err != nil {
+		return nil, err
+	}
+	return m.get(s.ID)
+}
+
+// Both locks must be held
+func (m *SeriesRepo[S]) setEntries(id uuid.UUID, entries []model.SeriesEntry) error {
+	for _, e := range entries {
+		if _, exists := m.book.books[e.Book]; !exists {
+			return repository.ErrNotFound
+		}
+	}
+	positions := make(map[uuid.UUID]float64, len(entries))
+	for _, e := range entries {
+		positions[e.Book] = e.Position
+	}
+	name := m.series[id].Name
+	for bid, b := range m.book.books {
+		pos, member := positions[bid]
+		wasMember := b.Series != nil && b.Series.ID == id
+		if !member && !wasMember {
+			continue
+		}
+		c := *b
+		c.Series = nil
+		if member {
+			c.Series = &model.BookSeries{ID: id, Name: name, Position: pos}
+		}
+		m.book.books[bid] = &c
+	}
+	m.book.reindex()
+	return nil
+}
+
+// Delete implements repository.SeriesManager.
+func (m *SeriesRepo[S]) Delete(ctx context.Context, id uuid.UUID) error {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if _, exists := m.series[id]; !exists {
+		return nil
+	}
+	if err := m.setEntries(id, nil); err != nil {
+		return err
+	}
+	delete(m.series, id)
+	m.order = slices.DeleteFunc(m.order, func(o uuid.UUID) bool { return o == id })
+	return nil
+}
+
+// Resolve implements repository.SeriesManager.
+func (m *SeriesRepo[S]) Resolve(ctx context.Context, name string) (*model.Series, error) {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if err := (model.Series{Name: name}).Validate(); err != nil {
+		return nil, repository.ErrInvalidInput
+	}
+	for _, id := range m.order {
+		if strings.EqualFold(m.series[id].Name, name) {
+			return m.get(id)
+		}
+	}
+	id := uuid.New()
+	m.series[id] = &model.Series{ID: id, Name: name}
+	m.order = append(m.order, id)
+	return m.get(id)
+}
+
+// Place implements repository.SeriesManager.
+func (m *SeriesRepo[S]) Place(ctx context.Context, seriesID, bookID uuid.UUID, position float64) error {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if position < 0 {
+		return repository.ErrInvalidInput
+	}
+	s, exists := m.series[seriesID]
+	if !exists {
+		return repository.ErrNotFound
+	}
+	b, exists := m.book.books[bookID]
+	if !exists {
+		return repository.ErrNotFound
+	}
+	c := *b
+	c.Series = &model.BookSeries{ID: seriesID, Name: s.Name, Position: position}
+	m.book.books[bookID] = &c
+	m.book.reindex()
+	return nil
+}
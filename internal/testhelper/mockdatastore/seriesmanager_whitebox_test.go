@@ -0,0 +1,79 @@
+package mockdatastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func TestSeriesRepo(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+
+	books := map[string]*model.Book{
+		"leviathan": {Title: "Leviathan Wakes"},
+		"caliban":   {Title: "Caliban's War"},
+		"butcher":   {Title: "The Butcher of Anderson Station"},
+		"dune":      {Title: "Dune"},
+	}
+	for _, b := range books {
+		require.NoError(t, repo.Book.Create(ctx, b))
+	}
+
+	expanse, err := repo.Series.Resolve(ctx, "The Expanse")
+	require.NoError(t, err)
+	again, err := repo.Series.Resolve(ctx, "the expanse")
+	require.NoError(t, err)
+	assert.Equal(t, expanse.ID, again.ID, "names should match regardless of case")
+
+	require.NoError(t, repo.Series.Place(ctx, expanse.ID, books["caliban"].ID, 2))
+	require.NoError(t, repo.Series.Place(ctx, expanse.ID, books["butcher"].ID, 1.5))
+	require.NoError(t, repo.Series.Place(ctx, expanse.ID, books["leviathan"].ID, 1))
+
+	s, err := repo.Series.GetByID(ctx, expanse.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []model.SeriesEntry{
+		{Book: books["leviathan"].ID, Title: "Leviathan Wakes", Position: 1},
+		{Book: books["butcher"].ID, Title: "The Butcher of Anderson Station", Position: 1.5},
+		{Book: books["caliban"].ID, Title: "Caliban's War", Position: 2},
+	}, s.Entries)
+
+	b, err := repo.Book.GetByID(ctx, books["butcher"].ID)
+	require.NoError(t, err)
+	require.NotNil(t, b.Series)
+	assert.Equal(t, "The Expanse", b.Series.Name)
+
+	// Updating replaces the entries, dropping whatever is left out
+	s.Name = "Expanse"
+	s.Entries = s.Entries[:1]
+	updated, err := repo.Series.Update(ctx, s)
+	require.NoError(t, err)
+	assert.Len(t, updated.Entries, 1)
+	b, err = repo.Book.GetByID(ctx, books["butcher"].ID)
+	require.NoError(t, err)
+	assert.Nil(t, b.Series)
+	b, err = repo.Book.GetByID(ctx, books["leviathan"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Expanse", b.Series.Name)
+
+	_, err = repo.Series.Update(ctx, &model.Series{ID: expanse.ID, Name: "Expanse",
+		Entries: []model.SeriesEntry{{Book: uuid.New(), Position: 1}}})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.Series.Update(ctx, &model.Series{ID: expanse.ID, Name: "Expanse",
+		Entries: []model.SeriesEntry{{Book: books["dune"].ID, Position: -1}}})
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+
+	// Books outlive their series
+	require.NoError(t, repo.Series.Delete(ctx, expanse.ID))
+	_, err = repo.Series.GetByID(ctx, expanse.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	b, err = repo.Book.GetByID(ctx, books["leviathan"].ID)
+	require.NoError(t, err)
+	assert.Nil(t, b.Series)
+}
@@ -0,0 +1,129 @@
+package mockdatastore
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// SubjectRepo implements SubjectManager.
+type SubjectRepo struct {
+	mut      sync.RWMutex
+	subjects map[uuid.UUID]*model.Subject
+}
+
+var _ repository.SubjectManager = (*SubjectRepo)(nil)
+
+func NewInMemorySubjectManager() *SubjectRepo {
+	return &SubjectRepo{
+		subjects: make(map[uuid.UUID]*model.Subject),
+	}
+}
+
+// GetByID implements repository.SubjectManager.
+func (m *SubjectRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.Subject, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	s, exists := m.subjects[id]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	c := *s
+	return &c, nil
+}
+
+// List implements repository.SubjectManager.
+func (m *SubjectRepo) List(ctx context.Context) ([]*model.Subject, error) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	result := make([]*model.Subject, 0, len(m.subjects))
+	for _, s := range m.subjects {
+		c := *s
+		result = append(result, &c)
+	}
+	slices.SortFunc(result, func(a, b *model.Subject) int {
+		return slices.Compare(a.Path, b.Path)
+	})
+	return result, nil
+}
+
+// Resolve implements repository.SubjectManager.
+func (m *SubjectRepo) Resolve(ctx context.Context, path []string) (*model.Subject, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if len(path) == 0 {
+		return nil, repository.ErrInvalidInput
+	}
+	var current *model.Subject
+	for i, name := range path {
+		parent := uuid.Nil
+		if current != nil {
+			parent = current.ID
+		}
+		current = m.child(parent, name)
+		if current == nil {
+			current = &model.Subject{
+				ID:     uuid.New(),
+				Parent: parent,
+				Name:   name,
+				Path:   slices.Clone(path[:i+1]),
+			}
+			m.subjects[current.ID] = current
+		}
+	}
+	c := *current
+	return &c, nil
+}
+
+func (m *SubjectRepo) child(parent uuid.UUID, name string) *model.Subject {
+	for _, s := range m.subjects {
+		if s.Parent == parent && strings.EqualFold(s.Name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// The given subjects and all their descendants
+func (m *SubjectRepo) tree(ids ...uuid.UUID) map[uuid.UUID]bool {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	result := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	for grew := true; grew; {
+		grew = false
+		for _, s := range m.subjects {
+			if result[s.Parent] && !result[s.ID] {
+				result[s.ID] = true
+				grew = true
+			}
+		}
+	}
+	return result
+}
+
+// The given subjects and all their ancestors
+func (m *SubjectRepo) ancestors(ids ...uuid.UUID) map[uuid.UUID]*model.Subject {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	result := make(map[uuid.UUID]*model.Subject, len(ids))
+	for _, id := range ids {
+		for s, exists := m.subjects[id]; exists && result[s.ID] == nil; s, exists = m.subjects[s.Parent] {
+			result[s.ID] = s
+		}
+	}
+	return result
+}
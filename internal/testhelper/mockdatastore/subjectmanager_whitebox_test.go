@@ -0,0 +1,79 @@
+package mockdatastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func TestSubjectRepo_Resolve(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemorySubjectManager()
+
+	opera, err := repo.Resolve(ctx, []string{"Fiction", "Science Fiction", "Space Opera"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Fiction", "Science Fiction", "Space Opera"}, opera.Path)
+
+	// Ancestors are shared, and names match regardless of case
+	scifi, err := repo.Resolve(ctx, []string{"fiction", "science fiction"})
+	require.NoError(t, err)
+	assert.Equal(t, scifi.ID, opera.Parent)
+	assert.Equal(t, "Science Fiction", scifi.Name)
+
+	subjects, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, subjects, 3)
+	assert.Equal(t, []string{"Fiction"}, subjects[0].Path, "parents should come first")
+	assert.Equal(t, uuid.Nil, subjects[0].Parent)
+
+	_, err = repo.Resolve(ctx, nil)
+	assert.ErrorIs(t, err, repository.ErrInvalidInput)
+}
+
+func TestBookRepo_Subjects(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+
+	fiction, err := repo.Subject.Resolve(ctx, []string{"Fiction"})
+	require.NoError(t, err)
+	scifi, err := repo.Subject.Resolve(ctx, []string{"Fiction", "Science Fiction"})
+	require.NoError(t, err)
+	history, err := repo.Subject.Resolve(ctx, []string{"History"})
+	require.NoError(t, err)
+
+	books := map[string]*model.Book{
+		"dune":       {Title: "Dune", Subjects: uuid.UUIDs{scifi.ID}},
+		"emma":       {Title: "Emma", Subjects: uuid.UUIDs{fiction.ID}},
+		"spqr":       {Title: "SPQR", Subjects: uuid.UUIDs{history.ID}},
+		"dune-guide": {Title: "The Dune Encyclopedia"},
+	}
+	for _, b := range books {
+		require.NoError(t, repo.Book.Create(ctx, b))
+	}
+
+	// A subject includes its descendants
+	summaries, _, err := repo.Book.Subject(ctx, fiction.ID, repository.BibliographyOptions{})
+	require.NoError(t, err)
+	var ids []uuid.UUID
+	for _, s := range summaries {
+		ids = append(ids, s.ID)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{books["dune"].ID, books["emma"].ID}, ids)
+
+	results, _, err := repo.Book.Search(ctx, repository.SearchPage{Limit: 10}, "dune")
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, _, err = repo.Book.SearchFiltered(ctx, repository.SearchPage{Limit: 10}, repository.PlainQuery("dune"), repository.BookFilter{
+		Subjects: uuid.UUIDs{fiction.ID},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, books["dune"].ID, results[0].Item.ID)
+}
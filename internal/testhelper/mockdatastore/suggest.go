@@ -0,0 +1,39 @@
+package mockdatastore
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// How well `name` completes `prefix`, ignoring case: 1 if it starts
+// with it, a half if only a later word does, and 0 if none do. This is
+// rougher than the datastore's trigrams, which also allow for typos.
+func suggestScore(name, prefix string) float64 {
+	name, prefix = strings.ToLower(name), strings.ToLower(prefix)
+	switch {
+	case strings.HasPrefix(name, prefix):
+		return 1
+	case slices.ContainsFunc(strings.Fields(name), func(w string) bool {
+		return strings.HasPrefix(w, prefix)
+	}):
+		return 0.5
+	}
+	return 0
+}
+
+// The best `limit` suggestions, ranked as the datastore ranks them
+func topSuggestions(s []repository.Suggestion, limit int) []repository.Suggestion {
+	slices.SortFunc(s, func(a, b repository.Suggestion) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(len(a.Text), len(b.Text)),
+			strings.Compare(a.Text, b.Text),
+			bytes.Compare(a.ID[:], b.ID[:]),
+		)
+	})
+	return s[:max(0, min(len(s), limit, repository.MaxSuggestions))]
+}
@@ -127,3 +127,94 @@ func (m *UserRepo) Permissions(ctx context.Context, userID uuid.UUID) (bool, err
 
 	return user.Admin, nil
 }
+
+// Suggest implements repository.UserManager.
+func (m *UserRepo) Suggest(ctx context.Context, prefix string, limit int) ([]repository.Suggestion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var s []repository.Suggestion
+	for _, u := range m.users {
+		if u.Unlisted {
+			continue
+		}
+		handle, _ := u.Username.Components()
+		if score := suggestScore(handle, prefix); score > 0 {
+			s = append(s, repository.Suggestion{ID: u.ID, Text: u.Username.String(), Score: score})
+		}
+	}
+	return topSuggestions(s, limit), nil
+}
+
+// Search implements repository.UserManager.
+func (m *UserRepo) Search(ctx context.Context, page repository.SearchPage, query ...string) ([]repository.SearchResult[model.UserProfile], []repository.AnyScoreItemer, error) {
+	return m.SearchQuery(ctx, page, repository.PlainQuery(query...))
+}
+
+// SearchQuery implements repository.UserManager. As with books, every
+// match scores the same.
+func (m *UserRepo) SearchQuery(ctx context.Context, page repository.SearchPage, query repository.Query) ([]repository.SearchResult[model.UserProfile], []repository.AnyScoreItemer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var resultsT []repository.SearchResult[model.UserProfile]
+	for _, u := range m.users {
+		if u.Unlisted {
+			continue
+		}
+		handle, _ := u.Username.Components()
+		username := u.Username.String()
+		if queryMatches(query, map[repository.QueryField][]string{
+			repository.FieldAny:  {handle, u.DisplayName, username},
+			repository.FieldUser: {handle, u.DisplayName, username},
+		}) {
+			p := u.Profile()
+			resultsT = append(resultsT, repository.SearchResult[model.UserProfile]{
+				Item: &p, ID: u.ID, Score: 1.0,
+				Highlights: queryHighlights(query, map[string]string{
+					"username": username,
+					"name":     u.DisplayName,
+				}, map[string][]repository.QueryField{
+					"username": repository.UserQueryFields,
+					"name":     repository.UserQueryFields,
+				}),
+			})
+		}
+	}
+	resultsT = searchPage(resultsT, page)
+	resultsASI := make([]repository.AnyScoreItemer, len(resultsT))
+	for i, r := range resultsT {
+		resultsASI[i] = r
+	}
+	return resultsT, resultsASI, nil
+}
+
+func (m *UserRepo) blobReferences(id uuid.UUID) []model.BlobReference {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var refs []model.BlobReference
+	for _, u := range m.users {
+		if u.Avatar == id {
+			refs = append(refs, model.BlobReference{Use: model.BlobUseAvatar, ID: u.ID})
+		}
+	}
+	return refs
+}
+
+func (m *UserRepo) detachBlob(id uuid.UUID) []model.BlobReference {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var refs []model.BlobReference
+	for _, u := range m.users {
+		if u.Avatar == id {
+			nu := *u
+			nu.Avatar = uuid.Nil
+			m.users[u.ID] = &nu
+			m.cache(&nu)
+			refs = append(refs, model.BlobReference{Use: model.BlobUseAvatar, ID: u.ID})
+		}
+	}
+	return refs
+}
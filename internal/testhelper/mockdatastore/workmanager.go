@@ -0,0 +1,212 @@
+package mockdatastore
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// WorkRepo implements WorkManager. Which work a book belongs to lives
+// on the book itself, so this needs the book manager to do anything.
+//
+// Locks are taken book first, then work, then comment.
+type WorkRepo[S comparable] struct {
+	book   *BookRepo[S]
+	comm   *CommentRepo[S]
+	mut    sync.RWMutex
+	titles map[uuid.UUID]string
+	// Editions which have been moved by hand
+	pinned map[uuid.UUID]bool
+}
+
+var _ repository.WorkManager = (*WorkRepo[string])(nil)
+
+func NewInMemoryWorkManager[S comparable]() *WorkRepo[S] {
+	return &WorkRepo[S]{
+		titles: make(map[uuid.UUID]string),
+		pinned: make(map[uuid.UUID]bool),
+	}
+}
+
+// Make a new, empty work. The lock must be held.
+func (m *WorkRepo[S]) create(title string) uuid.UUID {
+	id := uuid.New()
+	m.titles[id] = title
+	return id
+}
+
+// GetByID implements repository.WorkManager.
+func (m *WorkRepo[S]) GetByID(ctx context.Context, id uuid.UUID) (*model.Work, error) {
+	m.book.mut.RLock()
+	defer m.book.mut.RUnlock()
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return m.get(id)
+}
+
+// Both locks must be held
+func (m *WorkRepo[S]) get(id uuid.UUID) (*model.Work, error) {
+	title, exists := m.titles[id]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	var editions []*model.Book
+	for _, b := range m.book.books {
+		if b.Work == id {
+			editions = append(editions, b)
+		}
+	}
+	slices.SortFunc(editions, func(a, b *model.Book) int {
+		if c := a.Published.Compare(b.Published); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	w := &model.Work{ID: id, Title: title, Editions: uuid.UUIDs{}}
+	for _, e := range editions {
+		w.Editions = append(w.Editions, e.ID)
+	}
+	return w, nil
+}
+
+// Join implements repository.WorkManager.
+func (m *WorkRepo[S]) Join(ctx context.Context, workID uuid.UUID, editions ...uuid.UUID) (*model.Work, error) {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if _, exists := m.titles[workID]; !exists {
+		return nil, repository.ErrNotFound
+	}
+	if err := m.move(workID, editions); err != nil {
+		return nil, err
+	}
+	return m.get(workID)
+}
+
+// Split implements repository.WorkManager.
+func (m *WorkRepo[S]) Split(ctx context.Context, editions ...uuid.UUID) (*model.Work, error) {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if len(editions) == 0 {
+		return nil, repository.ErrInvalidInput
+	}
+	first, exists := m.book.books[editions[0]]
+	if !exists {
+		return nil, repository.ErrNotFound
+	}
+	workID := m.create(first.Title)
+	if err := m.move(workID, editions); err != nil {
+		delete(m.titles, workID)
+		return nil, err
+	}
+	return m.get(workID)
+}
+
+// Both locks must be held
+func (m *WorkRepo[S]) move(workID uuid.UUID, editions uuid.UUIDs) error {
+	for _, id := range editions {
+		if _, exists := m.book.books[id]; !exists {
+			return repository.ErrNotFound
+		}
+	}
+	var from uuid.UUIDs
+	for _, id := range editions {
+		b := *m.book.books[id]
+		from = append(from, b.Work)
+		b.Work = workID
+		m.book.books[id] = &b
+		m.pinned[id] = true
+	}
+	m.book.reindex()
+	m.prune(workID, from)
+	return nil
+}
+
+// Delete those of `works` which no longer have any editions, moving
+// their reviews into `into`. Both locks must be held.
+func (m *WorkRepo[S]) prune(into uuid.UUID, works uuid.UUIDs) {
+	for _, w := range works {
+		if w == into {
+			continue
+		}
+		if m.hasEditions(w) {
+			continue
+		}
+		if m.comm != nil {
+			m.comm.mut.Lock()
+			for id, c := range m.comm.comments {
+				if c.Work == w {
+					moved := *c
+					moved.Work = into
+					m.comm.comments[id] = &moved
+				}
+			}
+			m.comm.mut.Unlock()
+		}
+		delete(m.titles, w)
+	}
+}
+
+// The book lock must be held
+func (m *WorkRepo[S]) hasEditions(w uuid.UUID) bool {
+	for _, b := range m.book.books {
+		if b.Work == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *WorkRepo[S]) hasReviews(w uuid.UUID) bool {
+	if m.comm == nil {
+		return false
+	}
+	m.comm.mut.RLock()
+	defer m.comm.mut.RUnlock()
+	for _, c := range m.comm.comments {
+		if c.Work == w {
+			return true
+		}
+	}
+	return false
+}
+
+// Cluster implements repository.WorkManager.
+func (m *WorkRepo[S]) Cluster(ctx context.Context) (int, error) {
+	m.book.mut.Lock()
+	defer m.book.mut.Unlock()
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	var editions []*model.Book
+	for id, b := range m.book.books {
+		if !m.pinned[id] {
+			editions = append(editions, b)
+		}
+	}
+	moves := model.ClusterEditions(editions)
+	from := map[uuid.UUID]uuid.UUIDs{}
+	for id, to := range moves {
+		b := *m.book.books[id]
+		from[to] = append(from[to], b.Work)
+		b.Work = to
+		m.book.books[id] = &b
+	}
+	m.book.reindex()
+	for to, works := range from {
+		m.prune(to, works)
+	}
+	return len(moves), nil
+}
@@ -0,0 +1,103 @@
+package mockdatastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func TestWorkRepo_Cluster(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+	author := uuid.New()
+
+	hardback := &model.Book{Title: "The Hobbit", AuthorIDs: uuid.UUIDs{author}}
+	paperback := &model.Book{Title: "Hobbit (Anniversary Edition)", AuthorIDs: uuid.UUIDs{author}}
+	other := &model.Book{Title: "The Silmarillion", AuthorIDs: uuid.UUIDs{author}}
+	for _, b := range []*model.Book{hardback, paperback, other} {
+		require.NoError(t, repo.Book.Create(ctx, b))
+	}
+	// Every new book starts out in a work of its own
+	assert.NotEqual(t, uuid.Nil, hardback.Work)
+	assert.NotEqual(t, hardback.Work, paperback.Work)
+
+	n, err := repo.Work.Cluster(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	h, err := repo.Book.GetByID(ctx, hardback.ID)
+	require.NoError(t, err)
+	p, err := repo.Book.GetByID(ctx, paperback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, h.Work, p.Work)
+	w, err := repo.Work.GetByID(ctx, h.Work)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, uuid.UUIDs{hardback.ID, paperback.ID}, w.Editions)
+
+	// The emptied work is gone
+	for _, id := range []uuid.UUID{hardback.Work, paperback.Work} {
+		if id != h.Work {
+			_, err = repo.Work.GetByID(ctx, id)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		}
+	}
+
+	n, err = repo.Work.Cluster(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "clustering twice should move nothing")
+}
+
+func TestWorkRepo_SplitAndJoin(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryRepository[string]()
+	author := uuid.New()
+
+	novel := &model.Book{Title: "Dune", AuthorIDs: uuid.UUIDs{author}}
+	film := &model.Book{Title: "Dune", AuthorIDs: uuid.UUIDs{author}}
+	for _, b := range []*model.Book{novel, film} {
+		require.NoError(t, repo.Book.Create(ctx, b))
+	}
+	_, err := repo.Work.Cluster(ctx)
+	require.NoError(t, err)
+
+	// Split editions are pinned, so clustering leaves them be
+	split, err := repo.Work.Split(ctx, film.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.UUIDs{film.ID}, split.Editions)
+	n, err := repo.Work.Cluster(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Reviews of the work, and of its editions, are the work's reviews
+	review := &model.Comment{ID: uuid.New(), Work: split.ID}
+	edition := &model.Comment{ID: uuid.New(), Book: novel.ID}
+	repo.Comment.comments[review.ID] = review
+	repo.Comment.comments[edition.ID] = edition
+
+	n1, err := repo.Book.GetByID(ctx, novel.ID)
+	require.NoError(t, err)
+	comments, err := repo.Comment.WorkComments(ctx, n1.Work)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	// Joining empties the split work, taking its reviews along
+	joined, err := repo.Work.Join(ctx, n1.Work, film.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, uuid.UUIDs{novel.ID, film.ID}, joined.Editions)
+	_, err = repo.Work.GetByID(ctx, split.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	comments, err = repo.Comment.WorkComments(ctx, joined.ID)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2)
+
+	_, err = repo.Work.Split(ctx, uuid.New())
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.Work.Join(ctx, uuid.New(), novel.ID)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
@@ -0,0 +1,22 @@
+// Package blobstore has places to keep blobs' content other than the
+// database, for repository.BlobStore.
+package blobstore
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Content is addressed by its SHA-256 checksum, which is turned into
+// file names and object keys, so anything else is refused outright.
+func checkSum(sum string) error {
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != 32 || hex.EncodeToString(b) != sum {
+		return repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("`%v` is not a lowercase hex SHA-256 checksum", sum),
+		}
+	}
+	return nil
+}
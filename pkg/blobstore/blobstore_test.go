@@ -0,0 +1,74 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+func sum(content []byte) string {
+	s := sha256.Sum256(content)
+	return hex.EncodeToString(s[:])
+}
+
+// What every store must do
+func testStore(t *testing.T, store repository.BlobStore) {
+	ctx := t.Context()
+	content := []byte("not really a cover image")
+	key := sum(content)
+
+	_, err := store.Get(ctx, key)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// Putting the same content again is fine
+	for range 2 {
+		require.NoError(t, store.Put(ctx, key, bytes.NewReader(content), int64(len(content))))
+	}
+	r, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, content, got)
+
+	// Content has to be what its checksum says
+	other := []byte("something else")
+	assert.Error(t, store.Put(ctx, sum(content[1:]), bytes.NewReader(other), int64(len(other))))
+	_, err = store.Get(ctx, sum(content[1:]))
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// And checksums can't be anything but
+	for _, bad := range []string{"", "../../etc/passwd", strings.ToUpper(key), key[:10]} {
+		assert.ErrorIs(t, store.Put(ctx, bad, bytes.NewReader(content), int64(len(content))), repository.ErrInvalidInput, bad)
+	}
+
+	require.NoError(t, store.Delete(ctx, key))
+	require.NoError(t, store.Delete(ctx, key), "already gone")
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFilesystem(dir)
+	require.NoError(t, err)
+	testStore(t, store)
+
+	// Nothing is left lying around
+	content := []byte("cover")
+	require.NoError(t, store.Put(t.Context(), sum(content), bytes.NewReader(content), 5))
+	assert.FileExists(t, dir+"/"+sum(content)[:2]+"/"+sum(content))
+	require.Error(t, store.Put(t.Context(), sum(content[1:]), bytes.NewReader(content), 5))
+	entries, err := filepath.Glob(dir + "/*/*")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,118 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Keeps content as files in a directory, named by checksum. They are
+// spread across subdirectories by the checksum's first two characters,
+// so no one directory gets too big.
+type Filesystem struct {
+	dir string
+}
+
+var _ repository.BlobStore = (*Filesystem)(nil)
+
+// A store in `dir`, which is made if it isn't there
+func NewFilesystem(dir string) (*Filesystem, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("make blob directory: %w", err)
+	}
+	return &Filesystem{dir: dir}, nil
+}
+
+func (f *Filesystem) Name() string {
+	return "filesystem"
+}
+
+func (f *Filesystem) path(sum string) (string, error) {
+	if err := checkSum(sum); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.dir, sum[:2], sum), nil
+}
+
+// Put implements repository.BlobStore. Content is written to a
+// temporary file and only moved into place once its checksum has been
+// checked, so a file under a checksum always has that content.
+func (f *Filesystem) Put(ctx context.Context, sum string, content io.Reader, size int64) error {
+	const errorCaller string = "put blob content"
+	p, err := f.path(sum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".put-*")
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	// Does nothing once it has been renamed
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), content)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	} else if got := hex.EncodeToString(h.Sum(nil)); got != sum || n != size {
+		return repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: got %d bytes summing to `%v`, not %d summing to `%v`", errorCaller, n, got, size, sum),
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return nil
+}
+
+// Get implements repository.BlobStore. The content is an *os.File, so
+// can be seeked through.
+func (f *Filesystem) Get(ctx context.Context, sum string) (io.ReadCloser, error) {
+	const errorCaller string = "get blob content"
+	p, err := f.path(sum)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	file, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no content `%v`", errorCaller, sum),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return file, nil
+}
+
+// Delete implements repository.BlobStore.
+func (f *Filesystem) Delete(ctx context.Context, sum string) error {
+	const errorCaller string = "delete blob content"
+	p, err := f.path(sum)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Where and how to reach an S3-compatible service
+type S3Config struct {
+	// Like https://s3.us-east-1.amazonaws.com, or http://localhost:9000
+	// for a local MinIO
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Put the bucket in the path rather than the host name, which MinIO
+	// and most other S3-compatible services want
+	PathStyle bool
+	// Put in front of every key, so a bucket can be shared
+	Prefix string
+	// http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Keeps content as objects in an S3-compatible bucket, keyed by
+// checksum. Requests are signed with AWS Signature Version 4, which
+// every S3-compatible service takes.
+type S3 struct {
+	conf     S3Config
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+var _ repository.BlobStore = (*S3)(nil)
+
+func NewS3(conf S3Config) (*S3, error) {
+	u, err := url.Parse(conf.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse S3 endpoint: %w", err)
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("S3 endpoint `%v` must be a URL like https://host", conf.Endpoint)
+	} else if conf.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket must not be empty")
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+	return &S3{conf: conf, endpoint: u, now: time.Now}, nil
+}
+
+func (s *S3) Name() string {
+	return "s3"
+}
+
+func (s *S3) objectURL(sum string) *url.URL {
+	u := *s.endpoint
+	key := s.conf.Prefix + sum
+	if s.conf.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.conf.Bucket + "/" + key
+	} else {
+		u.Host = s.conf.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+// The SHA-256 of no payload at all, which is what requests without a
+// body sign
+const emptyPayloadHash string = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Make a signed request for the object with the checksum, with any
+// extra headers signed too
+func (s *S3) do(ctx context.Context, method, sum string, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	if err := checkSum(sum); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(sum).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signV4(req, payloadHash, s.conf.Region, "s3", s.conf.AccessKey, s.conf.SecretKey, s.now())
+	return s.conf.Client.Do(req)
+}
+
+// An error for a response S3 shouldn't have given, with the start of
+// whatever it said was wrong
+func s3Error(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 responded %v: %s", resp.Status, msg)
+}
+
+// Put implements repository.BlobStore. The checksum is sent as the
+// payload's hash, so S3 refuses content which doesn't match it.
+func (s *S3) Put(ctx context.Context, sum string, content io.Reader, size int64) error {
+	const errorCaller string = "put blob content"
+	resp, err := s.do(ctx, http.MethodPut, sum, content, size, sum, nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v: %w", errorCaller, s3Error(resp))
+	}
+	return nil
+}
+
+// Get implements repository.BlobStore. The content is streamed from S3
+// as it is read, so can't be seeked through, but is a
+// repository.BlobRangeReader.
+func (s *S3) Get(ctx context.Context, sum string) (io.ReadCloser, error) {
+	const errorCaller string = "get blob content"
+	resp, err := s.do(ctx, http.MethodGet, sum, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return s3Content{ReadCloser: resp.Body, s: s, sum: sum}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no content `%v`", errorCaller, sum),
+		}
+	default:
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("%v: %w", errorCaller, s3Error(resp))
+	}
+}
+
+// An object's content, which can be fetched again in part
+type s3Content struct {
+	io.ReadCloser
+	s   *S3
+	sum string
+}
+
+var _ repository.BlobRangeReader = s3Content{}
+
+// ReadRange implements repository.BlobRangeReader, by sending the range
+// on to S3.
+func (c s3Content) ReadRange(ctx context.Context, spec string) (io.ReadCloser, string, error) {
+	const errorCaller string = "get blob content range"
+	resp, err := c.s.do(ctx, http.MethodGet, c.sum, nil, 0, emptyPayloadHash, http.Header{"Range": {spec}})
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, resp.Header.Get("Content-Range"), nil
+	case http.StatusOK:
+		return resp.Body, "", nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return nil, "", repository.Err{
+			Code: repository.ErrInvalidInput,
+			Err:  fmt.Errorf("%v: range `%v` is not satisfiable", errorCaller, spec),
+		}
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, "", repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("%v: no content `%v`", errorCaller, c.sum),
+		}
+	default:
+		defer resp.Body.Close()
+		return nil, "", fmt.Errorf("%v: %w", errorCaller, s3Error(resp))
+	}
+}
+
+// Delete implements repository.BlobStore.
+func (s *S3) Delete(ctx context.Context, sum string) error {
+	const errorCaller string = "delete blob content"
+	resp, err := s.do(ctx, http.MethodDelete, sum, nil, 0, emptyPayloadHash, nil)
+	if err != nil {
+		return fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("%v: %w", errorCaller, s3Error(resp))
+	}
+}
+
+// Sign a request with AWS Signature Version 4, over its host and every
+// header it already has. See
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func signV4(req *http.Request, payloadHash, region, service, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		trimmed := make([]string, len(v))
+		for i := range v {
+			trimmed[i] = strings.Join(strings.Fields(v[i]), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = mac(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		accessKey, scope, signedHeaders, hex.EncodeToString(mac(key, stringToSign)),
+	))
+}
@@ -2,16 +2,21 @@ package endpoints
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
 	"github.com/whit-colm/itsc-4155-project/pkg/repository"
 )
 
 type athrHandle[S comparable] struct {
 	repo repository.AuthorManager[S]
+	book repository.BookManager[S]
 }
 
 var th athrHandle[string]
@@ -40,5 +45,238 @@ func (ah *athrHandle[S]) GetAuthorByID(c *gin.Context) {
 				Details: err})
 		return
 	}
+	if s.ID != id {
+		// This author was merged into another
+		redirectMergedAuthor(c, s.ID)
+		return
+	}
 	c.JSON(http.StatusOK, *s)
 }
+
+// Send the client to the same URL, but for the author a duplicate was
+// merged into.
+func redirectMergedAuthor(c *gin.Context, to uuid.UUID) {
+	u := *c.Request.URL
+	u.Path = strings.Replace(u.Path, c.Param("id"), to.String(), 1)
+	c.Redirect(http.StatusMovedPermanently, u.String())
+}
+
+// Get an author for modification, refusing if `id` has been merged
+// into another author. Acting on the canonical author when the client
+// asked for a since-merged one is almost certainly not what they
+// meant, and for deletion it could be disastrous.
+func (ah *athrHandle[S]) getForEdit(c *gin.Context, caller string, id uuid.UUID) (*model.Author, int, string, error) {
+	a, err := ah.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h, s, err := wrapDatastoreError(caller, err)
+		return nil, h, s, err
+	} else if a.ID != id {
+		return nil, http.StatusConflict,
+			fmt.Sprintf("This author has been merged into `%v`", a.ID),
+			fmt.Errorf("%v: author `%v` redirects to `%v`", caller, id, a.ID)
+	}
+	return a, 0, "", nil
+}
+
+// Check the parts of an author a client can set
+func validateAuthor(a *model.Author) (string, error) {
+	if strings.TrimSpace(a.FamilyName) == "" {
+		return "An author must have a family name",
+			errors.New("empty family name")
+	}
+	for _, v := range a.ExtIDs {
+		if err := v.Validate(); err != nil {
+			return fmt.Sprintf("External IDs must be one of `%v`, `%v`, or `%v`",
+				model.AuthorIDORCID, model.AuthorIDVIAF, model.AuthorIDOpenLibrary,
+			), err
+		}
+	}
+	return "", nil
+}
+
+func (ah *athrHandle[S]) Create(c *gin.Context) (int, string, error) {
+	const errorCaller string = "create author"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+
+	var a model.Author
+	if err := c.ShouldBindJSON(&a); err != nil {
+		return http.StatusBadRequest,
+			"Could not parse request body as an author",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else if s, err := validateAuthor(&a); err != nil {
+		return http.StatusBadRequest, s,
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	// IDs are always ours to give out
+	if id, err := uuid.NewV7(); err != nil {
+		return http.StatusInternalServerError,
+			"Failed to generate new UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else {
+		a.ID = id
+	}
+
+	if err := ah.repo.Create(c.Request.Context(), &a); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	created, err := ah.repo.GetByID(c.Request.Context(), a.ID)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusCreated, created)
+	return http.StatusCreated, "", nil
+}
+
+// Update an author using JSON merge patch semantics (RFC 7386). As
+// with books, `ext_ids` is replaced outright if present.
+func (ah *athrHandle[S]) Update(c *gin.Context) (int, string, error) {
+	const errorCaller string = "update author"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	current, h, s, err := ah.getForEdit(c, errorCaller, id)
+	if h != 0 {
+		return h, s, err
+	}
+	var a model.Author
+	if h, s, err := wrapMergePatch(c, errorCaller, current, &a); h != 0 {
+		return h, s, err
+	} else if a.ID != id {
+		return http.StatusBadRequest,
+			"The ID of an author cannot be changed",
+			fmt.Errorf("%v: patch changed ID `%v` -> `%v`", errorCaller, id, a.ID)
+	} else if s, err := validateAuthor(&a); err != nil {
+		return http.StatusBadRequest, s,
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	updated, err := ah.repo.Update(c.Request.Context(), &a)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, updated)
+	return http.StatusOK, "", nil
+}
+
+// Merge duplicate authors into the author in the URL. The body is an
+// object with a single field, `from`, listing the duplicates' IDs.
+func (ah *athrHandle[S]) Merge(c *gin.Context) (int, string, error) {
+	const errorCaller string = "merge authors"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	var body struct {
+		From uuid.UUIDs `json:"from"`
+	}
+	if err = c.ShouldBindJSON(&body); err != nil {
+		return http.StatusBadRequest,
+			"Could not parse request body",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else if len(body.From) == 0 {
+		return http.StatusBadRequest,
+			"At least one author must be given to merge",
+			fmt.Errorf("%v: empty `from`", errorCaller)
+	} else if slices.Contains(body.From, id) {
+		return http.StatusBadRequest,
+			"An author cannot be merged into itself",
+			fmt.Errorf("%v: `%v` in `from`", errorCaller, id)
+	}
+	if _, h, s, err := ah.getForEdit(c, errorCaller, id); h != 0 {
+		return h, s, err
+	}
+
+	merged, err := ah.repo.Merge(c.Request.Context(), id, body.From...)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, merged)
+	return http.StatusOK, "", nil
+}
+
+// Delete an author. This is refused while the author is still
+// credited on any books; merge them into the correct author instead.
+func (ah *athrHandle[S]) Delete(c *gin.Context) (int, string, error) {
+	const errorCaller string = "delete author"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if _, h, s, err := ah.getForEdit(c, errorCaller, id); h != 0 {
+		return h, s, err
+	}
+
+	if err = ah.repo.Delete(c.Request.Context(), id); errors.Is(err, repository.ErrConflict) {
+		return http.StatusConflict,
+			"This author is still credited on books, merge them into another author instead",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.Status(http.StatusNoContent)
+	return http.StatusNoContent, "", nil
+}
+
+// List the books an author is credited on.
+//
+// Query parameters:
+//   - sort: `published` (default) or `rating`
+//   - order: `desc` (default) or `asc`
+//   - limit: page size
+//   - cursor: the `next` value of the previous page
+func (ah *athrHandle[S]) Books(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get author books"
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	opts, summary, err := wrapBibliographyOptions(c)
+	if err != nil {
+		return http.StatusBadRequest,
+			summary,
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	// Distinguish "no such author" from "author with no books"
+	if a, err := ah.repo.GetByID(c.Request.Context(), id); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	} else if a.ID != id {
+		redirectMergedAuthor(c, a.ID)
+		return http.StatusMovedPermanently, "", nil
+	}
+	books, next, err := ah.book.Author(c.Request.Context(), id, opts)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	if books == nil {
+		books = []*model.BookSummary{}
+	}
+	c.JSON(http.StatusOK, pagedResponse[*model.BookSummary]{
+		Items: books,
+		Next:  next,
+	})
+	return http.StatusOK, "", nil
+}
@@ -1,22 +1,42 @@
 package endpoints
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
 	"github.com/whit-colm/itsc-4155-project/pkg/repository"
 )
 
 type blobHandle struct {
 	blob repository.BlobManager
+	// The most an upload can be, in bytes
+	maxSize int64
 }
 
 var lh blobHandle
 
+// How long caches are asked to keep blobs for, which is as long as
+// they will
+const blobMaxAge = 365 * 24 * time.Hour
+
+// Serve a blob's content. Blobs never change once made, so caches can
+// keep them for good, and revalidate them with a strong ETag: the
+// blob's checksum, or failing that its ID. Conditional requests
+// (If-None-Match, If-Modified-Since) and byte ranges are answered by
+// http.ServeContent when the content can be seeked through. Content
+// which can't, like S3's, is streamed instead (see serveStream).
 func (b *blobHandle) GetRaw(c *gin.Context) (int, string, error) {
 	const errorCaller string = "get blob"
 	id, err := uuid.Parse(c.Param("id"))
@@ -25,36 +45,280 @@ func (b *blobHandle) GetRaw(c *gin.Context) (int, string, error) {
 			"Unable to parse UUID",
 			fmt.Errorf("%s: %w", errorCaller, err)
 	}
-	o, err := lh.blob.GetByID(c.Request.Context(), id)
+	o, err := b.blob.GetByID(c.Request.Context(), id)
 	if err != nil {
 		return wrapDatastoreError(errorCaller, err)
 	}
 
-	if bb, err := io.ReadAll(o.Content); err != nil {
-		return http.StatusInternalServerError,
-			"Error reading blob into response",
-			fmt.Errorf("%v: %w", errorCaller, err)
-	} else {
-		for k, v := range o.Metadata {
-			switch k {
-			// TODO: update in future
-			case "last-modified":
-				c.Header(k, v)
-			default:
-				continue
+	if cl, ok := o.Content.(io.Closer); ok {
+		defer cl.Close()
+	}
+	etag := o.ID.String()
+	if sum := o.Metadata[model.BlobMetaSHA256]; sum != "" {
+		etag = sum
+	}
+	c.Header("ETag", `"`+etag+`"`)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(blobMaxAge.Seconds())))
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Older blobs may not have an HTTP date here, and just go without
+	modified, _ := http.ParseTime(o.Metadata[model.BlobMetaLastModified])
+
+	content, ok := o.Content.(io.ReadSeeker)
+	if !ok {
+		return serveStream(c, o, etag, modified)
+	}
+	if ct := o.Metadata[model.BlobMetaContentType]; ct != "" {
+		c.Header("Content-Type", ct)
+	}
+	http.ServeContent(c.Writer, c.Request, "", modified, content)
+	// Whatever the status, the response has been written
+	return http.StatusOK, "", nil
+}
+
+// Serve content which can't be seeked through, so can't be given to
+// http.ServeContent, without reading all of it into memory first. The
+// ETag and modification time are already known, so conditional
+// requests are answered before any is read. Ranges are fetched from
+// the store when it can, and otherwise the whole of it is sent.
+func serveStream(c *gin.Context, o *model.Blob, etag string, modified time.Time) (int, string, error) {
+	const errorCaller string = "get blob"
+	r := c.Request
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return http.StatusOK, "", nil
+	}
+
+	status, body := http.StatusOK, o.Content
+	length := o.Metadata[model.BlobMetaSize]
+	ranger, ok := o.Content.(repository.BlobRangeReader)
+	if ok {
+		c.Header("Accept-Ranges", "bytes")
+	}
+	if spec := r.Header.Get("Range"); ok && spec != "" && rangeStillValid(r, etag, modified) {
+		part, contentRange, err := ranger.ReadRange(r.Context(), spec)
+		if errors.Is(err, repository.ErrInvalidInput) {
+			if length != "" {
+				c.Header("Content-Range", "bytes */"+length)
 			}
+			return http.StatusRequestedRangeNotSatisfiable,
+				"The requested range is not satisfiable",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		} else if err != nil {
+			return wrapDatastoreError(errorCaller, err)
+		}
+		defer part.Close()
+		body = part
+		// Stores which ignore the range send the whole thing
+		if contentRange != "" {
+			status, length = http.StatusPartialContent, rangeLength(contentRange)
+			c.Header("Content-Range", contentRange)
 		}
-		c.Data(http.StatusOK, o.Metadata["content-type"], bb)
+	}
+
+	if ct := o.Metadata[model.BlobMetaContentType]; ct != "" {
+		c.Header("Content-Type", ct)
+	}
+	if length != "" {
+		c.Header("Content-Length", length)
+	}
+	c.Status(status)
+	if r.Method == http.MethodHead {
+		return http.StatusOK, "", nil
+	}
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		// Too late to say so, the response has started
+		return http.StatusOK, "", fmt.Errorf("%v: stream content: %w", errorCaller, err)
 	}
 	return http.StatusOK, "", nil
 }
 
+// Whether a cached copy is still good, as http.ServeContent decides it:
+// If-None-Match if there is one, otherwise If-Modified-Since.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			// A weak comparison, so W/ is ignored
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == "*" || t == `"`+etag+`"` {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.IsZero() && !modified.Truncate(time.Second).After(since)
+}
+
+// Whether a range may be served, which it can't if the client only
+// wants it of a version (If-Range) which this isn't
+func rangeStillValid(r *http.Request, etag string, modified time.Time) bool {
+	ir := r.Header.Get("If-Range")
+	if ir == "" || ir == `"`+etag+`"` {
+		return true
+	}
+	t, err := http.ParseTime(ir)
+	return err == nil && !modified.IsZero() && modified.Truncate(time.Second).Equal(t)
+}
+
+// The length of a Content-Range like `bytes 1-3/64`, or nothing if it
+// can't be made out
+func rangeLength(contentRange string) string {
+	var first, last int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &first, &last); err != nil || last < first {
+		return ""
+	}
+	return strconv.FormatInt(last-first+1, 10)
+}
+
+// Uploads bigger than this are refused, unless configured otherwise
+const DefaultBlobMaxSize int64 = 8 << 20
+
+// What a multipart upload may be allowed on top of the blob itself,
+// for its boundaries and part headers
+const multipartOverhead int64 = 64 << 10
+
+// Upload a blob, either as the whole request body or as the `file`
+// field of a multipart form. Only images are taken, since covers,
+// thumbnails and avatars are all blobs are used for. What kind of
+// image is sniffed from the content itself; whatever the request says
+// it is is ignored. The blob's type, size and SHA-256 checksum are kept
+// in its metadata. Uploading an image which is already a blob gives
+// back that blob, with 200 rather than 201.
 func (b *blobHandle) New(c *gin.Context) (int, string, error) {
 	const errorCaller string = "create blob"
-	panic("unimplemented")
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, b.maxSize+multipartOverhead)
+	tooLarge := func(err error) (int, string, error) {
+		return http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Blobs must be at most %d bytes", b.maxSize),
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var (
+		content  io.Reader = c.Request.Body
+		maxBytes *http.MaxBytesError
+	)
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		fh, err := c.FormFile("file")
+		if errors.As(err, &maxBytes) {
+			return tooLarge(err)
+		} else if err != nil {
+			return http.StatusBadRequest,
+				"Multipart uploads must have the blob as their `file` field",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return http.StatusInternalServerError,
+				"Could not read the uploaded file",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		defer f.Close()
+		content = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, b.maxSize+1))
+	if errors.As(err, &maxBytes) {
+		return tooLarge(err)
+	} else if err != nil {
+		return http.StatusBadRequest,
+			"Could not read the upload",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else if int64(len(data)) > b.maxSize {
+		return tooLarge(fmt.Errorf("upload over %d bytes", b.maxSize))
+	} else if len(data) == 0 {
+		return http.StatusBadRequest,
+			"Blobs must not be empty",
+			fmt.Errorf("%v: empty upload", errorCaller)
+	}
+	ct := http.DetectContentType(data)
+	if !strings.HasPrefix(ct, "image/") {
+		return http.StatusUnsupportedMediaType,
+			"Blobs must be images",
+			fmt.Errorf("%v: upload sniffed as `%v`", errorCaller, ct)
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return http.StatusInternalServerError,
+			"could not generate UUIDv7",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	sum := sha256.Sum256(data)
+	blob := model.Blob{
+		ID: id,
+		Metadata: map[string]string{
+			model.BlobMetaContentType:  ct,
+			model.BlobMetaSize:         strconv.Itoa(len(data)),
+			model.BlobMetaSHA256:       hex.EncodeToString(sum[:]),
+			model.BlobMetaLastModified: time.Now().UTC().Format(http.TimeFormat),
+		},
+		Content: bytes.NewReader(data),
+	}
+	if err := b.blob.Create(c.Request.Context(), &blob); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+
+	// Content which is already a blob gives back that one
+	status := http.StatusCreated
+	if blob.ID != id {
+		status = http.StatusOK
+	}
+	c.JSON(status, blob)
+	return status, "", nil
 }
 
+// Delete a blob. One still used as a book's cover or thumbnail, or a
+// user's avatar, is only deleted if `detach=true` is given, in which
+// case they go without; what was using it is returned.
 func (b *blobHandle) Delete(c *gin.Context) (int, string, error) {
 	const errorCaller string = "delete blob"
-	panic("unimplemented")
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%s: %w", errorCaller, err)
+	}
+	detach, err := strconv.ParseBool(c.DefaultQuery("detach", "false"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"`detach` must be true or false",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	ctx := c.Request.Context()
+	var refs []model.BlobReference
+	if detach {
+		refs, err = b.blob.Detach(ctx, id)
+	} else if refs, err = b.blob.References(ctx, id); err == nil && len(refs) > 0 {
+		return http.StatusConflict,
+			fmt.Sprintf("The blob is still used %d time(s) as a cover, thumbnail or avatar; use `detach=true` to delete it anyway", len(refs)),
+			fmt.Errorf("%v: blob `%v` referenced by %v", errorCaller, id, refs)
+	}
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	// Something may have started using the blob since, in which case
+	// this conflicts
+	if err := b.blob.Delete(ctx, id); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+
+	if !detach {
+		c.Status(http.StatusNoContent)
+		return http.StatusNoContent, "", nil
+	}
+	if refs == nil {
+		refs = []model.BlobReference{}
+	}
+	c.JSON(http.StatusOK, gin.H{"detached": refs})
+	return http.StatusOK, "", nil
 }
@@ -0,0 +1,309 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// Enough of a PNG to be sniffed as one
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 56)...)
+
+func blobFixture(t *testing.T) (*blobHandle, *mockdatastore.InMemoryRepository[string]) {
+	gin.SetMode(gin.TestMode)
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	return &blobHandle{repo.Blob, 1 << 10}, repo
+}
+
+func blobRequest(t *testing.T, ep func(*gin.Context) (int, string, error), req *http.Request, admin bool, id string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: id}}
+	c.Set("userID", uuid.NewString())
+	c.Set("permissions", admin)
+	wrap(ep)(c)
+	// As gin would once the handler is done
+	c.Writer.WriteHeaderNow()
+	return w
+}
+
+func TestBlobNew(t *testing.T) {
+	h, repo := blobFixture(t)
+	upload := func(body io.Reader, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/blob/new", body)
+		req.Header.Set("Content-Type", contentType)
+		return blobRequest(t, h.New, req, true, "")
+	}
+
+	// The type comes from what was uploaded, not what it says it is
+	w := upload(bytes.NewReader(testPNG), "image/jpeg")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created model.Blob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	sum := sha256.Sum256(testPNG)
+	assert.Equal(t, "image/png", created.Metadata[model.BlobMetaContentType])
+	assert.Equal(t, "64", created.Metadata[model.BlobMetaSize])
+	assert.Equal(t, hex.EncodeToString(sum[:]), created.Metadata[model.BlobMetaSHA256])
+	stored, err := repo.Blob.GetByID(t.Context(), created.ID)
+	require.NoError(t, err)
+	content, err := io.ReadAll(stored.Content)
+	require.NoError(t, err)
+	assert.Equal(t, testPNG, content)
+
+	// Multipart forms work too
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "cover.png")
+	require.NoError(t, err)
+	other := append(bytes.Clone(testPNG), "other"...)
+	_, err = fw.Write(other)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	w = upload(&form, mw.FormDataContentType())
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The same image again is the same blob
+	w = upload(bytes.NewReader(testPNG), "image/png")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var again model.Blob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &again))
+	assert.Equal(t, created, again)
+
+	for name, tt := range map[string]struct {
+		body        io.Reader
+		contentType string
+		want        int
+	}{
+		"not an image":    {strings.NewReader("<html><script>alert(1)</script></html>"), "image/png", http.StatusUnsupportedMediaType},
+		"too large":       {bytes.NewReader(append(testPNG, make([]byte, 1<<10)...)), "image/png", http.StatusRequestEntityTooLarge},
+		"empty":           {strings.NewReader(""), "image/png", http.StatusBadRequest},
+		"no file in form": {strings.NewReader("--x--\r\n"), "multipart/form-data; boundary=x", http.StatusBadRequest},
+	} {
+		assert.Equal(t, tt.want, upload(tt.body, tt.contentType).Code, name)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/blob/new", bytes.NewReader(testPNG))
+	assert.Equal(t, http.StatusForbidden, blobRequest(t, h.New, req, false, "").Code)
+}
+
+func TestBlobDelete(t *testing.T) {
+	h, repo := blobFixture(t)
+	ctx := t.Context()
+	newBlob := func(tag string) uuid.UUID {
+		b := model.Blob{ID: uuid.New(), Content: bytes.NewReader(append(bytes.Clone(testPNG), tag...))}
+		require.NoError(t, repo.Blob.Create(ctx, &b))
+		return b.ID
+	}
+	del := func(id uuid.UUID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/blob/"+id.String()+query, nil)
+		return blobRequest(t, h.Delete, req, true, id.String())
+	}
+
+	cover := newBlob("cover")
+	book := model.Book{ID: uuid.New(), Title: "Dune", CoverImage: cover, ThumbImage: cover}
+	require.NoError(t, repo.Book.Create(ctx, &book))
+	avatar := newBlob("avatar")
+	user := model.User{ID: uuid.New(), Avatar: avatar}
+	require.NoError(t, repo.User.Create(ctx, &user))
+
+	// Blobs in use stay put
+	assert.Equal(t, http.StatusConflict, del(cover, "").Code)
+	assert.Equal(t, http.StatusConflict, del(avatar, "").Code)
+	_, err := repo.Blob.GetByID(ctx, cover)
+	require.NoError(t, err)
+
+	// Unless whatever uses them is told to let go
+	w := del(cover, "?detach=true")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Detached []model.BlobReference `json:"detached"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.ElementsMatch(t, []model.BlobReference{
+		{Use: model.BlobUseCover, ID: book.ID},
+		{Use: model.BlobUseThumbnail, ID: book.ID},
+	}, resp.Detached)
+	b, err := repo.Book.GetByID(ctx, book.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, b.CoverImage)
+	assert.Equal(t, uuid.Nil, b.ThumbImage)
+	_, err = repo.Blob.GetByID(ctx, cover)
+	assert.Error(t, err)
+
+	// Nothing uses this one, and then it's gone
+	unused := newBlob("unused")
+	assert.Equal(t, http.StatusNoContent, del(unused, "").Code)
+	assert.Equal(t, http.StatusNotFound, del(unused, "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, del(avatar, "?detach=maybe").Code)
+	req := httptest.NewRequest(http.MethodDelete, "/api/blob/"+avatar.String(), nil)
+	assert.Equal(t, http.StatusForbidden, blobRequest(t, h.Delete, req, false, avatar.String()).Code)
+}
+
+func TestBlobGetRaw(t *testing.T) {
+	h, repo := blobFixture(t)
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sum := sha256.Sum256(testPNG)
+	blob := model.Blob{
+		ID: uuid.New(),
+		Metadata: map[string]string{
+			model.BlobMetaContentType:  "image/png",
+			model.BlobMetaSHA256:       hex.EncodeToString(sum[:]),
+			model.BlobMetaLastModified: modified.Format(http.TimeFormat),
+		},
+		Content: bytes.NewReader(testPNG),
+	}
+	require.NoError(t, repo.Blob.Create(t.Context(), &blob))
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/blob/"+blob.ID.String(), nil)
+		maps.Copy(req.Header, header)
+		return blobRequest(t, h.GetRaw, req, false, blob.ID.String())
+	}
+
+	w := get(nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testPNG, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "immutable")
+	etag := w.Header().Get("ETag")
+	assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`"`, etag)
+
+	// Anything cached is still good
+	w = get(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+	w = get(http.Header{"If-Modified-Since": {modified.Add(time.Hour).Format(http.TimeFormat)}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = get(http.Header{"If-None-Match": {`"something-else"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ranges are served on their own
+	w = get(http.Header{"Range": {"bytes=1-3"}})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, []byte("PNG"), w.Body.Bytes())
+	assert.Equal(t, "bytes 1-3/64", w.Header().Get("Content-Range"))
+	w = get(http.Header{"Range": {"bytes=100-"}})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/blob/"+uuid.NewString(), nil)
+	assert.Equal(t, http.StatusNotFound, blobRequest(t, h.GetRaw, req, false, uuid.NewString()).Code)
+}
+
+// Blobs whose content can't be seeked through, like S3's, but whose
+// ranges can be fetched
+type streamedBlobs struct {
+	repository.BlobManager
+	// The ranges asked of the store
+	ranges []string
+}
+
+func (s *streamedBlobs) GetByID(ctx context.Context, id uuid.UUID) (*model.Blob, error) {
+	o, err := s.BlobManager.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := io.ReadAll(o.Content)
+	o.Content = streamedContent{Reader: bytes.NewReader(data), data: data, s: s}
+	return o, nil
+}
+
+type streamedContent struct {
+	io.Reader
+	data []byte
+	s    *streamedBlobs
+}
+
+func (c streamedContent) ReadRange(ctx context.Context, spec string) (io.ReadCloser, string, error) {
+	c.s.ranges = append(c.s.ranges, spec)
+	var first, last int
+	if _, err := fmt.Sscanf(spec, "bytes=%d-%d", &first, &last); err != nil || first >= len(c.data) {
+		return nil, "", repository.Err{Code: repository.ErrInvalidInput, Err: fmt.Errorf("bad range `%v`", spec)}
+	}
+	last = min(last, len(c.data)-1)
+	return io.NopCloser(bytes.NewReader(c.data[first : last+1])),
+		fmt.Sprintf("bytes %d-%d/%d", first, last, len(c.data)), nil
+}
+
+func TestBlobGetRawStreamed(t *testing.T) {
+	h, repo := blobFixture(t)
+	streamed := &streamedBlobs{BlobManager: repo.Blob}
+	h.blob = streamed
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	sum := sha256.Sum256(testPNG)
+	blob := model.Blob{
+		ID: uuid.New(),
+		Metadata: map[string]string{
+			model.BlobMetaContentType:  "image/png",
+			model.BlobMetaSHA256:       hex.EncodeToString(sum[:]),
+			model.BlobMetaSize:         "64",
+			model.BlobMetaLastModified: modified.Format(http.TimeFormat),
+		},
+		Content: bytes.NewReader(testPNG),
+	}
+	require.NoError(t, repo.Blob.Create(t.Context(), &blob))
+	get := func(method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/blob/"+blob.ID.String(), nil)
+		maps.Copy(req.Header, header)
+		return blobRequest(t, h.GetRaw, req, false, blob.ID.String())
+	}
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	w := get(http.MethodGet, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testPNG, w.Body.Bytes())
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "64", w.Header().Get("Content-Length"))
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, modified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	w = get(http.MethodHead, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+
+	// Anything cached is still good
+	w = get(http.MethodGet, http.Header{"If-None-Match": {`"other", ` + etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.Bytes())
+	w = get(http.MethodGet, http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = get(http.MethodGet, http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = get(http.MethodGet, http.Header{"If-None-Match": {`"something-else"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ranges are passed on to the store
+	w = get(http.MethodGet, http.Header{"Range": {"bytes=1-3"}})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, []byte("PNG"), w.Body.Bytes())
+	assert.Equal(t, "bytes 1-3/64", w.Header().Get("Content-Range"))
+	assert.Equal(t, "3", w.Header().Get("Content-Length"))
+	assert.Equal(t, []string{"bytes=1-3"}, streamed.ranges)
+	w = get(http.MethodGet, http.Header{"Range": {"bytes=100-"}})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */64", w.Header().Get("Content-Range"))
+	// Unless they're of some other version
+	w = get(http.MethodGet, http.Header{"Range": {"bytes=1-3"}, "If-Range": {`"something-else"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, testPNG, w.Body.Bytes())
+}
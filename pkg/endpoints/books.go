@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,6 +16,8 @@ import (
 
 type bookHandle[S comparable] struct {
 	repo repository.BookManager[S]
+	rfsh repository.BookRefresher
+	srs  repository.SeriesManager
 }
 
 func (bh *bookHandle[S]) GetBookByID(c *gin.Context) {
@@ -33,6 +37,33 @@ func (bh *bookHandle[S]) GetBookByID(c *gin.Context) {
 				Details: err})
 		return
 	}
+	// Where each field came from is only of interest to some, so it
+	// is left out unless asked for with `?include=provenance`
+	if slices.Contains(strings.Split(c.Query("include"), ","), "provenance") {
+		b := *s
+		if b.Provenance, err = bh.repo.Provenance(c.Request.Context(), id); err != nil {
+			c.JSON(http.StatusInternalServerError,
+				jsonParsableError{Summary: "Could not get provenance of book",
+					Details: err})
+			return
+		}
+		s = &b
+	}
+	// Only the book's own page links to its neighbours in the series,
+	// it is too much work to do for every book in a list
+	if s.Series != nil {
+		series, err := bh.srs.GetByID(c.Request.Context(), s.Series.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError,
+				jsonParsableError{Summary: "Could not get series of book",
+					Details: err})
+			return
+		}
+		b, bs := *s, *s.Series
+		bs.Previous, bs.Next = series.Neighbours(id)
+		b.Series = &bs
+		s = &b
+	}
 	c.JSON(http.StatusOK, *s)
 }
 
@@ -75,6 +106,33 @@ func (bh *bookHandle[S]) AddBook(c *gin.Context) {
 		return
 	}
 
+	if b.Format != "" && !b.Format.Valid() {
+		c.JSON(http.StatusBadRequest,
+			jsonParsableError{Summary: "unknown edition format",
+				Details: fmt.Errorf("format `%v`", b.Format)})
+		return
+	}
+	for _, ct := range b.Contributors {
+		if !ct.Role.Valid() {
+			c.JSON(http.StatusBadRequest,
+				jsonParsableError{Summary: "unknown contributor role",
+					Details: fmt.Errorf("role `%v` of `%v`", ct.Role, ct.ID)})
+			return
+		}
+	}
+
+	// Where a book came from is for the datastore to say, and it came
+	// from whoever is submitting it. New books start out in a work of
+	// their own and in no series; admins can change both afterwards.
+	b.Source = nil
+	b.Work = uuid.Nil
+	b.Series = nil
+	submitter := model.Provenance{Source: model.ProvenanceUser}
+	if uid, err := wrapGinContextUserID(c); err == nil {
+		submitter.Actor = uid
+	}
+	b.Attribute(submitter)
+
 	if id, err := uuid.NewV7(); err != nil {
 		c.JSON(http.StatusInternalServerError,
 			jsonParsableError{Summary: "failed to generate new UUID",
@@ -93,3 +151,93 @@ func (bh *bookHandle[S]) AddBook(c *gin.Context) {
 
 	c.IndentedJSON(http.StatusCreated, b)
 }
+
+// Update a book using JSON merge patch semantics (RFC 7386).
+//
+// The stored book is rendered to JSON, the request body is merged
+// into it, and the result is written back. This lets admins fix a
+// single bad field (say, a scraped subtitle) without resending the
+// whole object. Arrays such as `isbns` and `authors` are replaced
+// outright, as the RFC dictates.
+func (bh *bookHandle[S]) Update(c *gin.Context) (int, string, error) {
+	const errorCaller string = "update book"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	current, err := bh.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	var b model.Book
+	if h, s, err := wrapMergePatch(c, errorCaller, current, &b); h != 0 {
+		return h, s, err
+	} else if b.ID != id {
+		return http.StatusBadRequest,
+			"The ID of a book cannot be changed",
+			fmt.Errorf("%v: patch changed ID `%v` -> `%v`", errorCaller, id, b.ID)
+	} else if b.Title == "" {
+		return http.StatusBadRequest,
+			"A book must have a title",
+			fmt.Errorf("%v: patch removed title", errorCaller)
+	} else if b.Format != "" && !b.Format.Valid() {
+		return http.StatusBadRequest,
+			"Format must be one of `hardcover`, `paperback`, `ebook` or `audiobook`",
+			fmt.Errorf("%v: unknown format `%v`", errorCaller, b.Format)
+	}
+	for _, ct := range b.Contributors {
+		if !ct.Role.Valid() {
+			return http.StatusBadRequest,
+				"Roles must be one of `author`, `editor`, `translator`, `illustrator` or `narrator`",
+				fmt.Errorf("%v: unknown role `%v` of `%v`", errorCaller, ct.Role, ct.ID)
+		}
+	}
+
+	uid, err := wrapGinContextUserID(c)
+	if err != nil {
+		return http.StatusInternalServerError,
+			"Issue parsing ID from context",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	updated, _, err := bh.repo.Edit(c.Request.Context(), &b, model.Provenance{
+		Source: model.ProvenanceAdmin,
+		Actor:  uid,
+	})
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, updated)
+	return http.StatusOK, "", nil
+}
+
+// Re-fetch a book's metadata from the provider it was scraped from,
+// rather than waiting for it to go stale. Fields which were edited by
+// hand are kept as they are.
+func (bh *bookHandle[S]) Refresh(c *gin.Context) (int, string, error) {
+	const errorCaller string = "refresh book"
+	if h, s, err := wrapRequireAdmin(c, errorCaller); h != 0 {
+		return h, s, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	book, changed, err := bh.rfsh.RefreshBook(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"book":    book,
+		"changed": append([]string{}, changed...),
+	})
+	return http.StatusOK, "", nil
+}
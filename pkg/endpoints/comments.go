@@ -18,6 +18,7 @@ type commentHandle[S comparable] struct {
 	book repository.BookManager[S]
 	comm repository.CommentManager[S]
 	vote repository.VoteManager
+	work repository.WorkManager
 }
 
 // TODO: This is not where I want to concrete this...
@@ -41,6 +42,24 @@ func (ch *commentHandle[S]) BookReviews(c *gin.Context) (int, string, error) {
 	return http.StatusOK, "", nil
 }
 
+// Reviews of a work, both of the work as a whole and of each of its
+// editions
+func (ch *commentHandle[S]) WorkReviews(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get work reviews"
+	workID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%s: %w", errorCaller, err)
+	}
+	comments, err := ch.comm.WorkComments(c.Request.Context(), workID)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, comments)
+	return http.StatusOK, "", nil
+}
+
 func (ch *commentHandle[S]) Get(c *gin.Context) (int, string, error) {
 	const errorCaller string = "get comment"
 	commentID, err := uuid.Parse(c.Param("id"))
@@ -59,6 +78,44 @@ func (ch *commentHandle[S]) Get(c *gin.Context) (int, string, error) {
 
 func (ch *commentHandle[S]) Post(c *gin.Context) (int, string, error) {
 	const errorCaller string = "post new comment"
+	return ch.post(c, errorCaller, func(comment *model.Comment) (int, string, error) {
+		// Try to get very likely non-existent ID
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return 0, "", nil
+		}
+		_, err = ch.book.GetByID(c.Request.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, "", nil
+		}
+		// Set the book ID if it was passed in the URL
+		comment.Book = id
+		return 0, "", nil
+	})
+}
+
+// Review a work as a whole, rather than any one edition of it
+func (ch *commentHandle[S]) WorkPost(c *gin.Context) (int, string, error) {
+	const errorCaller string = "post new work comment"
+	return ch.post(c, errorCaller, func(comment *model.Comment) (int, string, error) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return http.StatusBadRequest,
+				"Unable to parse UUID",
+				fmt.Errorf("%s: %w", errorCaller, err)
+		}
+		if _, err = ch.work.GetByID(c.Request.Context(), id); err != nil {
+			return wrapDatastoreError(errorCaller, err)
+		}
+		comment.Work = id
+		comment.Book = uuid.Nil
+		return 0, "", nil
+	})
+}
+
+// Create a comment from the request body. `attach` decides what the
+// comment is under; a non-zero status from it is returned as-is.
+func (ch *commentHandle[S]) post(c *gin.Context, errorCaller string, attach func(*model.Comment) (int, string, error)) (int, string, error) {
 	// The user ID parameter must be set.
 	tokenUserID, err := wrapGinContextUserID(c)
 	if errors.Is(err, errUserIDKeyNotFound) {
@@ -70,19 +127,6 @@ func (ch *commentHandle[S]) Post(c *gin.Context) (int, string, error) {
 			"issue parsing ID from context",
 			fmt.Errorf("%s: %w", errorCaller, err)
 	}
-	// Try to get very likely non-existent ID
-	contextBookID := func(c *gin.Context) uuid.UUID {
-		id, err := uuid.Parse(c.Param("id"))
-		if err != nil {
-			return uuid.Nil
-		}
-		_, err = ch.book.GetByID(c.Request.Context(), id)
-		if errors.Is(err, repository.ErrNotFound) {
-			return uuid.Nil
-		}
-		return id
-	}(c)
-
 	jBytes, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		return http.StatusBadRequest,
@@ -95,9 +139,8 @@ func (ch *commentHandle[S]) Post(c *gin.Context) (int, string, error) {
 			"could not parse JSON into comment object",
 			fmt.Errorf("%s: %w", errorCaller, err)
 	}
-	// Set the book ID if it was passed in the URL
-	if contextBookID != uuid.Nil {
-		comment.Book = contextBookID
+	if h, s, err := attach(&comment); h != 0 {
+		return h, s, err
 	}
 	// We do not need to populate the rest, this should be enough for
 	// the backing store
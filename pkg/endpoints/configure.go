@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -46,6 +47,14 @@ func wrapDatastoreError(caller string, err error) (int, string, error) {
 		return http.StatusBadRequest,
 			"Could not cast given value as necessary type",
 			fmt.Errorf("%v: %w", caller, err)
+	} else if errors.Is(err, repository.ErrConflict) {
+		return http.StatusConflict,
+			"The request conflicts with the current state of the datastore",
+			fmt.Errorf("%v: %w", caller, err)
+	} else if errors.Is(err, repository.ErrInvalidInput) {
+		return http.StatusBadRequest,
+			"The datastore rejected the given values",
+			fmt.Errorf("%v: %w", caller, err)
 	} else {
 		return http.StatusInternalServerError,
 			"An issue occured and your request could not be completed",
@@ -63,15 +72,89 @@ func wrapGetUUID(c *gin.Context, key string) (uuid.UUID, error) {
 	return uuid.Parse(idStr)
 }
 
+// Check that the request comes from a site administrator. This
+// expects the AuthorizationJWT and UserPermissions middlewares to have
+// run beforehand. A zero status means the user is an admin and the
+// handler can carry on; otherwise the values can be returned as-is.
+func wrapRequireAdmin(c *gin.Context, caller string) (int, string, error) {
+	if _, err := wrapGinContextUserID(c); errors.Is(err, errUserIDKeyNotFound) {
+		return http.StatusUnauthorized,
+			"You must be logged in to access this page",
+			fmt.Errorf("%v: %w", caller, err)
+	} else if err != nil {
+		return http.StatusInternalServerError,
+			"Issue parsing ID from context",
+			fmt.Errorf("%v: %w", caller, err)
+	}
+	if !c.GetBool("permissions") {
+		return http.StatusForbidden,
+			"You do not have the necessary permissions to do this",
+			fmt.Errorf("%v: user permissions error", caller)
+	}
+	return 0, "", nil
+}
+
+// Read the sort, order, limit and cursor query parameters used when
+// paging through a list of books. On error, the string is a summary
+// fit to show the user.
+func wrapBibliographyOptions(c *gin.Context) (repository.BibliographyOptions, string, error) {
+	opts := repository.BibliographyOptions{
+		Sort:       repository.BookSort(c.DefaultQuery("sort", string(repository.BookSortPublished))),
+		Descending: true,
+		Cursor:     c.Query("cursor"),
+	}
+	switch opts.Sort {
+	case repository.BookSortPublished, repository.BookSortRating:
+	default:
+		return opts,
+			"Books can only be sorted by `published` or `rating`",
+			fmt.Errorf("unknown sort `%v`", opts.Sort)
+	}
+	switch o := c.DefaultQuery("order", "desc"); o {
+	case "desc":
+	case "asc":
+		opts.Descending = false
+	default:
+		return opts,
+			"Order must be either `asc` or `desc`",
+			fmt.Errorf("unknown order `%v`", o)
+	}
+	if l := c.Query("limit"); l != "" {
+		var err error
+		if opts.Limit, err = strconv.Atoi(l); err != nil {
+			return opts, "Limit must be an integer", err
+		}
+	}
+	return opts, "", nil
+}
+
+// A single page of a cursor-paginated listing. Next is omitted on the
+// last page, and Prev on the first or where there's no going back.
+type pagedResponse[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
 type jsonParsableError struct {
 	Summary string `json:"summary"`
 	Details error  `json:"details"`
 }
 
 func (j jsonParsableError) MarshalJSON() ([]byte, error) {
+	// Queries which couldn't be parsed say where, so the client can
+	// point it out
+	var (
+		pos  *int
+		serr *repository.QuerySyntaxError
+	)
+	if errors.As(j.Details, &serr) {
+		pos = &serr.Pos
+	}
 	return json.Marshal(struct {
-		Summary string `json:"summary"`
-		Details string `json:"details"`
+		Summary  string `json:"summary"`
+		Details  string `json:"details"`
+		Position *int   `json:"position,omitempty"`
 	}{
 		Summary: j.Summary,
 		// I love Grust.
@@ -83,13 +166,14 @@ func (j jsonParsableError) MarshalJSON() ([]byte, error) {
 				return e.Error()
 			}
 		}(j.Details),
+		Position: pos,
 	})
 }
 
 var conf *oauth2.Config
 
 // Configure all backend endpoints
-func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c *oauth2.Config, scraper repository.BookScraper) {
+func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c *oauth2.Config, queue repository.ScrapeQueue, refresher repository.BookRefresher, blobMaxSize int64) {
 	conf = c
 
 	api := router.Group("/api")
@@ -97,8 +181,19 @@ func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c
 	s := dataStore{rp.Store}
 	api.GET("/health", s.Health)
 
-	sh := searchHandle[S]{rp.Book, rp.Author, rp.Comment, scraper}
+	sh := searchHandle[S]{rp.Book, rp.Author, rp.Comment, rp.User, queue, newSearchWeights()}
 	api.GET("/search", wrap(sh.Search))
+	api.GET("/search/suggest", wrap(sh.Suggest))
+	api.GET("/search/weights", wrap(sh.Weights))
+	api.PUT("/search/weights", AuthorizationJWT(), UserPermissions(), wrap(sh.SetWeights)) // Only to be used by site admins
+
+	subjects := api.Group("/subjects")
+	sj := subjectHandle[S]{rp.Subject, rp.Book}
+	subjects.GET("", wrap(sj.List))
+	subjects.GET("/:id/books", wrap(sj.Books))
+
+	jh := scrapeHandle{rp.ScrapeJob}
+	api.GET("/scrape/jobs/:id", wrap(jh.Job))
 
 	ah = authHandle{rp.User, rp.Auth}
 	var err error
@@ -110,8 +205,14 @@ func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c
 	api.GET("/auth/github/login", ah.Login)
 	api.GET("/auth/github/callback", wrap(ah.GithubCallback))
 
-	th := athrHandle[S]{rp.Author}
-	api.GET("/authors/:id", th.GetAuthorByID)
+	authors := api.Group("/authors")
+	th := athrHandle[S]{rp.Author, rp.Book}
+	authors.POST("", AuthorizationJWT(), UserPermissions(), wrap(th.Create)) // Only to be used by site admins
+	authors.GET("/:id", th.GetAuthorByID)
+	authors.PATCH("/:id", AuthorizationJWT(), UserPermissions(), wrap(th.Update))     // Only to be used by site admins
+	authors.DELETE("/:id", AuthorizationJWT(), UserPermissions(), wrap(th.Delete))    // Only to be used by site admins
+	authors.POST("/:id/merge", AuthorizationJWT(), UserPermissions(), wrap(th.Merge)) // Only to be used by site admins
+	authors.GET("/:id/books", wrap(th.Books))
 
 	profile := api.Group("/user")
 	profile.Use(AuthorizationJWT())
@@ -124,15 +225,17 @@ func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c
 	profile.DELETE("/me", wrap(uh.Delete))           // Only to be used by authenticated accts
 
 	books := api.Group("/books")
-	bh := bookHandle[S]{rp.Book}
+	bh := bookHandle[S]{rp.Book, refresher, rp.Series}
 	books.POST("/new", bh.AddBook).Use(AuthorizationJWT(), UserPermissions())
 	books.GET("/:id", bh.GetBookByID)
+	books.PATCH("/:id", AuthorizationJWT(), UserPermissions(), wrap(bh.Update))         // Only to be used by site admins
+	books.POST("/:id/refresh", AuthorizationJWT(), UserPermissions(), wrap(bh.Refresh)) // Only to be used by site admins
 	books.GET("/isbn/:isbn", bh.GetBookByISBN)
 	// See below for additional book endpoints
 
 	comments := api.Group("/comments")
 	comments.Use(AuthorizationJWT())
-	ch := commentHandle[S]{rp.Book, rp.Comment, rp.Vote}
+	ch := commentHandle[S]{rp.Book, rp.Comment, rp.Vote, rp.Work}
 	books.GET("/:id/reviews", wrap(ch.BookReviews))
 	books.GET("/:id/reviews/votes", wrap(ch.Votes)) // Only to be used by authenticated accts
 	books.POST("/:id/reviews", wrap(ch.Post))       // Only to be used by authenticated accts
@@ -143,9 +246,26 @@ func Configure[S comparable](router *gin.Engine, rp *repository.Repository[S], c
 	comments.PATCH("/:id", wrap(ch.Edit))                                              // Only to be used by authenticated accts
 	comments.DELETE(":id", wrap(ch.Delete)).Use(AuthorizationJWT(), UserPermissions()) // Only to be used by authenticated accts (+admin functionality)
 
+	works := api.Group("/works")
+	wh := workHandle{rp.Work}
+	works.POST("/cluster", AuthorizationJWT(), UserPermissions(), wrap(wh.Cluster)) // Only to be used by site admins
+	works.GET("/:id", wrap(wh.Get))
+	works.POST("/:id/join", AuthorizationJWT(), UserPermissions(), wrap(wh.Join))   // Only to be used by site admins
+	works.POST("/:id/split", AuthorizationJWT(), UserPermissions(), wrap(wh.Split)) // Only to be used by site admins
+	works.GET("/:id/reviews", wrap(ch.WorkReviews))
+	works.POST("/:id/reviews", AuthorizationJWT(), wrap(ch.WorkPost)) // Only to be used by authenticated accts
+
+	series := api.Group("/series")
+	rh := seriesHandle{rp.Series}
+	series.POST("", AuthorizationJWT(), UserPermissions(), wrap(rh.Create)) // Only to be used by site admins
+	series.GET("/:id", wrap(rh.Get))
+	series.PATCH("/:id", AuthorizationJWT(), UserPermissions(), wrap(rh.Update))  // Only to be used by site admins
+	series.DELETE("/:id", AuthorizationJWT(), UserPermissions(), wrap(rh.Delete)) // Only to be used by site admins
+
 	blob := api.Group("/blob")
-	lh = blobHandle{rp.Blob}
+	lh = blobHandle{rp.Blob, blobMaxSize}
 	blob.GET("/:id", wrap(lh.GetRaw))
-	blob.POST("/new", wrap(lh.New)).Use(AuthorizationJWT(), UserPermissions())      // Only to be used by site admins or system itself
-	blob.DELETE("/:id", wrap(lh.Delete)).Use(AuthorizationJWT(), UserPermissions()) // Only to be used by site admins or system itself
+	blob.HEAD("/:id", wrap(lh.GetRaw))
+	blob.POST("/new", AuthorizationJWT(), UserPermissions(), wrap(lh.New))      // Only to be used by site admins or system itself
+	blob.DELETE("/:id", AuthorizationJWT(), UserPermissions(), wrap(lh.Delete)) // Only to be used by site admins or system itself
 }
@@ -0,0 +1,98 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Content type for JSON merge patches, as defined by RFC 7386.
+const mergePatchContentType string = "application/merge-patch+json"
+
+// Check a request content type is acceptable for a merge patch.
+//
+// Strictly speaking only application/merge-patch+json should be
+// allowed, but plain JSON is accepted as well so clients don't need
+// to go out of their way to set a special header.
+func isMergePatchContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == mergePatchContentType || mt == "application/json"
+}
+
+// Apply a JSON merge patch (RFC 7386) to a JSON document.
+//
+// In short: objects are merged recursively, a null value removes the
+// key from the target, and anything else (including arrays) replaces
+// the target value outright.
+func mergePatch(target, patch []byte) ([]byte, error) {
+	var t, p any
+	if err := json.Unmarshal(target, &t); err != nil {
+		return nil, fmt.Errorf("merge patch: unmarshal target: %w", err)
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("merge patch: unmarshal patch: %w", err)
+	}
+	return json.Marshal(mergePatchValue(t, p))
+}
+
+func mergePatchValue(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = make(map[string]any, len(pm))
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = mergePatchValue(tm[k], v)
+		}
+	}
+	return tm
+}
+
+// Apply the request body of c as a merge patch over `current`, and
+// unmarshal the result into `into`. Like wrapRequireAdmin, a zero
+// status means everything went fine, otherwise the values can be
+// returned straight from the handler.
+func wrapMergePatch(c *gin.Context, caller string, current, into any) (int, string, error) {
+	if ct := c.ContentType(); !isMergePatchContentType(ct) {
+		return http.StatusUnsupportedMediaType,
+			fmt.Sprintf("Edits must be sent as `%v`", mergePatchContentType),
+			fmt.Errorf("%v: unexpected content-type `%v`", caller, ct)
+	}
+	original, err := json.Marshal(current)
+	if err != nil {
+		return http.StatusInternalServerError,
+			"Could not render the stored object as JSON",
+			fmt.Errorf("%v: %w", caller, err)
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return http.StatusBadRequest,
+			"There was an issue reading the body of your request",
+			fmt.Errorf("%v: %w", caller, err)
+	}
+	merged, err := mergePatch(original, patch)
+	if err != nil {
+		return http.StatusBadRequest,
+			"Could not apply your request as a JSON merge patch",
+			fmt.Errorf("%v: %w", caller, err)
+	}
+	if err = json.Unmarshal(merged, into); err != nil {
+		return http.StatusBadRequest,
+			"The patched object is not valid",
+			fmt.Errorf("%v: %w", caller, err)
+	}
+	return 0, "", nil
+}
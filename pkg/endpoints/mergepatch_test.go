@@ -0,0 +1,66 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test cases are taken from RFC 7386, Appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		have, err := mergePatch([]byte(tt.target), []byte(tt.patch))
+		if assert.NoError(t, err, "merging %s into %s", tt.patch, tt.target) {
+			assert.JSONEq(t, tt.expected, string(have),
+				"merging %s into %s", tt.patch, tt.target)
+		}
+	}
+}
+
+func TestMergePatchInvalidJSON(t *testing.T) {
+	_, err := mergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	assert.Error(t, err)
+
+	_, err = mergePatch([]byte(`nope`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestMergePatchBookFields(t *testing.T) {
+	target := []byte(`{"id":"019595bd-8d5c-75c6-b81b-07a9a7f81702","title":"Oliver Twist","subtitle":"wrong","authors":["01959161-cdfc-7142-8bab-a7008477f417"]}`)
+	have, err := mergePatch(target, []byte(`{"subtitle":null,"description":"An orphan."}`))
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(have, &m))
+	assert.NotContains(t, m, "subtitle")
+	assert.Equal(t, "An orphan.", m["description"])
+	assert.Equal(t, "Oliver Twist", m["title"])
+}
+
+func TestIsMergePatchContentType(t *testing.T) {
+	assert.True(t, isMergePatchContentType("application/merge-patch+json"))
+	assert.True(t, isMergePatchContentType("application/json; charset=utf-8"))
+	assert.False(t, isMergePatchContentType("text/plain"))
+	assert.False(t, isMergePatchContentType(""))
+}
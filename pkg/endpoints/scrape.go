@@ -0,0 +1,33 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type scrapeHandle struct {
+	jobs repository.ScrapeJobManager
+}
+
+// Report on a background scrape job, such as the one named in a
+// search's `X-Scrape-Job` header.
+func (h scrapeHandle) Job(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get scrape job"
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	job, err := h.jobs.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, job)
+	return http.StatusOK, "", nil
+}
@@ -1,46 +1,353 @@
 package endpoints
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
-	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
 	"github.com/whit-colm/itsc-4155-project/pkg/repository"
 )
 
-// Is not strictly necessary for security reasons, but prevents queries
-// from randomly failing due to unexpected characters.
-var querySanitizer *regexp.Regexp
-
-func init() {
-	querySanitizer = regexp.MustCompile(`([\\\'\"\;\(\)\[\]\{\}\^\%\x60])`)
-}
+// The domains which can be searched. Results which score the same are
+// ranked in this order.
+var searchDomains = []string{"comments", "booktitle", "authorname", "users"}
 
 type searchHandle[S comparable] struct {
 	book repository.BookManager[S]
 	athr repository.AuthorManager[S]
 	comm repository.CommentManager[S]
-	scrp repository.BookScraper
+	user repository.UserManager
+	scrp repository.ScrapeQueue
+	wght *searchWeights
+}
+
+// Each domain's scores come from its own index, so aren't comparable
+// with another's: a book's title is short and its words rare, so books
+// would outscore comments every time. Results are instead merged by
+// reciprocal rank fusion, which only looks at where each result ranks
+// in its own domain. The result ranked r (from 1) scores w/(rrfK+r),
+// w being its domain's weight.
+//
+// rrfK damps how much more the top few ranks are worth than the rest;
+// 60 is what RRF was first described with, and is the usual choice.
+const rrfK = 60
+
+// The most a domain can be weighted. Past this, a domain's last result
+// on a page would outrank every other domain's first.
+const maxSearchWeight float64 = 100
+
+// How much each domain counts for when merging search results. Weights
+// can be changed while we're running, so are shared between requests.
+type searchWeights struct {
+	mut sync.RWMutex
+	w   map[string]float64
+}
+
+// Every domain weighted the same
+func newSearchWeights() *searchWeights {
+	w := make(map[string]float64, len(searchDomains))
+	for _, d := range searchDomains {
+		w[d] = 1
+	}
+	return &searchWeights{w: w}
+}
+
+// A copy of every domain's weight
+func (sw *searchWeights) Get() map[string]float64 {
+	sw.mut.RLock()
+	defer sw.mut.RUnlock()
+	return maps.Clone(sw.w)
+}
+
+// Change the weights of some domains, leaving the rest as they are.
+// Either every weight is changed or none are, and all of them are
+// returned.
+func (sw *searchWeights) Set(w map[string]float64) (map[string]float64, error) {
+	for d, v := range w {
+		if !slices.Contains(searchDomains, d) {
+			return nil, fmt.Errorf("%w: unknown domain `%v`", repository.ErrInvalidInput, d)
+		} else if !(v > 0 && v <= maxSearchWeight) {
+			return nil, fmt.Errorf("%w: weight %v for `%v` out of range", repository.ErrInvalidInput, v, d)
+		}
+	}
+	sw.mut.Lock()
+	defer sw.mut.Unlock()
+	maps.Copy(sw.w, w)
+	return maps.Clone(sw.w), nil
 }
 
+// A point between two pages of search results. For each domain it
+// holds the last result ranked before that point, or nothing if there
+// are none. Cursors are signed, so that the positions we're handed
+// back are ones we gave out.
+type searchCursor struct {
+	// Identifies the query, domains and filters the cursor was made
+	// for; a cursor can't be used for any other search.
+	Search    string                                `json:"q"`
+	Positions map[string]*repository.SearchPosition `json:"p,omitempty"`
+	// For each domain, how many of its results were ranked before this
+	// point. Results are fused by these ranks, and a scrape picks up
+	// from however many books there were.
+	Ranks map[string]int `json:"n,omitempty"`
+	// The domains' weights when the search began. They are kept for
+	// the whole search, so that changing them doesn't reorder results
+	// which have already been paged through.
+	Weights map[string]float64 `json:"w,omitempty"`
+	// Whether this is the end of the page wanted, rather than the
+	// start.
+	Backward bool `json:"b,omitempty"`
+}
+
+// What each domain of a search is narrowed down to
+type searchFilters struct {
+	Books    repository.BookFilter
+	Authors  repository.AuthorFilter
+	Comments repository.CommentFilter
+}
+
+func (f searchFilters) IsZero() bool {
+	return f.Books.IsZero() && f.Authors.IsZero() && f.Comments.IsZero()
+}
+
+// Read the filters for a search from its query parameters. Parameters
+// apply to every domain they make sense for:
+//   - subject, language, published_from, published_to: books, and
+//     authors credited on a matching book
+//   - author: books
+//   - role: authors
+//   - rating_min, rating_max: books by their mean rating, authors
+//     credited on a matching book, and reviews by their own rating
+//   - poster, book, reviews: comments
+//
+// None of them are about users, so users are left out of any search
+// which is filtered.
+func searchFilterParams(c *gin.Context) (searchFilters, string, error) {
+	var f searchFilters
+	ids := func(param string) (uuid.UUIDs, error) {
+		var ids uuid.UUIDs
+		if v := c.Query(param); v != "" {
+			for _, s := range strings.Split(v, ",") {
+				id, err := uuid.Parse(strings.TrimSpace(s))
+				if err != nil {
+					return nil, err
+				}
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	}
+	var err error
+	for param, dst := range map[string]*uuid.UUIDs{
+		"subject": &f.Books.Subjects,
+		"author":  &f.Books.Authors,
+		"poster":  &f.Comments.Posters,
+		"book":    &f.Comments.Books,
+	} {
+		if *dst, err = ids(param); err != nil {
+			return f,
+				fmt.Sprintf("`%v` must be a comma-separated list of UUIDs", param),
+				fmt.Errorf("%v: %w", param, err)
+		}
+	}
+	for param, bound := range map[string]*model.PartialDate{
+		"published_from": &f.Books.PublishedFrom,
+		"published_to":   &f.Books.PublishedTo,
+	} {
+		if *bound, err = model.ParsePartialDate(c.Query(param)); err != nil {
+			return f,
+				fmt.Sprintf("`%v` must be a date like 2006, 2006-01 or 2006-01-02", param),
+				fmt.Errorf("%v: %w", param, err)
+		}
+	}
+	for param, bound := range map[string]**float32{
+		"rating_min": &f.Books.MinRating,
+		"rating_max": &f.Books.MaxRating,
+	} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		r, err := strconv.ParseFloat(v, 32)
+		if err != nil || r < 0 || r > 1 {
+			return f,
+				fmt.Sprintf("`%v` must be a rating from 0 to 1", param),
+				fmt.Errorf("%v: bad rating `%v`", param, v)
+		}
+		rating := float32(r)
+		*bound = &rating
+	}
+	if l := c.Query("language"); l != "" {
+		for _, lang := range strings.Split(l, ",") {
+			f.Books.Languages = append(f.Books.Languages, strings.TrimSpace(lang))
+		}
+	}
+	if r := c.Query("role"); r != "" {
+		for _, role := range strings.Split(r, ",") {
+			role := model.ContributorRole(strings.TrimSpace(role))
+			if !role.Valid() {
+				return f,
+					"`role` must be a comma-separated list of `author`, `editor`, `translator`, `illustrator` or `narrator`",
+					fmt.Errorf("role: unknown role `%v`", role)
+			}
+			f.Authors.Roles = append(f.Authors.Roles, role)
+		}
+	}
+	if v := c.Query("reviews"); v != "" {
+		if f.Comments.Reviews, err = strconv.ParseBool(v); err != nil {
+			return f, "`reviews` must be true or false", fmt.Errorf("reviews: %w", err)
+		}
+	}
+
+	// Authors are filtered by their books, though only by what the
+	// books are, not who wrote them
+	f.Authors.Books = f.Books
+	f.Authors.Books.Authors = nil
+	f.Comments.MinRating, f.Comments.MaxRating = f.Books.MinRating, f.Books.MaxRating
+	return f, "", nil
+}
+
+// A short digest of everything which decides a search's results
+func searchKey(query string, domains []string, filters searchFilters) (string, error) {
+	b, err := json.Marshal(struct {
+		Q string        `json:"q"`
+		D []string      `json:"d"`
+		F searchFilters `json:"f"`
+	}{query, domains, filters})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+type searchResponse struct {
+	pagedResponse[map[string]any]
+	Facets *repository.BookFacets `json:"facets,omitempty"`
+}
+
+// A search result, the domain it came from and its fused score
+type rankedResult struct {
+	domain int
+	score  float64
+	item   repository.AnyScoreItemer
+}
+
+// Rank results by fused score, then domain. Results from the same
+// domain never tie, as no two share a rank.
+func (r rankedResult) compare(o rankedResult) int {
+	switch {
+	case r.score > o.score:
+		return -1
+	case r.score < o.score:
+		return 1
+	default:
+		return r.domain - o.domain
+	}
+}
+
+// Effectively do the merge part of merge sort. Each domain's results
+// are already ranked, and a result's fused score only falls the further
+// down its domain it is, so the next result overall is whichever
+// domain's next result scores highest.
+//
+// ranks[i] is how many of domain i's results were ranked before its
+// first result here. Going backwards, results[i] ends at the one ranked
+// ranks[i] instead, and the page is built from the end. Returns the
+// page, and how many results were taken from each domain.
+func mergeSearchResults(results [][]repository.AnyScoreItemer, ranks []int, weights []float64, backward bool, limit int) ([]rankedResult, []int) {
+	var page []rankedResult
+	taken := make([]int, len(results))
+	candidate := func(i int) rankedResult {
+		r := results[i]
+		if backward {
+			return rankedResult{i, weights[i] / float64(rrfK+ranks[i]-taken[i]), r[len(r)-1-taken[i]]}
+		}
+		return rankedResult{i, weights[i] / float64(rrfK+ranks[i]+taken[i]+1), r[taken[i]]}
+	}
+	for len(page) < limit {
+		best := -1
+		for i, r := range results {
+			if taken[i] == len(r) {
+				continue
+			} else if best == -1 {
+				best = i
+			} else if cmp := candidate(i).compare(candidate(best)); (cmp < 0) != backward && cmp != 0 {
+				best = i
+			}
+		}
+		// Every domain has run dry
+		if best == -1 {
+			break
+		}
+		page = append(page, candidate(best))
+		taken[best]++
+	}
+	if backward {
+		slices.Reverse(page)
+	}
+	return page, taken
+}
+
+// Search books, authors and comments at once, merging the results by
+// reciprocal rank fusion (see rrfK) with each domain's weight.
+//
+// Query parameters:
+//   - q: the query, in the syntax repository.ParseQuery takes
+//   - d: comma-separated domains to search, any of `booktitle`,
+//     `authorname`, `comments` and `users`
+//   - r: page size
+//   - cursor: the `next` or `prev` value of another page
+//   - subject: comma-separated subject IDs books must be filed under
+//   - author: comma-separated author IDs books must credit
+//   - language: comma-separated languages books must be in
+//   - published_from, published_to: dates books must be published
+//     between, like 2006, 2006-01 or 2006-01-02
+//   - rating_min, rating_max: ratings from 0 to 1 books (and reviews)
+//     must be rated between
+//   - role: comma-separated roles authors must be credited in
+//   - poster: comma-separated user IDs comments must be by
+//   - book: comma-separated book IDs comments must be on
+//   - reviews: whether to leave out replies
+//
+// See searchFilterParams for which domains each filter applies to.
+// When searching books, the first page also counts them up by decade,
+// subject and rating under `facets`. Results say why they matched under
+// `highlights`, which has HTML snippets of the fields which did, with
+// the matches in <mark> elements.
 func (h searchHandle[S]) Search(c *gin.Context) (int, string, error) {
 	const errorCaller string = "search"
-	// Do search query stuff
 
 	var (
 		domains []string
-		query   string // TODO: "[]S"?
+		query   repository.Query
 		limit   int
-		offset  int
+		filters searchFilters
+		cur     searchCursor
 	)
-	domains = strings.Split(c.Query("d"), ",")
+	for _, d := range strings.Split(c.Query("d"), ",") {
+		if slices.Contains(searchDomains, d) && !slices.Contains(domains, d) {
+			domains = append(domains, d)
+		}
+	}
+	// Keep ties in a fixed order, whatever order they were asked for
+	slices.SortFunc(domains, func(a, b string) int {
+		return slices.Index(searchDomains, a) - slices.Index(searchDomains, b)
+	})
 	if q, err := url.QueryUnescape(c.Query("q")); err != nil {
 		return http.StatusBadRequest,
 			"Could not parse your query as a URL-encoded string",
@@ -49,151 +356,330 @@ func (h searchHandle[S]) Search(c *gin.Context) (int, string, error) {
 		return http.StatusBadRequest,
 			"Your query must not be empty",
 			nil
-	} else {
-		query = querySanitizer.ReplaceAllString(q, "\\$1")
+	} else if query, err = repository.ParseQuery(q); err != nil {
+		return http.StatusBadRequest,
+			"Your query could not be understood",
+			fmt.Errorf("%v: %w", errorCaller, err)
 	}
 	if r, err := strconv.Atoi(c.Query("r")); err != nil {
-		limit = 25
+		limit = repository.DefaultPageSize
 	} else {
-		limit = min(r, 250)
+		limit = repository.PageSize(r)
 	}
-	if o, err := strconv.Atoi(c.Query("o")); err != nil {
-		offset = 0
+	if f, summary, err := searchFilterParams(c); err != nil {
+		return http.StatusBadRequest, summary,
+			fmt.Errorf("%v: %w", errorCaller, err)
 	} else {
-		offset = o
+		filters = f
+	}
+	if len(domains) == 0 {
+		return http.StatusNotFound,
+			"Results was empty. Like Absolutely *Nothing* empty. Are you sure you provided valid domain(s)?",
+			fmt.Errorf("%v: no known domains in `%v`", errorCaller, c.Query("d"))
 	}
 
-	results := [][]repository.AnyScoreItemer{}
-	if slices.Contains(domains, "comments") {
-		// TODO: find a way to do multi-domain offsets without. this.
-		_, comments, err := h.comm.Search(c.Request.Context(), 0, limit+offset, query)
-		if err != nil {
-			return http.StatusServiceUnavailable,
-				errorCaller, err
+	key, err := searchKey(query.String(), domains, filters)
+	if err != nil {
+		return http.StatusInternalServerError,
+			"Could not page through search results",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if s := c.Query("cursor"); s != "" {
+		if err := repository.DecodeSignedCursor(s, jwtSigner.Public(), &cur); err != nil {
+			return http.StatusBadRequest,
+				"The cursor is not one we gave out",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		} else if cur.Search != key {
+			return http.StatusBadRequest,
+				"The cursor belongs to a different search",
+				fmt.Errorf("%v: cursor for search `%v`, not `%v`", errorCaller, cur.Search, key)
 		}
-		results = append(results, comments)
-	}
-	if slices.Contains(domains, "booktitle") {
-		// Searching for books is special, because this is also the
-		// method by which we scrape book data from our external
-		// sources. If we do not find a sufficient number of results
-		// we will make the same query to the scraper and retry.
-		var booktitle []repository.AnyScoreItemer
-		var err error
-		for i := range 2 {
-			if i == 1 {
-				// If we are on the second iteration, we will scrape
-				// and scrape until we have enough results
-				// or we run out of results to scrape.
-				added := 0
-				iter := 0
-				for added < limit {
-					o := offset + iter*limit
-					n, err := h.scrp.Scrape(c.Request.Context(), o, limit, query)
-					if err != nil {
-						break
-					}
-					if n == -1 {
-						// If we got -1, we know there's nothing left to scrape
-						break
-					} else {
-						added += n
-						iter++
-					}
-				}
-			}
-			// TODO: find a way to do multi-domain offsets without. this.
-			_, booktitle, err = h.book.Search(c.Request.Context(), 0, limit+offset, query)
-			if err != nil {
-				return http.StatusServiceUnavailable,
-					errorCaller, err
+	}
+
+	ctx := c.Request.Context()
+	searchers := map[string]func(context.Context, repository.SearchPage) ([]repository.AnyScoreItemer, error){
+		"comments": func(ctx context.Context, p repository.SearchPage) ([]repository.AnyScoreItemer, error) {
+			_, r, err := h.comm.SearchFiltered(ctx, p, query, filters.Comments)
+			return r, err
+		},
+		"booktitle": func(ctx context.Context, p repository.SearchPage) ([]repository.AnyScoreItemer, error) {
+			_, r, err := h.book.SearchFiltered(ctx, p, query, filters.Books)
+			return r, err
+		},
+		"authorname": func(ctx context.Context, p repository.SearchPage) ([]repository.AnyScoreItemer, error) {
+			_, r, err := h.athr.SearchFiltered(ctx, p, query, filters.Authors)
+			return r, err
+		},
+		"users": func(ctx context.Context, p repository.SearchPage) ([]repository.AnyScoreItemer, error) {
+			if !filters.IsZero() {
+				return nil, nil
 			}
-			// We don't need to scrape if we have enough results
-			if len(booktitle) > limit+offset {
-				break
+			_, r, err := h.user.SearchQuery(ctx, p, query)
+			return r, err
+		},
+	}
+	// Ask each domain for one more than a page, so we know whether
+	// there is anything left without asking again. Each domain only
+	// ever gets asked for that much, however deep the page.
+	results := make([][]repository.AnyScoreItemer, len(domains))
+	for i, d := range domains {
+		pos := cur.Positions[d]
+		p := repository.SearchPage{After: pos, Limit: limit + 1}
+		if cur.Backward {
+			if pos == nil {
+				// Nothing from this domain comes before the page
+				continue
 			}
+			p = repository.SearchPage{Through: pos, Limit: limit + 1}
 		}
-		results = append(results, booktitle)
-	}
-	if slices.Contains(domains, "authorname") {
-		// TODO: find a way to do multi-domain offsets without. this.
-		_, authorname, err := h.athr.Search(c.Request.Context(), 0, limit+offset, query)
+		r, err := searchers[d](ctx, p)
 		if err != nil {
 			return http.StatusServiceUnavailable,
 				errorCaller, err
 		}
-		results = append(results, authorname)
+		results[i] = r
 	}
-	if len(results) == 0 {
-		return http.StatusNotFound,
-			"Results was empty. Like Absolutely *Nothing* empty. Are you sure you provided valid domain(s)?",
-			fmt.Errorf("%v: results `%#v` for domains `%v` -> `%s`",
-				errorCaller,
-				results,
-				c.Query("d"),
-				domains,
-			)
-	}
-
-	processed := make([]map[string]any, limit+offset)
-	for i := range processed {
-		// Effectively do the merge part of merge sort
-		// Because each slice will already be sorted by best scoring
-		// first, we simply pick which top value of the search domains
-		// has the highest out of all of them, add it to the `ret`
-		// slice, then pop said slice.
-		highestScore := struct {
-			Idx   int
-			Score float64
-		}{
-			-1, 0.0,
-		}
-		for i, v := range results {
-			if len(v) < 1 {
-				continue
-			}
-			if s := v[0].ScoreValue(); s > highestScore.Score {
-				highestScore.Idx = i
-				highestScore.Score = s
+
+	// The first page fixes the weights for the rest of the search
+	if cur.Weights == nil {
+		cur.Weights = h.wght.Get()
+	}
+	ranks := make([]int, len(domains))
+	weights := make([]float64, len(domains))
+	for i, d := range domains {
+		ranks[i] = cur.Ranks[d]
+		weights[i] = cur.Weights[d]
+	}
+	page, taken := mergeSearchResults(results, ranks, weights, cur.Backward, limit)
+
+	// Work out where this page starts and ends
+	start := searchCursor{
+		Search:    key,
+		Positions: map[string]*repository.SearchPosition{},
+		Ranks:     map[string]int{},
+		Weights:   map[string]float64{},
+		Backward:  true,
+	}
+	end := searchCursor{
+		Search:    key,
+		Positions: map[string]*repository.SearchPosition{},
+		Ranks:     map[string]int{},
+		Weights:   map[string]float64{},
+	}
+	more := cur.Backward
+	for i, d := range domains {
+		start.Weights[d], end.Weights[d] = weights[i], weights[i]
+		r := results[i]
+		if cur.Backward {
+			end.Positions[d] = cur.Positions[d]
+			if before := len(r) - taken[i] - 1; before >= 0 {
+				pos := r[before].Position()
+				start.Positions[d] = &pos
 			}
+			start.Ranks[d], end.Ranks[d] = ranks[i]-taken[i], ranks[i]
+			continue
 		}
-		// If the index of the highest score is -1 we know we have
-		// exhausted all results and should cut our losses.
-		if highestScore.Idx == -1 {
-			break
+		start.Positions[d] = cur.Positions[d]
+		end.Positions[d] = cur.Positions[d]
+		if taken[i] > 0 {
+			pos := r[taken[i]-1].Position()
+			end.Positions[d] = &pos
 		}
+		start.Ranks[d], end.Ranks[d] = ranks[i], ranks[i]+taken[i]
+		more = more || taken[i] < len(r)
+	}
 
-		// Warning: This is unwell. We have to marshal the any to a byte array,
-		// unmarshal it to a map, tack on the APIVersion, then re-marshal it
-		// and *then* we can append it to the processed slice.
-		item := results[highestScore.Idx][0]
-		anyVal := item.ItemAsAny()
-
-		// Marshal to JSON
-		b, err := json.Marshal(anyVal)
-		if err != nil {
-			// Handle error, skip this item
-			continue
+	// Searching for books is special, because this is also the method
+	// by which we discover books from our external sources. Once we run
+	// out of results a scrape is queued in the background, and its ID
+	// is handed back so the client can check on it and search again
+	// once it is done. Scrapes can't be filtered, so a filtered search
+	// coming up short doesn't mean anything is missing. Our sources
+	// don't know our query syntax, so only get its words.
+	if i := slices.Index(domains, "booktitle"); i != -1 && !cur.Backward &&
+		taken[i] == len(results[i]) && filters.Books.IsZero() {
+		if job, err := h.scrp.Enqueue(ctx, query.Keywords(), end.Ranks["booktitle"], limit); err != nil {
+			// Not being able to scrape shouldn't fail the search
+			c.Error(fmt.Errorf("%v: %w", errorCaller, err))
+		} else {
+			c.Header("X-Scrape-Job", job.ID.String())
 		}
+	}
 
-		// Unmarshal to map
-		var m map[string]any
-		if err := json.Unmarshal(b, &m); err != nil {
-			// Handle error, skip this item
-			continue
+	resp := searchResponse{pagedResponse: pagedResponse[map[string]any]{
+		Items: make([]map[string]any, len(page)),
+	}}
+	// Facets are the same for every page, so are only worked out for
+	// the first
+	if slices.Contains(domains, "booktitle") && c.Query("cursor") == "" {
+		if resp.Facets, err = h.book.Facets(ctx, query, filters.Books); err != nil {
+			return http.StatusServiceUnavailable,
+				errorCaller, err
 		}
+	}
+	for i, r := range page {
+		if resp.Items[i], err = searchResultJSON(r.item); err != nil {
+			return http.StatusInternalServerError,
+				"Could not render search results",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+	if more {
+		if resp.Next, err = repository.EncodeSignedCursor(end, jwtSigner); err != nil {
+			return http.StatusInternalServerError,
+				"Could not page through search results",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+	if slices.ContainsFunc(domains, func(d string) bool { return start.Positions[d] != nil }) {
+		if resp.Prev, err = repository.EncodeSignedCursor(start, jwtSigner); err != nil {
+			return http.StatusInternalServerError,
+				"Could not page through search results",
+				fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+	return http.StatusOK, "", nil
+}
 
-		// Add APIVersion if possible
-		if a := item.APIVersion(); a != "" {
-			m["apiVersion"] = a
+// The weight each search domain's results are merged with, keyed by
+// domain
+func (h searchHandle[S]) Weights(c *gin.Context) (int, string, error) {
+	c.JSON(http.StatusOK, h.wght.Get())
+	return http.StatusOK, "", nil
+}
+
+// Change how search domains are weighted, for searches started from
+// now on. The body maps domains to their new weights, which must be
+// above 0 and at most maxSearchWeight; domains left out keep theirs.
+// Weights are only kept in memory, so go back to 1 on a restart.
+func (h searchHandle[S]) SetWeights(c *gin.Context) (int, string, error) {
+	const errorCaller string = "set search weights"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+
+	var w map[string]float64
+	if err := c.ShouldBindJSON(&w); err != nil {
+		return http.StatusBadRequest,
+			"Could not parse request body as a map of domains to weights",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	weights, err := h.wght.Set(w)
+	if err != nil {
+		return http.StatusBadRequest,
+			fmt.Sprintf("Weights must be for known domains, above 0 and at most %v", maxSearchWeight),
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	c.JSON(http.StatusOK, weights)
+	return http.StatusOK, "", nil
+}
+
+// The domains suggestions come from, in the order they are listed
+var suggestDomains = []string{"booktitle", "authorname", "users"}
+
+const (
+	// Suggestions are asked for on every keystroke, so any slower than
+	// this and they are no use by the time they arrive
+	suggestTimeout = 300 * time.Millisecond
+	// Too short a prefix matches nearly everything
+	minSuggestPrefix   = 2
+	defaultSuggestions = 5
+)
+
+// Suggest ways to complete a partly typed search, grouped by domain.
+//
+// Query parameters:
+//   - q: what has been typed so far
+//   - d: comma-separated domains to suggest from, any of `booktitle`,
+//     `authorname` and `users`; all of them if not given
+//   - r: suggestions per domain, at most repository.MaxSuggestions
+//
+// Every domain asked for is in the response, if only as an empty list.
+// Domains which fail or run out of time are left empty rather than
+// holding up the rest.
+func (h searchHandle[S]) Suggest(c *gin.Context) (int, string, error) {
+	const errorCaller string = "suggest"
+
+	suggesters := map[string]repository.Suggester{
+		"booktitle":  h.book,
+		"authorname": h.athr,
+		"users":      h.user,
+	}
+	domains := suggestDomains
+	if d := c.Query("d"); d != "" {
+		domains = nil
+		for _, d := range strings.Split(d, ",") {
+			if _, ok := suggesters[d]; ok && !slices.Contains(domains, d) {
+				domains = append(domains, d)
+			}
 		}
+	}
+	if len(domains) == 0 {
+		return http.StatusNotFound,
+			"There is nothing to suggest. Are you sure you provided valid domain(s)?",
+			fmt.Errorf("%v: no known domains in `%v`", errorCaller, c.Query("d"))
+	}
+	limit := defaultSuggestions
+	if r, err := strconv.Atoi(c.Query("r")); err == nil && r > 0 {
+		limit = min(r, repository.MaxSuggestions)
+	}
 
-		processed[i] = m
-		results[highestScore.Idx] = results[highestScore.Idx][1:]
+	resp := make(map[string][]repository.Suggestion, len(domains))
+	for _, d := range domains {
+		resp[d] = []repository.Suggestion{}
+	}
+	prefix := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(prefix) < minSuggestPrefix {
+		c.JSON(http.StatusOK, resp)
+		return http.StatusOK, "", nil
 	}
-	// TODO: THIS IS A BAD BAD BAD BAD BAD WAY OF DEALING WITH OFFSETS
-	processed = processed[offset:]
 
-	c.JSON(http.StatusOK, processed)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), suggestTimeout)
+	defer cancel()
+	var (
+		wg  sync.WaitGroup
+		mut sync.Mutex
+	)
+	for _, d := range domains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := suggesters[d].Suggest(ctx, prefix, limit)
+			mut.Lock()
+			defer mut.Unlock()
+			if err != nil {
+				c.Error(fmt.Errorf("%v: %v: %w", errorCaller, d, err))
+			} else if s != nil {
+				resp[d] = s
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, resp)
 	return http.StatusOK, "", nil
 }
+
+// Warning: This is unwell. We have to marshal the any to a byte array,
+// unmarshal it to a map, tack on the APIVersion (and highlights), and
+// then it can be marshalled again with the rest of the results.
+func searchResultJSON(item repository.AnyScoreItemer) (map[string]any, error) {
+	b, err := json.Marshal(item.ItemAsAny())
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if a := item.APIVersion(); a != "" {
+		m["apiVersion"] = a
+	}
+	if h := item.Highlighted(); len(h) > 0 {
+		m["highlights"] = h
+	}
+	return m, nil
+}
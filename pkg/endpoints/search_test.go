@@ -0,0 +1,427 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type fakeScrapeQueue struct {
+	offsets []int
+}
+
+func (q *fakeScrapeQueue) Enqueue(ctx context.Context, query string, offset, limit int) (*model.ScrapeJob, error) {
+	q.offsets = append(q.offsets, offset)
+	return &model.ScrapeJob{ID: uuid.New(), Query: query, Offset: offset, Limit: limit}, nil
+}
+
+// A search over 7 books and 4 authors which all match "dune"
+func searchFixture(t *testing.T) (searchHandle[string], *fakeScrapeQueue) {
+	gin.SetMode(gin.TestMode)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	jwtSigner = jwtCustomSigner{priv: priv, pub: pub}
+
+	ctx := t.Context()
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	for i := range 7 {
+		require.NoError(t, repo.Book.Create(ctx, &model.Book{
+			ID:    uuid.New(),
+			Title: fmt.Sprintf("Dune %d", i),
+		}))
+	}
+	for i := range 4 {
+		require.NoError(t, repo.Author.Create(ctx, &model.Author{
+			GivenName:  fmt.Sprintf("Author %d", i),
+			FamilyName: "Dune",
+		}))
+	}
+	q := &fakeScrapeQueue{}
+	return searchHandle[string]{repo.Book, repo.Author, repo.Comment, repo.User, q, newSearchWeights()}, q
+}
+
+func doSearch(t *testing.T, h searchHandle[string], params url.Values) (int, searchResponse) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/search?"+params.Encode(), nil)
+	status, _, _ := h.Search(c)
+
+	var resp searchResponse
+	if status == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return status, resp
+}
+
+func doSuggest(t *testing.T, h searchHandle[string], params url.Values) (int, map[string][]repository.Suggestion) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/search/suggest?"+params.Encode(), nil)
+	status, _, _ := h.Suggest(c)
+	var resp map[string][]repository.Suggestion
+	if status == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return status, resp
+}
+
+func TestSearchCursors(t *testing.T) {
+	h, queue := searchFixture(t)
+	params := url.Values{"q": {"dune"}, "d": {"booktitle,authorname"}, "r": {"3"}}
+
+	// Forwards through every page
+	var pages [][]string
+	seen := map[string]bool{}
+	for {
+		status, resp := doSearch(t, h, params)
+		require.Equal(t, http.StatusOK, status)
+		var ids []string
+		for _, item := range resp.Items {
+			id := item["id"].(string)
+			assert.False(t, seen[id], "result %v repeated", id)
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		assert.Equal(t, len(pages) > 0, resp.Prev != "", "only the first page has no prev")
+		pages = append(pages, ids)
+		if resp.Next == "" {
+			break
+		}
+		params.Set("cursor", resp.Next)
+	}
+	assert.Len(t, seen, 11)
+	assert.Len(t, pages, 4)
+	assert.Len(t, pages[3], 2)
+	// Scrapes pick up after the 7 books we have, once they run out
+	require.NotEmpty(t, queue.offsets)
+	for _, o := range queue.offsets {
+		assert.Equal(t, 7, o)
+	}
+
+	// And back again
+	status, resp := doSearch(t, h, params)
+	require.Equal(t, http.StatusOK, status)
+	for i := len(pages) - 2; i >= 0; i-- {
+		require.NotEmpty(t, resp.Prev, "page %d", i)
+		params.Set("cursor", resp.Prev)
+		status, resp = doSearch(t, h, params)
+		require.Equal(t, http.StatusOK, status)
+		var ids []string
+		for _, item := range resp.Items {
+			ids = append(ids, item["id"].(string))
+		}
+		assert.Equal(t, pages[i], ids, "page %d", i)
+		assert.NotEmpty(t, resp.Next)
+	}
+	assert.Empty(t, resp.Prev)
+}
+
+func TestSearchCursorRejected(t *testing.T) {
+	h, _ := searchFixture(t)
+	params := url.Values{"q": {"dune"}, "d": {"booktitle,authorname"}, "r": {"3"}}
+	_, resp := doSearch(t, h, params)
+	require.NotEmpty(t, resp.Next)
+
+	// Cursors only work for the search they came from
+	params.Set("cursor", resp.Next)
+	params.Set("d", "booktitle")
+	status, _ := doSearch(t, h, params)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// And can't be made up
+	forged, err := repository.EncodeCursor(searchCursor{})
+	require.NoError(t, err)
+	params.Set("d", "booktitle,authorname")
+	params.Set("cursor", forged)
+	status, _ = doSearch(t, h, params)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSearchFilters(t *testing.T) {
+	h, queue := searchFixture(t)
+	params := url.Values{"q": {"dune"}, "d": {"booktitle,authorname"}, "r": {"3"}}
+
+	// Only the first page is counted up
+	_, resp := doSearch(t, h, params)
+	require.NotNil(t, resp.Facets)
+	assert.Empty(t, resp.Facets.Decades, "none of the books are dated")
+	params.Set("cursor", resp.Next)
+	_, resp = doSearch(t, h, params)
+	assert.Nil(t, resp.Facets)
+	params.Del("cursor")
+
+	// Nothing has a rating, so nothing is left; filtered searches
+	// coming up short don't scrape
+	queue.offsets = nil
+	params.Set("rating_min", "0.5")
+	status, resp := doSearch(t, h, params)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Items)
+	assert.Empty(t, queue.offsets)
+
+	for param, v := range map[string]string{
+		"rating_min": "1.5",
+		"author":     "not-a-uuid",
+		"role":       "ghostwriter",
+		"reviews":    "sometimes",
+	} {
+		params := url.Values{"q": {"dune"}, "d": {"booktitle"}, param: {v}}
+		status, _ := doSearch(t, h, params)
+		assert.Equal(t, http.StatusBadRequest, status, "%v=%v", param, v)
+	}
+}
+
+func TestSearchQuerySyntax(t *testing.T) {
+	h, _ := searchFixture(t)
+
+	// Fields decide which domains can match at all
+	for q, want := range map[string]int{
+		"author:dune":        4,
+		"title:dune":         7,
+		"dune -title:dune":   4,
+		"title:dune OR dune": 11,
+		`title:"dune 3"`:     1,
+	} {
+		params := url.Values{"q": {q}, "d": {"booktitle,authorname"}, "r": {"20"}}
+		status, resp := doSearch(t, h, params)
+		require.Equal(t, http.StatusOK, status, q)
+		assert.Len(t, resp.Items, want, q)
+	}
+
+	// Mistakes say where they are
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	params := url.Values{"q": {"dune genre:scifi"}, "d": {"booktitle"}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/search?"+params.Encode(), nil)
+	wrap(h.Search)(c)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	var body struct {
+		Summary  string `json:"summary"`
+		Position *int   `json:"position"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.NotNil(t, body.Position)
+	assert.Equal(t, 5, *body.Position)
+}
+
+func TestSearchSuggest(t *testing.T) {
+	h, _ := searchFixture(t)
+	uname, err := model.UsernameFromComponents("dunefan", 7)
+	require.NoError(t, err)
+	require.NoError(t, h.user.Create(t.Context(), &model.User{Username: uname}))
+
+	status, resp := doSuggest(t, h, url.Values{"q": {"DU"}, "r": {"3"}})
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp["booktitle"], 3)
+	assert.Equal(t, "Dune 0", resp["booktitle"][0].Text)
+	assert.Len(t, resp["authorname"], 3, "authors' family names match")
+	require.Len(t, resp["users"], 1)
+	assert.Equal(t, "dunefan#0007", resp["users"][0].Text)
+
+	// Domains can be picked out, and too short a prefix gets nothing
+	status, resp = doSuggest(t, h, url.Values{"q": {"d"}, "d": {"users,comments"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string][]repository.Suggestion{"users": {}}, resp)
+
+	status, _ = doSuggest(t, h, url.Values{"q": {"dune"}, "d": {"comments"}})
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestSearchHighlights(t *testing.T) {
+	h, _ := searchFixture(t)
+	params := url.Values{"q": {"title:dune OR author:author"}, "d": {"booktitle,authorname"}, "r": {"20"}}
+	status, resp := doSearch(t, h, params)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, resp.Items, 11)
+	for _, item := range resp.Items {
+		switch item["apiVersion"] {
+		case model.BookSummaryApiVersion:
+			assert.Equal(t, map[string]any{"title": "<mark>Dune</mark> " + item["title"].(string)[5:]}, item["highlights"])
+		case model.AuthorApiVersion:
+			assert.Equal(t, map[string]any{"given_name": "<mark>Author</mark> " + item["given_name"].(string)[7:]}, item["highlights"])
+		default:
+			t.Errorf("unexpected apiVersion %v", item["apiVersion"])
+		}
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	h, _ := searchFixture(t)
+	ctx := t.Context()
+	for i, handle := range []string{"dunefan", "arrakis", "hidden dune"} {
+		uname, err := model.UsernameFromComponents(handle, i+1)
+		require.NoError(t, err)
+		require.NoError(t, h.user.Create(ctx, &model.User{
+			Username:    uname,
+			DisplayName: "Reader " + handle,
+			Email:       handle + "@example.com",
+			Unlisted:    handle == "hidden dune",
+		}))
+	}
+
+	for q, want := range map[string][]string{
+		"dune":              {"dunefan#0001"},
+		"reader":            {"arrakis#0002", "dunefan#0001"},
+		"user:arrakis#0002": {"arrakis#0002"},
+		"hidden":            nil,
+		"example.com":       nil,
+	} {
+		status, resp := doSearch(t, h, url.Values{"q": {q}, "d": {"users"}})
+		require.Equal(t, http.StatusOK, status, q)
+		var got []string
+		for _, item := range resp.Items {
+			assert.Equal(t, model.UserProfileApiVersion, item["apiVersion"], q)
+			assert.NotContains(t, item, "email", q)
+			got = append(got, item["username"].(string))
+		}
+		assert.ElementsMatch(t, want, got, q)
+	}
+
+	// Users have nothing to filter by
+	status, resp := doSearch(t, h, url.Values{"q": {"dune"}, "d": {"users"}, "reviews": {"true"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, resp.Items)
+	status, suggestions := doSuggest(t, h, url.Values{"q": {"hidden"}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, suggestions["users"], "unlisted users aren't suggested either")
+}
+
+// Results with fixed scores, as a domain would rank them
+func fixedResults(domain string, scores ...float64) []repository.AnyScoreItemer {
+	r := make([]repository.AnyScoreItemer, len(scores))
+	for i, s := range scores {
+		name := fmt.Sprintf("%v %d", domain, i)
+		r[i] = repository.SearchResult[string]{Item: &name, ID: uuid.New(), Score: s}
+	}
+	return r
+}
+
+func mergedNames(page []rankedResult) []string {
+	var names []string
+	for _, r := range page {
+		names = append(names, *r.item.ItemAsAny().(*string))
+	}
+	return names
+}
+
+func TestMergeSearchResults(t *testing.T) {
+	// BM25 scores on wildly different scales; merged by score, books
+	// would take every place
+	results := [][]repository.AnyScoreItemer{
+		fixedResults("book", 31.2, 28.7, 28.5, 20.1),
+		fixedResults("author", 0.42, 0.41, 0.09),
+		fixedResults("comment", 3.9, 3.1, 2.2, 1.7),
+	}
+	ranks := make([]int, len(results))
+
+	// Weighted the same, each domain's best come first
+	page, taken := mergeSearchResults(results, ranks, []float64{1, 1, 1}, false, 7)
+	assert.Equal(t, []string{
+		"book 0", "author 0", "comment 0",
+		"book 1", "author 1", "comment 1",
+		"book 2",
+	}, mergedNames(page))
+	assert.Equal(t, []int{3, 2, 2}, taken)
+
+	// A domain weighted down only shows once it has to, and one
+	// weighted up pushes ahead a few ranks
+	page, _ = mergeSearchResults(results, ranks, []float64{0.5, 1, 1}, false, 11)
+	assert.Equal(t, []string{
+		"author 0", "comment 0", "author 1", "comment 1", "author 2", "comment 2", "comment 3",
+		"book 0", "book 1", "book 2", "book 3",
+	}, mergedNames(page))
+	page, _ = mergeSearchResults(results, ranks, []float64{1, 1.02, 1}, false, 5)
+	assert.Equal(t, []string{
+		"author 0", "author 1", "book 0", "comment 0", "author 2",
+	}, mergedNames(page), "author 1 scores 1.02/62, just above 1/61")
+
+	// Paging through, a page at a time, gives the same as all at once
+	weights := []float64{1, 2, 0.5}
+	all, _ := mergeSearchResults(results, ranks, weights, false, 11)
+	var paged []string
+	for len(paged) < len(all) {
+		rest := make([][]repository.AnyScoreItemer, len(results))
+		for i, r := range results {
+			rest[i] = r[ranks[i]:]
+		}
+		page, taken := mergeSearchResults(rest, ranks, weights, false, 3)
+		require.NotEmpty(t, page)
+		paged = append(paged, mergedNames(page)...)
+		for i := range ranks {
+			ranks[i] += taken[i]
+		}
+	}
+	assert.Equal(t, mergedNames(all), paged)
+
+	// And going back from the end gives the last page again
+	ranks = []int{4, 3, 4}
+	page, _ = mergeSearchResults(results, ranks, weights, true, 3)
+	assert.Equal(t, mergedNames(all)[8:], mergedNames(page))
+}
+
+func doSetWeights(t *testing.T, h searchHandle[string], admin bool, body string) (int, map[string]float64) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/search/weights", strings.NewReader(body))
+	c.Set("userID", uuid.NewString())
+	c.Set("permissions", admin)
+	status, _, _ := h.SetWeights(c)
+	var resp map[string]float64
+	if status == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return status, resp
+}
+
+func TestSearchWeights(t *testing.T) {
+	h, _ := searchFixture(t)
+	params := url.Values{"q": {"dune"}, "d": {"booktitle,authorname"}, "r": {"4"}}
+	apiVersions := func(resp searchResponse) []string {
+		var v []string
+		for _, item := range resp.Items {
+			v = append(v, strings.SplitN(item["apiVersion"].(string), ".", 2)[0])
+		}
+		return v
+	}
+
+	// Every result scores the same in its own domain, so the domains
+	// take turns
+	_, first := doSearch(t, h, params)
+	assert.Equal(t, []string{"booksummary", "author", "booksummary", "author"}, apiVersions(first))
+
+	status, _ := doSetWeights(t, h, false, `{"authorname": 0.5}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	for _, body := range []string{`{"genres": 2}`, `{"authorname": 0}`, `{"authorname": -1}`, `{"authorname": 1000}`, `[]`} {
+		status, _ := doSetWeights(t, h, true, body)
+		assert.Equal(t, http.StatusBadRequest, status, body)
+	}
+	assert.Equal(t, 1.0, h.wght.Get()["authorname"], "bad weights change nothing")
+
+	status, weights := doSetWeights(t, h, true, `{"authorname": 0.5}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]float64{"comments": 1, "booktitle": 1, "authorname": 0.5, "users": 1}, weights)
+
+	// New searches put authors behind every book
+	_, resp := doSearch(t, h, params)
+	assert.Equal(t, []string{"booksummary", "booksummary", "booksummary", "booksummary"}, apiVersions(resp))
+
+	// But one already going carries on as it started
+	params.Set("cursor", first.Next)
+	_, resp = doSearch(t, h, params)
+	assert.Equal(t, []string{"booksummary", "author", "booksummary", "author"}, apiVersions(resp))
+}
@@ -0,0 +1,132 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type seriesHandle struct {
+	srs repository.SeriesManager
+}
+
+func (h seriesHandle) Get(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get series"
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	s, err := h.srs.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, s)
+	return http.StatusOK, "", nil
+}
+
+func (h seriesHandle) Create(c *gin.Context) (int, string, error) {
+	const errorCaller string = "create series"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+
+	var s model.Series
+	if err := c.ShouldBindJSON(&s); err != nil {
+		return http.StatusBadRequest,
+			"Could not parse request body as a series",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else if err := s.Validate(); err != nil {
+		return http.StatusBadRequest,
+			"A series needs a name, and each book in it once at a position of 0 or more",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	// IDs are always ours to give out
+	if id, err := uuid.NewV7(); err != nil {
+		return http.StatusInternalServerError,
+			"Failed to generate new UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	} else {
+		s.ID = id
+	}
+
+	if err := h.srs.Create(c.Request.Context(), &s); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	created, err := h.srs.GetByID(c.Request.Context(), s.ID)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusCreated, created)
+	return http.StatusCreated, "", nil
+}
+
+// Update a series using JSON merge patch semantics (RFC 7386). As with
+// any array, `entries` is replaced outright, so send every book which
+// should stay in the series.
+func (h seriesHandle) Update(c *gin.Context) (int, string, error) {
+	const errorCaller string = "update series"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	current, err := h.srs.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	var s model.Series
+	if h, msg, err := wrapMergePatch(c, errorCaller, current, &s); h != 0 {
+		return h, msg, err
+	} else if s.ID != id {
+		return http.StatusBadRequest,
+			"The ID of a series cannot be changed",
+			fmt.Errorf("%v: patch changed ID `%v` -> `%v`", errorCaller, id, s.ID)
+	} else if err := s.Validate(); err != nil {
+		return http.StatusBadRequest,
+			"A series needs a name, and each book in it once at a position of 0 or more",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	updated, err := h.srs.Update(c.Request.Context(), &s)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, updated)
+	return http.StatusOK, "", nil
+}
+
+// Delete a series. The books in it are kept, they just aren't in a
+// series any more.
+func (h seriesHandle) Delete(c *gin.Context) (int, string, error) {
+	const errorCaller string = "delete series"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	if _, err := h.srs.GetByID(c.Request.Context(), id); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+
+	if err := h.srs.Delete(c.Request.Context(), id); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.Status(http.StatusNoContent)
+	return http.StatusNoContent, "", nil
+}
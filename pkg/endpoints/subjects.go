@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type subjectHandle[S comparable] struct {
+	subj repository.SubjectManager
+	book repository.BookManager[S]
+}
+
+// The whole subject taxonomy. Each subject carries its parent and
+// path, so clients can build the tree however suits them.
+func (h subjectHandle[S]) List(c *gin.Context) (int, string, error) {
+	const errorCaller string = "list subjects"
+	subjects, err := h.subj.List(c.Request.Context())
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	if subjects == nil {
+		subjects = []*model.Subject{}
+	}
+	c.JSON(http.StatusOK, subjects)
+	return http.StatusOK, "", nil
+}
+
+// Books filed under a subject or any of its descendants, paged the
+// same way as an author's books.
+func (h subjectHandle[S]) Books(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get subject books"
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	opts, summary, err := wrapBibliographyOptions(c)
+	if err != nil {
+		return http.StatusBadRequest,
+			summary,
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	// Distinguish "no such subject" from "subject with no books"
+	if _, err := h.subj.GetByID(c.Request.Context(), id); err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	books, next, err := h.book.Subject(c.Request.Context(), id, opts)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	if books == nil {
+		books = []*model.BookSummary{}
+	}
+	c.JSON(http.StatusOK, pagedResponse[*model.BookSummary]{
+		Items: books,
+		Next:  next,
+	})
+	return http.StatusOK, "", nil
+}
@@ -265,7 +265,8 @@ func (h *userHandle) UpdateAvatar(c *gin.Context) (int, string, error) {
 		return wrapDatastoreError(errorCaller, err)
 	}
 
-	user.Avatar = id
+	// The same image may already be a blob, which is used instead
+	user.Avatar = newAvatar.ID
 	user, err = h.repo.Update(c.Request.Context(), user)
 	if err != nil {
 		return wrapDatastoreError(errorCaller, err)
@@ -311,7 +312,6 @@ func (h *userHandle) create(ctx context.Context, u model.User, a string) (int, s
 	}
 
 	u.ID = userID
-	u.Avatar = imgID
 
 	var status = http.StatusOK
 	var summary string
@@ -321,6 +321,8 @@ func (h *userHandle) create(ctx context.Context, u model.User, a string) (int, s
 		// So instead we just nil the field lol
 		u.Avatar = uuid.Nil
 		summary = "failed to commit user profile picture (this is not that bad)"
+	} else {
+		u.Avatar = b.ID
 	}
 	if err = h.repo.Create(ctx, &u); err != nil {
 		status = http.StatusServiceUnavailable
@@ -0,0 +1,119 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+type workHandle struct {
+	work repository.WorkManager
+}
+
+// The body of a join or split: the books (editions) to move
+type editionsRequest struct {
+	Editions uuid.UUIDs `json:"editions"`
+}
+
+func (h workHandle) Get(c *gin.Context) (int, string, error) {
+	const errorCaller string = "get work"
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	w, err := h.work.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, w)
+	return http.StatusOK, "", nil
+}
+
+// Move editions into this work, for when clustering missed that they
+// are the same book.
+func (h workHandle) Join(c *gin.Context) (int, string, error) {
+	const errorCaller string = "join editions"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var req editionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Editions) == 0 {
+		return http.StatusBadRequest,
+			"Expected a list of editions to join",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	w, err := h.work.Join(c.Request.Context(), id, req.Editions...)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, w)
+	return http.StatusOK, "", nil
+}
+
+// Move some of this work's editions out into a new work of their own,
+// for when clustering wrongly decided they are the same book.
+func (h workHandle) Split(c *gin.Context) (int, string, error) {
+	const errorCaller string = "split editions"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return http.StatusBadRequest,
+			"Unable to parse UUID",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var req editionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Editions) == 0 {
+		return http.StatusBadRequest,
+			"Expected a list of editions to split off",
+			fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	current, err := h.work.GetByID(c.Request.Context(), id)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	for _, e := range req.Editions {
+		if !slices.Contains(current.Editions, e) {
+			return http.StatusBadRequest,
+				"Only editions of this work can be split off from it",
+				fmt.Errorf("%v: book `%v` is not an edition of work `%v`", errorCaller, e, id)
+		}
+	}
+
+	w, err := h.work.Split(c.Request.Context(), req.Editions...)
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusCreated, w)
+	return http.StatusCreated, "", nil
+}
+
+// Group every edition which hasn't been placed by hand into works.
+func (h workHandle) Cluster(c *gin.Context) (int, string, error) {
+	const errorCaller string = "cluster editions"
+	if s, msg, err := wrapRequireAdmin(c, errorCaller); s != 0 {
+		return s, msg, err
+	}
+	n, err := h.work.Cluster(c.Request.Context())
+	if err != nil {
+		return wrapDatastoreError(errorCaller, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"moved": n})
+	return http.StatusOK, "", nil
+}
@@ -1,6 +1,10 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
 
 const AuthorApiVersion string = "author.itsc-4155-group-project.edu.whits.io/v1alpha3"
 
@@ -20,3 +24,24 @@ type AuthorIDs struct {
 	Type string `json:"type"`
 	ID   string `json:"id"`
 }
+
+// The external identifier schemes an author can be linked to. These
+// mirror the constraint on the `author_identifiers` table.
+const (
+	AuthorIDORCID       string = "orcid"
+	AuthorIDVIAF        string = "viaf"
+	AuthorIDOpenLibrary string = "opnlib"
+)
+
+// Check the identifier is of a known type and not blank
+func (a AuthorIDs) Validate() error {
+	switch a.Type {
+	case AuthorIDORCID, AuthorIDVIAF, AuthorIDOpenLibrary:
+	default:
+		return fmt.Errorf("unknown author identifier type `%v`", a.Type)
+	}
+	if a.ID == "" {
+		return fmt.Errorf("empty %v identifier", a.Type)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorIDsValidate(t *testing.T) {
+	valid := []AuthorIDs{
+		{Type: AuthorIDORCID, ID: "0000-0002-1825-0097"},
+		{Type: AuthorIDVIAF, ID: "102333412"},
+		{Type: AuthorIDOpenLibrary, ID: "OL24638A"},
+	}
+	for _, v := range valid {
+		assert.NoError(t, v.Validate(), "%v", v)
+	}
+
+	invalid := []AuthorIDs{
+		{Type: "isni", ID: "0000000121032683"},
+		{Type: "", ID: "OL24638A"},
+		{Type: AuthorIDORCID, ID: ""},
+	}
+	for _, v := range invalid {
+		assert.Error(t, v.Validate(), "%v", v)
+	}
+}
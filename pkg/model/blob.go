@@ -2,6 +2,7 @@ package model
 
 import (
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -11,9 +12,50 @@ const BlobApiVersion string = "blob.itsc-4155-group-project.edu.whits.io/v1"
 type Blob struct {
 	ID       uuid.UUID         `json:"id"`
 	Metadata map[string]string `json:"metadata"`
-	Content  io.Reader         `json:"content"`
+	Content  io.Reader         `json:"-"`
 }
 
 func (b Blob) APIVersion() string {
 	return BlobApiVersion
 }
+
+// The keys of a blob's metadata which are set whenever one is uploaded
+const (
+	BlobMetaContentType  string = "content-type"
+	BlobMetaSize         string = "size"
+	BlobMetaSHA256       string = "sha256"
+	BlobMetaLastModified string = "last-modified"
+)
+
+// What a blob is used as
+type BlobUse string
+
+const (
+	BlobUseCover     BlobUse = "cover"
+	BlobUseThumbnail BlobUse = "thumbnail"
+	BlobUseAvatar    BlobUse = "avatar"
+)
+
+// Something which uses a blob: a book's cover or thumbnail, or a
+// user's avatar. ID is the book's or user's.
+type BlobReference struct {
+	Use BlobUse   `json:"use"`
+	ID  uuid.UUID `json:"id"`
+}
+
+// A blob which nothing uses any more
+type OrphanedBlob struct {
+	ID uuid.UUID `json:"id"`
+	// In bytes, if it is known
+	Size    int64     `json:"size,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// What the blob garbage collector found, and deleted unless it was a
+// dry run
+type BlobCollection struct {
+	DryRun  bool           `json:"dry_run"`
+	Orphans []OrphanedBlob `json:"orphans"`
+	// The orphans' total size in bytes, as far as it is known
+	Size int64 `json:"size"`
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
-	"cloud.google.com/go/civil"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -9,17 +12,239 @@ const BookApiVersion string = "book.itsc-4155-group-project.edu.whits.io/v1alpha
 
 // An Books
 type Book struct {
-	ID          uuid.UUID  `json:"id"`
-	ISBNs       []ISBN     `json:"isbns"`
-	Title       string     `json:"title"`
-	Subtitle    string     `json:"subtitle,omitempty"`
-	Description string     `json:"description"`
-	AuthorIDs   uuid.UUIDs `json:"authors"`
-	Published   civil.Date `json:"published"`
-	CoverImage  uuid.UUID  `json:"bref_cover_image,omitempty"`
-	ThumbImage  uuid.UUID  `json:"bref_thumbnail_image,omitempty"`
-}
-
-func (b Book) APIVersion() string {
-	return BookApiVersion
+	ID          uuid.UUID `json:"id"`
+	ISBNs       []ISBN    `json:"isbns"`
+	Title       string    `json:"title"`
+	Subtitle    string    `json:"subtitle,omitempty"`
+	Description string    `json:"description"`
+	// Those of Contributors credited as authors, in billing order. See
+	// SyncContributors for how the two are kept in step.
+	AuthorIDs uuid.UUIDs `json:"authors"`
+	// Often only the year or month is known
+	Published  PartialDate `json:"published"`
+	CoverImage uuid.UUID   `json:"bref_cover_image,omitempty"`
+	ThumbImage uuid.UUID   `json:"bref_thumbnail_image,omitempty"`
+	// Everyone credited on the book, in billing order
+	Contributors []Contributor `json:"contributors,omitempty"`
+	// The most specific subjects the book is filed under
+	Subjects uuid.UUIDs `json:"subjects,omitempty"`
+	// The work this is an edition of. This is maintained by the
+	// datastore and ignored on update; see repository.WorkManager to
+	// move an edition between works.
+	Work uuid.UUID `json:"work,omitzero"`
+	// The series the book is in, if any. This is maintained by the
+	// datastore and ignored on update; see repository.SeriesManager.
+	Series *BookSeries `json:"series,omitempty"`
+	// Details of this particular edition
+	Format    EditionFormat `json:"format,omitempty"`
+	Publisher string        `json:"publisher,omitempty"`
+	Pages     int           `json:"pages,omitempty"`
+	// An ISO 639 language code, as the provider gave it
+	Language string `json:"language,omitempty"`
+	// Where the book's metadata was scraped from, if anywhere. This
+	// is maintained by the datastore and ignored on update.
+	Source *BookSource `json:"source,omitempty"`
+	// Where each field came from. This is only filled in when asked
+	// for; see Attribute for setting it on new books.
+	Provenance map[string]Provenance `json:"provenance,omitempty"`
+}
+
+// The names of the book fields which have provenance, as they appear
+// in JSON.
+const (
+	BookFieldTitle        string = "title"
+	BookFieldSubtitle     string = "subtitle"
+	BookFieldDescription  string = "description"
+	BookFieldPublished    string = "published"
+	BookFieldCover        string = "bref_cover_image"
+	BookFieldThumbnail    string = "bref_thumbnail_image"
+	BookFieldISBNs        string = "isbns"
+	BookFieldAuthors      string = "authors"
+	BookFieldContributors string = "contributors"
+	BookFieldSubjects     string = "subjects"
+	BookFieldFormat       string = "format"
+	BookFieldPublisher    string = "publisher"
+	BookFieldPages        string = "pages"
+	BookFieldLanguage     string = "language"
+)
+
+// The physical (or not) form an edition takes
+type EditionFormat string
+
+const (
+	FormatHardcover EditionFormat = "hardcover"
+	FormatPaperback EditionFormat = "paperback"
+	FormatEbook     EditionFormat = "ebook"
+	FormatAudiobook EditionFormat = "audiobook"
+)
+
+func (f EditionFormat) Valid() bool {
+	switch f {
+	case FormatHardcover, FormatPaperback, FormatEbook, FormatAudiobook:
+		return true
+	default:
+		return false
+	}
+}
+
+// Make sense of the free text formats providers use, such as "Mass
+// Market Paperback" or "E-book". Returns "" for anything unrecognised.
+func ParseEditionFormat(s string) EditionFormat {
+	s = strings.ToLower(s)
+	switch {
+	case strings.Contains(s, "audio"):
+		return FormatAudiobook
+	case strings.Contains(s, "ebook"), strings.Contains(s, "e-book"),
+		strings.Contains(s, "kindle"), strings.Contains(s, "epub"):
+		return FormatEbook
+	case strings.Contains(s, "paperback"), strings.Contains(s, "softcover"):
+		return FormatPaperback
+	case strings.Contains(s, "hardcover"), strings.Contains(s, "hardback"):
+		return FormatHardcover
+	default:
+		return ""
+	}
+}
+
+// Where a scraped book's metadata came from, and when
+type BookSource struct {
+	// The scraper provider's name and its own ID for the volume
+	Provider   string `json:"provider,omitempty"`
+	ProviderID string `json:"provider_id,omitempty"`
+	// The URL the cover image was taken from, so a refresh can tell
+	// if it has changed.
+	CoverURL string    `json:"cover_url,omitempty"`
+	Fetched  time.Time `json:"fetched,omitzero"`
+}
+
+// Who or what is responsible for a field's current value
+type ProvenanceSource string
+
+const (
+	ProvenanceScraper ProvenanceSource = "scraper"
+	ProvenanceUser    ProvenanceSource = "user"
+	ProvenanceAdmin   ProvenanceSource = "admin"
+)
+
+// Sources with a higher precedence win over those with a lower one: a
+// value set by hand is never overwritten by an automated one.
+func (s ProvenanceSource) Precedence() int {
+	switch s {
+	case ProvenanceScraper:
+		return 1
+	case ProvenanceUser:
+		return 2
+	case ProvenanceAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Where a single field's value came from
+type Provenance struct {
+	Source ProvenanceSource `json:"source"`
+	// Source specific, e.g. `googlebooks:zyTCAlFPjgYC` for the scraper
+	SourceID string `json:"source_id,omitempty"`
+	// The user who set the value, if a user did
+	Actor    uuid.UUID `json:"actor,omitzero"`
+	Recorded time.Time `json:"recorded,omitzero"`
+}
+
+// Whether a value from p may overwrite a value from o
+func (p Provenance) Overrides(o Provenance) bool {
+	return p.Source.Precedence() >= o.Source.Precedence()
+}
+
+// The fields (see the BookField constants) which differ between two
+// books. ISBNs and authors are compared as sets, contributors in
+// order as that is their billing.
+func (b Book) Changed(o Book) []string {
+	var changed []string
+	for _, f := range []struct {
+		name string
+		same bool
+	}{
+		{BookFieldTitle, b.Title == o.Title},
+		{BookFieldSubtitle, b.Subtitle == o.Subtitle},
+		{BookFieldDescription, b.Description == o.Description},
+		{BookFieldPublished, b.Published == o.Published},
+		{BookFieldCover, b.CoverImage == o.CoverImage},
+		{BookFieldThumbnail, b.ThumbImage == o.ThumbImage},
+		{BookFieldISBNs, sameElements(b.ISBNs, o.ISBNs)},
+		{BookFieldAuthors, sameElements(b.AuthorIDs, o.AuthorIDs)},
+		{BookFieldContributors, slices.Equal(b.Contributors, o.Contributors)},
+		{BookFieldSubjects, sameElements(b.Subjects, o.Subjects)},
+		{BookFieldFormat, b.Format == o.Format},
+		{BookFieldPublisher, b.Publisher == o.Publisher},
+		{BookFieldPages, b.Pages == o.Pages},
+		{BookFieldLanguage, b.Language == o.Language},
+	} {
+		if !f.same {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+// A copy of b with the named fields taken from o instead
+func (b Book) Merge(o Book, fields ...string) Book {
+	for _, f := range fields {
+		switch f {
+		case BookFieldTitle:
+			b.Title = o.Title
+		case BookFieldSubtitle:
+			b.Subtitle = o.Subtitle
+		case BookFieldDescription:
+			b.Description = o.Description
+		case BookFieldPublished:
+			b.Published = o.Published
+		case BookFieldCover:
+			b.CoverImage = o.CoverImage
+		case BookFieldThumbnail:
+			b.ThumbImage = o.ThumbImage
+		case BookFieldISBNs:
+			b.ISBNs = o.ISBNs
+		case BookFieldAuthors:
+			b.AuthorIDs = o.AuthorIDs
+		case BookFieldContributors:
+			b.Contributors = o.Contributors
+		case BookFieldSubjects:
+			b.Subjects = o.Subjects
+		case BookFieldFormat:
+			b.Format = o.Format
+		case BookFieldPublisher:
+			b.Publisher = o.Publisher
+		case BookFieldPages:
+			b.Pages = o.Pages
+		case BookFieldLanguage:
+			b.Language = o.Language
+		}
+	}
+	return b
+}
+
+// Attribute every field which has a value to p, replacing whatever
+// provenance the book had.
+func (b *Book) Attribute(p Provenance) {
+	b.Provenance = map[string]Provenance{}
+	for _, f := range (Book{}).Changed(*b) {
+		b.Provenance[f] = p
+	}
+}
+
+func sameElements[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[T]int, len(a))
+	for _, v := range a {
+		count[v]++
+	}
+	for _, v := range b {
+		if count[v]--; count[v] < 0 {
+			return false
+		}
+	}
+	return true
 }
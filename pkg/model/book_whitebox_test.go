@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookChanged(t *testing.T) {
+	a := Book{
+		Title:     "Matilda",
+		ISBNs:     []ISBN{MustNewISBN("9780142410370", ISBN13), MustNewISBN("0142410373", ISBN10)},
+		AuthorIDs: uuid.UUIDs{uuid.MustParse("01959161-cdfc-7142-8bab-a7008477f417")},
+	}
+	b := a
+	// Order doesn't matter for ISBNs and authors
+	b.ISBNs = []ISBN{a.ISBNs[1], a.ISBNs[0]}
+	assert.Empty(t, a.Changed(b))
+
+	b.Title = "Matilda!"
+	b.ISBNs = a.ISBNs[:1]
+	assert.Equal(t, []string{BookFieldTitle, BookFieldISBNs}, a.Changed(b))
+
+	merged := a.Merge(b, BookFieldTitle)
+	assert.Equal(t, "Matilda!", merged.Title)
+	assert.Len(t, merged.ISBNs, 2)
+}
+
+func TestProvenanceOverrides(t *testing.T) {
+	scraper := Provenance{Source: ProvenanceScraper}
+	user := Provenance{Source: ProvenanceUser}
+	admin := Provenance{Source: ProvenanceAdmin}
+
+	assert.True(t, scraper.Overrides(Provenance{}), "anything beats nothing")
+	assert.True(t, scraper.Overrides(scraper))
+	assert.False(t, scraper.Overrides(user))
+	assert.False(t, user.Overrides(admin))
+	assert.True(t, admin.Overrides(user))
+	assert.True(t, admin.Overrides(admin))
+}
+
+func TestBookAttribute(t *testing.T) {
+	b := Book{
+		Title:       "Matilda",
+		Description: "A girl who loves books.",
+		ISBNs:       []ISBN{MustNewISBN("9780142410370", ISBN13)},
+	}
+	p := Provenance{Source: ProvenanceUser}
+	b.Attribute(p)
+	assert.Equal(t, map[string]Provenance{
+		BookFieldTitle:       p,
+		BookFieldDescription: p,
+		BookFieldISBNs:       p,
+	}, b.Provenance)
+}
@@ -22,7 +22,11 @@ type Comment struct {
 	ID   uuid.UUID `json:"id"`
 	Body string    `json:"body"`
 	// The ID of the book object this review is under.
-	Book   uuid.UUID   `json:"bookID"`
+	Book uuid.UUID `json:"bookID"`
+	// The ID of the work this review is under, for reviews of a work
+	// as a whole rather than one edition of it. A comment is under
+	// either a book or a work, never both.
+	Work   uuid.UUID   `json:"workID,omitzero"`
 	Date   time.Time   `json:"date"`
 	Poster CommentUser `json:"poster"`
 
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// What someone credited on a book did for it
+type ContributorRole string
+
+const (
+	RoleAuthor      ContributorRole = "author"
+	RoleEditor      ContributorRole = "editor"
+	RoleTranslator  ContributorRole = "translator"
+	RoleIllustrator ContributorRole = "illustrator"
+	RoleNarrator    ContributorRole = "narrator"
+)
+
+func (r ContributorRole) Valid() bool {
+	switch r {
+	case RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator, RoleNarrator:
+		return true
+	default:
+		return false
+	}
+}
+
+// Make sense of the free text roles providers use, such as
+// "Illustrations" or "Translated by". Returns "" for anything
+// unrecognised, like "Cover design".
+func ParseContributorRole(s string) ContributorRole {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.Contains(s, "translat"):
+		return RoleTranslator
+	case strings.Contains(s, "illustrat"):
+		return RoleIllustrator
+	case strings.Contains(s, "narrat"), strings.Contains(s, "read by"),
+		s == "reader":
+		return RoleNarrator
+	case strings.Contains(s, "editor"), strings.Contains(s, "edited"),
+		s == "ed." || s == "ed":
+		return RoleEditor
+	case s == "author", s == "writer", s == "by":
+		return RoleAuthor
+	default:
+		return ""
+	}
+}
+
+// Some providers fold the role into the name, as in "Ken Liu
+// (Translator)". Split those apart; anything else is taken to be an
+// author's name as it stands.
+func ParseCredit(credit string) (string, ContributorRole) {
+	credit = strings.TrimSpace(credit)
+	if open := strings.LastIndex(credit, "("); open > 0 && strings.HasSuffix(credit, ")") {
+		if role := ParseContributorRole(credit[open+1 : len(credit)-1]); role != "" {
+			return strings.TrimSpace(credit[:open]), role
+		}
+	}
+	return credit, RoleAuthor
+}
+
+// Someone credited on a book. The same author may be credited more
+// than once in different roles.
+type Contributor struct {
+	ID   uuid.UUID       `json:"id"`
+	Role ContributorRole `json:"role"`
+}
+
+// An author as credited on a book summary
+type CreditedAuthor struct {
+	Author
+	Role ContributorRole `json:"role"`
+}
+
+// The IDs of everyone credited in a role, in billing order. This is
+// never nil.
+func (b Book) Credited(role ContributorRole) uuid.UUIDs {
+	ids := uuid.UUIDs{}
+	for _, c := range b.Contributors {
+		if c.Role == role && !slices.Contains(ids, c.ID) {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
+}
+
+// Bring AuthorIDs and Contributors back into agreement after either
+// was changed. Contributors wins, unless only AuthorIDs differs from
+// `prev`; then the authors are credited first, in the order given,
+// followed by everyone else as they were. Pass an empty book for new
+// books, which only need AuthorIDs if they have no Contributors.
+func (b *Book) SyncContributors(prev Book) {
+	if len(b.Contributors) == 0 ||
+		(!sameElements(b.AuthorIDs, prev.AuthorIDs) && slices.Equal(b.Contributors, prev.Contributors)) {
+		c := make([]Contributor, 0, len(b.AuthorIDs)+len(b.Contributors))
+		for _, id := range b.AuthorIDs {
+			c = append(c, Contributor{ID: id, Role: RoleAuthor})
+		}
+		for _, o := range b.Contributors {
+			if o.Role != RoleAuthor {
+				c = append(c, o)
+			}
+		}
+		b.Contributors = c
+	}
+	// Nobody is credited for the same thing twice
+	seen := make(map[Contributor]bool, len(b.Contributors))
+	contributors := make([]Contributor, 0, len(b.Contributors))
+	for _, c := range b.Contributors {
+		if !seen[c] {
+			seen[c] = true
+			contributors = append(contributors, c)
+		}
+	}
+	b.Contributors = contributors
+	b.AuthorIDs = b.Credited(RoleAuthor)
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCredit(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+		role  ContributorRole
+	}{
+		{"Charles Dickens", "Charles Dickens", RoleAuthor},
+		{"Ken Liu (Translator)", "Ken Liu", RoleTranslator},
+		{"Quentin Blake (Illustrations)", "Quentin Blake", RoleIllustrator},
+		{"Stephen Fry (Narrator)", "Stephen Fry", RoleNarrator},
+		{"Jane Doe (ed.)", "Jane Doe", RoleEditor},
+		// Brackets which aren't a role are part of the name
+		{"Prince (musician)", "Prince (musician)", RoleAuthor},
+	}
+	for _, tt := range tests {
+		name, role := ParseCredit(tt.input)
+		assert.Equal(t, tt.name, name, tt.input)
+		assert.Equal(t, tt.role, role, tt.input)
+	}
+}
+
+func TestSyncContributors(t *testing.T) {
+	author, other, translator := uuid.New(), uuid.New(), uuid.New()
+
+	// New books may give just authors
+	b := Book{AuthorIDs: uuid.UUIDs{author}}
+	b.SyncContributors(Book{})
+	assert.Equal(t, []Contributor{{ID: author, Role: RoleAuthor}}, b.Contributors)
+
+	// Contributors win, and give the authors
+	b = Book{Contributors: []Contributor{
+		{ID: translator, Role: RoleTranslator},
+		{ID: author, Role: RoleAuthor},
+		{ID: author, Role: RoleAuthor},
+	}}
+	b.SyncContributors(Book{})
+	assert.Equal(t, uuid.UUIDs{author}, b.AuthorIDs)
+	assert.Len(t, b.Contributors, 2, "duplicate credits should be dropped")
+
+	// Changing only the authors keeps everyone else
+	prev := b
+	b.AuthorIDs = uuid.UUIDs{other, author}
+	b.SyncContributors(prev)
+	assert.Equal(t, []Contributor{
+		{ID: other, Role: RoleAuthor},
+		{ID: author, Role: RoleAuthor},
+		{ID: translator, Role: RoleTranslator},
+	}, b.Contributors)
+	assert.Equal(t, uuid.UUIDs{other, author}, b.AuthorIDs)
+}
@@ -0,0 +1,209 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/civil"
+)
+
+// How much of a PartialDate is known
+type DatePrecision int
+
+const (
+	PrecisionNone DatePrecision = iota
+	PrecisionYear
+	PrecisionMonth
+	PrecisionDay
+)
+
+// A date which may only be known to the year or month, as publication
+// dates often are. Parts which aren't known are zero; the zero value
+// is a date which isn't known at all.
+//
+// In JSON this is "2006", "2006-01" or "2006-01-02" depending on
+// precision, or null if unknown.
+type PartialDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+func (d PartialDate) Precision() DatePrecision {
+	switch {
+	case d.Year == 0:
+		return PrecisionNone
+	case d.Month == 0:
+		return PrecisionYear
+	case d.Day == 0:
+		return PrecisionMonth
+	default:
+		return PrecisionDay
+	}
+}
+
+func (d PartialDate) IsZero() bool {
+	return d == PartialDate{}
+}
+
+// Whether the known parts make a real date, and nothing is known
+// without the parts above it.
+func (d PartialDate) IsValid() bool {
+	switch {
+	case d.IsZero():
+		return true
+	case d.Year < 1 || d.Year > 9999:
+		return false
+	case d.Month == 0:
+		return d.Day == 0
+	case d.Month < time.January || d.Month > time.December:
+		return false
+	case d.Day == 0:
+		return true
+	default:
+		return civil.Date{Year: d.Year, Month: d.Month, Day: d.Day}.IsValid()
+	}
+}
+
+// The first and last days the date could be. Both are zero if the
+// date isn't known.
+func (d PartialDate) Start() civil.Date {
+	switch d.Precision() {
+	case PrecisionYear:
+		return civil.Date{Year: d.Year, Month: time.January, Day: 1}
+	case PrecisionMonth:
+		return civil.Date{Year: d.Year, Month: d.Month, Day: 1}
+	case PrecisionDay:
+		return civil.Date{Year: d.Year, Month: d.Month, Day: d.Day}
+	default:
+		return civil.Date{}
+	}
+}
+
+func (d PartialDate) End() civil.Date {
+	switch d.Precision() {
+	case PrecisionYear:
+		return civil.Date{Year: d.Year, Month: time.December, Day: 31}
+	case PrecisionMonth:
+		return civil.Date{Year: d.Year, Month: d.Month + 1, Day: 1}.AddDays(-1)
+	case PrecisionDay:
+		return d.Start()
+	default:
+		return civil.Date{}
+	}
+}
+
+// The most precise date covering every day from `first` to `last`, so
+// the inverse of Start and End. Dates which don't share a year have
+// nothing in common and give the zero date.
+func PartialDateBetween(first, last civil.Date) PartialDate {
+	switch {
+	case !first.IsValid() || !last.IsValid() || first.Year != last.Year:
+		return PartialDate{}
+	case first == last:
+		return PartialDate{Year: first.Year, Month: first.Month, Day: first.Day}
+	case first.Month == last.Month:
+		return PartialDate{Year: first.Year, Month: first.Month}
+	default:
+		return PartialDate{Year: first.Year}
+	}
+}
+
+// Order dates by when they start, with unknown dates first and less
+// precise dates before more precise ones starting on the same day.
+func (d PartialDate) Compare(o PartialDate) int {
+	if c := d.Start().Compare(o.Start()); c != 0 {
+		return c
+	}
+	return int(d.Precision()) - int(o.Precision())
+}
+
+// Whether the date could fall between `from` and `to`, inclusive. A
+// zero bound leaves that end open, but an unknown date is never in
+// any range.
+func (d PartialDate) Within(from, to PartialDate) bool {
+	if d.IsZero() {
+		return false
+	}
+	if !from.IsZero() && d.End().Before(from.Start()) {
+		return false
+	}
+	if !to.IsZero() && to.End().Before(d.Start()) {
+		return false
+	}
+	return true
+}
+
+func (d PartialDate) String() string {
+	switch d.Precision() {
+	case PrecisionYear:
+		return fmt.Sprintf("%04d", d.Year)
+	case PrecisionMonth:
+		return fmt.Sprintf("%04d-%02d", d.Year, int(d.Month))
+	case PrecisionDay:
+		return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+	default:
+		return ""
+	}
+}
+
+// Parse a date in the form String gives. The empty string is the zero
+// date.
+func ParsePartialDate(s string) (PartialDate, error) {
+	if s == "" {
+		return PartialDate{}, nil
+	}
+	for _, f := range []struct {
+		layout    string
+		precision DatePrecision
+	}{
+		{"2006-01-02", PrecisionDay},
+		{"2006-01", PrecisionMonth},
+		{"2006", PrecisionYear},
+	} {
+		if t, err := time.Parse(f.layout, s); err == nil {
+			return PartialDateOf(t, f.precision), nil
+		}
+	}
+	return PartialDate{}, fmt.Errorf("could not parse `%v` as a date", s)
+}
+
+// The parts of t down to the given precision
+func PartialDateOf(t time.Time, p DatePrecision) PartialDate {
+	var d PartialDate
+	if p >= PrecisionYear {
+		d.Year = t.Year()
+	}
+	if p >= PrecisionMonth {
+		d.Month = t.Month()
+	}
+	if p >= PrecisionDay {
+		d.Day = t.Day()
+	}
+	return d
+}
+
+func (d PartialDate) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *PartialDate) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*d = PartialDate{}
+		return nil
+	}
+	parsed, err := ParsePartialDate(*s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
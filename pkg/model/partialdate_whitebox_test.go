@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialDateJSON(t *testing.T) {
+	tests := []struct {
+		date PartialDate
+		json string
+	}{
+		{PartialDate{}, `null`},
+		{PartialDate{Year: 1791}, `"1791"`},
+		{PartialDate{Year: 1943, Month: time.April}, `"1943-04"`},
+		{PartialDate{Year: 1859, Month: time.November, Day: 26}, `"1859-11-26"`},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.date)
+		require.NoError(t, err)
+		assert.Equal(t, tt.json, string(b))
+
+		var d PartialDate
+		require.NoError(t, json.Unmarshal(b, &d))
+		assert.Equal(t, tt.date, d, tt.json)
+	}
+
+	var d PartialDate
+	assert.Error(t, json.Unmarshal([]byte(`"1943-04-31"`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`"April 1943"`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`1943`), &d))
+}
+
+func TestPartialDateRange(t *testing.T) {
+	tests := []struct {
+		date       PartialDate
+		start, end civil.Date
+	}{
+		{PartialDate{Year: 2024}, civil.Date{Year: 2024, Month: 1, Day: 1}, civil.Date{Year: 2024, Month: 12, Day: 31}},
+		{PartialDate{Year: 2024, Month: time.February}, civil.Date{Year: 2024, Month: 2, Day: 1}, civil.Date{Year: 2024, Month: 2, Day: 29}},
+		{PartialDate{Year: 2023, Month: time.December}, civil.Date{Year: 2023, Month: 12, Day: 1}, civil.Date{Year: 2023, Month: 12, Day: 31}},
+		{PartialDate{Year: 2023, Month: time.May, Day: 4}, civil.Date{Year: 2023, Month: 5, Day: 4}, civil.Date{Year: 2023, Month: 5, Day: 4}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.start, tt.date.Start(), tt.date.String())
+		assert.Equal(t, tt.end, tt.date.End(), tt.date.String())
+		assert.Equal(t, tt.date, PartialDateBetween(tt.start, tt.end), "round trip %v", tt.date)
+	}
+	assert.Zero(t, PartialDateBetween(
+		civil.Date{Year: 2023, Month: 12, Day: 31},
+		civil.Date{Year: 2024, Month: 1, Day: 1},
+	))
+}
+
+func TestPartialDateWithin(t *testing.T) {
+	june := PartialDate{Year: 2006, Month: time.June}
+	year := PartialDate{Year: 2006}
+	day := PartialDate{Year: 2006, Month: time.March, Day: 14}
+
+	assert.True(t, year.Within(june, PartialDate{}), "a year overlaps any of its months")
+	assert.False(t, day.Within(june, PartialDate{}))
+	assert.True(t, day.Within(PartialDate{}, year))
+	assert.True(t, june.Within(june, june))
+	assert.False(t, PartialDate{}.Within(PartialDate{}, PartialDate{}), "unknown dates are never in range")
+}
+
+func TestPartialDateIsValid(t *testing.T) {
+	assert.True(t, PartialDate{}.IsValid())
+	assert.True(t, PartialDate{Year: 2024, Month: time.February, Day: 29}.IsValid())
+	assert.False(t, PartialDate{Year: 2023, Month: time.February, Day: 29}.IsValid())
+	assert.False(t, PartialDate{Year: 2023, Day: 4}.IsValid(), "a day needs a month")
+	assert.False(t, PartialDate{Year: 2023, Month: 13}.IsValid())
+}
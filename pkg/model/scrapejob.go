@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ScrapeJobApiVersion string = "scrapejob.itsc-4155-group-project.edu.whits.io/v1alpha1"
+
+type ScrapeJobStatus string
+
+const (
+	ScrapeJobQueued  ScrapeJobStatus = "queued"
+	ScrapeJobRunning ScrapeJobStatus = "running"
+	ScrapeJobDone    ScrapeJobStatus = "done"
+	ScrapeJobFailed  ScrapeJobStatus = "failed"
+)
+
+// A request to scrape external providers for a query, which is run in
+// the background by the scraper's workers.
+type ScrapeJob struct {
+	ID     uuid.UUID       `json:"id"`
+	Query  string          `json:"query"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Status ScrapeJobStatus `json:"status"`
+
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+	// How many new books the job stored
+	Added int `json:"added"`
+	// Why the last attempt failed, if it did
+	Error string `json:"error,omitempty"`
+
+	Created  time.Time `json:"created"`
+	RunAfter time.Time `json:"run_after"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+func (j ScrapeJob) APIVersion() string {
+	return ScrapeJobApiVersion
+}
+
+// Whether the job is over and done with, successfully or not
+func (j ScrapeJob) Terminal() bool {
+	return j.Status == ScrapeJobDone || j.Status == ScrapeJobFailed
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const SeriesApiVersion string = "series.itsc-4155-group-project.edu.whits.io/v1alpha1"
+
+// An ordered run of books, such as The Expanse. A book is in at most
+// one series.
+type Series struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	// In reading order. Several books may share a position, for
+	// instance different editions of the same novel.
+	Entries []SeriesEntry `json:"entries"`
+}
+
+func (s Series) APIVersion() string {
+	return SeriesApiVersion
+}
+
+// A book's place in a series. Positions needn't be whole numbers, so
+// a novella set between the second and third books can be 2.5.
+type SeriesEntry struct {
+	Book uuid.UUID `json:"book"`
+	// The book's title. This is maintained by the datastore and
+	// ignored on update.
+	Title    string  `json:"title,omitempty"`
+	Position float64 `json:"position"`
+}
+
+// Check a series can be stored: it needs a name, and each book may
+// only appear once at a position no less than zero.
+func (s Series) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("series has no name")
+	}
+	seen := make(map[uuid.UUID]bool, len(s.Entries))
+	for _, e := range s.Entries {
+		if e.Position < 0 {
+			return fmt.Errorf("book `%v` has negative position %v", e.Book, e.Position)
+		} else if seen[e.Book] {
+			return fmt.Errorf("book `%v` appears more than once", e.Book)
+		}
+		seen[e.Book] = true
+	}
+	return nil
+}
+
+// The series a book is in, as shown alongside the book
+type BookSeries struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Position float64   `json:"position"`
+	// The books either side of this one. These are only filled in on
+	// the book's own page.
+	Previous uuid.UUID `json:"previous,omitzero"`
+	Next     uuid.UUID `json:"next,omitzero"`
+}
+
+// The books immediately before and after `book`, which are uuid.Nil at
+// either end of the series or if the book isn't in it. Books sharing
+// its position are skipped over; they are other editions, not the
+// next instalment. Entries must be in order.
+func (s Series) Neighbours(book uuid.UUID) (prev, next uuid.UUID) {
+	i := -1
+	for j, e := range s.Entries {
+		if e.Book == book {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return uuid.Nil, uuid.Nil
+	}
+	pos := s.Entries[i].Position
+	for j := i - 1; j >= 0; j-- {
+		if s.Entries[j].Position < pos {
+			prev = s.Entries[j].Book
+			break
+		}
+	}
+	for j := i + 1; j < len(s.Entries); j++ {
+		if s.Entries[j].Position > pos {
+			next = s.Entries[j].Book
+			break
+		}
+	}
+	return prev, next
+}
+
+var seriesNumber = regexp.MustCompile(
+	`(?i)^(.*?)\s*([;,:(]|--)?\s*(?:((?:book|bk|volume|vol|number|no|part)\b)\.?\s*)?(#)?\s*(\d+(?:\.\d+)?)\s*\)?$`,
+)
+
+// Split a provider's series string, such as "The Expanse ; 3" or "A
+// Song of Ice and Fire, book 2", into the series name and the book's
+// position in it. The bool is false when there is no position to be
+// found, in which case the whole string is the name.
+//
+// A number only counts as a position if something marks it as one, so
+// that "Catch 22" stays a name.
+func ParseSeries(s string) (string, float64, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	m := seriesNumber.FindStringSubmatch(s)
+	if m == nil || m[2]+m[3]+m[4] == "" {
+		return s, 0, false
+	}
+	name := strings.TrimRight(m[1], " ,;:-(")
+	pos, err := strconv.ParseFloat(m[5], 64)
+	if name == "" || err != nil {
+		return s, 0, false
+	}
+	return name, pos, true
+}
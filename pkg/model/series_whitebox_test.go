@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeries(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		position float64
+		ok       bool
+	}{
+		{"The Expanse ; 3", "The Expanse", 3, true},
+		{"The Expanse -- 3", "The Expanse", 3, true},
+		{"A Song of Ice and Fire, book 2", "A Song of Ice and Fire", 2, true},
+		{"Expanse ; bk. 2.5", "Expanse", 2.5, true},
+		{"Discworld #12", "Discworld", 12, true},
+		{"Harry Potter (7)", "Harry Potter", 7, true},
+		{"Dune Chronicles Vol. 1", "Dune Chronicles", 1, true},
+		{"Catch 22", "Catch 22", 0, false},
+		{"Discworld  series", "Discworld series", 0, false},
+		{"#3", "#3", 0, false},
+	}
+	for _, tt := range tests {
+		name, position, ok := ParseSeries(tt.input)
+		assert.Equal(t, tt.name, name, tt.input)
+		assert.Equal(t, tt.position, position, tt.input)
+		assert.Equal(t, tt.ok, ok, tt.input)
+	}
+}
+
+func TestSeriesNeighbours(t *testing.T) {
+	leviathan, hardback, paperback, novella, abaddon := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	s := Series{Entries: []SeriesEntry{
+		{Book: leviathan, Position: 1},
+		{Book: hardback, Position: 2},
+		{Book: paperback, Position: 2},
+		{Book: novella, Position: 2.5},
+		{Book: abaddon, Position: 3},
+	}}
+
+	prev, next := s.Neighbours(paperback)
+	assert.Equal(t, leviathan, prev, "other editions aren't the previous book")
+	assert.Equal(t, novella, next)
+
+	prev, next = s.Neighbours(leviathan)
+	assert.Equal(t, uuid.Nil, prev)
+	assert.Equal(t, hardback, next)
+
+	prev, next = s.Neighbours(abaddon)
+	assert.Equal(t, novella, prev)
+	assert.Equal(t, uuid.Nil, next)
+
+	prev, next = s.Neighbours(uuid.New())
+	assert.Equal(t, uuid.Nil, prev)
+	assert.Equal(t, uuid.Nil, next)
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const SubjectApiVersion string = "subject.itsc-4155-group-project.edu.whits.io/v1alpha1"
+
+// A node in the subject taxonomy, e.g. "Science Fiction" under
+// "Fiction". A book filed under a subject is also under all of that
+// subject's ancestors.
+type Subject struct {
+	ID uuid.UUID `json:"id"`
+	// uuid.Nil for top-level subjects
+	Parent uuid.UUID `json:"parent,omitzero"`
+	Name   string    `json:"name"`
+	// Names from the top of the taxonomy down to, and including, this
+	// subject
+	Path []string `json:"path"`
+}
+
+func (s Subject) APIVersion() string {
+	return SubjectApiVersion
+}
+
+// Split a provider's category string, such as "Fiction / Science
+// Fiction / Space Opera", into a subject path. Google Books files
+// things as "Fiction / General" when it means plain "Fiction", so a
+// trailing "General" is dropped.
+func ParseSubjectPath(category string) []string {
+	var path []string
+	for _, p := range strings.Split(category, "/") {
+		if p = strings.Join(strings.Fields(p), " "); p != "" {
+			path = append(path, p)
+		}
+	}
+	if len(path) > 1 && strings.EqualFold(path[len(path)-1], "General") {
+		path = path[:len(path)-1]
+	}
+	return path
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubjectPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"Fiction / Science Fiction / Space Opera", []string{"Fiction", "Science Fiction", "Space Opera"}},
+		{"Juvenile Fiction / General", []string{"Juvenile Fiction"}},
+		{"General", []string{"General"}},
+		{"  Foxes  ", []string{"Foxes"}},
+		{"History //  Modern   /", []string{"History", "Modern"}},
+		{" / ", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ParseSubjectPath(tt.input), tt.input)
+	}
+}
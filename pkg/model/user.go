@@ -22,12 +22,42 @@ type User struct {
 	Email       string    `json:"email"`
 	Avatar      uuid.UUID `json:"bref_avatar"`
 	Admin       bool      `json:"admin"`
+	// Whether the user opted out of being found, by search or
+	// otherwise. Their profile can still be seen by anyone who has
+	// the link.
+	Unlisted bool `json:"unlisted"`
 }
 
 func (u User) APIVersion() string {
 	return UserApiVersion
 }
 
+const UserProfileApiVersion string = "userprofile.itsc-4155-group-project.edu.whits.io/v1alpha1"
+
+// The parts of a user anyone can see, such as when they turn up in a
+// search. Nothing private (e.g. their email) is in it.
+type UserProfile struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"name"`
+	Pronouns    string    `json:"pronouns"`
+	Username    Username  `json:"username"`
+	Avatar      uuid.UUID `json:"bref_avatar"`
+}
+
+func (u UserProfile) APIVersion() string {
+	return UserProfileApiVersion
+}
+
+func (u User) Profile() UserProfile {
+	return UserProfile{
+		ID:          u.ID,
+		DisplayName: u.DisplayName,
+		Pronouns:    u.Pronouns,
+		Username:    u.Username,
+		Avatar:      u.Avatar,
+	}
+}
+
 func (u User) ToAuthor() CommentUser {
 	return CommentUser{
 		ID:          u.ID,
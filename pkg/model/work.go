@@ -0,0 +1,107 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const WorkApiVersion string = "work.itsc-4155-group-project.edu.whits.io/v1alpha1"
+
+// A work is what people usually mean by "a book": the novel itself,
+// as opposed to its hardcover, paperback and ebook editions. Each
+// edition is a Book, and reviews may be left on either.
+type Work struct {
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title"`
+	// The books which are editions of this work
+	Editions uuid.UUIDs `json:"editions"`
+}
+
+func (w Work) APIVersion() string {
+	return WorkApiVersion
+}
+
+// Editions of the same work share a key: their normalised title (see
+// NormalizeTitle) and set of authors. Books without a title or
+// authors have no key, as there is too little to go on.
+func WorkKey(title string, authors uuid.UUIDs) string {
+	t := NormalizeTitle(title)
+	if t == "" || len(authors) == 0 {
+		return ""
+	}
+	ids := make([]string, len(authors))
+	for i, a := range authors {
+		ids[i] = a.String()
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	return t + "|" + strings.Join(ids, ",")
+}
+
+// Reduce a title to what stays the same across editions: lower case,
+// without punctuation, leading articles or bracketed notes such as
+// "(Penguin Classics)".
+func NormalizeTitle(title string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth = max(depth-1, 0)
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '/':
+			b.WriteRune(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	if len(words) > 1 && slices.Contains([]string{"the", "a", "an"}, words[0]) {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// Work out which editions should move to which work so that editions
+// sharing a WorkKey end up together. Returns the new work of each
+// edition which has to move.
+//
+// Each cluster goes to whichever work already holds the most of it,
+// ties going to the lowest ID, so a second run moves nothing.
+func ClusterEditions(editions []*Book) map[uuid.UUID]uuid.UUID {
+	clusters := map[string][]*Book{}
+	for _, e := range editions {
+		if k := WorkKey(e.Title, e.AuthorIDs); k != "" {
+			clusters[k] = append(clusters[k], e)
+		}
+	}
+
+	moves := map[uuid.UUID]uuid.UUID{}
+	for _, cluster := range clusters {
+		count := map[uuid.UUID]int{}
+		for _, e := range cluster {
+			count[e.Work]++
+		}
+		if len(count) < 2 {
+			continue
+		}
+		var target uuid.UUID
+		for w, n := range count {
+			if best := count[target]; n > best ||
+				(n == best && strings.Compare(w.String(), target.String()) < 0) {
+				target = w
+			}
+		}
+		for _, e := range cluster {
+			if e.Work != target {
+				moves[e.ID] = target
+			}
+		}
+	}
+	return moves
+}
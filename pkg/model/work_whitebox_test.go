@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Fantastic Mr. Fox", "fantastic mr fox"},
+		{"The Hobbit (Penguin Classics)", "hobbit"},
+		{"A Wizard of Earthsea [Illustrated]", "wizard of earthsea"},
+		{"Half-Blood  Prince", "half blood prince"},
+		{"The", "the"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, NormalizeTitle(tt.input), tt.input)
+	}
+}
+
+func TestWorkKey(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+
+	// Author order and repeats don't matter
+	assert.Equal(t,
+		WorkKey("The Hobbit", uuid.UUIDs{a, b}),
+		WorkKey("Hobbit", uuid.UUIDs{b, a, b}),
+	)
+	assert.NotEqual(t,
+		WorkKey("The Hobbit", uuid.UUIDs{a}),
+		WorkKey("The Hobbit", uuid.UUIDs{b}),
+	)
+	assert.Empty(t, WorkKey("The Hobbit", nil))
+	assert.Empty(t, WorkKey("()", uuid.UUIDs{a}))
+}
+
+func TestClusterEditions(t *testing.T) {
+	author := uuid.New()
+	w1, w2, w3 := uuid.New(), uuid.New(), uuid.New()
+	editions := []*Book{
+		{ID: uuid.New(), Work: w1, Title: "The Hobbit", AuthorIDs: uuid.UUIDs{author}},
+		{ID: uuid.New(), Work: w1, Title: "Hobbit (Anniversary Edition)", AuthorIDs: uuid.UUIDs{author}},
+		{ID: uuid.New(), Work: w2, Title: "the hobbit", AuthorIDs: uuid.UUIDs{author}},
+		// Same title but another author stays put
+		{ID: uuid.New(), Work: w3, Title: "The Hobbit", AuthorIDs: uuid.UUIDs{uuid.New()}},
+		// No authors, nothing to go on
+		{ID: uuid.New(), Work: w3, Title: "The Hobbit"},
+	}
+
+	moves := ClusterEditions(editions)
+	assert.Equal(t, map[uuid.UUID]uuid.UUID{editions[2].ID: w1}, moves,
+		"the lone edition should join the larger work")
+
+	// Applying the moves leaves nothing for a second run
+	editions[2].Work = w1
+	assert.Empty(t, ClusterEditions(editions))
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Page sizes used when a caller does not ask for one, or asks for too
+// many.
+const (
+	DefaultPageSize int = 25
+	MaxPageSize     int = 250
+)
+
+// Clamp a requested page size to something sensible.
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(limit, MaxPageSize)
+}
+
+// Encode some position in a result set as an opaque, URL safe string.
+//
+// Managers are free to put whatever they need to resume a query in
+// the cursor; callers should never try to interpret it.
+func EncodeCursor(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Decode a cursor made by EncodeCursor into v. Anything which does
+// not decode cleanly is reported as ErrInvalidInput, as it almost
+// certainly came from a client fiddling with the value.
+func DecodeCursor(cursor string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Err{Code: ErrInvalidInput, Err: fmt.Errorf("decode cursor: %w", err)}
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return Err{Code: ErrInvalidInput, Err: fmt.Errorf("decode cursor: %w", err)}
+	}
+	return nil
+}
+
+// Like EncodeCursor, but signed so that a client can't hand back a
+// position it made up. Ed25519 and ECDSA keys are supported.
+func EncodeSignedCursor(v any, signer crypto.Signer) (string, error) {
+	c, err := EncodeCursor(v)
+	if err != nil {
+		return "", err
+	}
+	// Ed25519 signs the message itself, anything else a digest of it
+	msg, opts := []byte(c), crypto.SignerOpts(crypto.Hash(0))
+	if _, ok := signer.Public().(ed25519.PublicKey); !ok {
+		sum := sha256.Sum256(msg)
+		msg, opts = sum[:], crypto.SHA256
+	}
+	sig, err := signer.Sign(rand.Reader, msg, opts)
+	if err != nil {
+		return "", fmt.Errorf("sign cursor: %w", err)
+	}
+	return c + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Check the signature on a cursor made by EncodeSignedCursor and
+// decode it into v. A missing or bad signature is ErrInvalidInput.
+func DecodeSignedCursor(cursor string, pub crypto.PublicKey, v any) error {
+	c, encSig, _ := strings.Cut(cursor, ".")
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return Err{Code: ErrInvalidInput, Err: fmt.Errorf("decode cursor: %w", err)}
+	}
+	var valid bool
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		valid = len(key) == ed25519.PublicKeySize && ed25519.Verify(key, []byte(c), sig)
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256([]byte(c))
+		valid = ecdsa.VerifyASN1(key, sum[:], sig)
+	default:
+		return fmt.Errorf("decode cursor: unsupported key type %T", pub)
+	}
+	if !valid {
+		return Err{Code: ErrInvalidInput, Err: fmt.Errorf("decode cursor: bad signature")}
+	}
+	return DecodeCursor(c, v)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	type position struct {
+		Key string `json:"k"`
+		N   int    `json:"n"`
+	}
+	in := position{Key: "1970-01-01", N: 42}
+
+	c, err := EncodeCursor(in)
+	assert.NoError(t, err)
+	assert.NotContains(t, c, "=")
+
+	var out position
+	assert.NoError(t, DecodeCursor(c, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	var out map[string]any
+	err := DecodeCursor("not a cursor!", &out)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+
+	// Valid base64, but not JSON
+	err = DecodeCursor("aGVsbG8", &out)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+}
+
+func TestSignedCursor(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for name, priv := range map[string]crypto.Signer{"ed25519": edPriv, "ecdsa": ecPriv} {
+		t.Run(name, func(t *testing.T) {
+			pub := priv.Public()
+			in := SearchPosition{Score: 0.75, ID: uuid.New()}
+
+			c, err := EncodeSignedCursor(in, priv)
+			require.NoError(t, err)
+			var out SearchPosition
+			require.NoError(t, DecodeSignedCursor(c, pub, &out))
+			assert.Equal(t, in, out)
+
+			// A cursor someone has fiddled with
+			forged, err := EncodeCursor(SearchPosition{Score: 99, ID: in.ID})
+			require.NoError(t, err)
+			_, sig, _ := strings.Cut(c, ".")
+			err = DecodeSignedCursor(forged+"."+sig, pub, &out)
+			assert.True(t, errors.Is(err, ErrInvalidInput))
+			err = DecodeSignedCursor(forged, pub, &out)
+			assert.True(t, errors.Is(err, ErrInvalidInput))
+		})
+	}
+
+	// Or one signed by someone else
+	c, err := EncodeSignedCursor(SearchPosition{}, edPriv)
+	require.NoError(t, err)
+	var out SearchPosition
+	require.NoError(t, DecodeSignedCursor(c, edPub, &out))
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	err = DecodeSignedCursor(c, otherPub, &out)
+	assert.True(t, errors.Is(err, ErrInvalidInput))
+}
+
+func TestSearchPositionCompare(t *testing.T) {
+	a, b := uuid.MustParse("00000000-0000-0000-0000-000000000001"), uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	assert.Negative(t, SearchPosition{Score: 2, ID: b}.Compare(SearchPosition{Score: 1, ID: a}), "higher scores rank first")
+	assert.Negative(t, SearchPosition{Score: 1, ID: a}.Compare(SearchPosition{Score: 1, ID: b}), "then lower IDs")
+	assert.Zero(t, SearchPosition{Score: 1, ID: a}.Compare(SearchPosition{Score: 1, ID: a}))
+}
+
+func TestPageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageSize(0))
+	assert.Equal(t, DefaultPageSize, PageSize(-3))
+	assert.Equal(t, 10, PageSize(10))
+	assert.Equal(t, MaxPageSize, PageSize(MaxPageSize+1))
+}
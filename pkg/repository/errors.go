@@ -18,6 +18,7 @@ var (
 	ErrBadTypecast     = errors.New("failed to typecast")
 	ErrMultipleResults = errors.New("multiple results found")
 	ErrInvalidInput    = errors.New("invalid input")
+	ErrConflict        = errors.New("conflicts with existing data")
 	ErrUndefined       = errors.New("undefined error")
 )
 
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Snippets of the fields of a search result which matched the query,
+// keyed by the field's name in the result's JSON. They are HTML: the
+// parts which matched are in <mark> elements, and everything else is
+// escaped.
+type Highlights map[string]string
+
+// Markers around the matched parts of a snippet, before it is made
+// into a highlight. Neither turns up in text people write.
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// Turn a snippet, with its matches between SnippetStart and SnippetEnd,
+// into a highlight. False is returned if nothing in it matched.
+func Highlight(snippet string) (string, bool) {
+	var (
+		b       strings.Builder
+		matched bool
+	)
+	// Any markers left over are unmatched, so are dropped
+	strip := strings.NewReplacer(SnippetStart, "", SnippetEnd, "")
+	escape := func(s string) string { return html.EscapeString(strip.Replace(s)) }
+	for {
+		i := strings.Index(snippet, SnippetStart)
+		if i == -1 {
+			break
+		}
+		n := strings.Index(snippet[i+len(SnippetStart):], SnippetEnd)
+		if n == -1 {
+			break
+		}
+		match := snippet[i+len(SnippetStart) : i+len(SnippetStart)+n]
+		b.WriteString(escape(snippet[:i]))
+		b.WriteString("<mark>" + escape(match) + "</mark>")
+		snippet = snippet[i+len(SnippetStart)+n+len(SnippetEnd):]
+		matched = matched || match != ""
+	}
+	b.WriteString(escape(snippet))
+	return b.String(), matched
+}
+
+// Mark the words of the query's terms in `fields` wherever they are in
+// text, ignoring case, as a snippet. This is rougher than a search
+// index's own snippets, which know about stemming and so on, and never
+// shortens the text, so is best kept to short things like names.
+// Excluded terms are never marked, as nothing they match is a result.
+func (q Query) Mark(text string, fields ...QueryField) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Offsets into one wouldn't be offsets into the other
+		return text
+	}
+	var spans [][2]int
+	for _, c := range q.Clauses {
+		for _, t := range c {
+			if !slices.Contains(fields, t.Field) {
+				continue
+			}
+			for _, w := range strings.Fields(strings.ToLower(t.Text)) {
+				for i := 0; ; {
+					n := strings.Index(lower[i:], w)
+					if n == -1 {
+						break
+					}
+					spans = append(spans, [2]int{i + n, i + n + len(w)})
+					i += n + len(w)
+				}
+			}
+		}
+	}
+	slices.SortFunc(spans, func(a, b [2]int) int { return a[0] - b[0] })
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for i := 0; i < len(spans); {
+		// Overlapping matches are marked as one
+		start, end := spans[i][0], spans[i][1]
+		for i++; i < len(spans) && spans[i][0] <= end; i++ {
+			end = max(end, spans[i][1])
+		}
+		b.WriteString(text[last:start] + SnippetStart + text[start:end] + SnippetEnd)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
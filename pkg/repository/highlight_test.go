@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlight(t *testing.T) {
+	h, ok := Highlight("a <b> \x02Dune\x03 & \x02\x03more\x03")
+	assert.True(t, ok)
+	assert.Equal(t, "a &lt;b&gt; <mark>Dune</mark> &amp; <mark></mark>more", h)
+
+	_, ok = Highlight("nothing matched")
+	assert.False(t, ok)
+}
+
+func TestQueryMark(t *testing.T) {
+	q, err := ParseQuery(`"le guin" title:ursula -ursula`)
+	require.NoError(t, err)
+	assert.Equal(t, "Ursula K. \x02Le\x03 \x02Guin\x03", q.Mark("Ursula K. Le Guin", AuthorQueryFields...),
+		"excluded terms and other fields' terms aren't marked")
+
+	q, err = ParseQuery("gui OR uin")
+	require.NoError(t, err)
+	assert.Equal(t, "Le \x02Guin\x03", q.Mark("Le Guin", FieldAny), "overlapping matches are marked as one")
+	assert.Equal(t, "Dune", q.Mark("Dune", FieldAny))
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+)
+
+// What part of a result a query term searches
+type QueryField string
+
+const (
+	// Whatever the domain searches by default
+	FieldAny         QueryField = ""
+	FieldTitle       QueryField = "title"
+	FieldSubtitle    QueryField = "subtitle"
+	FieldDescription QueryField = "description"
+	// Author names, both of authors themselves and books they are
+	// credited on
+	FieldAuthor QueryField = "author"
+	FieldISBN   QueryField = "isbn"
+	// The body of a comment
+	FieldComment QueryField = "comment"
+	// A user's handle, display name or full username
+	FieldUser QueryField = "user"
+)
+
+// The fields each domain understands. Anything else can't match.
+var (
+	BookQueryFields    = []QueryField{FieldAny, FieldTitle, FieldSubtitle, FieldDescription, FieldAuthor, FieldISBN}
+	AuthorQueryFields  = []QueryField{FieldAny, FieldAuthor}
+	CommentQueryFields = []QueryField{FieldAny, FieldComment}
+	UserQueryFields    = []QueryField{FieldAny, FieldUser}
+)
+
+// A parsed search query: every clause must match, and nothing which
+// matches an excluded term can. See ParseQuery for the syntax.
+type Query struct {
+	Clauses []QueryClause
+	Exclude []QueryTerm
+}
+
+// Terms of which at least one must match
+type QueryClause []QueryTerm
+
+type QueryTerm struct {
+	Field QueryField
+	// A single word, or several in order if Phrase is set. ISBNs are
+	// always just their digits.
+	Text   string
+	Phrase bool
+}
+
+// A query which couldn't be parsed
+type QuerySyntaxError struct {
+	Query string
+	// The byte offset into Query where the problem is
+	Pos int
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("query syntax: %v at position %d", e.Msg, e.Pos)
+}
+
+func (e *QuerySyntaxError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// Parse a search query. Terms are separated by spaces and must all
+// match, unless joined by OR:
+//
+//	dune                    a word, in whatever the domain searches
+//	"left hand"             a phrase, with the words in order
+//	title:dispossessed      a word or phrase in one field
+//	-sequel                 leave out anything matching the term
+//	dune OR arrakis         either term
+//
+// The fields are title, subtitle, description, author, isbn, comment
+// and user. Terms in a field a domain doesn't have (see BookQueryFields
+// and so on) never match anything there. Negated terms can't be part
+// of an OR, and there must be at least one term which isn't negated.
+func ParseQuery(s string) (Query, error) {
+	p := queryParser{s: s}
+	return p.parse()
+}
+
+// A query for any of the words in text, with none of them treated as
+// syntax, the same as searches had before there was a syntax.
+func PlainQuery(text ...string) Query {
+	var c QueryClause
+	for _, w := range strings.Fields(strings.Join(text, " ")) {
+		c = append(c, QueryTerm{Text: w})
+	}
+	if len(c) == 0 {
+		return Query{}
+	}
+	return Query{Clauses: []QueryClause{c}}
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...any) error {
+	return &QuerySyntaxError{Query: p.s, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Whether the current position is the end of a word
+func (p *queryParser) atSpace() bool {
+	if p.pos == len(p.s) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return unicode.IsSpace(r)
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.s) {
+		r, n := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += n
+	}
+}
+
+// The word starting at the current position, up to the next space
+func (p *queryParser) word() string {
+	end := p.pos
+	for end < len(p.s) {
+		r, n := utf8.DecodeRuneInString(p.s[end:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		end += n
+	}
+	return p.s[p.pos:end]
+}
+
+func (p *queryParser) parse() (Query, error) {
+	var (
+		q Query
+		// Whether the last term was followed by OR, and where
+		or    bool
+		orPos int
+	)
+	for p.skipSpace(); p.pos < len(p.s); p.skipSpace() {
+		if w := p.word(); w == "OR" {
+			switch {
+			case or:
+				return q, p.errorf(p.pos, "OR follows another OR")
+			case len(q.Clauses) == 0:
+				return q, p.errorf(p.pos, "OR needs a term before it")
+			}
+			or, orPos = true, p.pos
+			p.pos += len(w)
+			continue
+		}
+
+		start := p.pos
+		negate := p.s[p.pos] == '-'
+		if negate {
+			p.pos++
+		}
+		t, err := p.term()
+		if err != nil {
+			return q, err
+		}
+		switch {
+		case negate && or:
+			return q, p.errorf(start, "a negated term can't be part of an OR")
+		case negate:
+			q.Exclude = append(q.Exclude, t)
+		case or:
+			last := &q.Clauses[len(q.Clauses)-1]
+			*last = append(*last, t)
+		default:
+			q.Clauses = append(q.Clauses, QueryClause{t})
+		}
+		or = false
+		// Nor can a negated term come before an OR
+		if p.skipSpace(); negate && p.word() == "OR" {
+			return q, p.errorf(start, "a negated term can't be part of an OR")
+		}
+	}
+	switch {
+	case or:
+		return q, p.errorf(orPos, "OR needs a term after it")
+	case len(q.Clauses) == 0 && len(q.Exclude) == 0:
+		return q, p.errorf(0, "the query is empty")
+	case len(q.Clauses) == 0:
+		return q, p.errorf(0, "there must be at least one term which isn't negated")
+	}
+	return q, nil
+}
+
+// A single term, with any field but not its negation
+func (p *queryParser) term() (QueryTerm, error) {
+	var t QueryTerm
+	start := p.pos
+	if p.atSpace() {
+		return t, p.errorf(start-1, "nothing follows the -")
+	}
+
+	// A field is only a field if it's one we know, but anything which
+	// looks like one is assumed to be meant as one
+	w := p.word()
+	if i := strings.IndexByte(w, ':'); i > 0 && !strings.ContainsRune(w[:i], '"') {
+		name := QueryField(strings.ToLower(w[:i]))
+		known := slices.Concat(BookQueryFields, CommentQueryFields, UserQueryFields)
+		if name == FieldAny || !slices.Contains(known, name) {
+			return t, p.errorf(start, "there is no field `%v`", w[:i])
+		}
+		t.Field = name
+		p.pos += i + 1
+		if p.atSpace() {
+			return t, p.errorf(start, "`%v:` needs something to search for", w[:i])
+		}
+	}
+
+	valuePos := p.pos
+	switch c := p.s[p.pos]; {
+	case c == '"':
+		end := strings.IndexByte(p.s[p.pos+1:], '"')
+		if end == -1 {
+			return t, p.errorf(valuePos, "the quote is never closed")
+		}
+		t.Text = strings.Join(strings.Fields(p.s[p.pos+1:p.pos+1+end]), " ")
+		t.Phrase = true
+		p.pos += end + 2
+		if t.Text == "" {
+			return t, p.errorf(valuePos, "the quotes have nothing in them")
+		}
+		if !p.atSpace() {
+			return t, p.errorf(p.pos, "a closing quote must be followed by a space")
+		}
+	case c == '(' || c == ')':
+		return t, p.errorf(valuePos, "brackets aren't supported")
+	default:
+		w := p.word()
+		if i := strings.IndexAny(w, `"()`); i != -1 {
+			return t, p.errorf(valuePos+i, "`%c` can't be in the middle of a word", w[i])
+		}
+		t.Text = w
+		p.pos += len(w)
+	}
+
+	if t.Field == FieldISBN {
+		isbn, err := model.NewISBN(t.Text)
+		if err != nil || !isbn.Check() {
+			return t, p.errorf(valuePos, "`%v` isn't an ISBN", t.Text)
+		}
+		t.Text, t.Phrase = isbn.String(), false
+	}
+	return t, nil
+}
+
+// The part of the query a domain which searches `fields` can answer.
+// Terms in any other field never match, so they are dropped; if that
+// leaves a clause empty, nothing in the domain can match and false is
+// returned.
+func (q Query) Only(fields ...QueryField) (Query, bool) {
+	var r Query
+	for _, c := range q.Clauses {
+		var kept QueryClause
+		for _, t := range c {
+			if slices.Contains(fields, t.Field) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			return Query{}, false
+		}
+		r.Clauses = append(r.Clauses, kept)
+	}
+	for _, t := range q.Exclude {
+		if slices.Contains(fields, t.Field) {
+			r.Exclude = append(r.Exclude, t)
+		}
+	}
+	return r, len(r.Clauses) > 0
+}
+
+// Every word the query looks for, for searching somewhere which
+// doesn't know the syntax
+func (q Query) Keywords() string {
+	var words []string
+	for _, c := range q.Clauses {
+		for _, t := range c {
+			words = append(words, t.Text)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (t QueryTerm) String() string {
+	s := t.Text
+	if t.Phrase {
+		s = `"` + s + `"`
+	}
+	if t.Field != FieldAny {
+		s = string(t.Field) + ":" + s
+	}
+	return s
+}
+
+// The query in the syntax ParseQuery takes
+func (q Query) String() string {
+	var parts []string
+	for _, c := range q.Clauses {
+		terms := make([]string, len(c))
+		for i, t := range c {
+			terms[i] = t.String()
+		}
+		parts = append(parts, strings.Join(terms, " OR "))
+	}
+	for _, t := range q.Exclude {
+		parts = append(parts, "-"+t.String())
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Query
+	}{
+		{"dune", Query{Clauses: []QueryClause{{{Text: "dune"}}}}},
+		{`author:"Le  Guin" title:dispossessed`, Query{Clauses: []QueryClause{
+			{{Field: FieldAuthor, Text: "Le Guin", Phrase: true}},
+			{{Field: FieldTitle, Text: "dispossessed"}},
+		}}},
+		{"dune OR arrakis -Comment:sequel", Query{
+			Clauses: []QueryClause{{{Text: "dune"}, {Text: "arrakis"}}},
+			Exclude: []QueryTerm{{Field: FieldComment, Text: "sequel"}},
+		}},
+		{"user:dunefan#0007", Query{Clauses: []QueryClause{{{Field: FieldUser, Text: "dunefan#0007"}}}}},
+		{"isbn:978-0-441-47812-5 OR \"left hand\"", Query{Clauses: []QueryClause{
+			{{Field: FieldISBN, Text: "9780441478125"}, {Text: "left hand", Phrase: true}},
+		}}},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, q, tt.in)
+
+		// What String gives back parses to the same thing
+		again, err := ParseQuery(q.String())
+		require.NoError(t, err, q.String())
+		assert.Equal(t, q, again, q.String())
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		pos int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"-sequel", 0},
+		{"dune OR", 5},
+		{"OR dune", 0},
+		{"dune OR OR arrakis", 8},
+		{"dune OR -arrakis", 8},
+		{"-dune OR arrakis", 0},
+		{"dune -", 5},
+		{"genre:scifi", 0},
+		{"title:", 0},
+		{`dune "left hand`, 5},
+		{`dune ""`, 5},
+		{`"left hand"s`, 11},
+		{"dune (arrakis)", 5},
+		{`du"ne`, 2},
+		{"isbn:978...", 5},
+		{"isbn:9780441478126", 5},
+	}
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.in)
+		var serr *QuerySyntaxError
+		require.True(t, errors.As(err, &serr), "%q: %v", tt.in, err)
+		assert.Equal(t, tt.pos, serr.Pos, "%q: %v", tt.in, err)
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	}
+}
+
+func TestQueryOnly(t *testing.T) {
+	q, err := ParseQuery(`author:"le guin" OR dispossessed -title:sequel`)
+	require.NoError(t, err)
+
+	authors, ok := q.Only(AuthorQueryFields...)
+	assert.True(t, ok)
+	assert.Equal(t, Query{Clauses: []QueryClause{
+		{{Field: FieldAuthor, Text: "le guin", Phrase: true}, {Text: "dispossessed"}},
+	}}, authors, "authors have no titles to leave out")
+
+	q, err = ParseQuery(`author:"le guin" title:dispossessed`)
+	require.NoError(t, err)
+	_, ok = q.Only(AuthorQueryFields...)
+	assert.False(t, ok, "no author has a title")
+	_, ok = q.Only(BookQueryFields...)
+	assert.True(t, ok)
+}
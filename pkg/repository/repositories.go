@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"crypto"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,14 +20,18 @@ import (
 //
 // TODO: I don't like this.
 type Repository[S comparable] struct {
-	Author  AuthorManager[S]
-	Auth    AuthManager
-	Blob    BlobManager
-	Book    BookManager[S]
-	Comment CommentManager[S]
-	User    UserManager
-	Store   StoreManager
-	Vote    VoteManager
+	Author    AuthorManager[S]
+	Auth      AuthManager
+	Blob      BlobManager
+	Book      BookManager[S]
+	Comment   CommentManager[S]
+	User      UserManager
+	Store     StoreManager
+	Vote      VoteManager
+	ScrapeJob ScrapeJobManager
+	Subject   SubjectManager
+	Work      WorkManager
+	Series    SeriesManager
 }
 
 // The most fundamental manager type, which implements primitive CRUD
@@ -53,23 +59,81 @@ type CRUDmanager[K comparable, T any] interface {
 // TODO: query is string to make me not want to kill myself, should a
 // real app use that generic S?
 type Searcher[S comparable, T any] interface {
-	// Searches the domain given a query
+	// Searches the domain given a query, returning the page of results
+	// asked for
 	//
 	// The two non-error return values are effectively the same, except
 	// for their types; use the AnyScoreItemer for JSON stuff and the
 	// SearchResult[T] for typed internal searches
-	Search(ctx context.Context, offset, limit int, query ...string) ([]SearchResult[T], []AnyScoreItemer, error)
+	Search(ctx context.Context, page SearchPage, query ...string) ([]SearchResult[T], []AnyScoreItemer, error)
 }
 
+// Where a result ranks in a search. Results are ranked best score
+// first, with ties broken by ID so every result has a fixed place.
+type SearchPosition struct {
+	Score float64   `json:"s"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// Negative if p ranks ahead of o, positive if behind
+func (p SearchPosition) Compare(o SearchPosition) int {
+	switch {
+	case p.Score > o.Score:
+		return -1
+	case p.Score < o.Score:
+		return 1
+	default:
+		return bytes.Compare(p.ID[:], o.ID[:])
+	}
+}
+
+// Which results of a search to return. Pages are keyed on the
+// position of a result rather than an offset, so later pages cost no
+// more than the first.
+type SearchPage struct {
+	// Return the first results ranked after this one, or from the top
+	// if nil.
+	After *SearchPosition
+	// Instead return the last results ranked up to and including this
+	// one, for paging backwards. Results are in rank order either way.
+	Through *SearchPosition
+	Limit   int
+}
+
+// Completes what someone has started typing into a search box. This
+// is meant to keep up with typing, so is quick and rough rather than
+// thorough.
+type Suggester interface {
+	// Up to `limit` names in the domain which start with `prefix`, or
+	// have a word which does, best first. Case is ignored.
+	Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error)
+}
+
+// Something a partly typed search could be completed to
+type Suggestion struct {
+	ID   uuid.UUID `json:"id"`
+	Text string    `json:"text"`
+	// How closely Text matches what was typed, from 0 to 1
+	Score float64 `json:"score"`
+}
+
+// The most suggestions a domain gives at once
+const MaxSuggestions int = 10
+
 type AnyScoreItemer interface {
 	model.APIVersioner
 	ItemAsAny() any
 	ScoreValue() float64
+	Position() SearchPosition
+	Highlighted() Highlights
 }
 
 type SearchResult[T any] struct {
 	Item  *T
+	ID    uuid.UUID
 	Score float64
+	// Why the result matched, if the domain can say. Nil otherwise.
+	Highlights Highlights
 }
 
 // Take a guess as to what this does.
@@ -78,6 +142,20 @@ type BookScraper interface {
 	ScrapeISBN(ctx context.Context, isbn model.ISBN) (int, error)
 }
 
+// Re-fetches a book's metadata from wherever it was scraped from
+type BookRefresher interface {
+	// Refresh one book now, returning it along with the fields which
+	// changed.
+	RefreshBook(ctx context.Context, id uuid.UUID) (*model.Book, []string, error)
+}
+
+// Queues scrapes to be run in the background
+type ScrapeQueue interface {
+	// Queue a scrape for the query, returning the job which will
+	// handle it. This may be an existing job for the same query.
+	Enqueue(ctx context.Context, query string, offset, limit int) (*model.ScrapeJob, error)
+}
+
 // We don't super-need the result to have an APIVersion, but it if it
 // does, we want to expose it.
 func (sr SearchResult[T]) APIVersion() string {
@@ -96,6 +174,14 @@ func (sr SearchResult[T]) ScoreValue() float64 {
 	return sr.Score
 }
 
+func (sr SearchResult[T]) Position() SearchPosition {
+	return SearchPosition{Score: sr.Score, ID: sr.ID}
+}
+
+func (sr SearchResult[T]) Highlighted() Highlights {
+	return sr.Highlights
+}
+
 /*******************************/
 /*** TOP-LEVEL SYSTEM CONFIG ***/
 /*******************************/
@@ -118,8 +204,150 @@ type AuthManager interface {
 	Rotate(ctx context.Context, ttl time.Duration) (crypto.Signer, error)
 }
 
+type SubjectManager interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Subject, error)
+	// The whole taxonomy, ordered by path so parents come before their
+	// children.
+	List(ctx context.Context) ([]*model.Subject, error)
+	// Find the subject at a path (see model.ParseSubjectPath), creating
+	// it and any missing ancestors. Names are matched without regard
+	// to case.
+	Resolve(ctx context.Context, path []string) (*model.Subject, error)
+}
+
+// Groups editions (books) into works. Every book is an edition of
+// exactly one work; a book created without one gets a work of its own.
+type WorkManager interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Work, error)
+	// Move editions into a work. Works left without any editions are
+	// deleted, and their reviews move along with the editions.
+	//
+	// Editions moved by Join or Split are pinned, and never moved by
+	// Cluster afterwards.
+	Join(ctx context.Context, workID uuid.UUID, editions ...uuid.UUID) (*model.Work, error)
+	// Move editions out of whatever work they are in and into a new
+	// one together.
+	Split(ctx context.Context, editions ...uuid.UUID) (*model.Work, error)
+	// Join editions which look to be the same work (see
+	// model.WorkKey) and aren't pinned. Returns how many editions were
+	// moved.
+	Cluster(ctx context.Context) (int, error)
+}
+
+// Ordered runs of books. Updating a series replaces its name,
+// description and entries wholesale; books left out of the entries are
+// no longer in the series.
+type SeriesManager interface {
+	CRUDmanager[uuid.UUID, model.Series]
+	// Find a series by name, without regard to case, creating it if
+	// there is none.
+	Resolve(ctx context.Context, name string) (*model.Series, error)
+	// Put a book into a series at a position, taking it out of any
+	// series it was in before.
+	Place(ctx context.Context, seriesID, bookID uuid.UUID, position float64) error
+}
+
+// Blobs are immutable, and each content is only kept once: creating a
+// blob with the same content as another gives back the existing one,
+// whose ID the created blob is changed to. Delete fails with
+// ErrConflict while anything still references the blob; Detach it first
+// to delete it regardless.
 type BlobManager interface {
 	CRUDmanager[uuid.UUID, model.Blob]
+	// Every book and user using the blob
+	References(ctx context.Context, id uuid.UUID) ([]model.BlobReference, error)
+	// Stop everything from using the blob, returning what did. Books
+	// are left without that cover or thumbnail, and users without an
+	// avatar.
+	Detach(ctx context.Context, id uuid.UUID) ([]model.BlobReference, error)
+}
+
+// Keeps blobs' content, apart from their metadata. Content is addressed
+// by its SHA-256 checksum in hex, so the same content is only ever kept
+// once per store.
+type BlobStore interface {
+	// The store's name, which is recorded with every blob whose content
+	// it keeps, so must not change
+	Name() string
+	// Keep `size` bytes of content under its checksum. Putting content
+	// which is already there does nothing. Content which doesn't match
+	// its checksum may be refused.
+	Put(ctx context.Context, sum string, content io.Reader, size int64) error
+	// The content with the checksum, which must be closed. Fails with
+	// ErrNotFound if there is none.
+	Get(ctx context.Context, sum string) (io.ReadCloser, error)
+	// Stop keeping the content with the checksum. Deleting content
+	// which isn't there does nothing.
+	Delete(ctx context.Context, sum string) error
+}
+
+// Content from a BlobStore which can't be seeked through, but can be
+// fetched again in part, like an S3 object
+type BlobRangeReader interface {
+	// Fetch just the bytes an HTTP Range header (like `bytes=0-99`)
+	// asks for, with the Content-Range of what was fetched. If the
+	// store sends back everything anyway, that is empty. Fails with
+	// ErrInvalidInput if the range can't be satisfied.
+	ReadRange(ctx context.Context, spec string) (io.ReadCloser, string, error)
+}
+
+// Moves blobs' content between stores
+type BlobMigrator interface {
+	// Move the content of up to `limit` blobs from the store named
+	// `from` to the one named `to`, returning how many were moved. Once
+	// none are, there are none left in `from`.
+	MigrateBlobs(ctx context.Context, from, to string, limit int) (int, error)
+}
+
+// Backfills deduplication for blobs made before it
+type BlobDeduplicator interface {
+	// Merge up to `limit` sets of blobs with the same content into one
+	// each, pointing whatever used the others at it, and return how
+	// many blobs were merged away. Once none are, every blob's content
+	// is its own.
+	DedupeBlobs(ctx context.Context, limit int) (int, error)
+	// Correct the count of what references each blob, which garbage
+	// collection goes by, returning how many were wrong
+	RecountBlobRefs(ctx context.Context) (int, error)
+}
+
+// Deletes blobs which nothing uses any more
+type BlobCollector interface {
+	// Mark every blob used as a book's cover or thumbnail or a user's
+	// avatar, then sweep away the rest, leaving those created within
+	// `grace` as whatever made them may not have used them yet. With
+	// `dryRun`, they are only reported. A blob which is used again
+	// before it is swept is left alone.
+	CollectBlobs(ctx context.Context, grace time.Duration, dryRun bool) (*model.BlobCollection, error)
+}
+
+// A persistent queue of scrape jobs, shared between every instance of
+// the backend.
+type ScrapeJobManager interface {
+	// Queue a scrape for the query. If an identical job is already
+	// queued or running, or finished successfully within `fresh`, that
+	// job is returned instead and the bool is false.
+	Enqueue(ctx context.Context, job *model.ScrapeJob, fresh time.Duration) (*model.ScrapeJob, bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.ScrapeJob, error)
+	// Take the next runnable job and mark it as running. A job which
+	// has been running for longer than `lease` is assumed to belong to
+	// a dead worker and can be claimed again, unless that was its last
+	// attempt, in which case it fails. Returns ErrNotFound if
+	// there is nothing to do. The claimed job's Attempts identifies the
+	// claim: Complete and Fail return ErrConflict unless the job is
+	// still running under it, which it isn't once its lease runs out
+	// and someone else claims it.
+	Claim(ctx context.Context, lease time.Duration) (*model.ScrapeJob, error)
+	// Mark a job as done, recording how many books it added
+	Complete(ctx context.Context, id uuid.UUID, attempt int, added int) error
+	// Record a failed attempt. The job is queued again after `backoff`
+	// unless it has used up all its attempts, in which case it fails
+	// for good.
+	Fail(ctx context.Context, id uuid.UUID, attempt int, cause error, backoff time.Duration) error
+	// Queue a job again straight away without counting the attempt,
+	// for a worker which stopped before it could finish. Like Complete
+	// and Fail, the job must still be running under the attempt.
+	Release(ctx context.Context, id uuid.UUID, attempt int) error
 }
 
 /******************************/
@@ -129,17 +357,143 @@ type BlobManager interface {
 type AuthorManager[S comparable] interface {
 	CRUDmanager[uuid.UUID, model.Author]
 	Searcher[S, model.Author]
+	// Suggests authors' full names
+	Suggester
+	// Search, but only for authors matching the filter, with a parsed
+	// query. Search is the same as a PlainQuery and an empty filter.
+	SearchFiltered(ctx context.Context, page SearchPage, query Query, filter AuthorFilter) ([]SearchResult[model.Author], []AnyScoreItemer, error)
 	Book(ctx context.Context, bookID uuid.UUID) ([]*model.Author, error)
 	ExistsByName(ctx context.Context, name string) (*model.Author, bool, error)
+	ExistsByExtID(ctx context.Context, id model.AuthorIDs) (*model.Author, bool, error)
+	// Fold duplicate authors into one canonical author. Every book
+	// credited to an author in `from` is credited to `into` instead,
+	// and the duplicates are replaced with redirects so their old IDs
+	// (and names) keep resolving. Returns the canonical author.
+	Merge(ctx context.Context, into uuid.UUID, from ...uuid.UUID) (*model.Author, error)
 }
 
 type BookManager[S comparable] interface {
 	CRUDmanager[uuid.UUID, model.Book]
 	Searcher[S, model.BookSummary]
+	// Suggests book titles
+	Suggester
 	Summarize(context.Context, *model.Book) (*model.BookSummary, error)
 	GetByISBN(context.Context, model.ISBN) (*model.Book, error)
-	Author(ctx context.Context, authorID uuid.UUID) ([]*model.Book, error)
+	// Page through the books an author is credited on. The returned
+	// string is the cursor for the following page, which is empty
+	// once there is nothing left.
+	Author(ctx context.Context, authorID uuid.UUID, opts BibliographyOptions) ([]*model.BookSummary, string, error)
+	// Page through the books filed under a subject or any of its
+	// descendants, in the same way as Author.
+	Subject(ctx context.Context, subjectID uuid.UUID, opts BibliographyOptions) ([]*model.BookSummary, string, error)
+	// Search, but only for books matching the filter, with a parsed
+	// query. Search is the same as a PlainQuery and an empty filter.
+	SearchFiltered(ctx context.Context, page SearchPage, query Query, filter BookFilter) ([]SearchResult[model.BookSummary], []AnyScoreItemer, error)
+	// Count every book a filtered search matches, not just a page of
+	// them, by decade published, subject and rating.
+	Facets(ctx context.Context, query Query, filter BookFilter) (*BookFacets, error)
 	ExistsByISBN(ctx context.Context, isbns ...model.ISBN) (*model.Book, bool, error)
+	// Update a book on someone's behalf. A field is only overwritten
+	// if `by` takes precedence over whoever set it last (see
+	// model.Provenance.Overrides), and the fields which do change are
+	// attributed to `by`. Returns the book and the changed fields.
+	//
+	// Update is the same as an Edit by an unnamed admin.
+	Edit(ctx context.Context, book *model.Book, by model.Provenance) (*model.Book, []string, error)
+	// Where each field of a book came from, keyed by the model's
+	// BookField names. Fields with no known provenance are absent.
+	Provenance(ctx context.Context, id uuid.UUID) (map[string]model.Provenance, error)
+	// Scraped books last fetched before `before`, oldest first.
+	Stale(ctx context.Context, before time.Time, limit int) ([]*model.Book, error)
+	// Write freshly scraped metadata for a book as an Edit by the
+	// scraper, and store its Source (stamped as fetched now).
+	Refresh(ctx context.Context, book *model.Book) (*model.Book, []string, error)
+}
+
+// Narrows down a book search. Zero values don't filter anything.
+type BookFilter struct {
+	// Books must be under at least one of these subjects, or their
+	// descendants
+	Subjects uuid.UUIDs
+	// Books must credit at least one of these authors, in any role
+	Authors uuid.UUIDs
+	// Books must be in one of these languages, without regard to case
+	Languages []string
+	// Books must have been published between these, inclusive. A
+	// book's date only has to overlap the range, so one known only to
+	// be from 2006 is kept by a range starting in June 2006. Books
+	// with no known date are dropped by either bound.
+	PublishedFrom, PublishedTo model.PartialDate
+	// Books must have a mean rating between these, inclusive. Unrated
+	// books are dropped by either bound.
+	MinRating, MaxRating *float32
+}
+
+func (f BookFilter) IsZero() bool {
+	return len(f.Subjects) == 0 && len(f.Authors) == 0 &&
+		len(f.Languages) == 0 &&
+		f.PublishedFrom.IsZero() && f.PublishedTo.IsZero() &&
+		f.MinRating == nil && f.MaxRating == nil
+}
+
+// How many books a search matched, broken down a few ways. Each facet
+// is only made up of the values which have books.
+type BookFacets struct {
+	// By the decade books were published in, earliest first. Values
+	// are the decade's first year, like "1990"; undated books aren't
+	// counted.
+	Decades []FacetCount `json:"decades"`
+	// By subject, most books first. Values are subject IDs. As with
+	// filtering, a book counts towards every ancestor of the subjects
+	// it is filed under.
+	Subjects []FacetCount `json:"subjects"`
+	// By mean rating, in buckets RatingBucket wide, lowest first.
+	// Values are the bottom of the bucket, like "0.8"; the top bucket
+	// includes 1. Unrated books aren't counted.
+	Ratings []FacetCount `json:"ratings"`
+}
+
+// The width of the buckets BookFacets.Ratings are counted in
+const RatingBucket float32 = 0.2
+
+type FacetCount struct {
+	Value string `json:"value"`
+	// Something to show for the value, where it's not enough by itself
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Narrows down an author search. Zero values don't filter anything.
+type AuthorFilter struct {
+	// Authors must be credited on at least one book matching this
+	Books BookFilter
+	// Authors must be credited in one of these roles. This applies to
+	// the same book as Books.
+	Roles []model.ContributorRole
+}
+
+func (f AuthorFilter) IsZero() bool {
+	return f.Books.IsZero() && len(f.Roles) == 0
+}
+
+// The orderings an author's bibliography can be listed in
+type BookSort string
+
+const (
+	BookSortPublished BookSort = "published"
+	BookSortRating    BookSort = "rating"
+)
+
+// Options for paging through an author's books.
+//
+// Cursor is opaque to callers, it should be whatever the previous page
+// handed back (or empty for the first page). A cursor is only valid
+// for the Sort and Descending it was created with.
+type BibliographyOptions struct {
+	Sort       BookSort
+	Descending bool
+	Cursor     string
+	Limit      int
 }
 
 /*************************/
@@ -149,11 +503,46 @@ type BookManager[S comparable] interface {
 type CommentManager[S comparable] interface {
 	CRUDmanager[uuid.UUID, model.Comment]
 	Searcher[S, model.Comment]
+	// Search, but only for comments matching the filter, with a parsed
+	// query. Search is the same as a PlainQuery and an empty filter.
+	SearchFiltered(ctx context.Context, page SearchPage, query Query, filter CommentFilter) ([]SearchResult[model.Comment], []AnyScoreItemer, error)
 	BookComments(ctx context.Context, bookID uuid.UUID) ([]*model.Comment, error)
+	// Comments on a work, including those on any of its editions
+	WorkComments(ctx context.Context, workID uuid.UUID) ([]*model.Comment, error)
+}
+
+// Narrows down a comment search. Zero values don't filter anything.
+type CommentFilter struct {
+	// Comments must be by one of these users
+	Posters uuid.UUIDs
+	// Comments must be on one of these books, or be reviews of the
+	// work one of them is an edition of
+	Books uuid.UUIDs
+	// Leave out replies, keeping only reviews
+	Reviews bool
+	// Reviews must be rated between these, inclusive. Replies have no
+	// rating, so either bound leaves them out.
+	MinRating, MaxRating *float32
+}
+
+func (f CommentFilter) IsZero() bool {
+	return len(f.Posters) == 0 && len(f.Books) == 0 && !f.Reviews &&
+		f.MinRating == nil && f.MaxRating == nil
 }
 
 type UserManager interface {
 	CRUDmanager[uuid.UUID, model.User]
+	// Searches handles, display names and full usernames, leaving out
+	// unlisted users. Only profiles are returned, never whole users.
+	//
+	// Users aren't generic over S like the other searchable managers,
+	// as they are used by handlers which can't be.
+	Searcher[string, model.UserProfile]
+	// Search, with a parsed query. Search is the same as a PlainQuery.
+	SearchQuery(ctx context.Context, page SearchPage, query Query) ([]SearchResult[model.UserProfile], []AnyScoreItemer, error)
+	// Suggests full usernames, matching on their handle. Unlisted
+	// users are left out.
+	Suggester
 	ExistsByGithubID(context.Context, string) (bool, error)
 	GetByGithubID(context.Context, string) (*model.User, error)
 	GetByUsername(context.Context, model.Username) (*model.User, error)
@@ -0,0 +1,245 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+const (
+	googleBooksURL string = "https://www.googleapis.com/books/v1"
+	maxLimit       int    = 40
+
+	// Defaults for GoogleBooksConfig
+	googleBooksRate       float64       = 5
+	googleBooksBurst      int           = 10
+	googleBooksWorkers    int           = 4
+	googleBooksRetries    int           = 3
+	googleBooksRetryDelay time.Duration = time.Second
+	// If Google asks us to wait longer than this, give up instead
+	maxRetryAfter time.Duration = time.Minute
+)
+
+// Google Books volumes API
+type GoogleBooks struct {
+	baseURL string
+	client  *http.Client
+	limiter *RateLimiter
+	workers int
+	retries int
+	// Backoff between retries when Google does not say how long to
+	// wait, doubled on each attempt.
+	retryDelay time.Duration
+}
+
+var _ Provider = (*GoogleBooks)(nil)
+
+// How to talk to Google Books. The zero value uses the public API with
+// sensible limits.
+type GoogleBooksConfig struct {
+	// Something which looks like `https://www.googleapis.com/books/v1`
+	BaseURL string
+	Client  *http.Client
+	// Shared by every request this provider makes. If nil, a limiter
+	// of 5 requests a second (bursting to 10) is used.
+	Limiter *RateLimiter
+	// How many volumes are fetched at once
+	Workers int
+	// How many times a rate limited or unavailable request is retried
+	// before giving up. Negative disables retries.
+	MaxRetries int
+}
+
+// Create a Google Books provider.
+func NewGoogleBooks(cfg GoogleBooksConfig) *GoogleBooks {
+	g := &GoogleBooks{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		client:     cfg.Client,
+		limiter:    cfg.Limiter,
+		workers:    cfg.Workers,
+		retries:    cfg.MaxRetries,
+		retryDelay: googleBooksRetryDelay,
+	}
+	if g.baseURL == "" {
+		g.baseURL = googleBooksURL
+	}
+	if g.client == nil {
+		g.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if g.limiter == nil {
+		g.limiter = NewRateLimiter(googleBooksRate, googleBooksBurst)
+	}
+	if g.workers <= 0 {
+		g.workers = googleBooksWorkers
+	}
+	if g.retries == 0 {
+		g.retries = googleBooksRetries
+	}
+	return g
+}
+
+func (g *GoogleBooks) Name() string {
+	return "googlebooks"
+}
+
+// Google rate limits fairly aggressively, so a 429 (or 503) is retried
+// a few times, waiting as long as we are told to, rather than treated
+// as a failure straight away.
+func (g *GoogleBooks) get(ctx context.Context, url string, v any) error {
+	for attempt := 0; ; attempt++ {
+		if err := g.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := getJSON(ctx, g.client, url, v)
+		var se statusError
+		if !errors.As(err, &se) ||
+			(se.Code != http.StatusTooManyRequests && se.Code != http.StatusServiceUnavailable) {
+			return err
+		}
+		if attempt >= g.retries || se.RetryAfter > maxRetryAfter {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		wait := se.RetryAfter
+		if wait == 0 {
+			// Exponential backoff with a little jitter so the workers
+			// don't all come back at once
+			wait = g.retryDelay << attempt
+			wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Search implements Provider.
+//
+// The search endpoint only hands back abbreviated volume information
+// (notably, only thumbnail images) so each result is fetched again
+// individually.
+func (g *GoogleBooks) Search(ctx context.Context, offset, limit int, query string) ([]Volume, error) {
+	const errorCaller string = "Google Books search"
+	if limit < 0 {
+		return nil, fmt.Errorf("%s: limit `%d` is negative, which is not allowed", errorCaller, limit)
+	}
+
+	var ids []string
+	for start := offset; start < offset+limit; start += maxLimit {
+		q := url.Values{}
+		q.Set("q", query)
+		q.Set("orderBy", "relevance")
+		q.Set("startIndex", strconv.Itoa(start))
+		q.Set("maxResults", strconv.Itoa(min(maxLimit, offset+limit-start)))
+
+		var aux struct {
+			Total int `json:"totalItems"`
+			Items []struct {
+				ID string `json:"id"`
+			} `json:"items"`
+		}
+		if err := g.get(ctx, g.baseURL+"/volumes?"+q.Encode(), &aux); err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		for _, v := range aux.Items {
+			ids = append(ids, v.ID)
+		}
+		if aux.Total == 0 || len(aux.Items) == 0 {
+			break
+		}
+	}
+
+	// Fetch the volumes with a bounded number of workers. The first
+	// failure cancels the rest.
+	volumes := make([]Volume, len(ids))
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(g.workers)
+	for i, id := range ids {
+		eg.Go(func() error {
+			v, err := g.volume(egCtx, id)
+			if err != nil {
+				return err
+			}
+			volumes[i] = *v
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return volumes, nil
+}
+
+// ISBN implements Provider.
+func (g *GoogleBooks) ISBN(ctx context.Context, isbn model.ISBN) (*Volume, error) {
+	v, err := g.Search(ctx, 0, 1, "isbn:"+isbn.String())
+	if err != nil {
+		return nil, err
+	} else if len(v) == 0 {
+		return nil, repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("Google Books has no volume with ISBN `%v`", isbn),
+		}
+	}
+	return &v[0], nil
+}
+
+// Fetch a single volume by its Google Books ID
+func (g *GoogleBooks) volume(ctx context.Context, id string) (*Volume, error) {
+	var aux struct {
+		VolumeInfo volumeInfo `json:"volumeInfo"`
+	}
+	if err := g.get(ctx, g.baseURL+"/volumes/"+url.PathEscape(id), &aux); err != nil {
+		return nil, err
+	}
+
+	v := aux.VolumeInfo
+	vol := &Volume{
+		Provider:    g.Name(),
+		ProviderID:  id,
+		Title:       v.Title,
+		Subtitle:    v.Subtitle,
+		Description: v.Description,
+		Published:   v.PublishedDate,
+		ISBNs:       extractISBN(v.IndustryIdentifiers),
+		Categories:  v.Categories,
+		Publisher:   v.Publisher,
+		Pages:       v.PageCount,
+		Language:    v.Language,
+	}
+	// Translators and the like are sometimes listed as authors with
+	// their role in brackets
+	for _, credit := range v.Authors {
+		name, role := model.ParseCredit(credit)
+		vol.Authors = append(vol.Authors, VolumeAuthor{Name: name, Role: role})
+	}
+	for _, l := range []string{
+		v.ImageLinks.ExtraLarge, v.ImageLinks.Large,
+		v.ImageLinks.Medium, v.ImageLinks.Small,
+	} {
+		if l != "" {
+			vol.Covers = append(vol.Covers, l)
+		}
+	}
+	for _, l := range []string{v.ImageLinks.Thumbnail, v.ImageLinks.SmallThumbnail} {
+		if l != "" {
+			vol.Thumbnails = append(vol.Thumbnails, l)
+		}
+	}
+	return vol, nil
+}
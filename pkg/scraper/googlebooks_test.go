@@ -0,0 +1,222 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A stand-in for the Books API. Searches return `results` volumes,
+// named vol0, vol1, ...; fetching a volume gives back `volumes[id]` if
+// set, or a volume with just a title.
+type fakeBooksAPI struct {
+	srv *httptest.Server
+
+	results int
+	volumes map[string]string
+	// Answer this many requests with a 429 before behaving, sending
+	// retryAfter as the Retry-After header if it is set
+	throttle   int32
+	retryAfter string
+	// How long fetching a volume takes. If block is set, it never
+	// finishes and instead waits for the client to go away.
+	delay time.Duration
+	block bool
+
+	requests    atomic.Int32
+	inflight    atomic.Int32
+	maxInflight atomic.Int32
+	// Closed on the first volume fetch
+	started chan struct{}
+	once    atomic.Bool
+}
+
+func newFakeBooksAPI(t *testing.T, results int) *fakeBooksAPI {
+	f := &fakeBooksAPI{
+		results: results,
+		volumes: map[string]string{},
+		started: make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/volumes", func(w http.ResponseWriter, r *http.Request) {
+		if f.throttled(w) {
+			return
+		}
+		var items []string
+		for i := range f.results {
+			items = append(items, fmt.Sprintf(`{"id": "vol%d"}`, i))
+		}
+		fmt.Fprintf(w, `{"totalItems": %d, "items": [%s]}`,
+			f.results, strings.Join(items, ","))
+	})
+	mux.HandleFunc("/volumes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if f.throttled(w) {
+			return
+		}
+		if !f.once.Swap(true) {
+			close(f.started)
+		}
+		n := f.inflight.Add(1)
+		defer f.inflight.Add(-1)
+		for m := f.maxInflight.Load(); n > m && !f.maxInflight.CompareAndSwap(m, n); m = f.maxInflight.Load() {
+		}
+		if f.block {
+			<-r.Context().Done()
+			return
+		}
+		time.Sleep(f.delay)
+
+		id := r.PathValue("id")
+		if v, ok := f.volumes[id]; ok {
+			w.Write([]byte(v))
+			return
+		}
+		fmt.Fprintf(w, `{"volumeInfo": {"title": "Volume %s"}}`, id)
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeBooksAPI) throttled(w http.ResponseWriter) bool {
+	f.requests.Add(1)
+	for {
+		n := atomic.LoadInt32(&f.throttle)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&f.throttle, n, n-1) {
+			break
+		}
+	}
+	if f.retryAfter != "" {
+		w.Header().Set("Retry-After", f.retryAfter)
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	return true
+}
+
+// A Google Books provider pointed at the fake, with no rate limit
+// and very short backoff.
+func (f *fakeBooksAPI) provider(cfg GoogleBooksConfig) *GoogleBooks {
+	cfg.BaseURL = f.srv.URL
+	if cfg.Limiter == nil {
+		cfg.Limiter = NewRateLimiter(0, 1)
+	}
+	g := NewGoogleBooks(cfg)
+	g.retryDelay = time.Millisecond
+	return g
+}
+
+func TestGoogleBooksSearch(t *testing.T) {
+	f := newFakeBooksAPI(t, 12)
+	f.delay = 10 * time.Millisecond
+	g := f.provider(GoogleBooksConfig{Workers: 3})
+
+	volumes, err := g.Search(t.Context(), 0, 12, "oliver twist")
+	require.NoError(t, err)
+	require.Len(t, volumes, 12)
+	for i, v := range volumes {
+		assert.Equal(t, fmt.Sprintf("vol%d", i), v.ProviderID, "volumes should stay in search order")
+		assert.Equal(t, fmt.Sprintf("Volume vol%d", i), v.Title)
+	}
+	assert.LessOrEqual(t, f.maxInflight.Load(), int32(3), "more volumes fetched at once than there are workers")
+	assert.Greater(t, f.maxInflight.Load(), int32(1), "volumes should be fetched concurrently")
+}
+
+func TestGoogleBooksRetryCap(t *testing.T) {
+	f := newFakeBooksAPI(t, 1)
+	f.throttle = 1000
+	g := f.provider(GoogleBooksConfig{MaxRetries: 2})
+
+	_, err := g.Search(t.Context(), 0, 1, "oliver twist")
+	var se statusError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, http.StatusTooManyRequests, se.Code)
+	assert.Equal(t, int32(3), f.requests.Load(), "one attempt and two retries")
+}
+
+func TestGoogleBooksRetryAfter(t *testing.T) {
+	f := newFakeBooksAPI(t, 1)
+	f.throttle = 1
+	f.retryAfter = "1"
+	g := f.provider(GoogleBooksConfig{})
+
+	start := time.Now()
+	volumes, err := g.Search(t.Context(), 0, 1, "oliver twist")
+	require.NoError(t, err)
+	assert.Len(t, volumes, 1)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "Retry-After was not respected")
+}
+
+func TestGoogleBooksRetryAfterTooLong(t *testing.T) {
+	f := newFakeBooksAPI(t, 1)
+	f.throttle = 1
+	f.retryAfter = "3600"
+	g := f.provider(GoogleBooksConfig{})
+
+	_, err := g.Search(t.Context(), 0, 1, "oliver twist")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), f.requests.Load())
+}
+
+func TestGoogleBooksCancel(t *testing.T) {
+	f := newFakeBooksAPI(t, 20)
+	f.block = true
+	g := f.provider(GoogleBooksConfig{Workers: 2})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		<-f.started
+		cancel()
+	}()
+
+	done := make(chan error)
+	go func() {
+		_, err := g.Search(ctx, 0, 20, "oliver twist")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("search did not stop when cancelled")
+	}
+	// The search and at most one volume per worker; nothing after
+	// the cancellation
+	assert.LessOrEqual(t, f.requests.Load(), int32(3))
+}
+
+func TestGoogleBooksRateLimit(t *testing.T) {
+	f := newFakeBooksAPI(t, 5)
+	// One request every 50ms, with no burst to speak of
+	g := f.provider(GoogleBooksConfig{
+		Limiter: NewRateLimiter(20, 1),
+		Workers: 5,
+	})
+
+	start := time.Now()
+	_, err := g.Search(t.Context(), 0, 5, "oliver twist")
+	require.NoError(t, err)
+	// The search and five volumes: the first is free, the rest wait
+	assert.Equal(t, int32(6), f.requests.Load())
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond, "requests were not rate limited")
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := NewRateLimiter(0.001, 1)
+	require.NoError(t, l.Wait(t.Context()))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"cloud.google.com/go/civil"
 	"github.com/google/uuid"
 	"github.com/whit-colm/itsc-4155-project/pkg/model"
 	"github.com/whit-colm/itsc-4155-project/pkg/repository"
@@ -49,6 +48,10 @@ type volumeInfo struct {
 	PublishedDate       string               `json:"publishedDate"`
 	Description         string               `json:"description"`
 	IndustryIdentifiers []industryIdentifier `json:"industryIdentifiers"`
+	Categories          []string             `json:"categories"`
+	Publisher           string               `json:"publisher"`
+	PageCount           int                  `json:"pageCount"`
+	Language            string               `json:"language"`
 	ImageLinks          imageLinks           `json:"imageLinks"`
 }
 
@@ -141,7 +144,8 @@ func storeImage(ctx context.Context, imageURL string, blobManager repository.Blo
 		return uuid.Nil, fmt.Errorf("failed to store image: %v", err)
 	}
 
-	return ref, nil
+	// An image which was already stored keeps its ID
+	return blob.ID, nil
 }
 
 // StoreBook saves the data into the database
@@ -169,16 +173,30 @@ func getFirstAuthor(authors []string) string {
 	return "Unknown Author"
 }
 
-// Converts a string date to civil.Date using different layouts
-func parsePublishedDate(dateStr string) civil.Date {
-	layouts := []string{"2006-01-02", "2006-01", "2006"}
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, dateStr); err == nil {
-			return civil.DateOf(t)
+// Converts a string date to a model.PartialDate using different
+// layouts, keeping only as much of it as the provider gave
+func parsePublishedDate(dateStr string) model.PartialDate {
+	layouts := []struct {
+		layout    string
+		precision model.DatePrecision
+	}{
+		{"2006-01-02", model.PrecisionDay},
+		{"2006-01", model.PrecisionMonth},
+		{"2006", model.PrecisionYear},
+		// Open Library dates are free text, these are the most common
+		{"January 2, 2006", model.PrecisionDay},
+		{"Jan 2, 2006", model.PrecisionDay},
+		{"January 2006", model.PrecisionMonth},
+		{"Jan 2006", model.PrecisionMonth},
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l.layout, dateStr); err == nil {
+			return model.PartialDateOf(t, l.precision)
 		}
 	}
-	fmt.Printf("Warning: could not parse date: %s\n", dateStr)
-	return civil.Date{}
+	// Providers often have no date, or one in a shape not worth
+	// guessing at, so this is unremarkable
+	return model.PartialDate{}
 }
 
 // Splits a full name into given and family name
@@ -0,0 +1,338 @@
+package scraper
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+)
+
+const (
+	openLibraryURL       string = "https://openlibrary.org"
+	openLibraryCoversURL string = "https://covers.openlibrary.org"
+
+	// Defaults for OpenLibraryConfig. Open Library asks that anonymous
+	// clients stay around one request a second.
+	openLibraryRate  float64 = 1
+	openLibraryBurst int     = 3
+)
+
+// Open Library (https://openlibrary.org/developers/api)
+//
+// Open Library is a lot more forthcoming than Google Books about who
+// an author is: every author has a stable key (e.g. `OL23919A`), which
+// is attached to the authors we create so they can be matched later.
+type OpenLibrary struct {
+	baseURL   string
+	coversURL string
+	client    *http.Client
+	limiter   *RateLimiter
+}
+
+var _ Provider = (*OpenLibrary)(nil)
+
+// How to talk to Open Library. The zero value uses the public API with
+// sensible limits.
+type OpenLibraryConfig struct {
+	// Something which looks like `https://openlibrary.org`
+	BaseURL string
+	// Something which looks like `https://covers.openlibrary.org`
+	CoversURL string
+	Client    *http.Client
+	// Shared by every request this provider makes. If nil, a limiter
+	// of 1 request a second (bursting to 3) is used.
+	Limiter *RateLimiter
+}
+
+// Create an Open Library provider.
+func NewOpenLibrary(cfg OpenLibraryConfig) *OpenLibrary {
+	o := &OpenLibrary{
+		baseURL:   strings.TrimSuffix(cfg.BaseURL, "/"),
+		coversURL: strings.TrimSuffix(cfg.CoversURL, "/"),
+		client:    cfg.Client,
+		limiter:   cfg.Limiter,
+	}
+	if o.baseURL == "" {
+		o.baseURL = openLibraryURL
+	}
+	if o.coversURL == "" {
+		o.coversURL = openLibraryCoversURL
+	}
+	if o.client == nil {
+		o.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if o.limiter == nil {
+		o.limiter = NewRateLimiter(openLibraryRate, openLibraryBurst)
+	}
+	return o
+}
+
+func (o *OpenLibrary) Name() string {
+	return "openlibrary"
+}
+
+// Every API request goes through the limiter
+func (o *OpenLibrary) get(ctx context.Context, url string, v any) error {
+	if err := o.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return getJSON(ctx, o.client, url, v)
+}
+
+// Keys look like `/authors/OL23919A`, we only keep the last part
+func olKeyID(key string) string {
+	return key[strings.LastIndex(key, "/")+1:]
+}
+
+// Text fields (descriptions, mostly) are either a plain string or an
+// object of the form {"type": "/type/text", "value": "..."}.
+type olText string
+
+func (t *olText) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = olText(s)
+		return nil
+	}
+	var v struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*t = olText(v.Value)
+	return nil
+}
+
+type olKey struct {
+	Key string `json:"key"`
+}
+
+// Cover URLs for an Open Library cover ID, cover first then thumbnail
+func (o *OpenLibrary) coverURLs(id int) (string, string) {
+	return fmt.Sprintf("%v/b/id/%d-L.jpg", o.coversURL, id),
+		fmt.Sprintf("%v/b/id/%d-M.jpg", o.coversURL, id)
+}
+
+// Take the first valid ISBN of each type. Even a single edition
+// sometimes carries more than one (or junk), and a book only gets one
+// of each.
+func pickISBNs(candidates ...string) []model.ISBN {
+	var isbns []model.ISBN
+	seen := map[model.IsbnVersion]bool{}
+	for _, c := range candidates {
+		i, err := model.NewISBN(c)
+		if err != nil || seen[i.Version()] {
+			continue
+		}
+		seen[i.Version()] = true
+		isbns = append(isbns, i)
+	}
+	return isbns
+}
+
+// Search implements Provider.
+//
+// Search results are works, which cover every edition ever printed.
+// A book is one edition, so each work is pinned to the edition Open
+// Library matched the query against and only that edition's ISBNs,
+// date and key are used. Works without any edition are skipped, as
+// there would be nothing to refresh them from later.
+func (o *OpenLibrary) Search(ctx context.Context, offset, limit int, query string) ([]Volume, error) {
+	const errorCaller string = "Open Library search"
+	if limit < 0 {
+		return nil, fmt.Errorf("%s: limit `%d` is negative, which is not allowed", errorCaller, limit)
+	}
+
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("fields", "key,title,subtitle,author_name,author_key,cover_i,subject,"+
+		"editions,editions.key,editions.title,editions.subtitle,editions.isbn,editions.publish_date,editions.cover_i")
+
+	var aux struct {
+		Docs []struct {
+			Key        string   `json:"key"`
+			Title      string   `json:"title"`
+			Subtitle   string   `json:"subtitle"`
+			AuthorName []string `json:"author_name"`
+			AuthorKey  []string `json:"author_key"`
+			CoverID    int      `json:"cover_i"`
+			Subject    []string `json:"subject"`
+			Editions   struct {
+				Docs []struct {
+					Key         string   `json:"key"`
+					Title       string   `json:"title"`
+					Subtitle    string   `json:"subtitle"`
+					ISBN        []string `json:"isbn"`
+					PublishDate []string `json:"publish_date"`
+					CoverID     int      `json:"cover_i"`
+				} `json:"docs"`
+			} `json:"editions"`
+		} `json:"docs"`
+	}
+	if err := o.get(ctx, o.baseURL+"/search.json?"+q.Encode(), &aux); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	volumes := make([]Volume, 0, len(aux.Docs))
+	for _, d := range aux.Docs {
+		if len(d.Editions.Docs) == 0 {
+			continue
+		}
+		ed := d.Editions.Docs[0]
+		v := Volume{
+			Provider:   o.Name(),
+			ProviderID: olKeyID(ed.Key),
+			Title:      cmp.Or(ed.Title, d.Title),
+			Subtitle:   cmp.Or(ed.Subtitle, d.Subtitle),
+			ISBNs:      pickISBNs(ed.ISBN...),
+			Categories: d.Subject,
+		}
+		if len(ed.PublishDate) > 0 {
+			v.Published = ed.PublishDate[0]
+		}
+		for i, name := range d.AuthorName {
+			a := VolumeAuthor{Name: name}
+			if i < len(d.AuthorKey) {
+				a.ExtIDs = []model.AuthorIDs{{
+					Type: model.AuthorIDOpenLibrary,
+					ID:   d.AuthorKey[i],
+				}}
+			}
+			v.Authors = append(v.Authors, a)
+		}
+		if id := cmp.Or(ed.CoverID, d.CoverID); id > 0 {
+			cover, thumb := o.coverURLs(id)
+			v.Covers, v.Thumbnails = []string{cover}, []string{thumb}
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// ISBN implements Provider.
+//
+// An edition often has very little on it, with the description,
+// subjects and even authors living on the work it belongs to instead,
+// so the work is fetched as well when the edition is lacking.
+func (o *OpenLibrary) ISBN(ctx context.Context, isbn model.ISBN) (*Volume, error) {
+	const errorCaller string = "Open Library ISBN"
+	var ed struct {
+		Key         string   `json:"key"`
+		Title       string   `json:"title"`
+		Subtitle    string   `json:"subtitle"`
+		PublishDate string   `json:"publish_date"`
+		Description olText   `json:"description"`
+		ISBN10      []string `json:"isbn_10"`
+		ISBN13      []string `json:"isbn_13"`
+		Covers      []int    `json:"covers"`
+		Authors     []olKey  `json:"authors"`
+		Works       []olKey  `json:"works"`
+		Subjects    []string `json:"subjects"`
+		Publishers  []string `json:"publishers"`
+		Pages       int      `json:"number_of_pages"`
+		Languages   []olKey  `json:"languages"`
+		Format      string   `json:"physical_format"`
+		Series      []string `json:"series"`
+		// Everyone credited other than the authors, with free text
+		// roles such as "Illustrator"
+		Contributors []struct {
+			Role string `json:"role"`
+			Name string `json:"name"`
+		} `json:"contributors"`
+	}
+	if err := o.get(ctx, o.baseURL+"/isbn/"+isbn.String()+".json", &ed); err != nil {
+		return nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+
+	authorKeys := make([]string, 0, len(ed.Authors))
+	for _, a := range ed.Authors {
+		authorKeys = append(authorKeys, a.Key)
+	}
+	if len(ed.Works) > 0 && (len(authorKeys) == 0 || ed.Description == "" || len(ed.Subjects) == 0) {
+		var work struct {
+			Description olText `json:"description"`
+			Authors     []struct {
+				Author olKey `json:"author"`
+			} `json:"authors"`
+			Subjects []string `json:"subjects"`
+		}
+		if err := o.get(ctx, o.baseURL+ed.Works[0].Key+".json", &work); err != nil {
+			return nil, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+		if ed.Description == "" {
+			ed.Description = work.Description
+		}
+		if len(ed.Subjects) == 0 {
+			ed.Subjects = work.Subjects
+		}
+		if len(authorKeys) == 0 {
+			for _, a := range work.Authors {
+				authorKeys = append(authorKeys, a.Author.Key)
+			}
+		}
+	}
+
+	v := &Volume{
+		Provider:    o.Name(),
+		ProviderID:  olKeyID(ed.Key),
+		Title:       ed.Title,
+		Subtitle:    ed.Subtitle,
+		Description: string(ed.Description),
+		Published:   ed.PublishDate,
+		ISBNs:       pickISBNs(append(ed.ISBN13, ed.ISBN10...)...),
+		Categories:  ed.Subjects,
+		Format:      model.ParseEditionFormat(ed.Format),
+		Pages:       ed.Pages,
+	}
+	if len(ed.Series) > 0 {
+		v.Series = ed.Series[0]
+	}
+	if len(ed.Publishers) > 0 {
+		v.Publisher = ed.Publishers[0]
+	}
+	// Languages are keys like `/languages/eng`
+	if len(ed.Languages) > 0 {
+		v.Language = olKeyID(ed.Languages[0].Key)
+	}
+	for _, key := range authorKeys {
+		var a struct {
+			Name string `json:"name"`
+		}
+		if err := o.get(ctx, o.baseURL+key+".json", &a); err != nil {
+			return nil, fmt.Errorf("%v: author `%v`: %w", errorCaller, key, err)
+		}
+		v.Authors = append(v.Authors, VolumeAuthor{
+			Name: a.Name,
+			ExtIDs: []model.AuthorIDs{{
+				Type: model.AuthorIDOpenLibrary,
+				ID:   olKeyID(key),
+			}},
+		})
+	}
+	// Open Library has no IDs for these, only names. Roles we don't
+	// keep track of, like "Cover design", are left out.
+	for _, c := range ed.Contributors {
+		if role := model.ParseContributorRole(c.Role); role != "" && c.Name != "" {
+			v.Authors = append(v.Authors, VolumeAuthor{Name: c.Name, Role: role})
+		}
+	}
+	// Negative cover IDs are placeholders for deleted covers
+	for _, c := range ed.Covers {
+		if c > 0 {
+			cover, thumb := o.coverURLs(c)
+			v.Covers, v.Thumbnails = []string{cover}, []string{thumb}
+			break
+		}
+	}
+	return v, nil
+}
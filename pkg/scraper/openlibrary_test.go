@@ -0,0 +1,188 @@
+package scraper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// A stand-in for both openlibrary.org and covers.openlibrary.org with
+// just enough of Fantastic Mr Fox in it.
+func fakeOpenLibrary(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search.json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "fantastic mr fox", r.URL.Query().Get("q"))
+		// The second work has no editions, so cannot be used
+		w.Write([]byte(`{"numFound": 2, "docs": [{
+			"key": "/works/OL45804W",
+			"title": "Fantastic Mr Fox",
+			"author_name": ["Roald Dahl"],
+			"author_key": ["OL34184A"],
+			"cover_i": 1,
+			"subject": ["Foxes", "Juvenile fiction"],
+			"editions": {"numFound": 1, "start": 0, "docs": [{
+				"key": "/books/OL7353617M",
+				"title": "Fantastic Mr. Fox",
+				"isbn": ["not an isbn", "9780140328721", "0140328726"],
+				"publish_date": ["October 1, 1988"],
+				"cover_i": 8739161
+			}]}
+		}, {
+			"key": "/works/OL1W",
+			"title": "Fantastic Mr Fox (Abridged)",
+			"editions": {"numFound": 0, "start": 0, "docs": []}
+		}]}`))
+	})
+	mux.HandleFunc("/isbn/9780140328721.json", func(w http.ResponseWriter, r *http.Request) {
+		// Like the real thing, the edition has no authors and defers
+		// to the work.
+		w.Write([]byte(`{
+			"key": "/books/OL7353617M",
+			"title": "Fantastic Mr. Fox",
+			"publish_date": "October 1, 1988",
+			"isbn_10": ["0140328726"],
+			"isbn_13": ["9780140328721"],
+			"covers": [-1, 8739161],
+			"works": [{"key": "/works/OL45804W"}],
+			"publishers": ["Puffin Books"],
+			"number_of_pages": 96,
+			"languages": [{"key": "/languages/eng"}],
+			"physical_format": "Paperback",
+			"series": ["Puffin Modern Classics -- 12"],
+			"contributors": [
+				{"role": "Illustrator", "name": "Quentin Blake"},
+				{"role": "Cover design", "name": "Somebody"}
+			]
+		}`))
+	})
+	mux.HandleFunc("/works/OL45804W.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"description": {"type": "/type/text", "value": "The Foxes are in trouble."},
+			"authors": [{"author": {"key": "/authors/OL34184A"}}],
+			"subjects": ["Foxes"]
+		}`))
+	})
+	mux.HandleFunc("/authors/OL34184A.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "Roald Dahl"}`))
+	})
+	mux.HandleFunc("/b/id/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte{0xff, 0xd8, 0xff})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// An Open Library provider pointed at the fake, with no rate limit
+func openLibraryAt(srv *httptest.Server) *OpenLibrary {
+	return NewOpenLibrary(OpenLibraryConfig{
+		BaseURL:   srv.URL,
+		CoversURL: srv.URL,
+		Limiter:   NewRateLimiter(0, 1),
+	})
+}
+
+func TestOpenLibrarySearch(t *testing.T) {
+	srv := fakeOpenLibrary(t)
+	ol := openLibraryAt(srv)
+
+	volumes, err := ol.Search(t.Context(), 0, 10, "fantastic mr fox")
+	require.NoError(t, err)
+	require.Len(t, volumes, 1)
+
+	v := volumes[0]
+	assert.Equal(t, "openlibrary", v.Provider)
+	// Everything comes from the matched edition, not the work
+	assert.Equal(t, "OL7353617M", v.ProviderID)
+	assert.Equal(t, "Fantastic Mr. Fox", v.Title)
+	assert.Equal(t, "October 1, 1988", v.Published)
+	assert.Equal(t, []model.ISBN{
+		model.MustNewISBN("9780140328721", model.ISBN13),
+		model.MustNewISBN("0140328726", model.ISBN10),
+	}, v.ISBNs)
+	assert.Equal(t, []VolumeAuthor{{
+		Name:   "Roald Dahl",
+		ExtIDs: []model.AuthorIDs{{Type: model.AuthorIDOpenLibrary, ID: "OL34184A"}},
+	}}, v.Authors)
+	assert.Equal(t, []string{srv.URL + "/b/id/8739161-L.jpg"}, v.Covers)
+	assert.Equal(t, []string{srv.URL + "/b/id/8739161-M.jpg"}, v.Thumbnails)
+}
+
+func TestOpenLibraryISBN(t *testing.T) {
+	srv := fakeOpenLibrary(t)
+	ol := openLibraryAt(srv)
+
+	v, err := ol.ISBN(t.Context(), model.MustNewISBN("9780140328721", model.ISBN13))
+	require.NoError(t, err)
+	assert.Equal(t, "OL7353617M", v.ProviderID)
+	assert.Equal(t, "Fantastic Mr. Fox", v.Title)
+	assert.Equal(t, "The Foxes are in trouble.", v.Description)
+	assert.Equal(t, "1988-10-01", parsePublishedDate(v.Published).String())
+	assert.Equal(t, []string{"Foxes"}, v.Categories)
+	require.Len(t, v.Authors, 2)
+	assert.Equal(t, "Roald Dahl", v.Authors[0].Name)
+	assert.Equal(t, "OL34184A", v.Authors[0].ExtIDs[0].ID)
+	// Roles we don't know are left out
+	assert.Equal(t, VolumeAuthor{Name: "Quentin Blake", Role: model.RoleIllustrator}, v.Authors[1])
+	// The placeholder -1 cover is skipped
+	assert.Equal(t, []string{srv.URL + "/b/id/8739161-L.jpg"}, v.Covers)
+	assert.Equal(t, model.FormatPaperback, v.Format)
+	assert.Equal(t, "Puffin Books", v.Publisher)
+	assert.Equal(t, 96, v.Pages)
+	assert.Equal(t, "eng", v.Language)
+}
+
+func TestOpenLibraryISBNNotFound(t *testing.T) {
+	srv := fakeOpenLibrary(t)
+	ol := openLibraryAt(srv)
+
+	_, err := ol.ISBN(t.Context(), model.MustNewISBN("9780375822070", model.ISBN13))
+	assert.True(t, errors.Is(err, repository.ErrNotFound))
+}
+
+func TestScrapeISBNOpenLibrary(t *testing.T) {
+	ctx := t.Context()
+	srv := fakeOpenLibrary(t)
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, openLibraryAt(srv))
+	isbn := model.MustNewISBN("9780140328721", model.ISBN13)
+
+	n, err := scrp.ScrapeISBN(ctx, isbn)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	book, err := repo.Book.GetByISBN(ctx, isbn)
+	require.NoError(t, err)
+	require.Len(t, book.AuthorIDs, 1)
+	require.NotNil(t, book.Series)
+	assert.Equal(t, "Puffin Modern Classics", book.Series.Name)
+	assert.Equal(t, 12.0, book.Series.Position)
+
+	// The author should be findable by their Open Library key, and a
+	// second scrape (by search this time) should reuse them rather
+	// than creating a duplicate.
+	author, exists, err := repo.Author.ExistsByExtID(ctx, model.AuthorIDs{
+		Type: model.AuthorIDOpenLibrary, ID: "OL34184A",
+	})
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Equal(t, book.AuthorIDs[0], author.ID)
+
+	n, err = scrp.ScrapeISBN(ctx, isbn)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n, "book already exists")
+
+	n, err = scrp.ScrapeISBN(ctx, model.MustNewISBN("9780375822070", model.ISBN13))
+	require.NoError(t, err)
+	assert.Equal(t, -1, n, "no provider has this book")
+}
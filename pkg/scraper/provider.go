@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// A Provider is an external source of book metadata, such as Google
+// Books or Open Library. The scraper asks each registered provider in
+// turn and stores whatever comes back.
+type Provider interface {
+	// A short, stable name for the provider, e.g. `openlibrary`
+	Name() string
+	// Find volumes matching a free-text query. An empty result means
+	// the provider has nothing (more) for this query.
+	Search(ctx context.Context, offset, limit int, query string) ([]Volume, error)
+	// Look up a single volume by ISBN. If the provider does not know
+	// of the ISBN, this returns repository.ErrNotFound.
+	ISBN(ctx context.Context, isbn model.ISBN) (*Volume, error)
+}
+
+// A Volume is book metadata as a provider reports it, before it has
+// been matched against anything in the datastore.
+type Volume struct {
+	// The Name() of the provider this came from, and its own ID for
+	// the volume.
+	Provider   string
+	ProviderID string
+
+	Title       string
+	Subtitle    string
+	Description string
+	// The publication date as the provider wrote it, which varies
+	// wildly in format. Use parsePublishedDate.
+	Published  string
+	ISBNs      []model.ISBN
+	Authors    []VolumeAuthor
+	Categories []string
+
+	// Edition details, where the provider has them
+	Format    model.EditionFormat
+	Publisher string
+	Pages     int
+	Language  string
+	// The series and position as the provider wrote it, e.g. "The
+	// Expanse ; 3". Use model.ParseSeries.
+	Series string
+
+	// Image URLs, best (largest) first
+	Covers     []string
+	Thumbnails []string
+}
+
+// An author credit on a volume. Providers which know their own
+// identifier for the author should include it, so the same person can
+// be matched across books even when their name is written differently.
+type VolumeAuthor struct {
+	Name   string
+	ExtIDs []model.AuthorIDs
+	// What they did for the volume; empty for its authors
+	Role model.ContributorRole
+}
+
+// GET a URL and decode its JSON body into v. A 404 is reported as
+// repository.ErrNotFound, any other non-200 status is an error.
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return repository.Err{
+			Code: repository.ErrNotFound,
+			Err:  fmt.Errorf("GET %v: %v", url, resp.Status),
+		}
+	default:
+		return statusError{
+			URL:        url,
+			Code:       resp.StatusCode,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// A provider answered with a status we didn't expect
+type statusError struct {
+	URL  string
+	Code int
+	// How long the provider asked us to wait before trying again, if
+	// it said.
+	RetryAfter time.Duration
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("GET %v: unexpected status %d %v",
+		e.URL, e.Code, http.StatusText(e.Code))
+}
+
+// Parse a Retry-After header, which is either a number of seconds or
+// an HTTP date. Returns 0 if it is missing or nonsense.
+func retryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil {
+		return max(time.Duration(s)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
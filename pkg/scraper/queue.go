@@ -0,0 +1,165 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+const (
+	// How long a finished scrape is considered fresh; identical
+	// queries within this window reuse the finished job rather than
+	// scraping again.
+	ScrapeFreshness time.Duration = 15 * time.Minute
+
+	// How often idle workers check for new jobs. Jobs queued through
+	// this Queue wake a worker immediately, this only matters for
+	// jobs queued by other instances of the backend.
+	queuePollInterval time.Duration = 2 * time.Second
+	// How long a job may run before it is assumed its worker died and
+	// it is given to someone else
+	jobLease time.Duration = 5 * time.Minute
+	// How long a job may run before it is given up on. Well within the
+	// lease, so a job is never claimed again while it is still running
+	// and its outcome has time to be recorded.
+	jobTimeout time.Duration = 3 * time.Minute
+	// How long recording a job's outcome may take
+	jobRecordTimeout time.Duration = 10 * time.Second
+	// Failed jobs wait jobBackoff, then twice that, and so on, up to
+	// maxJobBackoff.
+	jobBackoff    time.Duration = 30 * time.Second
+	maxJobBackoff time.Duration = 30 * time.Minute
+)
+
+// Queue runs scrape jobs in the background with a fixed number of
+// workers, so that searches don't have to wait on external providers.
+type Queue struct {
+	jobs    repository.ScrapeJobManager
+	scraper repository.BookScraper
+	workers int
+
+	poll    time.Duration
+	lease   time.Duration
+	timeout time.Duration
+	backoff time.Duration
+	wake    chan struct{}
+}
+
+var _ repository.ScrapeQueue = (*Queue)(nil)
+
+func NewQueue(jobs repository.ScrapeJobManager, scraper repository.BookScraper, workers int) *Queue {
+	return &Queue{
+		jobs:    jobs,
+		scraper: scraper,
+		workers: max(workers, 1),
+		poll:    queuePollInterval,
+		lease:   jobLease,
+		timeout: jobTimeout,
+		backoff: jobBackoff,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Enqueue implements repository.ScrapeQueue.
+func (q *Queue) Enqueue(ctx context.Context, query string, offset, limit int) (*model.ScrapeJob, error) {
+	job, created, err := q.jobs.Enqueue(ctx, &model.ScrapeJob{
+		Query:  query,
+		Offset: offset,
+		Limit:  limit,
+	}, ScrapeFreshness)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		// Nudge a worker if one is idle, otherwise the next one to
+		// finish will pick it up
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return job, nil
+}
+
+// Run the workers until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range q.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		for q.runOne(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.poll):
+		}
+	}
+}
+
+// Claim and run a single job. Returns whether there was one.
+func (q *Queue) runOne(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	job, err := q.jobs.Claim(ctx, q.lease)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false
+	} else if err != nil {
+		log.Printf("scrape queue: %v", err)
+		return false
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+	added, err := q.scraper.Scrape(jobCtx, job.Offset, job.Limit, job.Query)
+
+	// The job's outcome should be recorded even if we are shutting
+	// down, otherwise it sits as running until its lease expires.
+	recordCtx, cancelRecord := context.WithTimeout(context.WithoutCancel(ctx), jobRecordTimeout)
+	defer cancelRecord()
+	if err != nil && ctx.Err() != nil {
+		// Shutting down isn't the job's fault, so it goes back as it was
+		if rerr := q.jobs.Release(recordCtx, job.ID, job.Attempts); rerr != nil {
+			log.Printf("scrape queue: job %v: %v", job.ID, rerr)
+		}
+		return true
+	} else if err != nil {
+		if ferr := q.jobs.Fail(recordCtx, job.ID, job.Attempts, err, q.backoffFor(job.Attempts)); ferr != nil {
+			log.Printf("scrape queue: job %v: %v", job.ID, ferr)
+		}
+		return true
+	}
+	// -1 just means there was nothing to find
+	if err = q.jobs.Complete(recordCtx, job.ID, job.Attempts, max(added, 0)); err != nil {
+		log.Printf("scrape queue: job %v: %v", job.ID, err)
+	}
+	return true
+}
+
+// Exponential backoff for a job which has failed `attempts` times
+func (q *Queue) backoffFor(attempts int) time.Duration {
+	d := q.backoff
+	for range max(attempts-1, 0) {
+		d *= 2
+		if d >= maxJobBackoff {
+			return maxJobBackoff
+		}
+	}
+	return d
+}
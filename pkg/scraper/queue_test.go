@@ -0,0 +1,177 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+)
+
+// A BookScraper which fails the first `fails` scrapes and then adds
+// `added` books. If `interrupt` is set, it's called during the scrape
+// instead, as though the queue were shut down.
+type fakeScraper struct {
+	fails     int
+	added     int
+	calls     int
+	interrupt func()
+}
+
+func (f *fakeScraper) Scrape(ctx context.Context, offset, limit int, query string) (int, error) {
+	f.calls++
+	if f.interrupt != nil {
+		f.interrupt()
+		return 0, ctx.Err()
+	}
+	if f.calls <= f.fails {
+		return 0, errors.New("provider fell over")
+	}
+	return f.added, nil
+}
+
+func (f *fakeScraper) ScrapeISBN(ctx context.Context, isbn model.ISBN) (int, error) {
+	return f.Scrape(ctx, 0, 1, isbn.String())
+}
+
+func newTestQueue(s *fakeScraper) (*Queue, *mockdatastore.ScrapeJobRepo) {
+	jobs := mockdatastore.NewInMemoryScrapeJobManager()
+	q := NewQueue(jobs, s, 1)
+	// Retry immediately, the tests have better things to do
+	q.backoff = 0
+	return q, jobs
+}
+
+func TestQueueDeduplicates(t *testing.T) {
+	q, _ := newTestQueue(&fakeScraper{})
+
+	a, err := q.Enqueue(t.Context(), "fantastic mr fox", 0, 10)
+	require.NoError(t, err)
+	b, err := q.Enqueue(t.Context(), "fantastic mr fox", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, a.ID, b.ID)
+
+	c, err := q.Enqueue(t.Context(), "fantastic mr fox", 10, 10)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.ID, c.ID, "a different page is a different job")
+}
+
+func TestQueueRunsJob(t *testing.T) {
+	s := &fakeScraper{added: 3}
+	q, jobs := newTestQueue(s)
+
+	job, err := q.Enqueue(t.Context(), "matilda", 0, 10)
+	require.NoError(t, err)
+	require.True(t, q.runOne(t.Context()))
+	assert.False(t, q.runOne(t.Context()), "queue should be empty")
+
+	job, err = jobs.GetByID(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobDone, job.Status)
+	assert.Equal(t, 3, job.Added)
+	assert.Equal(t, 1, job.Attempts)
+
+	// A finished job is still fresh, so searching again shouldn't
+	// scrape again
+	again, err := q.Enqueue(t.Context(), "matilda", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID)
+	assert.False(t, q.runOne(t.Context()))
+	assert.Equal(t, 1, s.calls)
+}
+
+func TestQueueRetries(t *testing.T) {
+	s := &fakeScraper{fails: 2, added: 1}
+	q, jobs := newTestQueue(s)
+
+	job, err := q.Enqueue(t.Context(), "the witches", 0, 10)
+	require.NoError(t, err)
+	for q.runOne(t.Context()) {
+	}
+
+	job, err = jobs.GetByID(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobDone, job.Status)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Empty(t, job.Error)
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	s := &fakeScraper{fails: 100}
+	q, jobs := newTestQueue(s)
+
+	job, err := q.Enqueue(t.Context(), "the twits", 0, 10)
+	require.NoError(t, err)
+	for q.runOne(t.Context()) {
+	}
+
+	job, err = jobs.GetByID(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobFailed, job.Status)
+	assert.Equal(t, job.MaxAttempts, job.Attempts)
+	assert.Equal(t, "provider fell over", job.Error)
+	assert.Equal(t, job.MaxAttempts, s.calls)
+
+	// Failed jobs can be queued again
+	again, err := q.Enqueue(t.Context(), "the twits", 0, 10)
+	require.NoError(t, err)
+	assert.NotEqual(t, job.ID, again.ID)
+}
+
+func TestQueueShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	q, jobs := newTestQueue(&fakeScraper{interrupt: cancel})
+
+	job, err := q.Enqueue(t.Context(), "esio trot", 0, 10)
+	require.NoError(t, err)
+	q.runOne(ctx)
+
+	job, err = jobs.GetByID(t.Context(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ScrapeJobQueued, job.Status)
+	assert.Equal(t, 0, job.Attempts, "being interrupted isn't a failed attempt")
+	assert.Empty(t, job.Error)
+}
+
+func TestQueueRun(t *testing.T) {
+	q, jobs := newTestQueue(&fakeScraper{added: 1})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	job, err := q.Enqueue(t.Context(), "danny the champion of the world", 0, 10)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		j, err := jobs.GetByID(t.Context(), job.ID)
+		return err == nil && j.Status == model.ScrapeJobDone
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("workers did not stop")
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	// Recording the outcome has to fit in what's left of the lease
+	assert.Less(t, jobTimeout+jobRecordTimeout, jobLease)
+}
+
+func TestBackoff(t *testing.T) {
+	q := NewQueue(nil, nil, 1)
+	assert.Equal(t, jobBackoff, q.backoffFor(1))
+	assert.Equal(t, 2*jobBackoff, q.backoffFor(2))
+	assert.Equal(t, 4*jobBackoff, q.backoffFor(3))
+	assert.Equal(t, maxJobBackoff, q.backoffFor(50))
+}
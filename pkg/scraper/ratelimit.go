@@ -0,0 +1,77 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A token bucket rate limiter. One limiter should be shared by
+// everything talking to the same API, so that the limit holds no
+// matter how many searches or workers are running at once.
+type RateLimiter struct {
+	mut    sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// Allow `perSecond` requests a second on average, with up to `burst`
+// at once after a quiet period. A rate of zero or less is unlimited.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	burst = max(burst, 1)
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a request may be made, or ctx is done. A nil
+// RateLimiter never waits.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	d := l.reserve()
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		// We never used our token, so give it back for someone else
+		l.mut.Lock()
+		l.tokens = min(l.tokens+1, l.burst)
+		l.mut.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Take a token, returning how long to wait until it is actually ours.
+// The bucket may go negative, which is how waiters queue up behind one
+// another.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+const (
+	// How often the refresher looks for stale books
+	refreshInterval time.Duration = time.Hour
+	// How many stale books are refreshed each time
+	refreshBatch int = 50
+)
+
+// Refresher re-fetches the metadata of scraped books, so corrections
+// on the provider's end (a better cover, a description which was
+// missing) eventually make it here too. Fields an admin has edited by
+// hand are never overwritten.
+type Refresher struct {
+	blob repository.BlobManager
+	book repository.BookManager[*model.Book]
+	// Metadata sources, in order of preference
+	providers []Provider
+	// Books fetched longer ago than this are stale
+	maxAge time.Duration
+
+	interval time.Duration
+	batch    int
+}
+
+var _ repository.BookRefresher = (*Refresher)(nil)
+
+// Create a refresher for books older than maxAge. Books are refreshed
+// from the provider they were scraped from, so that provider should be
+// among those given.
+func NewRefresher(blob repository.BlobManager, book repository.BookManager[*model.Book], maxAge time.Duration, providers ...Provider) *Refresher {
+	return &Refresher{
+		blob:      blob,
+		book:      book,
+		providers: providers,
+		maxAge:    maxAge,
+		interval:  refreshInterval,
+		batch:     refreshBatch,
+	}
+}
+
+// Refresh stale books periodically until ctx is cancelled.
+func (r *Refresher) Run(ctx context.Context) {
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	for {
+		if _, err := r.RefreshStale(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("refresh: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Refresh one batch of the stalest books, returning how many were
+// refreshed. One book failing doesn't stop the others.
+func (r *Refresher) RefreshStale(ctx context.Context) (int, error) {
+	const errorCaller string = "refresh stale books"
+	books, err := r.book.Stale(ctx, time.Now().Add(-r.maxAge), r.batch)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	var (
+		n    int
+		errs []error
+	)
+	for _, b := range books {
+		if _, _, err := r.refresh(ctx, b); err != nil {
+			errs = append(errs, fmt.Errorf("%v: book `%v`: %w", errorCaller, b.ID, err))
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// RefreshBook implements repository.BookRefresher.
+func (r *Refresher) RefreshBook(ctx context.Context, id uuid.UUID) (*model.Book, []string, error) {
+	const errorCaller string = "refresh book"
+	book, err := r.book.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	updated, changed, err := r.refresh(ctx, book)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	return updated, changed, nil
+}
+
+func (r *Refresher) refresh(ctx context.Context, book *model.Book) (*model.Book, []string, error) {
+	var src model.BookSource
+	if book.Source != nil {
+		src = *book.Source
+	}
+	p, v, err := r.lookup(ctx, src.Provider, book.ISBNs)
+	if errors.Is(err, repository.ErrNotFound) && src.Provider != "" {
+		// The provider has forgotten about the book. There is nothing
+		// to refresh from, but it shouldn't be asked again until the
+		// book goes stale again.
+		return r.book.Refresh(ctx, book)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// The datastore won't let the scraper overwrite anything set by
+	// hand, but knowing which fields those are up front saves
+	// downloading images which would only be thrown away.
+	prov, err := r.book.Provenance(ctx, book.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	scraper := model.Provenance{Source: model.ProvenanceScraper}
+	edited := func(field string) bool {
+		return !scraper.Overrides(prov[field])
+	}
+	// Only take what the provider has; a field it left empty this time
+	// is more likely a gap in its data than a deliberate removal.
+	updated := *book
+	if v.Title != "" && !edited(model.BookFieldTitle) {
+		updated.Title = v.Title
+	}
+	if v.Subtitle != "" && !edited(model.BookFieldSubtitle) {
+		updated.Subtitle = v.Subtitle
+	}
+	if v.Description != "" && !edited(model.BookFieldDescription) {
+		updated.Description = v.Description
+	}
+	if v.Format != "" && !edited(model.BookFieldFormat) {
+		updated.Format = v.Format
+	}
+	if v.Publisher != "" && !edited(model.BookFieldPublisher) {
+		updated.Publisher = v.Publisher
+	}
+	if v.Pages > 0 && !edited(model.BookFieldPages) {
+		updated.Pages = v.Pages
+	}
+	if v.Language != "" && !edited(model.BookFieldLanguage) {
+		updated.Language = v.Language
+	}
+	if v.Published != "" && !edited(model.BookFieldPublished) {
+		if d := parsePublishedDate(v.Published); !d.IsZero() {
+			updated.Published = d
+		}
+	}
+	updated.Source = &model.BookSource{
+		Provider:   p.Name(),
+		ProviderID: v.ProviderID,
+		CoverURL:   src.CoverURL,
+	}
+	// Images are only fetched again if the provider's cover has moved
+	cover, thumb := !edited(model.BookFieldCover), !edited(model.BookFieldThumbnail)
+	if u := coverURL(*v); u != "" && u != src.CoverURL && (cover || thumb) {
+		coverID, thumbID, err := storeImages(ctx, r.blob, *v, cover, thumb)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cover {
+			updated.CoverImage = coverID
+		}
+		if thumb {
+			updated.ThumbImage = thumbID
+		}
+		updated.Source.CoverURL = u
+	}
+
+	result, changed, err := r.book.Refresh(ctx, &updated)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(changed) > 0 {
+		log.Printf("refresh: book %v (%v %v): changed %v",
+			book.ID, p.Name(), v.ProviderID, changed)
+	}
+	return result, changed, nil
+}
+
+// Find a book on the provider it came from, or if it didn't come from
+// one (or that provider is no longer registered), the first provider
+// which knows any of its ISBNs.
+func (r *Refresher) lookup(ctx context.Context, provider string, isbns []model.ISBN) (Provider, *Volume, error) {
+	providers := r.providers
+	for _, p := range r.providers {
+		if p.Name() == provider {
+			providers = []Provider{p}
+			break
+		}
+	}
+	var errs []error
+	for _, p := range providers {
+		for _, isbn := range isbns {
+			v, err := p.ISBN(ctx, isbn)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			} else if err != nil {
+				errs = append(errs, fmt.Errorf("%v: %w", p.Name(), err))
+				break
+			}
+			return p, v, nil
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return nil, nil, repository.Err{
+		Code: repository.ErrNotFound,
+		Err:  fmt.Errorf("no provider has a volume with ISBNs %v", isbns),
+	}
+}
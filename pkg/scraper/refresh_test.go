@@ -0,0 +1,156 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
+	"github.com/whit-colm/itsc-4155-project/pkg/repository"
+)
+
+// A provider which knows whatever volumes it is given, by ISBN
+type fakeProvider struct {
+	volumes map[model.ISBN]Volume
+}
+
+func (f *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *fakeProvider) Search(ctx context.Context, offset, limit int, query string) ([]Volume, error) {
+	return nil, nil
+}
+
+func (f *fakeProvider) ISBN(ctx context.Context, isbn model.ISBN) (*Volume, error) {
+	v, ok := f.volumes[isbn]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &v, nil
+}
+
+func TestRefreshBook(t *testing.T) {
+	ctx := t.Context()
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		// Each image is different, or they'd all be one blob
+		w.Write(append([]byte{0xff, 0xd8, 0xff}, r.URL.Path...))
+	}))
+	t.Cleanup(images.Close)
+
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	isbn := model.MustNewISBN("9780140328721", model.ISBN13)
+	p := &fakeProvider{volumes: map[model.ISBN]Volume{isbn: {
+		Provider:   "fake",
+		ProviderID: "fox",
+		Title:      "Fantastic Mr. Fox",
+		Published:  "1970",
+		ISBNs:      []model.ISBN{isbn},
+		Authors:    []VolumeAuthor{{Name: "Roald Dahl"}},
+		Covers:     []string{images.URL + "/old-L.jpg"},
+		Thumbnails: []string{images.URL + "/old-M.jpg"},
+	}}}
+	n, err := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, p).ScrapeISBN(ctx, isbn)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	scraped, err := repo.Book.GetByISBN(ctx, isbn)
+	require.NoError(t, err)
+	require.NotNil(t, scraped.Source)
+	assert.Equal(t, "fake", scraped.Source.Provider)
+	assert.Equal(t, images.URL+"/old-L.jpg", scraped.Source.CoverURL)
+
+	// An admin fixes the title by hand
+	edit := *scraped
+	edit.Title = "Fantastic Mr Fox"
+	_, err = repo.Book.Update(ctx, &edit)
+	require.NoError(t, err)
+
+	// Meanwhile the provider fixes the description and cover, and
+	// "fixes" the title back
+	v := p.volumes[isbn]
+	v.Description = "The Foxes are in trouble."
+	v.Covers = []string{images.URL + "/new-L.jpg"}
+	v.Thumbnails = []string{images.URL + "/new-M.jpg"}
+	p.volumes[isbn] = v
+
+	r := NewRefresher(repo.Blob, repo.Book, 24*time.Hour, p)
+	book, changed, err := r.RefreshBook(ctx, scraped.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		model.BookFieldDescription, model.BookFieldCover, model.BookFieldThumbnail,
+	}, changed)
+	assert.Equal(t, "Fantastic Mr Fox", book.Title, "manual edit was overwritten")
+	assert.Equal(t, v.Description, book.Description)
+	assert.NotEqual(t, scraped.CoverImage, book.CoverImage)
+	assert.NotEqual(t, uuid.Nil, book.CoverImage)
+	assert.Equal(t, images.URL+"/new-L.jpg", book.Source.CoverURL)
+	prov, err := repo.Book.Provenance(ctx, scraped.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ProvenanceAdmin, prov[model.BookFieldTitle].Source)
+	assert.Equal(t, model.ProvenanceScraper, prov[model.BookFieldDescription].Source)
+	assert.Equal(t, "fake:fox", prov[model.BookFieldDescription].SourceID)
+	assert.True(t, book.Source.Fetched.After(scraped.Source.Fetched))
+
+	// Nothing has changed since, so nothing should change now
+	_, changed, err = r.RefreshBook(ctx, scraped.ID)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
+
+func TestRefreshStale(t *testing.T) {
+	ctx := t.Context()
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	p := &fakeProvider{volumes: map[model.ISBN]Volume{}}
+
+	var ids []uuid.UUID
+	for i, s := range []string{"9780140328721", "9780375822070"} {
+		isbn := model.MustNewISBN(s, model.ISBN13)
+		b := model.Book{
+			ID:    uuid.New(),
+			Title: "Old title",
+			ISBNs: []model.ISBN{isbn},
+			Source: &model.BookSource{
+				Provider: "fake",
+				// The second book was fetched recently
+				Fetched: time.Now().Add(time.Duration(i-1) * 48 * time.Hour),
+			},
+		}
+		require.NoError(t, repo.Book.Create(ctx, &b))
+		p.volumes[isbn] = Volume{Provider: "fake", Title: "New title", ISBNs: b.ISBNs}
+		ids = append(ids, b.ID)
+	}
+	// A book somebody added by hand has nowhere to be refreshed from
+	require.NoError(t, repo.Book.Create(ctx, &model.Book{ID: uuid.New(), Title: "Manual"}))
+
+	r := NewRefresher(repo.Blob, repo.Book, 24*time.Hour, p)
+	n, err := r.RefreshStale(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	stale, err := repo.Book.GetByID(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "New title", stale.Title)
+	fresh, err := repo.Book.GetByID(ctx, ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, "Old title", fresh.Title)
+
+	// If the provider forgets the book, it is left alone but isn't
+	// stale anymore
+	delete(p.volumes, stale.ISBNs[0])
+	r.maxAge = 0
+	n, err = r.RefreshStale(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	stale, err = repo.Book.GetByID(ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "New title", stale.Title)
+	assert.WithinDuration(t, time.Now(), stale.Source.Fetched, time.Minute)
+}
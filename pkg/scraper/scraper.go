@@ -2,14 +2,10 @@ package scraper
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"math/rand"
-	"net/http"
-	"net/url"
-	"sync"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,280 +13,329 @@ import (
 	"github.com/whit-colm/itsc-4155-project/pkg/repository"
 )
 
-const (
-	googleBooksAPI string = "https://www.googleapis.com/books/v1/volumes?&orderBy=relevance&maxResults=%d&startIndex=%d&q=%s"
-	maxLimit       int    = 40
-)
-
 type BookScraper struct {
 	blob repository.BlobManager
 	book repository.BookManager[*model.Book]
 	athr repository.AuthorManager[*model.Author]
-	keys map[string]string
+	subj repository.SubjectManager
+	srs  repository.SeriesManager
+	// Metadata sources, in order of preference
+	providers []Provider
 }
 
-func NewBookScraper(blob repository.BlobManager, book repository.BookManager[*model.Book], athr repository.AuthorManager[*model.Author]) *BookScraper {
+var _ repository.BookScraper = (*BookScraper)(nil)
+
+// Create a scraper which pulls from the given providers. Providers
+// are asked in the order given, so the most trusted should go first.
+func NewBookScraper(blob repository.BlobManager, book repository.BookManager[*model.Book], athr repository.AuthorManager[*model.Author], subj repository.SubjectManager, srs repository.SeriesManager, providers ...Provider) *BookScraper {
 	return &BookScraper{
-		blob: blob,
-		book: book,
-		athr: athr,
+		blob:      blob,
+		book:      book,
+		athr:      athr,
+		subj:      subj,
+		srs:       srs,
+		providers: providers,
 	}
 }
 
-func (s *BookScraper) scrapeGoogleBooks(ctx context.Context, offset, limit int, query string, iCh chan<- int, eCh chan<- error, wg *sync.WaitGroup) {
-	defer wg.Done()
-	const errorCaller string = "Google Books Scraper"
-	if limit < 0 {
-		eCh <- fmt.Errorf("%s: limit `%d` is negative, which is not allowed", errorCaller, limit)
-		return
-	}
-	if limit > maxLimit {
-		eCh <- fmt.Errorf("%s: limit `%d` is greater than Google Books API maximum `%d`",
-			errorCaller, limit, maxLimit,
-		)
-		return
+// Scrape implements repository.BookScraper.
+//
+// Providers are searched in priority order until `limit` new books
+// have been stored. A provider failing is not fatal so long as another
+// one answers, and neither is a volume failing to be stored so long as
+// another one is. Returns -1 if no provider had anything at all for the
+// query.
+func (s *BookScraper) Scrape(ctx context.Context, offset, limit int, query string) (int, error) {
+	const errorCaller string = "scrape"
+	var (
+		total  int
+		found  bool
+		errs   []error
+		failed []error
+	)
+	for _, p := range s.providers {
+		volumes, err := p.Search(ctx, offset, limit, query)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v: %w", errorCaller, p.Name(), err))
+			continue
+		}
+		found = found || len(volumes) > 0
+		for _, v := range volumes {
+			n, err := s.store(ctx, v)
+			if ctx.Err() != nil {
+				return total, fmt.Errorf("%v: %w", errorCaller, ctx.Err())
+			} else if err != nil {
+				failed = append(failed, fmt.Errorf("%v: %v %v: %w", errorCaller, p.Name(), v.ProviderID, err))
+				continue
+			}
+			total += n
+		}
+		if total >= limit {
+			break
+		}
 	}
-	url := fmt.Sprintf(googleBooksAPI, limit, offset, query)
-	resp, err := http.Get(url)
-	if err != nil {
-		eCh <- fmt.Errorf("%v: %w", errorCaller, err)
-		return
+
+	switch {
+	case total == 0 && len(failed) > 0:
+		return 0, errors.Join(failed...)
+	case found:
+		for _, err := range failed {
+			log.Print(err)
+		}
+		return total, nil
+	case len(errs) > 0:
+		return 0, errors.Join(errs...)
+	default:
+		return -1, nil
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusTooManyRequests {
-		// If we hit the rate limit, we wait for half a second to a second
-		// before retrying. This is a simple backoff strategy.
-		// This is not a perfect solution, but it should work for most cases.
-		randDelay := 1500 + rand.Intn(500)
-		time.Sleep(time.Millisecond * time.Duration(randDelay))
-		s.scrapeGoogleBooks(ctx, offset, limit, query, iCh, eCh, wg)
-		return
-	} else if resp.StatusCode != http.StatusOK {
-		eCh <- fmt.Errorf("%s: received status code %d from Google Books API", errorCaller, resp.StatusCode)
-		return
+}
+
+// Gets the book data using ISBN and converts it
+//
+// The first provider which knows of the ISBN wins.
+func (s *BookScraper) ScrapeISBN(ctx context.Context, isbn model.ISBN) (int, error) {
+	const errorCaller string = "scrape ISBN"
+	var errs []error
+	for _, p := range s.providers {
+		v, err := p.ISBN(ctx, isbn)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v: %w", errorCaller, p.Name(), err))
+			continue
+		}
+		n, err := s.store(ctx, *v)
+		if err != nil {
+			return 0, fmt.Errorf("%v: %v: %w", errorCaller, p.Name(), err)
+		}
+		return n, nil
 	}
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		eCh <- fmt.Errorf("%v: %w", errorCaller, err)
-		return
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
 	}
+	return -1, nil
+}
 
-	var aux struct {
-		Total int `json:"totalItems"`
-		Items []struct {
-			SelfLink string `json:"selfLink"`
-		} `json:"items"`
+// Store a volume as a new book, unless we already have it. Returns the
+// number of books created, so 0 or 1.
+func (s *BookScraper) store(ctx context.Context, v Volume) (int, error) {
+	const errorCaller string = "store volume"
+	// Without an ISBN there is no reliable way to tell if we already
+	// have the book, and nothing for users to look it up by either.
+	if len(v.ISBNs) == 0 {
+		return 0, nil
 	}
-	if err := json.Unmarshal(respBody, &aux); err != nil {
-		eCh <- fmt.Errorf("%v: %w", errorCaller, err)
-		return
-	} else if aux.Total == 0 {
-		iCh <- -1 // No books found
-		return
+	b := model.Book{
+		ID:          uuid.Nil,
+		Title:       v.Title,
+		Subtitle:    v.Subtitle,
+		Description: v.Description,
+		Published:   parsePublishedDate(v.Published),
+		ISBNs:       v.ISBNs,
+		Format:      v.Format,
+		Publisher:   v.Publisher,
+		Pages:       v.Pages,
+		Language:    v.Language,
+		CoverImage:  uuid.Nil,
+		ThumbImage:  uuid.Nil,
+		Source: &model.BookSource{
+			Provider:   v.Provider,
+			ProviderID: v.ProviderID,
+			CoverURL:   coverURL(v),
+			Fetched:    time.Now(),
+		},
+	}
+	if _, exists, err := s.book.ExistsByISBN(ctx, b.ISBNs...); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+		}
+	} else if exists {
+		return 0, nil
 	}
 
-	/*** Prepare request for each self-link ***/
-	// The 'fields' constant is commented out because it is not used in the current implementation.
-	// It can be used to pre-filter the fields returned by the Google Books API, saving bandwidth
-	// but it liked to 503 me last night so it's in limbo for now.
-	//const fields string = "fields=volumeInfo(title,subtitle,authors,publishedDate,description,industryIdentifiers,categories,imageLinks)"
-	var links []string = make([]string, len(aux.Items))
-	for i, v := range aux.Items {
-		links[i] = v.SelfLink /*+ "&" + fields*/
+	// Now we know the book does not exist, so we can store it. A book
+	// is still worth having without its images, and with no cover URL
+	// recorded the refresher tries them again.
+	var err error
+	if b.CoverImage, b.ThumbImage, err = storeImages(ctx, s.blob, v, true, true); err != nil {
+		log.Printf("%v: %v %v: %v", errorCaller, v.Provider, v.ProviderID, err)
+		b.CoverImage, b.ThumbImage = uuid.Nil, uuid.Nil
+		b.Source.CoverURL = ""
 	}
 
-	newBook := func(link string) (int, error) {
-		const errorCaller string = "self-link scrape"
-		resp, err := http.Get(link)
-		if err != nil {
-			return 0, fmt.Errorf("%s: %w", errorCaller, err)
-		}
-		defer resp.Body.Close()
+	// Set book ID
+	id, err := uuid.NewV7()
+	if err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	b.ID = id
 
-		respBody, err := io.ReadAll(resp.Body)
+	// Authors, and everyone else credited, in the provider's order
+	for _, va := range v.Authors {
+		author, err := s.author(ctx, va)
 		if err != nil {
-			return 0, fmt.Errorf("%s: %w", errorCaller, err)
-		}
-		var aux struct {
-			VolumeInfo struct {
-				Title               string               `json:"title"`
-				Subtitle            string               `json:"subtitle"`
-				Authors             []string             `json:"authors"`
-				PublishedDate       string               `json:"publishedDate"`
-				Description         string               `json:"description"`
-				IndustryIdentifiers []industryIdentifier `json:"industryIdentifiers"`
-				Categories          []string             `json:"categories"`
-				ImageLinks          imageLinks           `json:"imageLinks"`
-			} `json:"volumeInfo"`
-		}
-		if err := json.Unmarshal(respBody, &aux); err != nil {
 			return 0, fmt.Errorf("%v: %w", errorCaller, err)
 		}
-		v := aux.VolumeInfo
-		b := model.Book{
-			ID:          uuid.Nil,
-			Title:       v.Title,
-			Subtitle:    v.Subtitle,
-			Description: v.Description,
-			Published:   parsePublishedDate(v.PublishedDate),
-			ISBNs:       extractISBN(v.IndustryIdentifiers),
-			CoverImage:  uuid.Nil,
-			ThumbImage:  uuid.Nil,
-		}
-		if _, exists, err := s.book.ExistsByISBN(ctx, b.ISBNs...); err != nil {
-			if !errors.Is(err, repository.ErrNotFound) {
-				return 0, fmt.Errorf("%v: %w", errorCaller, err)
-			}
-		} else if exists {
-			return 0, nil
-		}
-
-		// Now we know the book does not exist, so we can store it
-		// Store thumbnail image
-		storeBlobbedUrl := func(url string, to *uuid.UUID) error {
-			b, err := urlToBlob(ctx, url)
-			if err != nil {
-				return fmt.Errorf("%v: failed to convert URL to blob: %w", errorCaller, err)
-			}
-			if err := s.blob.Create(ctx, b); err != nil {
-				return fmt.Errorf("%v: failed to create blob: %w", errorCaller, err)
-			}
-			*to = b.ID
-			return nil
-		}
-		// Set thumbnail and cover images
-		if v.ImageLinks.Thumbnail != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.Thumbnail, &b.ThumbImage); err != nil {
-				return 0, err
-			}
-		} else if v.ImageLinks.SmallThumbnail != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.SmallThumbnail, &b.ThumbImage); err != nil {
-				return 0, err
-			}
+		role := va.Role
+		if role == "" {
+			role = model.RoleAuthor
 		}
+		b.Contributors = append(b.Contributors, model.Contributor{ID: author.ID, Role: role})
+	}
+	b.SyncContributors(model.Book{})
 
-		if v.ImageLinks.ExtraLarge != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.ExtraLarge, &b.CoverImage); err != nil {
-				return 0, err
-			}
-		} else if v.ImageLinks.Large != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.Large, &b.CoverImage); err != nil {
-				return 0, err
-			}
-		} else if v.ImageLinks.Medium != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.Medium, &b.CoverImage); err != nil {
-				return 0, err
-			}
-		} else if v.ImageLinks.Small != "" {
-			if err := storeBlobbedUrl(v.ImageLinks.Small, &b.CoverImage); err != nil {
-				return 0, err
-			}
-		} else {
-			b.CoverImage = b.ThumbImage
-		}
+	if b.Subjects, err = s.subjects(ctx, v.Categories); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
 
-		// Set book ID
-		id, err := uuid.NewV7()
-		if err != nil {
+	// A series without a position can't be put in order, so it is
+	// left for an admin to sort out
+	var series *model.Series
+	name, pos, ok := model.ParseSeries(v.Series)
+	if ok {
+		if series, err = s.srs.Resolve(ctx, name); err != nil {
 			return 0, fmt.Errorf("%v: %w", errorCaller, err)
 		}
-		b.ID = id
-
-		// Authors
-		for _, authorName := range v.Authors {
-			// Check if the author already exists
-			author, exists, err := s.athr.ExistsByName(ctx, authorName)
-			if err != nil {
-				if !errors.Is(err, repository.ErrNotFound) {
-					return 0, fmt.Errorf("%v: %w", errorCaller, err)
-				}
-			}
-			if !exists {
-				// Create a new author if it does not exist
-				// TODO: find a method to get the author from a tertiary source
-				// as google books does not provide a method of discriminating
-				// between authors with the same name.
-				id, err := uuid.NewV7()
-				if err != nil {
-					return 0, fmt.Errorf("%v: %w", errorCaller, err)
-				}
-				author = &model.Author{
-					ID:         id,
-					GivenName:  "",
-					FamilyName: authorName,
-				}
-				if err := s.athr.Create(ctx, author); err != nil {
-					return 0, fmt.Errorf("%v: %w", errorCaller, err)
-				}
-			}
-			b.AuthorIDs = append(b.AuthorIDs, author.ID)
-		}
+	}
 
-		// Commit the book to the datastore
-		if err := s.book.Create(ctx, &b); err != nil {
-			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	// Commit the book to the datastore
+	b.Attribute(model.Provenance{
+		Source:   model.ProvenanceScraper,
+		SourceID: v.Provider + ":" + v.ProviderID,
+		Recorded: b.Source.Fetched,
+	})
+	if err := s.book.Create(ctx, &b); err != nil {
+		return 0, fmt.Errorf("%v: %w", errorCaller, err)
+	}
+	// The book is stored either way, so this isn't worth failing over
+	if series != nil {
+		if err := s.srs.Place(ctx, series.ID, b.ID, pos); err != nil {
+			log.Printf("%v: book %v: %v", errorCaller, b.ID, err)
 		}
-		return 1, nil
 	}
+	return 1, nil
+}
 
-	total := 0
-	for _, link := range links {
-		n, err := newBook(link)
+// How many of a volume's categories are kept. Open Library tags
+// popular books with dozens of subjects, most of them noise.
+const maxSubjects int = 8
+
+// File a volume's categories into the subject taxonomy, creating any
+// subjects we haven't seen before.
+func (s *BookScraper) subjects(ctx context.Context, categories []string) (uuid.UUIDs, error) {
+	var ids uuid.UUIDs
+	for _, c := range categories {
+		if len(ids) >= maxSubjects {
+			break
+		}
+		path := model.ParseSubjectPath(c)
+		if len(path) == 0 {
+			continue
+		}
+		subject, err := s.subj.Resolve(ctx, path)
 		if err != nil {
-			eCh <- err
-			return
+			return nil, err
+		}
+		if !slices.Contains(ids, subject.ID) {
+			ids = append(ids, subject.ID)
 		}
-		total += n
 	}
-	iCh <- total
+	return ids, nil
 }
 
-func (s *BookScraper) Scrape(ctx context.Context, offset, limit int, query string) (int, error) {
-	const errorCaller string = "scrape"
-	// Encode the query to be URL-safe if it is not already
-	if decoded, err := url.QueryUnescape(query); err == nil && decoded == query {
-		query = url.QueryEscape(query)
+// Find the author a volume is crediting, or create them. External IDs
+// are the most reliable way to match an author, then their name.
+func (s *BookScraper) author(ctx context.Context, va VolumeAuthor) (*model.Author, error) {
+	for _, extID := range va.ExtIDs {
+		author, exists, err := s.athr.ExistsByExtID(ctx, extID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		} else if exists {
+			return author, nil
+		}
 	}
 
-	rem := limit % maxLimit
-	nchunks := limit / maxLimit
-	agents := nchunks
-	if rem != 0 {
-		agents++
+	author, exists, err := s.athr.ExistsByName(ctx, va.Name)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if exists {
+		// We know this author by name but not by this ID; remember it
+		// so next time we can skip the guesswork.
+		if len(va.ExtIDs) > 0 {
+			updated := *author
+			updated.ExtIDs = append(append([]model.AuthorIDs{}, author.ExtIDs...), va.ExtIDs...)
+			if author, err = s.athr.Update(ctx, &updated); err != nil {
+				return nil, err
+			}
+		}
+		return author, nil
 	}
-	// asynchronous function to fetch book data from self-link
-	// and store it (and its blob, author, etc) in the database
-	// if it does not already exist
-	var wg sync.WaitGroup
-	wg.Add(agents)
-	errCh := make(chan error, agents)
-	addCh := make(chan int, agents)
 
-	if rem != 0 {
-		go s.scrapeGoogleBooks(ctx, offset+nchunks*maxLimit, rem, query, addCh, errCh, &wg)
+	// Create a new author if it does not exist
+	// TODO: Google Books does not provide a method of discriminating
+	// between authors with the same name, so authors found there are
+	// only ever matched by name.
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+	author = &model.Author{
+		ID:         id,
+		GivenName:  "",
+		FamilyName: va.Name,
+		ExtIDs:     va.ExtIDs,
 	}
-	for i := range nchunks {
-		go s.scrapeGoogleBooks(ctx, offset+i*maxLimit, maxLimit, query, addCh, errCh, &wg)
+	if err := s.athr.Create(ctx, author); err != nil {
+		return nil, err
 	}
-	wg.Wait()
-	close(errCh)
-	close(addCh)
+	return author, nil
+}
 
-	// Check for errors
-	for err := range errCh {
+// The URL a volume's cover is taken from; see storeImages
+func coverURL(v Volume) string {
+	if len(v.Covers) > 0 {
+		return v.Covers[0]
+	} else if len(v.Thumbnails) > 0 {
+		return v.Thumbnails[0]
+	}
+	return ""
+}
+
+// Store a volume's cover and thumbnail images as blobs, using the best
+// of each we are given. If there is no cover the thumbnail doubles as
+// one. Either can be skipped, in which case uuid.Nil is returned for
+// it.
+func storeImages(ctx context.Context, blobs repository.BlobManager, v Volume, cover, thumb bool) (uuid.UUID, uuid.UUID, error) {
+	storeBlobbedUrl := func(url string) (uuid.UUID, error) {
+		b, err := urlToBlob(ctx, url)
 		if err != nil {
-			return 0, fmt.Errorf("%v: %w", errorCaller, err)
+			return uuid.Nil, fmt.Errorf("failed to convert URL to blob: %w", err)
 		}
+		if err := blobs.Create(ctx, b); err != nil {
+			return uuid.Nil, fmt.Errorf("failed to create blob: %w", err)
+		}
+		return b.ID, nil
 	}
-	total := 0
-	for added := range addCh {
-		total += added
-	}
-	// Return the number of books stored
-	return total, nil
-}
 
-// Gets the book data using ISBN and converts it
-func (s *BookScraper) ScrapeISBN(ctx context.Context, isbn model.ISBN) (int, error) {
-	return s.Scrape(ctx, 0, 1, fmt.Sprintf("isbn:%s", isbn.String()))
+	var coverID, thumbID uuid.UUID
+	var err error
+	if len(v.Thumbnails) > 0 && (thumb || (cover && len(v.Covers) == 0)) {
+		if thumbID, err = storeBlobbedUrl(v.Thumbnails[0]); err != nil {
+			return uuid.Nil, uuid.Nil, err
+		}
+	}
+	if cover {
+		if len(v.Covers) > 0 {
+			if coverID, err = storeBlobbedUrl(v.Covers[0]); err != nil {
+				return uuid.Nil, uuid.Nil, err
+			}
+		} else {
+			coverID = thumbID
+		}
+	}
+	if !thumb {
+		thumbID = uuid.Nil
+	}
+	return coverID, thumbID, nil
 }
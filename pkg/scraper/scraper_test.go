@@ -1,19 +1,33 @@
 package scraper
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/whit-colm/itsc-4155-project/internal/testhelper/dummyvalues"
 	"github.com/whit-colm/itsc-4155-project/internal/testhelper/mockdatastore"
+	"github.com/whit-colm/itsc-4155-project/pkg/model"
 )
 
 // Test for FetchBookByISBN
 func TestFetchBookByISBN(t *testing.T) {
 	ctx := t.Context()
 	repo := mockdatastore.NewInMemoryRepository[string]()
-	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author)
+	api := newFakeBooksAPI(t, 1)
+	api.volumes["vol0"] = `{"volumeInfo": {
+		"title": "Oliver Twist",
+		"authors": ["Charles Dickens"],
+		"publishedDate": "1837-02",
+		"industryIdentifiers": [
+			{"type": "ISBN_10", "identifier": "0141439742"},
+			{"type": "ISBN_13", "identifier": "9780141439747"}
+		]
+	}}`
+	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, api.provider(GoogleBooksConfig{}))
 
 	isbn := dummyvalues.ExampleBook.ISBNs[0]
 
@@ -30,6 +44,55 @@ func TestFetchBookByISBN(t *testing.T) {
 	assert.NotNil(t, book, "Book should not be nil")
 }
 
+func TestScrapeSubjects(t *testing.T) {
+	ctx := t.Context()
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	api := newFakeBooksAPI(t, 1)
+	api.volumes["vol0"] = `{"volumeInfo": {
+		"title": "Fantastic Mr. Fox",
+		"industryIdentifiers": [{"type": "ISBN_13", "identifier": "9780140328721"}],
+		"categories": ["Juvenile Fiction / Animals / Foxes", "Juvenile Fiction / General", "Juvenile Fiction / Animals / Foxes"]
+	}}`
+	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, api.provider(GoogleBooksConfig{}))
+
+	_, err := scrp.Scrape(ctx, 0, 1, "fantastic mr fox")
+	require.NoError(t, err)
+	book, err := repo.Book.GetByISBN(ctx, model.MustNewISBN("9780140328721", model.ISBN13))
+	require.NoError(t, err)
+	require.Len(t, book.Subjects, 2, "duplicate categories should be filed once")
+
+	foxes, err := repo.Subject.GetByID(ctx, book.Subjects[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Juvenile Fiction", "Animals", "Foxes"}, foxes.Path)
+	juvenile, err := repo.Subject.GetByID(ctx, book.Subjects[1])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Juvenile Fiction"}, juvenile.Path)
+}
+
+func TestScrapeDeadCover(t *testing.T) {
+	ctx := t.Context()
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	api := newFakeBooksAPI(t, 2)
+	api.volumes["vol0"] = fmt.Sprintf(`{"volumeInfo": {
+		"title": "Matilda",
+		"industryIdentifiers": [{"type": "ISBN_13", "identifier": "9780142410370"}],
+		"imageLinks": {"thumbnail": "%v/gone.jpg"}
+	}}`, api.srv.URL)
+	api.volumes["vol1"] = `{"volumeInfo": {
+		"title": "The BFG",
+		"industryIdentifiers": [{"type": "ISBN_13", "identifier": "9780142410387"}]
+	}}`
+	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, api.provider(GoogleBooksConfig{}))
+
+	count, err := scrp.Scrape(ctx, 0, 2, "roald dahl")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "a missing cover shouldn't cost the book, or the ones after it")
+	book, err := repo.Book.GetByISBN(ctx, model.MustNewISBN("9780142410370", model.ISBN13))
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, book.ThumbImage)
+	assert.Empty(t, book.Source.CoverURL, "the images should be tried again on refresh")
+}
+
 // Test for extractISBN
 func TestExtractISBN(t *testing.T) {
 	identifiers := []industryIdentifier{
@@ -51,9 +114,11 @@ func TestParsePublishedDate(t *testing.T) {
 		expected string
 	}{
 		{"2006-01-02", "2006-01-02"},
-		{"2006-01", "2006-01-01"},
-		{"2006", "2006-01-01"},
-		{"invalid-date", "0000-00-00"},
+		{"2006-01", "2006-01"},
+		{"2006", "2006"},
+		{"Jan 2, 2006", "2006-01-02"},
+		{"January 2006", "2006-01"},
+		{"invalid-date", ""},
 	}
 
 	for _, tt := range tests {
@@ -61,3 +126,28 @@ func TestParsePublishedDate(t *testing.T) {
 		assert.Equal(t, tt.expected, date.String(), "Failed parsing date: "+tt.input)
 	}
 }
+
+func TestScrapeContributors(t *testing.T) {
+	ctx := t.Context()
+	repo := mockdatastore.NewInMemoryRepository[string]()
+	api := newFakeBooksAPI(t, 1)
+	api.volumes["vol0"] = `{"volumeInfo": {
+		"title": "The Three-Body Problem",
+		"authors": ["Cixin Liu", "Ken Liu (Translator)"],
+		"industryIdentifiers": [{"type": "ISBN_13", "identifier": "9780765382030"}]
+	}}`
+	scrp := NewBookScraper(repo.Blob, repo.Book, repo.Author, repo.Subject, repo.Series, api.provider(GoogleBooksConfig{}))
+
+	_, err := scrp.Scrape(ctx, 0, 1, "three body problem")
+	require.NoError(t, err)
+	book, err := repo.Book.GetByISBN(ctx, model.MustNewISBN("9780765382030", model.ISBN13))
+	require.NoError(t, err)
+	require.Len(t, book.AuthorIDs, 1, "the translator isn't an author")
+	require.Len(t, book.Contributors, 2)
+	assert.Equal(t, model.Contributor{ID: book.AuthorIDs[0], Role: model.RoleAuthor}, book.Contributors[0])
+	assert.Equal(t, model.RoleTranslator, book.Contributors[1].Role)
+
+	translator, err := repo.Author.GetByID(ctx, book.Contributors[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ken Liu", translator.FamilyName, "the role shouldn't be part of the name")
+}
@@ -3,6 +3,19 @@ import '../styles/BookDetails.css';
 import Comments from './Comments';
 import { useParams, Link } from 'react-router-dom';
 
+// Published dates may only be known to the year ("1791") or month
+// ("1943-04"), so only show as much as we have
+function formatPublished(published) {
+  const [year, month, day] = published.split('-').map(Number);
+  const date = new Date(Date.UTC(year, (month || 1) - 1, day || 1));
+  return date.toLocaleDateString(undefined, {
+    timeZone: 'UTC',
+    year: 'numeric',
+    ...(month && { month: day ? 'numeric' : 'long' }),
+    ...(day && { day: 'numeric' }),
+  });
+}
+
 function BookDetails({ jwt }) {
   const { bookId } = useParams();
   const [book, setBook] = useState(null);
@@ -143,7 +156,7 @@ function BookDetails({ jwt }) {
         <p>
           <strong>Published:</strong>{' '}
           {book.published && typeof book.published === 'string' ?
-            new Date(book.published + 'T00:00:00Z').toLocaleDateString(undefined, { timeZone: 'UTC' })
+            formatPublished(book.published)
             : 'Unknown'
           }
         </p>
@@ -74,6 +74,8 @@ function Profile({ jwt }) {
   const [username, setUsername] = useState('');
   const [email, setEmail] = useState('');
   const [pronouns, setPronouns] = useState('');
+  // Unlisted users don't turn up in searches or suggestions
+  const [unlisted, setUnlisted] = useState(false);
   const [isEditing, setIsEditing] = useState(false);
   const [userId, setUserId] = useState('');
   const [error, setError] = useState('');
@@ -142,6 +144,7 @@ function Profile({ jwt }) {
           setEmail(userData.email || '');
           setUserId(userData.id);
           setPronouns(userData.pronouns || '');
+          setUnlisted(!!userData.unlisted);
           setAvatarUuid(userData.bref_avatar || '');
           fetchAvatar(userData.bref_avatar);
 
@@ -153,6 +156,7 @@ function Profile({ jwt }) {
           setEmail('');
           setUserId('');
           setPronouns('');
+          setUnlisted(false);
           setAvatarUuid('');
           setAvatarUrl(null);
         }
@@ -196,6 +200,7 @@ function Profile({ jwt }) {
         username: username,
         email: email,
         pronouns: pronouns,
+        unlisted: unlisted,
       };
 
       const response = await fetch('/api/user/me', {
@@ -217,6 +222,7 @@ function Profile({ jwt }) {
       setUsername(updatedUser.username || '');
       setEmail(updatedUser.email || '');
       setPronouns(updatedUser.pronouns || '');
+      setUnlisted(!!updatedUser.unlisted);
       setAvatarUuid(updatedUser.bref_avatar || '');
       fetchAvatar(updatedUser.bref_avatar);
 
@@ -425,6 +431,15 @@ function Profile({ jwt }) {
               placeholder="Pronouns (e.g., she/her, they/them)"
               disabled={isSubmitting}
             />
+            <label htmlFor="profile-unlisted">
+              <input
+                id="profile-unlisted"
+                type="checkbox"
+                checked={unlisted}
+                onChange={(e) => setUnlisted(e.target.checked)}
+                disabled={isSubmitting}
+              /> Hide me from search
+            </label>
             <div className="form-actions">
               <button type="submit" disabled={isSubmitting}>
                 {isSubmitting ? 'Saving...' : 'Save Changes'}
@@ -440,6 +455,7 @@ function Profile({ jwt }) {
             <p><strong>Pronouns:</strong> {pronouns || 'N/A'}</p>
             <p><strong>Username:</strong> {username || 'N/A'}</p>
             <p><strong>Email:</strong> {email || 'N/A'}</p>
+            <p><strong>Shown in search:</strong> {unlisted ? 'No' : 'Yes'}</p>
             <div className="profile-actions">
               <button onClick={() => { setIsEditing(true); setSuccessMessage(''); setError(''); }} disabled={isSubmitting}>Edit Profile</button>
               <button onClick={handleDeleteAccountClick} disabled={isSubmitting} className="delete-button">Delete Account</button>
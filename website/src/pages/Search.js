@@ -6,6 +6,7 @@ import '../styles/Search.css';
 const BOOK_SUMMARY_API_VERSION = "booksummary.itsc-4155-group-project.edu.whits.io/v1alpha2"; // Updated to v1alpha2
 const AUTHOR_API_VERSION = "author.itsc-4155-group-project.edu.whits.io/v1alpha3";         // Updated to v1alpha3
 const COMMENT_API_VERSION = "comment.itsc-4155-group-project.edu.whits.io/v1alpha1"; // Remains v1alpha1
+const USER_PROFILE_API_VERSION = "userprofile.itsc-4155-group-project.edu.whits.io/v1alpha1";
 
 function Search() {
   const [query, setQuery] = useState('');
@@ -13,7 +14,10 @@ function Search() {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
   const [page, setPage] = useState(1);
-  const [hasNextPage, setHasNextPage] = useState(false);
+  // Pages are fetched by cursor; `page` only counts them for display
+  const [cursor, setCursor] = useState('');
+  const [nextCursor, setNextCursor] = useState('');
+  const [prevCursor, setPrevCursor] = useState('');
   // State for selected search domains/indices
   const [indices, setIndices] = useState(['booktitle', 'authorname', 'comments']); // Default indices
 
@@ -27,7 +31,12 @@ function Search() {
         ? [...prevIndices, value] // Add index if checked
         : prevIndices.filter(index => index !== value) // Remove index if unchecked
     );
-    setPage(1); // Reset to page 1 when indices change
+    resetPage(); // Reset to page 1 when indices change
+  };
+
+  const resetPage = () => {
+    setPage(1);
+    setCursor('');
   };
 
   useEffect(() => {
@@ -35,8 +44,9 @@ function Search() {
       // Reset results if query or indices are empty
       if (!query.trim() || indices.length === 0) {
         setResults([]);
-        setHasNextPage(false);
-        if (page !== 1) setPage(1);
+        setNextCursor('');
+        setPrevCursor('');
+        if (page !== 1) resetPage();
         // Optionally set an error if no indices are selected
         if (indices.length === 0) {
             setError("Please select at least one field to search.");
@@ -48,12 +58,12 @@ function Search() {
       setLoading(true);
       setError(null);
       try {
-        const offset = (page - 1) * limit;
         // Use the dynamic indices state joined by a comma
         const domainsToSearch = indices.join(',');
         const response = await fetch(
           `/api/search?q=${encodeURIComponent(query)}` +
-          `&d=${domainsToSearch}&r=${limit}&o=${offset}` // Use dynamic domains
+          `&d=${domainsToSearch}&r=${limit}` + // Use dynamic domains
+          (cursor ? `&cursor=${encodeURIComponent(cursor)}` : '')
         );
 
         if (!response.ok) {
@@ -67,20 +77,21 @@ function Search() {
           }
           throw new Error(errorSummary);
         }
-        // Backend returns a flat array []map[string]any
+        // Backend returns { items, next, prev }, where next and prev
+        // are cursors for the pages either side, if there are any
         const data = await response.json();
 
-        // Ensure data is an array
-        const searchResults = Array.isArray(data) ? data : [];
+        // Ensure items is an array
+        const searchResults = Array.isArray(data?.items) ? data.items : [];
 
         setResults(searchResults);
-        // Determine if there might be a next page
-        setHasNextPage(searchResults.length === limit);
+        setNextCursor(data?.next || '');
+        setPrevCursor(data?.prev || '');
 
       } catch (err) {
         setError(err.message);
         setResults([]);
-        setHasNextPage(false); // No next page on error
+        setNextCursor(''); // No next page on error
         // Optionally reset page to 1 on error, or leave it
         // setPage(1);
       } finally {
@@ -95,17 +106,23 @@ function Search() {
 
     return () => clearTimeout(debounceTimeout);
     // eslint-disable-next-line react-hooks/exhaustive-deps
-  }, [query, page, indices]); // Add indices to dependency array
+  }, [query, cursor, indices]); // Add indices to dependency array
 
   const handleInputChange = (e) => {
     setQuery(e.target.value);
-    setPage(1); // Reset to page 1 on new query
+    resetPage(); // Reset to page 1 on new query
+  };
+
+  const handleNextPage = () => {
+    if (!nextCursor) return;
+    setCursor(nextCursor);
+    setPage(page + 1);
   };
 
-  const handlePageChange = (newPage) => {
-    // Basic validation
-    if (newPage < 1) return;
-    setPage(newPage);
+  const handlePrevPage = () => {
+    if (!prevCursor) return;
+    setCursor(prevCursor);
+    setPage(page - 1);
   };
 
   return (
@@ -148,6 +165,14 @@ function Search() {
                         onChange={handleIndexChange}
                     /> Comments
                 </label>
+                <label>
+                    <input
+                        type="checkbox"
+                        value="users"
+                        checked={indices.includes('users')}
+                        onChange={handleIndexChange}
+                    /> Users
+                </label>
             </div>
         </div>
         {/* Maybe add limit/results per page config later */}
@@ -210,6 +235,19 @@ function Search() {
               title = `Comment by ${result.poster?.name || result.poster?.username || 'Unknown User'}`;
               details = result.body?.substring(0, 150) + (result.body?.length > 150 ? '...' : '');
               break;
+            case USER_PROFILE_API_VERSION:
+              if (result.id) {
+                  key = result.id;
+                  linkTo = `/user/${result.id}`;
+              } else {
+                  console.warn("User result missing ID:", result);
+                  title = "Invalid User Data";
+                  details = "Missing user identifier.";
+                  break;
+              }
+              title = result.name || result.username || 'Unknown User';
+              details = `@${result.username}`;
+              break;
             default:
               title = 'Unrecognized Search Result';
               console.warn("Unrecognized search result type:", result);
@@ -232,11 +270,11 @@ function Search() {
       {/* Update pagination controls */}
       <div>
         <div className="pagination-controls"></div>
-        <button onClick={() => handlePageChange(page - 1)} disabled={page === 1 || loading}>
+        <button onClick={handlePrevPage} disabled={!prevCursor || loading}>
           Previous
         </button>
         <span>Page {page}</span>
-        <button onClick={() => handlePageChange(page + 1)} disabled={!hasNextPage || loading}>
+        <button onClick={handleNextPage} disabled={!nextCursor || loading}>
           Next
         </button>
       </div>